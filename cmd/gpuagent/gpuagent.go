@@ -23,20 +23,28 @@ import (
 	"flag"
 	"fmt"
 	"github.com/nebuly-ai/nos/internal/controllers/gpuagent"
+	"github.com/nebuly-ai/nos/pkg/agent"
 	configv1alpha1 "github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/config/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
 	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
 	"github.com/nebuly-ai/nos/pkg/resource"
 	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"time"
 )
 
@@ -65,12 +73,17 @@ func main() {
 
 	ctx := ctrl.SetupSignalHandler()
 
-	// Get node name
+	// Get node name and namespace
 	nodeName, err := util.GetEnvOrError(constant.EnvVarNodeName)
 	if err != nil {
 		setupLog.Error(err, fmt.Sprintf("missing required env variable %s", constant.EnvVarNodeName))
 		os.Exit(1)
 	}
+	namespace, err := util.GetEnvOrError(constant.EnvVarPodNamespace)
+	if err != nil {
+		setupLog.Error(err, fmt.Sprintf("missing required env variable %s", constant.EnvVarPodNamespace))
+		os.Exit(1)
+	}
 
 	// Load config and setup controller manager
 	options := ctrl.Options{
@@ -86,6 +99,26 @@ func main() {
 	}
 	reportingSeconds := agentConfig.ReportConfigIntervalSeconds * time.Second
 	setupLog.Info("loaded config", "reportingInterval", reportingSeconds)
+	devicePluginCM := types.NamespacedName{
+		Name:      agentConfig.DevicePluginConfigMap.Name,
+		Namespace: agentConfig.DevicePluginConfigMap.Namespace,
+	}
+	if devicePluginCM.Name == "" {
+		devicePluginCM.Name = constant.DefaultDevicePluginCMName
+		setupLog.Info(
+			"device plugin CM name is not set, using default value",
+			"name",
+			constant.DefaultDevicePluginCMName,
+		)
+	}
+	if devicePluginCM.Namespace == "" {
+		devicePluginCM.Namespace = constant.DefaultDevicePluginCMNamespace
+		setupLog.Info(
+			"device plugin CM namespace is not set, using default value",
+			"namespace",
+			constant.DefaultDevicePluginCMNamespace,
+		)
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -102,22 +135,77 @@ func main() {
 	nvmlClient := nvml.NewClient(ctrl.Log.WithName("NvmlClient"))
 	gpuClient := slicing.NewClient(resourceClient, nvmlClient)
 
-	// Check if any of the GPUs of the node has MIG mode enabled
+	// Check if any of the GPUs of the node has MIG mode enabled. MIG GPUs are allowed
+	// only on nodes with hybrid partitioning, in which they are managed by the mig-agent.
+	var node v1.Node
+	if err = mgr.GetAPIReader().Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+		setupLog.Error(err, "unable to fetch node", "node", nodeName)
+		os.Exit(1)
+	}
 	anyMigEnabledGpu, err := AnyMigEnabledGpu(nvmlClient)
 	if err != nil {
 		setupLog.Error(err, "unable to fetch GPUs")
 		os.Exit(1)
 	}
-	if anyMigEnabledGpu {
+	if anyMigEnabledGpu && !gpu.IsHybridPartitioningEnabled(node) {
 		setupLog.Error(errors.New("cannot run on a node with MIG enabled GPUs"), "exiting")
 		os.Exit(1)
 	}
 
-	// Setup Reporter
+	// Setup agent Lease
+	gpuModels, err := nvmlClient.GetGpuModels()
+	if err != nil {
+		setupLog.Error(err, "unable to get GPU models")
+		os.Exit(1)
+	}
+	kubeClient := kubernetes.NewForConfigOrDie(ctrl.GetConfigOrDie())
+	leaseRenewer := agent.NewLeaseRenewer(
+		kubeClient,
+		namespace,
+		nodeName,
+		agent.Info{
+			Name:         agent.GpuAgentName,
+			Version:      agent.Version,
+			Capabilities: []gpu.PartitioningKind{gpu.PartitioningKindMps, gpu.PartitioningKindTimeSlicing},
+			GpuModels:    gpuModels,
+		},
+		constant.DefaultAgentLeaseDuration,
+	)
+	if err = mgr.Add(leaseRenewer); err != nil {
+		setupLog.Error(err, "unable to create agent Lease renewer")
+		os.Exit(1)
+	}
+
+	// Setup metrics of the GPU slices
+	metrics.Registry.MustRegister(agent.NewSliceMetricsCollector(
+		nodeName,
+		gpuClient.GetDevices,
+		gpuagent.ExtractProfileNameStr,
+		resourceClient,
+		nvmlClient,
+	))
+
+	// Setup device watcher
+	deviceWatcher := resource.NewWatcher(
+		kubeClient,
+		nodeName,
+		pluginapi.DevicePluginPath,
+		constant.DefaultDeviceWatcherDebounce,
+	)
+	if err = mgr.Add(deviceWatcher); err != nil {
+		setupLog.Error(err, "unable to create device watcher")
+		os.Exit(1)
+	}
+
+	// Setup Reporter. The device plugin ConfigMap is read directly from the API server,
+	// so that the agent does not cache all the ConfigMaps of the cluster.
 	reporter := gpuagent.NewReporter(
 		mgr.GetClient(),
+		mgr.GetAPIReader(),
 		gpuClient,
+		devicePluginCM,
 		reportingSeconds,
+		deviceWatcher,
 	)
 	if err = reporter.SetupWithManager(mgr, "reporter", nodeName); err != nil {
 		setupLog.Error(err, "unable to create Reporter")
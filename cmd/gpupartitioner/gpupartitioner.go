@@ -21,9 +21,12 @@ import (
 	"flag"
 	"fmt"
 	"github.com/nebuly-ai/nos/internal/controllers/gpupartitioner"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/hybrid"
 	"github.com/nebuly-ai/nos/internal/partitioning/mig"
 	"github.com/nebuly-ai/nos/internal/partitioning/mps"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/internal/partitioning/timeslicing"
 	configv1alpha1 "github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/config/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/api/scheduler"
@@ -33,11 +36,11 @@ import (
 	"github.com/nebuly-ai/nos/pkg/scheduler/plugins/capacityscheduling"
 	testutil "github.com/nebuly-ai/nos/pkg/test/util"
 	"github.com/nebuly-ai/nos/pkg/util"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -49,6 +52,7 @@ import (
 	schedulerruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -99,6 +103,13 @@ func main() {
 		setupLog.Error(err, "config is invalid")
 		os.Exit(1)
 	}
+	if config.DevicePluginDelaySeconds != 0 {
+		setupLog.Info(
+			"devicePluginDelaySeconds is deprecated and ignored, since plans are confirmed by the gpu-agent",
+			"devicePluginDelaySeconds",
+			config.DevicePluginDelaySeconds,
+		)
+	}
 	devicePluginCM := types.NamespacedName{
 		Name:      config.DevicePluginConfigMap.Name,
 		Namespace: config.DevicePluginConfigMap.Namespace,
@@ -122,7 +133,7 @@ func main() {
 
 	// Setup known MIG geometries
 	if config.KnownMigGeometriesFile != "" {
-		knownGeometries, err := loadKnownMigGeometriesFromFile(config.KnownMigGeometriesFile)
+		knownGeometries, err := gpumig.LoadAllowedMigGeometriesListFromFile(config.KnownMigGeometriesFile)
 		if err != nil {
 			setupLog.Error(err, "unable to load known MIG geometries")
 			os.Exit(1)
@@ -134,6 +145,18 @@ func main() {
 		setupLog.Info("using known MIG geometries loaded from file", "geometries", knownGeometries)
 	}
 
+	// Cache only the Leases of the agents, which run in the same namespace of the gpu-partitioner
+	agentLeaseSelector, err := gpupartitioner.AgentLeaseSelector(os.Getenv(constant.EnvVarPodNamespace))
+	if err != nil {
+		setupLog.Error(err, "unable to create agent Lease selector")
+		os.Exit(1)
+	}
+	options.NewCache = cache.BuilderWithOptions(cache.Options{
+		SelectorsByObject: cache.SelectorsByObject{
+			&coordinationv1.Lease{}: agentLeaseSelector,
+		},
+	})
+
 	// Setup controller manager
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
@@ -155,8 +178,11 @@ func main() {
 	nodeController := gpupartitioner.NewNodeController(
 		mgr.GetClient(),
 		mgr.GetScheme(),
+		mgr.GetEventRecorderFor(constant.ClusterStateNodeControllerName),
 		mig.NewNodeInitializer(mgr.GetClient()),
 		clusterState,
+		config.PlanReportTimeoutSeconds*time.Second,
+		config.RequireAgentLeases,
 	)
 	if err = nodeController.SetupWithManager(mgr, constant.ClusterStateNodeControllerName); err != nil {
 		setupLog.Error(
@@ -182,6 +208,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup partitioning plan status controller
+	planStatusController := gpupartitioner.NewPlanStatusController(mgr.GetClient())
+	if err = planStatusController.SetupWithManager(mgr, constant.PartitioningPlanControllerName); err != nil {
+		setupLog.Error(
+			err,
+			"unable to create controller",
+			"controller",
+			constant.PartitioningPlanControllerName,
+		)
+		os.Exit(1)
+	}
+
 	// Init scheduler
 	k8sClient := kubernetes.NewForConfigOrDie(ctrl.GetConfigOrDie())
 	schedulerFramework, err := newSchedulerFramework(ctx, config, k8sClient)
@@ -211,6 +249,27 @@ func main() {
 		}
 	}()
 
+	if config.DryRun {
+		setupLog.Info("dry-run mode enabled, partitioning plans will not be applied")
+	}
+	plannerOpts := core.PlannerOptions{
+		Optimizing:          config.Planner == configv1alpha1.PlannerOptimizing,
+		BeamWidth:           config.PlannerBeamWidth,
+		MaxSearchExpansions: config.PlannerMaxSearchExpansions,
+		NodeCooldown:        config.NodeCooldownSeconds * time.Second,
+	}
+	setupLog.Info(
+		"partitioning planner",
+		"optimizing",
+		plannerOpts.Optimizing,
+		"beamWidth",
+		plannerOpts.BeamWidth,
+		"maxSearchExpansions",
+		plannerOpts.MaxSearchExpansions,
+		"nodeCooldown",
+		plannerOpts.NodeCooldown,
+	)
+
 	// Setup MIG controller
 	migController := mig.NewController(
 		mgr.GetScheme(),
@@ -218,6 +277,8 @@ func main() {
 		podBatcher,
 		clusterState,
 		schedulerFramework,
+		plannerOpts,
+		config.DryRun,
 	)
 	if err = migController.SetupWithManager(mgr, constant.MigPartitionerControllerName); err != nil {
 		setupLog.Error(
@@ -233,11 +294,13 @@ func main() {
 	mpsSlicingController := mps.NewController(
 		mgr.GetScheme(),
 		mgr.GetClient(),
+		mgr.GetAPIReader(),
 		podBatcher,
 		clusterState,
 		schedulerFramework,
 		devicePluginCM,
-		config.DevicePluginDelaySeconds*time.Second,
+		plannerOpts,
+		config.DryRun,
 	)
 	if err = mpsSlicingController.SetupWithManager(mgr, constant.MpsPartitionerControllerName); err != nil {
 		setupLog.Error(
@@ -249,6 +312,125 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup hybrid controller
+	hybridController := hybrid.NewController(
+		mgr.GetScheme(),
+		mgr.GetClient(),
+		mgr.GetAPIReader(),
+		podBatcher,
+		clusterState,
+		schedulerFramework,
+		devicePluginCM,
+		plannerOpts,
+		config.DryRun,
+	)
+	if err = hybridController.SetupWithManager(mgr, constant.HybridPartitionerControllerName); err != nil {
+		setupLog.Error(
+			err,
+			"unable to create controller",
+			"controller",
+			constant.HybridPartitionerControllerName,
+		)
+		os.Exit(1)
+	}
+
+	// Setup time-slicing controller
+	timeSlicingController := timeslicing.NewController(
+		mgr.GetScheme(),
+		mgr.GetClient(),
+		mgr.GetAPIReader(),
+		podBatcher,
+		clusterState,
+		schedulerFramework,
+		devicePluginCM,
+		plannerOpts,
+		config.DryRun,
+	)
+	if err = timeSlicingController.SetupWithManager(mgr, constant.TimeSlicingPartitionerControllerName); err != nil {
+		setupLog.Error(
+			err,
+			"unable to create controller",
+			"controller",
+			constant.TimeSlicingPartitionerControllerName,
+		)
+		os.Exit(1)
+	}
+
+	// Setup compaction controllers
+	if config.CompactionIdleSeconds > 0 {
+		compactionIdleThreshold := config.CompactionIdleSeconds * time.Second
+		migCompactionController := mig.NewCompactionController(
+			mgr.GetClient(),
+			clusterState,
+			compactionIdleThreshold,
+			plannerOpts.NodeCooldown,
+			config.DryRun,
+		)
+		if err = migCompactionController.SetupWithManager(mgr, constant.MigCompactionControllerName); err != nil {
+			setupLog.Error(
+				err,
+				"unable to create controller",
+				"controller",
+				constant.MigCompactionControllerName,
+			)
+			os.Exit(1)
+		}
+		mpsCompactionController := mps.NewCompactionController(
+			mgr.GetClient(),
+			mgr.GetAPIReader(),
+			clusterState,
+			devicePluginCM,
+			compactionIdleThreshold,
+			plannerOpts.NodeCooldown,
+			config.DryRun,
+		)
+		if err = mpsCompactionController.SetupWithManager(mgr, constant.MpsCompactionControllerName); err != nil {
+			setupLog.Error(
+				err,
+				"unable to create controller",
+				"controller",
+				constant.MpsCompactionControllerName,
+			)
+			os.Exit(1)
+		}
+		hybridCompactionController := hybrid.NewCompactionController(
+			mgr.GetClient(),
+			mgr.GetAPIReader(),
+			clusterState,
+			devicePluginCM,
+			compactionIdleThreshold,
+			plannerOpts.NodeCooldown,
+			config.DryRun,
+		)
+		if err = hybridCompactionController.SetupWithManager(mgr, constant.HybridCompactionControllerName); err != nil {
+			setupLog.Error(
+				err,
+				"unable to create controller",
+				"controller",
+				constant.HybridCompactionControllerName,
+			)
+			os.Exit(1)
+		}
+		timeSlicingCompactionController := timeslicing.NewCompactionController(
+			mgr.GetClient(),
+			mgr.GetAPIReader(),
+			clusterState,
+			devicePluginCM,
+			compactionIdleThreshold,
+			plannerOpts.NodeCooldown,
+			config.DryRun,
+		)
+		if err = timeSlicingCompactionController.SetupWithManager(mgr, constant.TimeSlicingCompactionControllerName); err != nil {
+			setupLog.Error(
+				err,
+				"unable to create controller",
+				"controller",
+				constant.TimeSlicingCompactionControllerName,
+			)
+			os.Exit(1)
+		}
+	}
+
 	// Setup health checks
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -288,6 +470,19 @@ func setupIndexer(ctx context.Context, mgr ctrl.Manager) error {
 		return err
 	}
 
+	// Index PartitioningPlans' nodes
+	err = mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.PartitioningPlan{}, constant.PartitioningPlanNodeNameKey, func(rawObj client.Object) []string {
+		p := rawObj.(*v1alpha1.PartitioningPlan)
+		res := make([]string, 0, len(p.Spec.Nodes))
+		for _, n := range p.Spec.Nodes {
+			res = append(res, n.Name)
+		}
+		return res
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -366,15 +561,3 @@ func decodeSchedulerConfig(data []byte) (*schedulerconfig.KubeSchedulerConfigura
 	}
 	return nil, fmt.Errorf("couldn't decode as KubeSchedulerConfiguration, got %s: ", gvk)
 }
-
-func loadKnownMigGeometriesFromFile(file string) (gpumig.AllowedMigGeometriesList, error) {
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
-	}
-	var allowedGeometries = make(gpumig.AllowedMigGeometriesList, 0)
-	if err = yaml.Unmarshal(data, &allowedGeometries); err != nil {
-		return nil, err
-	}
-	return allowedGeometries, nil
-}
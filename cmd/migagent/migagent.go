@@ -23,9 +23,11 @@ import (
 	"flag"
 	"fmt"
 	"github.com/nebuly-ai/nos/internal/controllers/migagent"
+	"github.com/nebuly-ai/nos/pkg/agent"
 	configv1alpha1 "github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/config/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
 	"github.com/nebuly-ai/nos/pkg/resource"
@@ -33,15 +35,21 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	pdrv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"time"
 )
 
+const defaultDrainTimeout = 120 * time.Second
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -67,12 +75,17 @@ func main() {
 
 	ctx := ctrl.SetupSignalHandler()
 
-	// Get node name
+	// Get node name and namespace
 	nodeName, err := util.GetEnvOrError(constant.EnvVarNodeName)
 	if err != nil {
 		setupLog.Error(err, fmt.Sprintf("missing required env variable %s", constant.EnvVarNodeName))
 		os.Exit(1)
 	}
+	namespace, err := util.GetEnvOrError(constant.EnvVarPodNamespace)
+	if err != nil {
+		setupLog.Error(err, fmt.Sprintf("missing required env variable %s", constant.EnvVarPodNamespace))
+		os.Exit(1)
+	}
 
 	// Load config and setup controller manager
 	options := ctrl.Options{
@@ -106,26 +119,86 @@ func main() {
 	var sharedState = migagent.NewSharedState()
 
 	// Init MIG client
-	lister, err := resource.NewPodResourcesListerClient(
-		constant.DefaultPodResourcesTimeout,
-		constant.DefaultPodResourcesMaxMsgSize,
-	)
+	kubeClient := kubernetes.NewForConfigOrDie(ctrl.GetConfigOrDie())
+	var nvmlClient nvml.Client
+	var lister pdrv1.PodResourcesListerClient
+	devicePluginDir := pluginapi.DevicePluginPath
+	if migAgentConfig.SimulateGpus {
+		setupLog.Info("Initializing simulated NVML client", "GPUs", migAgentConfig.SimulatedGpus)
+		nvmlClient, lister, err = initSimulation(ctx, kubeClient, nodeName, migAgentConfig.SimulatedGpus)
+		if err != nil {
+			setupLog.Error(err, "unable to initialize GPU simulation")
+			os.Exit(1)
+		}
+		// there are no device plugins to watch
+		devicePluginDir = ""
+	} else {
+		lister, err = resource.NewPodResourcesListerClient(
+			constant.DefaultPodResourcesTimeout,
+			constant.DefaultPodResourcesMaxMsgSize,
+		)
+		setupLog.Info("Initializing NVML client")
+		nvmlClient = nvml.NewClient(ctrl.Log.WithName("NvmlClient"))
+	}
 	resourceClient := resource.NewClient(lister)
-	setupLog.Info("Initializing NVML client")
-	nvmlClient := nvml.NewClient(ctrl.Log.WithName("NvmlClient"))
 	migClient := mig.NewClient(resourceClient, nvmlClient)
 
-	if err = initAgent(ctx, nvmlClient, migClient); err != nil {
+	if err = initAgent(ctx, migClient); err != nil {
 		setupLog.Error(err, "unable to initialize agent")
 		os.Exit(1)
 	}
 
+	// Setup agent Lease
+	gpuModels, err := nvmlClient.GetGpuModels()
+	if err != nil {
+		setupLog.Error(err, "unable to get GPU models")
+		os.Exit(1)
+	}
+	leaseRenewer := agent.NewLeaseRenewer(
+		kubeClient,
+		namespace,
+		nodeName,
+		agent.Info{
+			Name:         agent.MigAgentName,
+			Version:      agent.Version,
+			Capabilities: []gpu.PartitioningKind{gpu.PartitioningKindMig},
+			GpuModels:    gpuModels,
+		},
+		constant.DefaultAgentLeaseDuration,
+	)
+	if err = mgr.Add(leaseRenewer); err != nil {
+		setupLog.Error(err, "unable to create agent Lease renewer")
+		os.Exit(1)
+	}
+
+	// Setup metrics of the GPU slices
+	metrics.Registry.MustRegister(agent.NewSliceMetricsCollector(
+		nodeName,
+		migClient.GetMigDevices,
+		mig.ExtractProfileNameStr,
+		resourceClient,
+		nvmlClient,
+	))
+
+	// Setup device watcher
+	deviceWatcher := resource.NewWatcher(
+		kubeClient,
+		nodeName,
+		devicePluginDir,
+		constant.DefaultDeviceWatcherDebounce,
+	)
+	if err = mgr.Add(deviceWatcher); err != nil {
+		setupLog.Error(err, "unable to create device watcher")
+		os.Exit(1)
+	}
+
 	// Setup MIG Reporter
 	migReporter := migagent.NewReporter(
 		mgr.GetClient(),
 		migClient,
 		sharedState,
 		migAgentConfig.ReportConfigIntervalSeconds*time.Second,
+		deviceWatcher,
 	)
 	if err = migReporter.SetupWithManager(mgr, "reporter", nodeName); err != nil {
 		setupLog.Error(err, "unable to create MIG Reporter")
@@ -133,17 +206,48 @@ func main() {
 	}
 
 	// Setup MIG Actuator
+	var drainer *migagent.Drainer
+	if migAgentConfig.DrainUsedDevices {
+		drainTimeout := defaultDrainTimeout
+		if migAgentConfig.DrainTimeoutSeconds > 0 {
+			drainTimeout = migAgentConfig.DrainTimeoutSeconds * time.Second
+		}
+		setupLog.Info("draining of used MIG devices enabled", "timeout", drainTimeout)
+		drainer = migagent.NewDrainer(
+			kubeClient,
+			resourceClient,
+			mgr.GetEventRecorderFor("mig-agent"),
+			drainTimeout,
+		)
+	}
 	migActuator := migagent.NewActuator(
 		mgr.GetClient(),
 		migClient,
 		sharedState,
 		nodeName,
+		drainer,
 	)
 	if err = migActuator.SetupWithManager(mgr, "actuator"); err != nil {
 		setupLog.Error(err, "unable to create MIG Actuator")
 		os.Exit(1)
 	}
 
+	// Setup MIG mode manager
+	migModeManager := migagent.NewMigModeManager(
+		mgr.GetClient(),
+		nvmlClient,
+		migClient,
+		resourceClient,
+		sharedState,
+		nodeName,
+		mgr.GetEventRecorderFor("mig-agent"),
+		drainer,
+	)
+	if err = migModeManager.SetupWithManager(mgr, "mig-mode-manager"); err != nil {
+		setupLog.Error(err, "unable to create MIG mode manager")
+		os.Exit(1)
+	}
+
 	// Add health check endpoints to manager
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -162,12 +266,36 @@ func main() {
 	}
 }
 
-func initAgent(ctx context.Context, nvmlClient nvml.Client, migClient mig.Client) error {
-	setupLog.Info("Checking MIG-enabled GPUs")
-	if err := checkAtLeastOneMigGpu(nvmlClient); err != nil {
-		return err
+// initSimulation returns a simulated NVML client simulating the GPUs provided as argument, and a client
+// of a simulated Kubelet PodResources server exposing their devices, which is served until the context is cancelled
+func initSimulation(
+	ctx context.Context,
+	kubeClient kubernetes.Interface,
+	nodeName string,
+	gpus []configv1alpha1.SimulatedGpu,
+) (nvml.Client, pdrv1.PodResourcesListerClient, error) {
+	simulatedGpus := make([]nvml.SimulatedGpu, len(gpus))
+	for i, g := range gpus {
+		simulatedGpus[i] = nvml.SimulatedGpu{Model: gpu.Model(g.Model), MigEnabled: g.MigEnabled}
+	}
+	nvmlClient, err := nvml.NewSimulatedClient(simulatedGpus)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	server := resource.NewSimulatedPodResourcesServer(nvmlClient, kubeClient, nodeName)
+	if err = server.Start(ctx, resource.SimulatedPodResourcesPath); err != nil {
+		return nil, nil, err
+	}
+	lister, err := resource.NewPodResourcesListerClientAt(
+		resource.SimulatedPodResourcesPath,
+		constant.DefaultPodResourcesTimeout,
+		constant.DefaultPodResourcesMaxMsgSize,
+	)
+	return nvmlClient, lister, err
+}
+
+func initAgent(ctx context.Context, migClient mig.Client) error {
 	setupLog.Info("Cleaning up unused MIG resources")
 	if err := cleanupUnusedMigResources(ctx, migClient); err != nil {
 		return err
@@ -176,17 +304,6 @@ func initAgent(ctx context.Context, nvmlClient nvml.Client, migClient mig.Client
 	return nil
 }
 
-func checkAtLeastOneMigGpu(nvmlClient nvml.Client) error {
-	migGpus, err := nvmlClient.GetMigEnabledGPUs()
-	if err != nil {
-		return fmt.Errorf("unable to get MIG enabled GPUs: %s", err)
-	}
-	if len(migGpus) == 0 {
-		return fmt.Errorf("at least one MIG-enabled GPU is required, found 0")
-	}
-	return nil
-}
-
 // cleanupUnusedMigResources deletes all the GPU Instances and Compute Instances of the MIG Profiles that are not in
 // use, for all the MIG-enabled GPUs of the current node.
 func cleanupUnusedMigResources(ctx context.Context, migClient mig.Client) error {
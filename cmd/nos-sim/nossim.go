@@ -0,0 +1,218 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/simulator"
+	configv1alpha1 "github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/config/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	gpumig "github.com/nebuly-ai/nos/pkg/gpu/mig"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+const (
+	outputYaml = "yaml"
+	outputJson = "json"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+func main() {
+	// Setup CLI args
+	var configFile string
+	var kind string
+	var knownMigGeometriesFile string
+	var schedulerConfigFile string
+	var allPending bool
+	var output string
+	var now string
+	flag.StringVar(&configFile, "config", "",
+		"The GPU Partitioner config file from which the simulator loads the known MIG geometries, "+
+			"the scheduler config and the planner options. Omit this flag to use the default values. "+
+			"Command-line flags override configuration from this file.")
+	flag.StringVar(&kind, "kind", gpu.PartitioningKindMig.String(),
+		"The kind of partitioning to simulate (mig, mps, hybrid or time-slicing).")
+	flag.StringVar(&knownMigGeometriesFile, "known-mig-geometries", "",
+		"The file containing the known MIG geometries.")
+	flag.StringVar(&schedulerConfigFile, "scheduler-config", "",
+		"The KubeSchedulerConfiguration file whose first profile is used for placing the pods.")
+	flag.BoolVar(&allPending, "all-pending", false,
+		"Consider as candidates all the pending pods not bound to any node, "+
+			"and not only the ones marked as unschedulable by the scheduler.")
+	flag.StringVar(&output, "output", outputYaml, "The output format (yaml or json).")
+	flag.StringVar(&now, "now", "",
+		"The time of the simulation in RFC 3339 format (e.g. 2023-01-02T15:04:05Z), used for checking the node "+
+			"cooldown and for generating the plan IDs. Omit this flag to use the current time.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] FILE...\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Simulates the GPU partitioning of the nodes and pods defined in the YAML files provided as argument.")
+		fmt.Fprintln(flag.CommandLine.Output(), "Pods bound to a node run on it, pending pods are the candidates of the partitioning plan.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts), zap.WriteTo(os.Stderr)))
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if output != outputYaml && output != outputJson {
+		setupLog.Error(fmt.Errorf("output must be either %q or %q", outputYaml, outputJson), "invalid output format")
+		os.Exit(2)
+	}
+	var clock core.Clock
+	if now != "" {
+		simulationTime, err := time.Parse(time.RFC3339, now)
+		if err != nil {
+			setupLog.Error(err, "invalid simulation time")
+			os.Exit(2)
+		}
+		clock = func() time.Time { return simulationTime }
+	}
+
+	// Load config
+	config := configv1alpha1.GpuPartitionerConfig{}
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+		if err = yaml.Unmarshal(data, &config); err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+		// Relative paths are resolved against the directory of the config file, since the simulator
+		// is usually not run from the working directory of the gpu-partitioner
+		config.KnownMigGeometriesFile = resolvePath(configFile, config.KnownMigGeometriesFile)
+		config.SchedulerConfigFile = resolvePath(configFile, config.SchedulerConfigFile)
+	}
+	if knownMigGeometriesFile != "" {
+		config.KnownMigGeometriesFile = knownMigGeometriesFile
+	}
+	if schedulerConfigFile != "" {
+		config.SchedulerConfigFile = schedulerConfigFile
+	}
+
+	// Setup known MIG geometries
+	if config.KnownMigGeometriesFile != "" {
+		knownGeometries, err := gpumig.LoadAllowedMigGeometriesListFromFile(config.KnownMigGeometriesFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load known MIG geometries")
+			os.Exit(1)
+		}
+		if err = gpumig.SetKnownGeometries(knownGeometries.GroupByModel()); err != nil {
+			setupLog.Error(err, "unable to set known MIG geometries")
+			os.Exit(1)
+		}
+		setupLog.V(1).Info("using known MIG geometries loaded from file", "geometries", knownGeometries)
+	}
+
+	// Load nodes and pods
+	nodes, pods, err := simulator.LoadObjectsFromFiles(flag.Args()...)
+	if err != nil {
+		setupLog.Error(err, "unable to load nodes and pods")
+		os.Exit(1)
+	}
+	setupLog.V(1).Info("loaded cluster state", "nodes", len(nodes), "pods", len(pods))
+
+	// Setup scheduler framework
+	ctx := log.IntoContext(context.Background(), ctrl.Log.WithName("simulator"))
+	profile, err := getSchedulerProfile(config)
+	if err != nil {
+		setupLog.Error(err, "unable to load scheduler profile")
+		os.Exit(1)
+	}
+	schedulerFramework, err := simulator.NewSchedulerFramework(ctx, profile, nodes, pods)
+	if err != nil {
+		setupLog.Error(err, "unable to create scheduler framework")
+		os.Exit(1)
+	}
+
+	// Run simulation
+	sim, err := simulator.New(schedulerFramework, simulator.Options{
+		Kind: gpu.PartitioningKind(kind),
+		PlannerOptions: core.PlannerOptions{
+			Optimizing:          config.Planner == configv1alpha1.PlannerOptimizing,
+			BeamWidth:           config.PlannerBeamWidth,
+			MaxSearchExpansions: config.PlannerMaxSearchExpansions,
+			NodeCooldown:        config.NodeCooldownSeconds * time.Second,
+			Clock:               clock,
+		},
+		AllPending: allPending,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create simulator")
+		os.Exit(1)
+	}
+	res, err := sim.Run(ctx, nodes, pods)
+	if err != nil {
+		setupLog.Error(err, "simulation failed")
+		os.Exit(1)
+	}
+
+	// Print result
+	var out []byte
+	if output == outputJson {
+		out, err = json.MarshalIndent(res, "", "  ")
+	} else {
+		out, err = yaml.Marshal(res)
+	}
+	if err != nil {
+		setupLog.Error(err, "unable to encode simulation result")
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+	setupLog.Info(
+		"simulation completed",
+		"candidatePods",
+		len(res.Pods),
+		"placedPods",
+		res.PlacedPods(),
+		"changedNodes",
+		len(res.ChangedNodes),
+	)
+}
+
+func getSchedulerProfile(config configv1alpha1.GpuPartitionerConfig) (schedulerconfig.KubeSchedulerProfile, error) {
+	if config.SchedulerConfigFile == "" {
+		return simulator.DefaultSchedulerProfile()
+	}
+	return simulator.LoadSchedulerProfile(config.SchedulerConfigFile)
+}
+
+func resolvePath(configFile string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
+}
@@ -18,34 +18,63 @@ package gpuagent
 
 import (
 	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
 	"github.com/nebuly-ai/nos/pkg/util/predicate"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
+	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
 	"time"
 )
 
 type Reporter struct {
 	client.Client
+	cmReader        client.Reader
 	gpuClient       gpu.Client
+	devicePluginCM  types.NamespacedName
 	refreshInterval time.Duration
+	deviceWatcher   *resource.Watcher
 }
 
-func NewReporter(k8sClient client.Client, gpuClient gpu.Client, refreshInterval time.Duration) Reporter {
+// NewReporter returns a Reporter that reports the status of the shared GPUs of the node, and that reports
+// the partitioning plans applied to the node once the device plugin exposes the devices defined by the config
+// of the plan. The device plugin config is read through the cmReader from the ConfigMap provided as argument,
+// unless the node overrides it through the label v1alpha1.LabelDevicePluginConfigMap.
+func NewReporter(
+	k8sClient client.Client,
+	cmReader client.Reader,
+	gpuClient gpu.Client,
+	devicePluginCM types.NamespacedName,
+	refreshInterval time.Duration,
+	deviceWatcher *resource.Watcher,
+) Reporter {
 	return Reporter{
 		Client:          k8sClient,
+		cmReader:        cmReader,
 		gpuClient:       gpuClient,
+		devicePluginCM:  devicePluginCM,
 		refreshInterval: refreshInterval,
+		deviceWatcher:   deviceWatcher,
 	}
 }
 
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
 
 func (r *Reporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := klog.FromContext(ctx)
@@ -55,7 +84,10 @@ func (r *Reporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 	if err := r.Client.Get(ctx, client.ObjectKey{Name: req.Name, Namespace: req.Namespace}, &instance); err != nil {
 		return ctrl.Result{}, err
 	}
+	// Consider only slicing and time-slicing annotations, since on nodes with hybrid
+	// partitioning the status of the MIG GPUs is reported by the mig-agent
 	lastStatusAnnotations, _ := gpu.ParseNodeAnnotations(instance)
+	lastStatusAnnotations = lastStatusAnnotations.Filter(isSharedGpuStatusAnnotation)
 
 	// Fetch GPUs
 	devices, err := r.gpuClient.GetDevices(ctx)
@@ -65,9 +97,17 @@ func (r *Reporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 	}
 
 	// Check if status changed
-	currentStatusAnnotations := devices.AsStatusAnnotation(slicing.ExtractProfileNameStr)
+	currentStatusAnnotations := devices.AsStatusAnnotation(extractStatusProfileNameStr)
 	logger.Info("computed annotations", "current", currentStatusAnnotations, "last", lastStatusAnnotations, "devices", devices)
-	if currentStatusAnnotations.Equal(lastStatusAnnotations) {
+
+	// Check if the device plugin loaded the config of a plan that has not been reported yet
+	planId, reportPlan, planErr := r.getPlanToReport(ctx, instance, devices)
+	if planErr != nil {
+		logger.Error(planErr, "unable to check if the device plugin loaded the config of the partitioning plan")
+		return ctrl.Result{}, planErr
+	}
+
+	if currentStatusAnnotations.Equal(lastStatusAnnotations) && !reportPlan {
 		logger.Info("current status is equal to last reported status, nothing to do")
 		return ctrl.Result{RequeueAfter: r.refreshInterval}, nil
 	}
@@ -78,14 +118,15 @@ func (r *Reporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 	if updated.Annotations == nil {
 		updated.Annotations = make(map[string]string)
 	}
-	for k := range updated.Annotations {
-		if strings.HasPrefix(k, v1alpha1.AnnotationGpuStatusPrefix) {
-			delete(updated.Annotations, k)
-		}
+	for _, a := range lastStatusAnnotations {
+		delete(updated.Annotations, a.String())
 	}
 	for _, a := range currentStatusAnnotations {
 		updated.Annotations[a.String()] = a.GetValue()
 	}
+	if reportPlan {
+		updated.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] = planId
+	}
 	if err := r.Client.Patch(ctx, updated, client.MergeFrom(&instance)); err != nil {
 		logger.Error(err, "unable to update node status annotations", "annotations", updated.Annotations)
 		return ctrl.Result{}, err
@@ -95,8 +136,103 @@ func (r *Reporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 	return ctrl.Result{RequeueAfter: r.refreshInterval}, nil
 }
 
+// getPlanToReport returns the ID of the partitioning plan applied to the node and true if the plan has to be
+// reported, which happens when the plan has not been reported yet and the device plugin exposes exactly
+// the GPU replicas defined by the config of the plan.
+//
+// On nodes with hybrid partitioning the plan is reported only after the mig-agent has reported that
+// the MIG geometry of the plan has been applied.
+func (r *Reporter) getPlanToReport(ctx context.Context, node v1.Node, devices gpu.DeviceList) (string, bool, error) {
+	logger := klog.FromContext(ctx)
+
+	planId := node.Annotations[v1alpha1.AnnotationPartitioningPlan]
+	if planId == "" || planId == node.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] {
+		return "", false, nil
+	}
+	if gpu.IsHybridPartitioningEnabled(node) && node.Annotations[v1alpha1.AnnotationReportedMigPartitioningPlan] != planId {
+		logger.V(1).Info("mig-agent has not applied the MIG geometry of the plan yet", "plan", planId)
+		return "", false, nil
+	}
+
+	// The node must be labeled with the config of the plan, otherwise the device plugin can't load it
+	configKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId)
+	if node.Labels[constant.LabelNvidiaDevicePluginConfig] != configKey {
+		logger.V(1).Info("node is not labeled with the device plugin config of the plan yet", "plan", planId)
+		return "", false, nil
+	}
+
+	// Fetch the config of the plan
+	var cm v1.ConfigMap
+	devicePluginCM := mps.GetDevicePluginCM(node, r.devicePluginCM)
+	if err := r.cmReader.Get(ctx, devicePluginCM, &cm); err != nil {
+		return "", false, err
+	}
+	configYaml, ok := cm.Data[configKey]
+	if !ok {
+		logger.V(1).Info("device plugin config of the plan not found", "plan", planId, "configMap", devicePluginCM)
+		return "", false, nil
+	}
+	var pluginConfig mps.PluginConfig
+	if err := yaml.Unmarshal([]byte(configYaml), &pluginConfig); err != nil {
+		return "", false, fmt.Errorf("unable to unmarshal device plugin config %s: %v", configKey, err)
+	}
+	expected, err := pluginConfig.GetReplicas()
+	if err != nil {
+		return "", false, err
+	}
+
+	// Compare the replicas defined by the config with the ones exposed by the device plugin
+	if !reflect.DeepEqual(expected, getReplicas(devices)) {
+		logger.V(1).Info("device plugin has not loaded the config of the plan yet", "plan", planId)
+		return "", false, nil
+	}
+
+	return planId, true, nil
+}
+
+// getReplicas returns the number of replicas of each shared GPU resource included in the
+// devices provided as argument, grouped by the index of the GPU they belong to.
+func getReplicas(devices gpu.DeviceList) map[int]map[v1.ResourceName]int {
+	res := make(map[int]map[v1.ResourceName]int)
+	for _, d := range devices {
+		if d.ResourceName == constant.ResourceNvidiaGPU {
+			continue
+		}
+		if res[d.GpuIndex] == nil {
+			res[d.GpuIndex] = make(map[v1.ResourceName]int)
+		}
+		res[d.GpuIndex][d.ResourceName]++
+	}
+	return res
+}
+
+// ExtractProfileNameStr returns the name of the profile of the shared GPU resource provided as argument,
+// which can be either a GPU slice or a replica of a time-sliced GPU
+func ExtractProfileNameStr(r v1.ResourceName) (string, error) {
+	if timeslicing.IsGpuReplica(r) {
+		return timeslicing.ExtractProfileNameStr(r)
+	}
+	return slicing.ExtractProfileNameStr(r)
+}
+
+// extractStatusProfileNameStr returns the name of the profile with which the resource provided as argument
+// is reported in the status annotations of the node. Besides shared GPU resources, it reports full GPUs too,
+// so that the GPU partitioner knows which GPUs are in use and never replicates them.
+func extractStatusProfileNameStr(r v1.ResourceName) (string, error) {
+	if r == constant.ResourceNvidiaGPU {
+		return timeslicing.ProfileFull.String(), nil
+	}
+	return ExtractProfileNameStr(r)
+}
+
+func isSharedGpuStatusAnnotation(a gpu.StatusAnnotation) bool {
+	return slicing.IsSlicingStatusAnnotation(a) ||
+		timeslicing.IsTimeSlicingStatusAnnotation(a) ||
+		timeslicing.IsFullGpuStatusAnnotation(a)
+}
+
 func (r *Reporter) SetupWithManager(mgr ctrl.Manager, controllerName string, nodeName string) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(
 			&v1.Node{},
 			builder.WithPredicates(
@@ -105,6 +241,9 @@ func (r *Reporter) SetupWithManager(mgr ctrl.Manager, controllerName string, nod
 				predicate.NodeResourcesChanged{},
 			),
 		).
-		Named(controllerName).
-		Complete(r)
+		Named(controllerName)
+	if r.deviceWatcher != nil {
+		b = b.Watches(&source.Channel{Source: r.deviceWatcher.Events()}, &handler.EnqueueRequestForObject{})
+	}
+	return b.Complete(r)
 }
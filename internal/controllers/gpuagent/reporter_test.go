@@ -0,0 +1,310 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpuagent_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/controllers/gpuagent"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	gpumocks "github.com/nebuly-ai/nos/pkg/test/mocks/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+	"testing"
+)
+
+func TestExtractProfileNameStr(t *testing.T) {
+	testCases := []struct {
+		name        string
+		resource    v1.ResourceName
+		expected    string
+		errExpected bool
+	}{
+		{
+			name:        "Full GPU",
+			resource:    "nvidia.com/gpu",
+			errExpected: true,
+		},
+		{
+			name:        "MIG device",
+			resource:    "nvidia.com/mig-1g.10gb",
+			errExpected: true,
+		},
+		{
+			name:     "GPU slice",
+			resource: "nvidia.com/gpu-10gb",
+			expected: "10gb",
+		},
+		{
+			name:     "Time-sliced GPU replica",
+			resource: "nvidia.com/gpu-shared",
+			expected: "shared",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := gpuagent.ExtractProfileNameStr(tt.resource)
+			if tt.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, profile)
+		})
+	}
+}
+
+func TestReporter__ReportPlan(t *testing.T) {
+	const nodeName = "node-1"
+	const planId = "plan-2"
+	configKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, nodeName, planId)
+
+	pluginConfig, err := mps.ToPluginConfig(v1.Node{}, state.NodePartitioning{
+		GPUs: []state.GPUPartitioning{
+			{
+				GPUIndex: 0,
+				Resources: map[v1.ResourceName]int{
+					"nvidia.com/gpu-10gb": 2,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	pluginConfigYaml, err := yaml.Marshal(pluginConfig)
+	assert.NoError(t, err)
+	devicePluginCM := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      "test-name",
+		},
+		Data: map[string]string{
+			configKey: string(pluginConfigYaml),
+		},
+	}
+
+	newDevice := func(resourceName v1.ResourceName, deviceId string, gpuIndex int) gpu.Device {
+		return gpu.Device{
+			Device: resource.Device{
+				ResourceName: resourceName,
+				DeviceId:     deviceId,
+				Status:       resource.StatusFree,
+			},
+			GpuIndex: gpuIndex,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		node     v1.Node
+		devices  gpu.DeviceList
+		expected string
+	}{
+		{
+			name: "Device plugin exposes the devices of the plan config, plan should be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaDevicePluginConfig: configKey,
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         planId,
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::0", 0),
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::1", 0),
+				newDevice("nvidia.com/gpu", "gpu-1", 1),
+			},
+			expected: planId,
+		},
+		{
+			name: "Device plugin still exposes the devices of the previous config, plan should not be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaDevicePluginConfig: configKey,
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         planId,
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-20gb", "gpu-0::0", 0),
+				newDevice("nvidia.com/gpu", "gpu-1", 1),
+			},
+			expected: "plan-1",
+		},
+		{
+			name: "Node is not labeled with the config of the plan, plan should not be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaDevicePluginConfig: fmt.Sprintf(mps.DevicePluginConfigKeyFormat, nodeName, "plan-1"),
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         planId,
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::0", 0),
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::1", 0),
+			},
+			expected: "plan-1",
+		},
+		{
+			name: "Hybrid node, MIG geometry of the plan not applied yet, plan should not be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindHybrid.String(),
+					constant.LabelNvidiaDevicePluginConfig: configKey,
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:            planId,
+					v1alpha1.AnnotationReportedPartitioningPlan:    "plan-1",
+					v1alpha1.AnnotationReportedMigPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::0", 0),
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::1", 0),
+			},
+			expected: "plan-1",
+		},
+		{
+			name: "Hybrid node, device plugin still exposes the devices of the previous config, plan should not be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindHybrid.String(),
+					constant.LabelNvidiaDevicePluginConfig: configKey,
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:            planId,
+					v1alpha1.AnnotationReportedPartitioningPlan:    "plan-1",
+					v1alpha1.AnnotationReportedMigPartitioningPlan: planId,
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-20gb", "gpu-0::0", 0),
+			},
+			expected: "plan-1",
+		},
+		{
+			name: "Hybrid node, MIG geometry applied and device plugin exposes the devices of the plan config, plan should be reported",
+			node: factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning:          gpu.PartitioningKindHybrid.String(),
+					constant.LabelNvidiaDevicePluginConfig: configKey,
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:            planId,
+					v1alpha1.AnnotationReportedPartitioningPlan:    "plan-1",
+					v1alpha1.AnnotationReportedMigPartitioningPlan: planId,
+				}).
+				Get(),
+			devices: gpu.DeviceList{
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::0", 0),
+				newDevice("nvidia.com/gpu-10gb", "gpu-0::1", 0),
+			},
+			expected: planId,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithObjects(&tt.node, &devicePluginCM).Build()
+			gpuClient := gpumocks.NewClient(t)
+			gpuClient.On("GetDevices", mock.Anything).Return(tt.devices, nil)
+			reporter := gpuagent.NewReporter(
+				k8sClient,
+				k8sClient,
+				gpuClient,
+				types.NamespacedName{Namespace: devicePluginCM.Namespace, Name: devicePluginCM.Name},
+				0,
+				nil,
+			)
+
+			ctx := context.Background()
+			_, err := reporter.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: nodeName}})
+			assert.NoError(t, err)
+
+			var updated v1.Node
+			assert.NoError(t, k8sClient.Get(ctx, client.ObjectKey{Name: nodeName}, &updated))
+			assert.Equal(t, tt.expected, updated.Annotations[v1alpha1.AnnotationReportedPartitioningPlan])
+		})
+	}
+}
+
+func TestReporter__ReportFullGPUs(t *testing.T) {
+	const nodeName = "node-1"
+	node := factory.BuildNode(nodeName).
+		WithLabels(map[string]string{
+			v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindTimeSlicing.String(),
+		}).
+		Get()
+	devices := gpu.DeviceList{
+		{
+			Device:   resource.Device{ResourceName: constant.ResourceNvidiaGPU, DeviceId: "gpu-0", Status: resource.StatusUsed},
+			GpuIndex: 0,
+		},
+		{
+			Device:   resource.Device{ResourceName: constant.ResourceNvidiaGPU, DeviceId: "gpu-1", Status: resource.StatusFree},
+			GpuIndex: 1,
+		},
+		{
+			Device:   resource.Device{ResourceName: "nvidia.com/gpu-shared", DeviceId: "gpu-2::0", Status: resource.StatusFree},
+			GpuIndex: 2,
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(&node).Build()
+	gpuClient := gpumocks.NewClient(t)
+	gpuClient.On("GetDevices", mock.Anything).Return(devices, nil)
+	reporter := gpuagent.NewReporter(k8sClient, k8sClient, gpuClient, types.NamespacedName{}, 0, nil)
+
+	ctx := context.Background()
+	_, err := reporter.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: nodeName}})
+	assert.NoError(t, err)
+
+	var updated v1.Node
+	assert.NoError(t, k8sClient.Get(ctx, client.ObjectKey{Name: nodeName}, &updated))
+	assert.Equal(
+		t,
+		map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, timeslicing.ProfileFull, resource.StatusUsed):   "1",
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, timeslicing.ProfileFull, resource.StatusFree):   "1",
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, timeslicing.ProfileShared, resource.StatusFree): "1",
+		},
+		updated.Annotations,
+	)
+}
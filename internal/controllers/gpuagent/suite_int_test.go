@@ -26,6 +26,7 @@ import (
 	gpumock "github.com/nebuly-ai/nos/pkg/test/mocks/gpu"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"path/filepath"
@@ -91,7 +92,7 @@ var _ = BeforeSuite(func() {
 	Expect(err).ToNot(HaveOccurred())
 
 	// Setup Reporter
-	reporter := gpuagent.NewReporter(k8sClient, gpuClient, reporterRefreshInterval)
+	reporter := gpuagent.NewReporter(k8sClient, k8sClient, gpuClient, types.NamespacedName{}, reporterRefreshInterval, nil)
 	Expect(reporter.SetupWithManager(k8sManager, "Reporter", nodeName)).To(Succeed())
 
 	go func() {
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/util/pod"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// compactionCheckInterval is the interval at which the CompactionController checks
+// whether the free slices of the nodes can be compacted
+const compactionCheckInterval = 10 * time.Second
+
+// CompactionController periodically compacts the free GPU slices of the nodes with a certain kind of
+// GPU partitioning. The slices created for pods that have since finished are never deleted by the
+// partitioning plans, so over time they fragment the GPUs and prevent creating larger slices.
+//
+// The controller compacts the nodes only when no pods that could be helped by extra resources have
+// been pending for at least the idle threshold, and when all the nodes have reported the last plan.
+// Compaction plans are computed and applied holding the same lock as the Controller of the same kind,
+// so that they never race with the plans computed for the pending pods.
+type CompactionController struct {
+	client.Client
+	clusterState  *state.ClusterState
+	kind          gpu.PartitioningKind
+	compactor     core.Compactor
+	actuator      core.Actuator
+	snapshotTaker core.SnapshotTaker
+	idleThreshold time.Duration
+	idleSince     time.Time
+	name          string
+	lock          *sync.Mutex
+}
+
+func NewCompactionController(
+	client client.Client,
+	clusterState *state.ClusterState,
+	kind gpu.PartitioningKind,
+	compactor core.Compactor,
+	actuator core.Actuator,
+	snapshotTaker core.SnapshotTaker,
+	idleThreshold time.Duration,
+) CompactionController {
+	return CompactionController{
+		Client:        client,
+		clusterState:  clusterState,
+		kind:          kind,
+		compactor:     compactor,
+		actuator:      actuator,
+		snapshotTaker: snapshotTaker,
+		idleThreshold: idleThreshold,
+		lock:          getPartitioningLock(kind),
+	}
+}
+
+// Start runs the compaction loop until the context is cancelled
+func (c *CompactionController) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName(c.name)
+	ctx = log.IntoContext(ctx, logger)
+	logger.Info("starting compaction loop", "idleThreshold", c.idleThreshold)
+
+	ticker := time.NewTicker(compactionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if _, err := c.compactIfIdle(ctx, now); err != nil {
+				logger.Error(err, "unable to compact GPU partitioning")
+			}
+		}
+	}
+}
+
+// compactIfIdle compacts the free slices of the nodes if the cluster has been idle for at least
+// the idle threshold, and returns true if it applies a compaction plan
+func (c *CompactionController) compactIfIdle(ctx context.Context, now time.Time) (bool, error) {
+	// If there isn't any node with this kind of partitioning then there's noting to do
+	if !c.clusterState.IsPartitioningEnabled(c.kind) {
+		return false, nil
+	}
+	logger := log.FromContext(ctx)
+
+	// Check if there are pending pods that could be helped by the partitioner
+	pendingPods, err := fetchPendingPods(ctx, c)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch pending pods: %w", err)
+	}
+	for _, p := range pendingPods {
+		if pod.ExtraResourcesCouldHelpScheduling(p) {
+			logger.V(3).Info("found pending pods, skipping compaction")
+			c.idleSince = time.Time{}
+			return false, nil
+		}
+	}
+
+	// Check if the cluster has been idle for long enough
+	if c.idleSince.IsZero() {
+		c.idleSince = now
+	}
+	if now.Sub(c.idleSince) < c.idleThreshold {
+		return false, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Check if last plan has been reported
+	if waitingAnyNodeToReportPlan(c.clusterState) {
+		logger.V(1).Info("last partitioning plan has not been reported by all nodes yet, skipping compaction")
+		return false, nil
+	}
+
+	snapshot, err := c.snapshotTaker.TakeSnapshot(c.clusterState)
+	if err != nil {
+		return false, fmt.Errorf("unable to take a snapshot of the cluster state: %w", err)
+	}
+	plan, err := c.compactor.Compact(ctx, snapshot.Clone())
+	if err != nil {
+		return false, fmt.Errorf("unable to compute compaction plan: %w", err)
+	}
+	if snapshot.GetPartitioningState().Equal(plan.DesiredState) {
+		logger.V(3).Info("nothing to compact")
+		return false, nil
+	}
+
+	logger.Info("compacting GPU partitioning", "plan", plan.GetId())
+	applied, err := c.actuator.Apply(ctx, snapshot.Clone(), plan)
+	// Wait for the cluster to be idle again before compacting it again
+	c.idleSince = time.Time{}
+	if err != nil {
+		return false, fmt.Errorf("unable to apply compaction plan: %w", err)
+	}
+	if applied {
+		metrics.ObserveCompaction(c.kind)
+	}
+	return applied, nil
+}
+
+func (c *CompactionController) SetupWithManager(mgr ctrl.Manager, name string) error {
+	c.name = name
+	return mgr.Add(c)
+}
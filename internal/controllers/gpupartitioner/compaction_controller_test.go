@@ -0,0 +1,251 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/nebuly-ai/nos/pkg/test/mocks"
+	"github.com/nebuly-ai/nos/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeCompactor struct {
+	desired state.PartitioningState
+}
+
+func (c fakeCompactor) Compact(_ context.Context, _ core.Snapshot) (core.PartitioningPlan, error) {
+	return core.NewPartitioningPlan(gpu.PartitioningKindMig, c.desired), nil
+}
+
+type fakeActuator struct {
+	applied int
+}
+
+func (a *fakeActuator) Apply(_ context.Context, _ core.Snapshot, _ core.PartitioningPlan) (bool, error) {
+	a.applied++
+	return true, nil
+}
+
+func TestCompactionController__compactIfIdle(t *testing.T) {
+	current := state.PartitioningState{
+		"node-1": {GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-1g.6gb": 4}},
+		}},
+	}
+	compacted := state.PartitioningState{
+		"node-1": {GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-4g.24gb": 1}},
+		}},
+	}
+	pendingPod := factory.BuildPod("ns-1", "pd-1").WithPhase(v1.PodPending).Get()
+	pendingPod.Status.Conditions = []v1.PodCondition{
+		{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: v1.PodReasonUnschedulable},
+	}
+	now := time.Now()
+	threshold := time.Minute
+
+	testCases := []struct {
+		name            string
+		pods            []v1.Pod
+		nodeAnnotations map[string]string
+		idleSince       time.Time
+		desired         state.PartitioningState
+
+		expectedApplied   bool
+		expectedIdleSince time.Time
+	}{
+		{
+			name:              "Pending pods, should not compact and reset idle time",
+			pods:              []v1.Pod{pendingPod},
+			idleSince:         now.Add(-2 * threshold),
+			desired:           compacted,
+			expectedApplied:   false,
+			expectedIdleSince: time.Time{},
+		},
+		{
+			name:              "No pending pods, should start idle time",
+			desired:           compacted,
+			expectedApplied:   false,
+			expectedIdleSince: now,
+		},
+		{
+			name:              "Idle for less than threshold, should not compact",
+			idleSince:         now.Add(-threshold / 2),
+			desired:           compacted,
+			expectedApplied:   false,
+			expectedIdleSince: now.Add(-threshold / 2),
+		},
+		{
+			name: "Idle for more than threshold but plan not reported yet, should not compact",
+			nodeAnnotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-1",
+			},
+			idleSince:         now.Add(-2 * threshold),
+			desired:           compacted,
+			expectedApplied:   false,
+			expectedIdleSince: now.Add(-2 * threshold),
+		},
+		{
+			name:              "Idle for more than threshold but nothing to compact, should not apply any plan",
+			idleSince:         now.Add(-2 * threshold),
+			desired:           current,
+			expectedApplied:   false,
+			expectedIdleSince: now.Add(-2 * threshold),
+		},
+		{
+			name:              "Idle for more than threshold, should compact and reset idle time",
+			idleSince:         now.Add(-2 * threshold),
+			desired:           compacted,
+			expectedApplied:   true,
+			expectedIdleSince: time.Time{},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").
+				WithLabels(map[string]string{v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String()}).
+				WithAnnotations(tt.nodeAnnotations).
+				Get()
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&node)
+			clusterState := state.NewClusterState(map[string]framework.NodeInfo{node.Name: *nodeInfo})
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			objs := make([]client.Object, 0, len(tt.pods))
+			for i := range tt.pods {
+				objs = append(objs, &tt.pods[i])
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+			snapshot := mocks.NewSnapshot(t)
+			snapshot.On("Clone").Return(snapshot).Maybe()
+			snapshot.On("GetPartitioningState").Return(current).Maybe()
+			snapshotTaker := mocks.NewSnapshotTaker(t)
+			snapshotTaker.On("TakeSnapshot", mock.Anything).Return(snapshot, nil).Maybe()
+			actuator := &fakeActuator{}
+
+			controller := NewCompactionController(
+				c,
+				clusterState,
+				gpu.PartitioningKindMig,
+				fakeCompactor{desired: tt.desired},
+				actuator,
+				snapshotTaker,
+				threshold,
+			)
+			controller.idleSince = tt.idleSince
+
+			applied, err := controller.compactIfIdle(context.Background(), now)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedApplied, applied)
+			assert.Equal(t, tt.expectedIdleSince, controller.idleSince)
+			if tt.expectedApplied {
+				assert.Equal(t, 1, actuator.applied)
+			} else {
+				assert.Equal(t, 0, actuator.applied)
+			}
+		})
+	}
+}
+
+func TestCompactionController__compactIfIdle__SharesLockWithController(t *testing.T) {
+	current := state.PartitioningState{
+		"node-1": {GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-1g.6gb": 4}},
+		}},
+	}
+	compacted := state.PartitioningState{
+		"node-1": {GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-4g.24gb": 1}},
+		}},
+	}
+	node := factory.BuildNode("node-1").
+		WithLabels(map[string]string{v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String()}).
+		Get()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	clusterState := state.NewClusterState(map[string]framework.NodeInfo{node.Name: *nodeInfo})
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	snapshot := mocks.NewSnapshot(t)
+	snapshot.On("Clone").Return(snapshot).Maybe()
+	snapshot.On("GetPartitioningState").Return(current).Maybe()
+	snapshotTaker := mocks.NewSnapshotTaker(t)
+	snapshotTaker.On("TakeSnapshot", mock.Anything).Return(snapshot, nil).Maybe()
+	actuator := &fakeActuator{}
+
+	controller := NewController(
+		scheme,
+		c,
+		util.NewBatcher[v1.Pod](time.Second, time.Second),
+		clusterState,
+		gpu.PartitioningKindMig,
+		nil,
+		actuator,
+		snapshotTaker,
+	)
+	compactionController := NewCompactionController(
+		c,
+		clusterState,
+		gpu.PartitioningKindMig,
+		fakeCompactor{desired: compacted},
+		actuator,
+		snapshotTaker,
+		time.Minute,
+	)
+	assert.Same(t, controller.lock, compactionController.lock)
+
+	// While the partitioner holds the lock, the compaction must wait for it
+	now := time.Now()
+	compactionController.idleSince = now.Add(-2 * time.Minute)
+	controller.lock.Lock()
+	done := make(chan bool)
+	go func() {
+		applied, err := compactionController.compactIfIdle(context.Background(), now)
+		assert.NoError(t, err)
+		done <- applied
+	}()
+	select {
+	case <-done:
+		t.Fatal("compaction applied while the partitioner was holding the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	controller.lock.Unlock()
+	assert.True(t, <-done)
+	assert.Equal(t, 1, actuator.applied)
+}
@@ -19,44 +19,78 @@ package gpupartitioner
 import (
 	"context"
 	"fmt"
+	"github.com/google/go-cmp/cmp"
 	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/agent"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	nodeutil "github.com/nebuly-ai/nos/pkg/util/node"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+	"time"
 )
 
 type NodeController struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	recorder       record.EventRecorder
 	clusterState   *state.ClusterState
 	migInitializer core.NodeInitializer
+	// planReportTimeout is the time after which a node that did not report the last
+	// partitioning plan applied to it is excluded from partitioning. Zero disables the timeout.
+	planReportTimeout time.Duration
+	// requireAgentLease specifies whether only the nodes on which the agents required for partitioning
+	// their GPUs hold a fresh Lease are considered for partitioning
+	requireAgentLease bool
 }
 
 func NewNodeController(
 	client client.Client,
 	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
 	migInitializer core.NodeInitializer,
 	state *state.ClusterState,
+	planReportTimeout time.Duration,
+	requireAgentLease bool,
 ) NodeController {
 	return NodeController{
-		Client:         client,
-		Scheme:         scheme,
-		clusterState:   state,
-		migInitializer: migInitializer,
+		Client:            client,
+		Scheme:            scheme,
+		recorder:          recorder,
+		clusterState:      state,
+		migInitializer:    migInitializer,
+		planReportTimeout: planReportTimeout,
+		requireAgentLease: requireAgentLease,
 	}
 }
 
+//+kubebuilder:rbac:groups=core,resources=nodes/status,verbs=get;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
+
 func (c *NodeController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
@@ -69,13 +103,22 @@ func (c *NodeController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 	if apierrors.IsNotFound(err) {
-		logger.V(2).Info("deleting node", "node", instance.Name)
+		logger.V(2).Info("deleting node", "node", req.Name)
+		c.clusterState.DeleteNode(req.Name)
+		metrics.DeleteNode(req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	// Nodes whose GPU partitioning has been disabled are not partitioned anymore
+	if _, ok := gpu.GetPartitioningKind(instance); !ok {
+		logger.V(2).Info("GPU partitioning disabled, deleting node", "node", instance.Name)
 		c.clusterState.DeleteNode(instance.Name)
+		metrics.DeleteNode(instance.Name)
 		return ctrl.Result{}, nil
 	}
 
 	// Check if Node has GPU model and count info
-	_, err = gpu.GetModel(instance)
+	model, err := gpu.GetModel(instance)
 	if err != nil {
 		logger.Info("cannot get GPU model from node, skipping", "err", err, "node", instance.Name)
 		return ctrl.Result{}, nil
@@ -86,6 +129,46 @@ func (c *NodeController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, nil
 	}
 
+	// Check if the MIG geometries allowed by the GPUs are known, either because they are reported
+	// by the mig-agent or because the GPU model is known. Hybrid nodes with unknown GPU models
+	// can still be partitioned with MPS, so they are not skipped.
+	_, knownMigGeometries := mig.GetNodeAllowedGeometries(instance, model)
+	unknownMigModel := (gpu.IsMigPartitioningEnabled(instance) || gpu.IsHybridPartitioningEnabled(instance)) &&
+		!knownMigGeometries
+	if err = c.updateUnknownMigModelCondition(ctx, instance, model, unknownMigModel); err != nil {
+		logger.Error(err, "unable to update node condition", "node", instance.Name)
+		return ctrl.Result{}, err
+	}
+	if unknownMigModel && gpu.IsMigPartitioningEnabled(instance) {
+		logger.Info("GPU model is not associated with any known MIG geometry, skipping", "node", instance.Name, "model", model)
+		c.clusterState.DeleteNode(instance.Name)
+		return ctrl.Result{}, nil
+	}
+
+	// Check if the node reported the last partitioning plan applied to it within the timeout.
+	// The condition is updated in place, so that the cluster state immediately excludes the node.
+	requeueAfter, err := c.updatePlanNotReportedCondition(ctx, &instance)
+	if err != nil {
+		logger.Error(err, "unable to update node condition", "node", instance.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Check that the agents required for partitioning the node are running. Nodes without running
+	// agents are not initialized, since nobody would apply the partitioning to their GPUs.
+	agentReady, leaseRequeueAfter, err := c.updateAgentNotReadyCondition(ctx, &instance)
+	if err != nil {
+		logger.Error(err, "unable to update node condition", "node", instance.Name)
+		return ctrl.Result{}, err
+	}
+	if !agentReady {
+		logger.Info("partitioning agents are not running on node, skipping", "node", instance.Name)
+		c.clusterState.DeleteNode(instance.Name)
+		return ctrl.Result{}, nil
+	}
+	if leaseRequeueAfter > 0 && (requeueAfter == 0 || leaseRequeueAfter < requeueAfter) {
+		requeueAfter = leaseRequeueAfter
+	}
+
 	// Handle MIG node initialization
 	var nodeInitialized = core.IsNodeInitialized(instance)
 	if gpu.IsMigPartitioningEnabled(instance) && !nodeInitialized {
@@ -97,6 +180,16 @@ func (c *NodeController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	if gpu.IsMpsPartitioningEnabled(instance) && !nodeInitialized {
 		nodeInitialized = true
 	}
+	// Handle time-slicing node initialization: GPUs without replicas are exposed
+	// as full GPUs, so there's nothing to initialize
+	if gpu.IsTimeSlicingPartitioningEnabled(instance) && !nodeInitialized {
+		nodeInitialized = true
+	}
+	// Handle hybrid node initialization: GPUs without any partitioning are
+	// considered free, so there's nothing to initialize
+	if gpu.IsHybridPartitioningEnabled(instance) && !nodeInitialized {
+		nodeInitialized = true
+	}
 
 	// If the node is not initialized, do not add it to cluster state
 	if !nodeInitialized {
@@ -113,14 +206,225 @@ func (c *NodeController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	logger.V(2).Info("updating node", "node", instance.Name, "nPods", len(podList.Items))
 	c.clusterState.UpdateNode(instance, podList.Items)
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// updateUnknownMigModelCondition sets the status of the node condition NodeConditionUnknownMigGpuModel
+// and records a warning Event when the condition becomes True. Nodes that never had the condition
+// get it only if their GPU model is unknown.
+func (c *NodeController) updateUnknownMigModelCondition(ctx context.Context, node v1.Node, model gpu.Model, unknown bool) error {
+	condition := v1.NodeCondition{
+		Type:    v1alpha1.NodeConditionUnknownMigGpuModel,
+		Status:  v1.ConditionFalse,
+		Reason:  v1alpha1.ReasonKnownMigGpuModel,
+		Message: fmt.Sprintf("GPU model %q is associated with known MIG geometries", model),
+	}
+	if unknown {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1alpha1.ReasonUnknownMigGpuModel
+		condition.Message = fmt.Sprintf(
+			"GPU model %q is not associated with any known MIG geometry, "+
+				"add it to the known MIG geometries of the GPU Partitioner for enabling MIG partitioning",
+			model,
+		)
+	}
+
+	current, found := nodeutil.GetCondition(node, condition.Type)
+	if !found && !unknown {
+		return nil
+	}
+	updated := node.DeepCopy()
+	if changed := nodeutil.SetCondition(updated, condition); !changed {
+		return nil
+	}
+	if err := c.Status().Patch(ctx, updated, client.StrategicMergeFrom(&node)); err != nil {
+		return err
+	}
+	if unknown && current.Status != v1.ConditionTrue {
+		c.recorder.Event(updated, v1.EventTypeWarning, v1alpha1.ReasonUnknownMigGpuModel, condition.Message)
+	}
+	return nil
+}
+
+// updatePlanNotReportedCondition sets the status of the node condition NodeConditionPartitioningPlanNotReported
+// and records a warning Event when the node did not report the last partitioning plan applied to it within
+// the plan report timeout. The condition is cleared as soon as the node reports the plan.
+//
+// If the node is still waiting to report the plan within the timeout, updatePlanNotReportedCondition returns
+// the time after which the node must be checked again.
+func (c *NodeController) updatePlanNotReportedCondition(ctx context.Context, node *v1.Node) (time.Duration, error) {
+	planId, waitingTime, waiting := getPlanWaitingToBeReported(*node)
+	if waiting && c.planReportTimeout <= 0 {
+		return 0, nil
+	}
+	if waiting && waitingTime < c.planReportTimeout {
+		return c.planReportTimeout - waitingTime, nil
+	}
+
+	condition := v1.NodeCondition{
+		Type:    v1alpha1.NodeConditionPartitioningPlanNotReported,
+		Status:  v1.ConditionFalse,
+		Reason:  v1alpha1.ReasonPlanReported,
+		Message: "node reported the last partitioning plan applied to it",
+	}
+	if waiting {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1alpha1.ReasonPlanReportTimeout
+		condition.Message = fmt.Sprintf(
+			"node did not report partitioning plan %q within %s, "+
+				"excluding it from GPU partitioning until the plan is reported",
+			planId,
+			c.planReportTimeout,
+		)
+	}
+
+	current, found := nodeutil.GetCondition(*node, condition.Type)
+	if !found && !waiting {
+		return 0, nil
+	}
+	updated := node.DeepCopy()
+	if changed := nodeutil.SetCondition(updated, condition); !changed {
+		return 0, nil
+	}
+	if err := c.Status().Patch(ctx, updated, client.StrategicMergeFrom(node)); err != nil {
+		return 0, err
+	}
+	*node = *updated
+	if kind, ok := gpu.GetPartitioningKind(*node); ok {
+		metrics.ObservePlanReportTimeout(kind, node.Name, waiting)
+	}
+	if waiting && current.Status != v1.ConditionTrue {
+		log.FromContext(ctx).Info("node did not report partitioning plan within timeout", "node", node.Name, "plan", planId)
+		c.recorder.Event(updated, v1.EventTypeWarning, v1alpha1.ReasonPlanReportTimeout, condition.Message)
+	}
+	return 0, nil
+}
+
+// updateAgentNotReadyCondition sets the status of the node condition NodeConditionPartitioningAgentNotReady
+// and records a warning Event when the agents required for partitioning the GPUs of the node do not hold
+// any fresh Lease. The condition is updated in place.
+//
+// updateAgentNotReadyCondition returns true if the agents are running, together with the time after which
+// their Leases must be checked again.
+func (c *NodeController) updateAgentNotReadyCondition(ctx context.Context, node *v1.Node) (bool, time.Duration, error) {
+	var missing []gpu.PartitioningKind
+	var checkAfter time.Duration
+	if kind, ok := gpu.GetPartitioningKind(*node); ok && c.requireAgentLease {
+		var leaseList coordinationv1.LeaseList
+		if err := c.List(ctx, &leaseList, client.MatchingLabels{v1alpha1.LabelAgentNode: node.Name}); err != nil {
+			return false, 0, err
+		}
+		missing, checkAfter = agent.GetMissingCapabilities(kind, leaseList.Items, time.Now())
+	}
+	ready := len(missing) == 0
+
+	condition := v1.NodeCondition{
+		Type:    v1alpha1.NodeConditionPartitioningAgentNotReady,
+		Status:  v1.ConditionFalse,
+		Reason:  v1alpha1.ReasonPartitioningAgentReady,
+		Message: "the agents required for partitioning the GPUs of the node are running",
+	}
+	if !ready {
+		missingStr := make([]string, 0, len(missing))
+		for _, m := range missing {
+			missingStr = append(missingStr, m.String())
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = v1alpha1.ReasonPartitioningAgentNotReady
+		condition.Message = fmt.Sprintf(
+			"no running agent provides the %s partitioning required by the node, "+
+				"excluding it from GPU partitioning until the agent renews its Lease",
+			strings.Join(missingStr, ", "),
+		)
+	}
+
+	current, found := nodeutil.GetCondition(*node, condition.Type)
+	if !found && ready {
+		return true, checkAfter, nil
+	}
+	updated := node.DeepCopy()
+	if changed := nodeutil.SetCondition(updated, condition); !changed {
+		return ready, checkAfter, nil
+	}
+	if err := c.Status().Patch(ctx, updated, client.StrategicMergeFrom(node)); err != nil {
+		return false, 0, err
+	}
+	*node = *updated
+	if !ready && current.Status != v1.ConditionTrue {
+		c.recorder.Event(updated, v1.EventTypeWarning, v1alpha1.ReasonPartitioningAgentNotReady, condition.Message)
+	}
+	return ready, checkAfter, nil
+}
+
+// AgentLeaseSelector returns the selector of the Leases held by the nos agents running in the namespace
+// provided as argument, or in any namespace if the namespace is empty. The cache of the manager running
+// the NodeController should select the Leases with it, so that it does not store all the Leases of the cluster.
+func AgentLeaseSelector(namespace string) (cache.ObjectSelector, error) {
+	requirement, err := labels.NewRequirement(v1alpha1.LabelAgentNode, selection.Exists, nil)
+	if err != nil {
+		return cache.ObjectSelector{}, err
+	}
+	selector := cache.ObjectSelector{Label: labels.NewSelector().Add(*requirement)}
+	if namespace != "" {
+		selector.Field = fields.OneTermEqualSelector("metadata.namespace", namespace)
+	}
+	return selector, nil
+}
+
+// mapAgentLeaseToNode returns the request for reconciling the node on which
+// the agent holding the Lease provided as argument is running
+func mapAgentLeaseToNode(lease client.Object) []reconcile.Request {
+	nodeName, ok := lease.GetLabels()[v1alpha1.LabelAgentNode]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: nodeName}}}
+}
+
+// agentLeaseChanged filters the updates of the agent Leases: renewals of Leases that are still fresh
+// do not change the readiness of the agents, while their expiration is detected by requeueing the nodes.
+func agentLeaseChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldLease, ok := e.ObjectOld.(*coordinationv1.Lease)
+			if !ok {
+				return false
+			}
+			if !agent.IsLeaseFresh(*oldLease, time.Now()) {
+				return true
+			}
+			return !cmp.Equal(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations())
+		},
+	}
+}
+
+// gpuPartitioningEnabled filters the events of the nodes with GPU partitioning enabled. Updates disabling
+// the GPU partitioning of a node are let through, so that the node is not partitioned anymore.
+func gpuPartitioningEnabled() predicate.Predicate {
+	isEnabled := func(o client.Object) bool {
+		_, ok := o.GetLabels()[v1alpha1.LabelGpuPartitioning]
+		return ok
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isEnabled(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isEnabled(e.ObjectOld) || isEnabled(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isEnabled(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return isEnabled(e.Object)
+		},
+	}
 }
 
 func (c *NodeController) SetupWithManager(mgr ctrl.Manager, name string) error {
-	// Reconcile only nodes with GPU partitioning enabled
-	selectorPredicate, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
+	agentLeasePredicate, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
 		MatchExpressions: []metav1.LabelSelectorRequirement{{
-			Key:      v1alpha1.LabelGpuPartitioning,
+			Key:      v1alpha1.LabelAgentNode,
 			Operator: metav1.LabelSelectorOpExists,
 		}},
 	})
@@ -129,7 +433,12 @@ func (c *NodeController) SetupWithManager(mgr ctrl.Manager, name string) error {
 	}
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
-		For(&v1.Node{}, builder.WithPredicates(selectorPredicate)).
+		For(&v1.Node{}, builder.WithPredicates(gpuPartitioningEnabled())).
+		Watches(
+			&source.Kind{Type: &coordinationv1.Lease{}},
+			handler.EnqueueRequestsFromMapFunc(mapAgentLeaseToNode),
+			builder.WithPredicates(agentLeasePredicate, agentLeaseChanged()),
+		).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
 		Complete(c)
 }
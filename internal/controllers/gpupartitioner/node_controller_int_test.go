@@ -24,9 +24,12 @@ import (
 	"github.com/nebuly-ai/nos/pkg/constant"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/test/factory"
+	nodeutil "github.com/nebuly-ai/nos/pkg/util/node"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"time"
 )
 
@@ -141,4 +144,38 @@ var _ = Describe("Node Controller", func() {
 			}, timeout, interval).Should(BeTrue())
 		})
 	})
+
+	When("A node with MIG partitioning enabled has an unknown GPU model", func() {
+		It("Should *not* be added to the Cluster State and should have the UnknownMigGpuModel condition", func() {
+			By("By creating an initialized node with MIG partitioning enabled and an unknown GPU model")
+			nodeName := "node-mig-unknown-model"
+			node := factory.BuildNode(nodeName).
+				WithLabels(map[string]string{
+					constant.LabelNvidiaProduct:   "unknown-model",
+					constant.LabelNvidiaCount:     "1",
+					v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+				}).
+				WithAnnotations(map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, "10gb"): "1",
+				}).
+				Get()
+			Expect(k8sClient.Create(ctx, &node)).To(Succeed())
+
+			By("Checking that the node has the UnknownMigGpuModel condition")
+			Eventually(func() v1.ConditionStatus {
+				var updated v1.Node
+				if err := k8sClient.Get(ctx, client.ObjectKey{Name: nodeName}, &updated); err != nil {
+					return ""
+				}
+				condition, _ := nodeutil.GetCondition(updated, v1alpha1.NodeConditionUnknownMigGpuModel)
+				return condition.Status
+			}, timeout, interval).Should(Equal(v1.ConditionTrue))
+
+			By("Checking that the node is *not* added to the Cluster State")
+			Consistently(func() bool {
+				_, ok := clusterState.GetNode(nodeName)
+				return ok
+			}, 5, interval).Should(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,498 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/nebuly-ai/nos/pkg/test/mocks/partitioning"
+	nodeutil "github.com/nebuly-ai/nos/pkg/util/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestNodeController__UnknownMigModel(t *testing.T) {
+	testCases := []struct {
+		name       string
+		kind       gpu.PartitioningKind
+		model      gpu.Model
+		placements string
+		conditions []v1.NodeCondition
+
+		expectedInClusterState bool
+		expectedCondition      v1.ConditionStatus
+		expectedEvents         int
+	}{
+		{
+			name:                   "MIG node with known model, condition is not added",
+			kind:                   gpu.PartitioningKindMig,
+			model:                  gpu.GPUModel_H100_SXM5_80GB,
+			expectedInClusterState: true,
+			expectedCondition:      "",
+			expectedEvents:         0,
+		},
+		{
+			name:                   "MIG node with unknown model, node is skipped",
+			kind:                   gpu.PartitioningKindMig,
+			model:                  "unknown",
+			expectedInClusterState: false,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         1,
+		},
+		{
+			name:  "MIG node with unknown model already reported, event is not recorded again",
+			kind:  gpu.PartitioningKindMig,
+			model: "unknown",
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionUnknownMigGpuModel, Status: v1.ConditionTrue, Reason: v1alpha1.ReasonUnknownMigGpuModel},
+			},
+			expectedInClusterState: false,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         0,
+		},
+		{
+			name:  "MIG node whose model became known, condition is cleared",
+			kind:  gpu.PartitioningKindMig,
+			model: gpu.GPUModel_A100_PCIe_40GB,
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionUnknownMigGpuModel, Status: v1.ConditionTrue, Reason: v1alpha1.ReasonUnknownMigGpuModel},
+			},
+			expectedInClusterState: true,
+			expectedCondition:      v1.ConditionFalse,
+			expectedEvents:         0,
+		},
+		{
+			name:                   "MIG node with unknown model but reported MIG placements, condition is not added",
+			kind:                   gpu.PartitioningKindMig,
+			model:                  "unknown",
+			placements:             `{"1g.10gb":[{"start":0,"size":1}],"7g.80gb":[{"start":0,"size":8}]}`,
+			expectedInClusterState: true,
+			expectedCondition:      "",
+			expectedEvents:         0,
+		},
+		{
+			name:                   "Hybrid node with unknown model, node is added since it can use MPS",
+			kind:                   gpu.PartitioningKindHybrid,
+			model:                  "unknown",
+			expectedInClusterState: true,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         1,
+		},
+		{
+			name:                   "MPS node with unknown model, condition is not added",
+			kind:                   gpu.PartitioningKindMps,
+			model:                  "unknown",
+			expectedInClusterState: true,
+			expectedCondition:      "",
+			expectedEvents:         0,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning: tt.kind.String(),
+					constant.LabelNvidiaProduct:   tt.model.String(),
+					constant.LabelNvidiaCount:     "1",
+				}).
+				WithAnnotations(map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, "1g.10gb"): "1",
+				}).
+				Get()
+			if tt.placements != "" {
+				node.Annotations[v1alpha1.AnnotationMigPlacements] = tt.placements
+			}
+			node.Status.Conditions = tt.conditions
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&node).Build()
+			recorder := record.NewFakeRecorder(10)
+			clusterState := state.NewEmptyClusterState()
+			controller := NewNodeController(c, scheme, recorder, partitioning.NewNodeInitializer(t), clusterState, 0, false)
+
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&node)})
+			require.NoError(t, err)
+
+			_, inClusterState := clusterState.GetNode(node.Name)
+			assert.Equal(t, tt.expectedInClusterState, inClusterState)
+
+			var updated v1.Node
+			require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(&node), &updated))
+			condition, _ := nodeutil.GetCondition(updated, v1alpha1.NodeConditionUnknownMigGpuModel)
+			assert.Equal(t, tt.expectedCondition, condition.Status)
+			assert.Len(t, recorder.Events, tt.expectedEvents)
+		})
+	}
+}
+
+func TestNodeController__PlanReportTimeout(t *testing.T) {
+	timeout := 10 * time.Minute
+	stuckPlan := gpu.PlanId{Kind: gpu.PartitioningKindMps, Generation: time.Now().Add(-2 * timeout).UnixMicro()}
+	recentPlan := gpu.PlanId{Kind: gpu.PartitioningKindMps, Generation: time.Now().Add(-timeout / 2).UnixMicro()}
+	timedOutCondition := v1.NodeCondition{
+		Type:   v1alpha1.NodeConditionPartitioningPlanNotReported,
+		Status: v1.ConditionTrue,
+		Reason: v1alpha1.ReasonPlanReportTimeout,
+	}
+
+	testCases := []struct {
+		name         string
+		timeout      time.Duration
+		specPlan     gpu.PlanId
+		reportedPlan gpu.PlanId
+		conditions   []v1.NodeCondition
+
+		expectedCondition v1.ConditionStatus
+		expectedEvents    int
+		expectedRequeue   bool
+	}{
+		{
+			name:              "Plan reported, condition is not added",
+			timeout:           timeout,
+			specPlan:          stuckPlan,
+			reportedPlan:      stuckPlan,
+			expectedCondition: "",
+			expectedEvents:    0,
+			expectedRequeue:   false,
+		},
+		{
+			name:              "Plan not reported within timeout, node is marked and event is recorded",
+			timeout:           timeout,
+			specPlan:          stuckPlan,
+			expectedCondition: v1.ConditionTrue,
+			expectedEvents:    1,
+			expectedRequeue:   false,
+		},
+		{
+			name:              "Plan not reported within timeout already marked, event is not recorded again",
+			timeout:           timeout,
+			specPlan:          stuckPlan,
+			conditions:        []v1.NodeCondition{timedOutCondition},
+			expectedCondition: v1.ConditionTrue,
+			expectedEvents:    0,
+			expectedRequeue:   false,
+		},
+		{
+			name:              "Plan not reported yet within timeout, node is checked again",
+			timeout:           timeout,
+			specPlan:          recentPlan,
+			expectedCondition: "",
+			expectedEvents:    0,
+			expectedRequeue:   true,
+		},
+		{
+			name:              "Plan reported after timeout, condition is cleared",
+			timeout:           timeout,
+			specPlan:          stuckPlan,
+			reportedPlan:      stuckPlan,
+			conditions:        []v1.NodeCondition{timedOutCondition},
+			expectedCondition: v1.ConditionFalse,
+			expectedEvents:    0,
+			expectedRequeue:   false,
+		},
+		{
+			name:              "Timeout disabled, condition is not added",
+			timeout:           0,
+			specPlan:          stuckPlan,
+			expectedCondition: "",
+			expectedEvents:    0,
+			expectedRequeue:   false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaProduct:   gpu.GPUModel_A100_PCIe_40GB.String(),
+					constant.LabelNvidiaCount:     "1",
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan: tt.specPlan.String(),
+				}).
+				Get()
+			if !tt.reportedPlan.IsZero() {
+				node.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] = tt.reportedPlan.String()
+			}
+			node.Status.Conditions = tt.conditions
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&node).Build()
+			recorder := record.NewFakeRecorder(10)
+			clusterState := state.NewEmptyClusterState()
+			controller := NewNodeController(c, scheme, recorder, partitioning.NewNodeInitializer(t), clusterState, tt.timeout, false)
+
+			res, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&node)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRequeue, res.RequeueAfter > 0)
+			assert.LessOrEqual(t, res.RequeueAfter, tt.timeout)
+
+			nodeInfo, inClusterState := clusterState.GetNode(node.Name)
+			require.True(t, inClusterState)
+			assert.Equal(t, tt.expectedCondition == v1.ConditionTrue, core.IsExcludedFromPartitioning(*nodeInfo.Node()))
+
+			var updated v1.Node
+			require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(&node), &updated))
+			condition, _ := nodeutil.GetCondition(updated, v1alpha1.NodeConditionPartitioningPlanNotReported)
+			assert.Equal(t, tt.expectedCondition, condition.Status)
+			assert.Len(t, recorder.Events, tt.expectedEvents)
+		})
+	}
+}
+
+func TestNodeController__DeleteNode(t *testing.T) {
+	countPlanReportTimeoutSeries := func(t *testing.T, node string) int {
+		families, err := ctrlmetrics.Registry.Gather()
+		require.NoError(t, err)
+		var res int
+		for _, f := range families {
+			if f.GetName() != "nos_gpu_partitioner_node_plan_report_timeout" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "node" && l.GetValue() == node {
+						res++
+					}
+				}
+			}
+		}
+		return res
+	}
+
+	disabledNode := factory.BuildNode("node-1").
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct: gpu.GPUModel_A100_PCIe_40GB.String(),
+			constant.LabelNvidiaCount:   "1",
+		}).
+		Get()
+
+	testCases := []struct {
+		name    string
+		objects []client.Object
+	}{
+		{
+			name:    "Node deleted",
+			objects: []client.Object{},
+		},
+		{
+			name:    "GPU partitioning disabled",
+			objects: []client.Object{&disabledNode},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterState := state.NewClusterState(map[string]framework.NodeInfo{
+				"node-1": *framework.NewNodeInfo(),
+				"node-2": *framework.NewNodeInfo(),
+			})
+			metrics.ObservePlanReportTimeout(gpu.PartitioningKindMps, "node-1", true)
+			metrics.ObservePlanReportTimeout(gpu.PartitioningKindMps, "node-2", true)
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()
+			controller := NewNodeController(c, scheme, record.NewFakeRecorder(10), partitioning.NewNodeInitializer(t), clusterState, 0, false)
+
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "node-1"}})
+			require.NoError(t, err)
+
+			_, found := clusterState.GetNode("node-1")
+			assert.False(t, found)
+			_, found = clusterState.GetNode("node-2")
+			assert.True(t, found)
+			assert.Equal(t, 0, countPlanReportTimeoutSeries(t, "node-1"))
+			assert.Equal(t, 1, countPlanReportTimeoutSeries(t, "node-2"))
+		})
+	}
+}
+
+func TestAgentLeaseSelector(t *testing.T) {
+	agentLabels := labels.Set{v1alpha1.LabelAgentNode: "node-1"}
+	otherLabels := labels.Set{"app": "foo"}
+
+	t.Run("Namespace", func(t *testing.T) {
+		selector, err := AgentLeaseSelector("nos-system")
+		require.NoError(t, err)
+		assert.True(t, selector.Label.Matches(agentLabels))
+		assert.False(t, selector.Label.Matches(otherLabels))
+		require.NotNil(t, selector.Field)
+		assert.True(t, selector.Field.Matches(fields.Set{"metadata.namespace": "nos-system"}))
+		assert.False(t, selector.Field.Matches(fields.Set{"metadata.namespace": "kube-node-lease"}))
+	})
+
+	t.Run("All namespaces", func(t *testing.T) {
+		selector, err := AgentLeaseSelector("")
+		require.NoError(t, err)
+		assert.True(t, selector.Label.Matches(agentLabels))
+		assert.False(t, selector.Label.Matches(otherLabels))
+		assert.Nil(t, selector.Field)
+	})
+}
+
+func TestNodeController__AgentLease(t *testing.T) {
+	newAgentLease := func(agentName string, capabilities string, renewedAgo time.Duration) *coordinationv1.Lease {
+		renewTime := metav1.NewMicroTime(time.Now().Add(-renewedAgo))
+		duration := int32(40)
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        agentName + "-node-1",
+				Namespace:   "nos-system",
+				Labels:      map[string]string{v1alpha1.LabelAgent: agentName, v1alpha1.LabelAgentNode: "node-1"},
+				Annotations: map[string]string{v1alpha1.AnnotationAgentCapabilities: capabilities},
+			},
+			Spec: coordinationv1.LeaseSpec{RenewTime: &renewTime, LeaseDurationSeconds: &duration},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		kind              gpu.PartitioningKind
+		requireAgentLease bool
+		leases            []client.Object
+		conditions        []v1.NodeCondition
+
+		expectedInClusterState bool
+		expectedCondition      v1.ConditionStatus
+		expectedEvents         int
+		expectedRequeue        bool
+	}{
+		{
+			name:                   "Lease not required, condition is not added",
+			kind:                   gpu.PartitioningKindMps,
+			requireAgentLease:      false,
+			expectedInClusterState: true,
+			expectedCondition:      "",
+			expectedEvents:         0,
+			expectedRequeue:        false,
+		},
+		{
+			name:                   "No agent lease, node is skipped and event is recorded",
+			kind:                   gpu.PartitioningKindMps,
+			requireAgentLease:      true,
+			expectedInClusterState: false,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         1,
+			expectedRequeue:        false,
+		},
+		{
+			name:                   "Expired agent lease, node is skipped",
+			kind:                   gpu.PartitioningKindMps,
+			requireAgentLease:      true,
+			leases:                 []client.Object{newAgentLease("gpu-agent", "mps", time.Minute)},
+			expectedInClusterState: false,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         1,
+			expectedRequeue:        false,
+		},
+		{
+			name:                   "Fresh agent lease, node is added and checked again when the lease expires",
+			kind:                   gpu.PartitioningKindMps,
+			requireAgentLease:      true,
+			leases:                 []client.Object{newAgentLease("gpu-agent", "mps", 0)},
+			expectedInClusterState: true,
+			expectedCondition:      "",
+			expectedEvents:         0,
+			expectedRequeue:        true,
+		},
+		{
+			name:                   "Hybrid node with only the MIG agent running, node is skipped",
+			kind:                   gpu.PartitioningKindHybrid,
+			requireAgentLease:      true,
+			leases:                 []client.Object{newAgentLease("mig-agent", "mig", 0)},
+			expectedInClusterState: false,
+			expectedCondition:      v1.ConditionTrue,
+			expectedEvents:         1,
+			expectedRequeue:        false,
+		},
+		{
+			name:              "Agent renewed its lease, condition is cleared",
+			kind:              gpu.PartitioningKindMps,
+			requireAgentLease: true,
+			leases:            []client.Object{newAgentLease("gpu-agent", "mps", 0)},
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningAgentNotReady, Status: v1.ConditionTrue, Reason: v1alpha1.ReasonPartitioningAgentNotReady},
+			},
+			expectedInClusterState: true,
+			expectedCondition:      v1.ConditionFalse,
+			expectedEvents:         0,
+			expectedRequeue:        true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning: tt.kind.String(),
+					constant.LabelNvidiaProduct:   gpu.GPUModel_A100_PCIe_40GB.String(),
+					constant.LabelNvidiaCount:     "1",
+				}).
+				WithConditions(tt.conditions...).
+				Get()
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&node).WithObjects(tt.leases...).Build()
+			recorder := record.NewFakeRecorder(10)
+			clusterState := state.NewEmptyClusterState()
+			controller := NewNodeController(c, scheme, recorder, partitioning.NewNodeInitializer(t), clusterState, 0, tt.requireAgentLease)
+
+			res, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&node)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRequeue, res.RequeueAfter > 0)
+
+			_, inClusterState := clusterState.GetNode(node.Name)
+			assert.Equal(t, tt.expectedInClusterState, inClusterState)
+
+			var updated v1.Node
+			require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(&node), &updated))
+			condition, _ := nodeutil.GetCondition(updated, v1alpha1.NodeConditionPartitioningAgentNotReady)
+			assert.Equal(t, tt.expectedCondition, condition.Status)
+			assert.Len(t, recorder.Events, tt.expectedEvents)
+		})
+	}
+}
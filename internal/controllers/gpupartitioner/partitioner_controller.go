@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
@@ -31,6 +32,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sync"
 	"time"
 )
 
@@ -44,6 +46,26 @@ type Controller struct {
 	actuator      core.Actuator
 	snapshotTaker core.SnapshotTaker
 	kind          gpu.PartitioningKind
+	lock          *sync.Mutex
+}
+
+// partitioningLocks contains, for each partitioning kind, the lock that serializes the computation
+// and the application of the plans of that kind, so that the plans computed by the Controller and
+// by the CompactionController of the same kind are never applied concurrently.
+var partitioningLocks = struct {
+	sync.Mutex
+	locks map[gpu.PartitioningKind]*sync.Mutex
+}{locks: make(map[gpu.PartitioningKind]*sync.Mutex)}
+
+// getPartitioningLock returns the lock that must be held while computing and applying
+// the partitioning plans of the kind provided as argument
+func getPartitioningLock(kind gpu.PartitioningKind) *sync.Mutex {
+	partitioningLocks.Lock()
+	defer partitioningLocks.Unlock()
+	if _, ok := partitioningLocks.locks[kind]; !ok {
+		partitioningLocks.locks[kind] = &sync.Mutex{}
+	}
+	return partitioningLocks.locks[kind]
 }
 
 func NewController(
@@ -65,6 +87,7 @@ func NewController(
 		actuator:      actuator,
 		snapshotTaker: snapshotTaker,
 		kind:          kind,
+		lock:          getPartitioningLock(kind),
 	}
 }
 
@@ -115,7 +138,7 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	}
 
 	// Check if last plan has been reported
-	if waiting := c.waitingAnyNodeToReportPlan(); waiting {
+	if waiting := waitingAnyNodeToReportPlan(c.clusterState); waiting {
 		logger.V(1).Info("last partitioning plan has not been reported by all nodes yet, skipping reconcile")
 		c.podBatcher.Reset()
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
@@ -130,9 +153,17 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	// If batch is ready then process pending pods
 	select {
-	case <-c.podBatcher.Ready():
+	case batch := <-c.podBatcher.Ready():
 		logger.V(1).Info("batch ready")
+		metrics.ObserveBatchSize(c.kind, len(batch))
 		c.currentBatch = make(map[string]v1.Pod)
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		// A compaction plan may have been applied while the batch was being collected
+		if waitingAnyNodeToReportPlan(c.clusterState) {
+			logger.V(1).Info("last partitioning plan has not been reported by all nodes yet, skipping batch")
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
 		err := c.processPendingPods(ctx)
 		return ctrl.Result{}, err
 	default:
@@ -153,7 +184,7 @@ func (c *Controller) processPendingPods(ctx context.Context) error {
 	logger.Info("processing pending pods")
 
 	// Fetch pending pods
-	allPendingPods, err := c.fetchPendingPods(ctx)
+	allPendingPods, err := fetchPendingPods(ctx, c)
 	if err != nil {
 		logger.Error(err, "unable to fetch pending pods")
 		return err
@@ -182,11 +213,13 @@ func (c *Controller) processPendingPods(ctx context.Context) error {
 	}
 
 	// Compute desired state
+	planStart := time.Now()
 	plan, err := c.planner.Plan(ctx, snapshot.Clone(), pods)
 	if err != nil {
 		logger.Error(err, "unable to plan desired partitioning state")
 		return err
 	}
+	metrics.ObservePlan(c.kind, time.Since(planStart), plan.Explanation.PlacedPods())
 	logger.Info("computed desired partitioning state", "partitioning", plan)
 
 	// Apply partitioning plan
@@ -199,7 +232,8 @@ func (c *Controller) processPendingPods(ctx context.Context) error {
 	return nil
 }
 
-func (c *Controller) fetchPendingPods(ctx context.Context) ([]v1.Pod, error) {
+// fetchPendingPods returns the pods that are pending and that have not been assigned to any node yet
+func fetchPendingPods(ctx context.Context, c client.Reader) ([]v1.Pod, error) {
 	var podList v1.PodList
 	if err := c.List(ctx, &podList, client.MatchingFields{constant.PodPhaseKey: string(v1.PodPending)}); err != nil {
 		return nil, err
@@ -209,26 +243,55 @@ func (c *Controller) fetchPendingPods(ctx context.Context) ([]v1.Pod, error) {
 	}), nil
 }
 
-func (c *Controller) waitingAnyNodeToReportPlan() bool {
-	nodes := c.clusterState.GetNodes()
+// waitingAnyNodeToReportPlan returns true if any node of the cluster has not reported yet
+// the last partitioning plan applied to it. Nodes excluded from partitioning, for instance because
+// they did not report the plan within the plan report timeout, are not waited.
+func waitingAnyNodeToReportPlan(clusterState *state.ClusterState) bool {
+	nodes := clusterState.GetNodes()
 	for _, n := range nodes {
-		if c.waitingToReportPlan(*n.Node()) {
+		if core.IsExcludedFromPartitioning(*n.Node()) {
+			continue
+		}
+		if waitingToReportPlan(*n.Node()) {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *Controller) waitingToReportPlan(n v1.Node) bool {
-	plan, ok := n.Annotations[v1alpha1.AnnotationPartitioningPlan]
-	if !ok || plan == "" {
-		return false
+// waitingToReportPlan returns true if the node has not reported yet the last partitioning plan applied to it.
+func waitingToReportPlan(n v1.Node) bool {
+	_, _, waiting := getPlanWaitingToBeReported(n)
+	return waiting
+}
+
+// getPlanWaitingToBeReported returns the last partitioning plan applied to the node and the time
+// elapsed since its creation, if the node has not reported the plan yet. The returned bool is false
+// if the node is not waiting to report any plan.
+//
+// The node is considered to have reported the plan only if the reported plan is exactly the same as the
+// one in its spec, so that the report of a previous plan can never be mistaken for the report of the last one.
+// A node that reported the failure of the plan is not waited either, so that the partitioner can back it off
+// instead of waiting for a plan that will never be applied.
+func getPlanWaitingToBeReported(n v1.Node) (gpu.PlanId, time.Duration, bool) {
+	specPlanStr, ok := n.Annotations[v1alpha1.AnnotationPartitioningPlan]
+	if !ok || specPlanStr == "" {
+		return gpu.PlanId{}, 0, false
+	}
+	specPlan, err := gpu.ParsePlanId(specPlanStr)
+	if err != nil {
+		// the plan has not been created by the partitioner, so there's nothing to wait for
+		return gpu.PlanId{}, 0, false
+	}
+	// A plan whose status has been reported has been handled by the node, even if it failed
+	if status, ok := gpu.GetLastPlanStatus(n); ok && status.PlanId == specPlan.String() {
+		return gpu.PlanId{}, 0, false
 	}
-	reported, ok := n.Annotations[v1alpha1.AnnotationReportedPartitioningPlan]
-	if !ok {
-		return true
+	reportedPlan, err := gpu.ParsePlanId(n.Annotations[v1alpha1.AnnotationReportedPartitioningPlan])
+	if err == nil && reportedPlan == specPlan {
+		return gpu.PlanId{}, 0, false
 	}
-	return plan != reported
+	return specPlan, time.Since(specPlan.CreationTime()), true
 }
 
 func (c *Controller) SetupWithManager(mgr ctrl.Manager, name string) error {
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner
+
+import (
+	"testing"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+func TestWaitingToReportPlan(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name:        "No plan",
+			annotations: map[string]string{},
+			expected:    false,
+		},
+		{
+			name: "Plan not reported yet",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan: "mig-2",
+			},
+			expected: true,
+		},
+		{
+			name: "Reported plan is older than spec plan",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-1",
+			},
+			expected: true,
+		},
+		{
+			name: "Reported plan has same generation but different kind",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mps-2",
+			},
+			expected: true,
+		},
+		{
+			name: "Reported plan is invalid",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "foo",
+			},
+			expected: true,
+		},
+		{
+			name: "Plan reported",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-2",
+			},
+			expected: false,
+		},
+		{
+			name: "Failure of the spec plan reported",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-1",
+				v1alpha1.AnnotationPartitioningPlanStatus:   `{"planId":"mig-2","outcome":"Failed","time":"2023-01-01T00:00:00Z"}`,
+			},
+			expected: false,
+		},
+		{
+			name: "Failure of an older plan reported",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-1",
+				v1alpha1.AnnotationPartitioningPlanStatus:   `{"planId":"mig-1","outcome":"Failed","time":"2023-01-01T00:00:00Z"}`,
+			},
+			expected: true,
+		},
+		{
+			name: "Legacy plan reported",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "1680000000",
+				v1alpha1.AnnotationReportedPartitioningPlan: "1680000000",
+			},
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).Get()
+			assert.Equal(t, tt.expected, waitingToReportPlan(node))
+		})
+	}
+}
+
+func TestWaitingAnyNodeToReportPlan(t *testing.T) {
+	testCases := []struct {
+		name                string
+		stuckNodeConditions []v1.NodeCondition
+		expected            bool
+	}{
+		{
+			name:                "Node not reporting the plan is waited",
+			stuckNodeConditions: nil,
+			expected:            true,
+		},
+		{
+			name: "Node not reporting the plan within the timeout is not waited",
+			stuckNodeConditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningPlanNotReported, Status: v1.ConditionTrue},
+			},
+			expected: false,
+		},
+		{
+			name: "Node that reported a previous plan after the timeout is waited",
+			stuckNodeConditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningPlanNotReported, Status: v1.ConditionFalse},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			reportedNode := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-2",
+			}).Get()
+			stuckNode := factory.BuildNode("node-2").WithAnnotations(map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:         "mig-2",
+				v1alpha1.AnnotationReportedPartitioningPlan: "mig-1",
+			}).Get()
+			stuckNode.Status.Conditions = tt.stuckNodeConditions
+
+			nodeInfos := make(map[string]framework.NodeInfo)
+			for _, n := range []v1.Node{reportedNode, stuckNode} {
+				n := n
+				nodeInfo := framework.NewNodeInfo()
+				nodeInfo.SetNode(&n)
+				nodeInfos[n.Name] = *nodeInfo
+			}
+			clusterState := state.NewClusterState(nodeInfos)
+
+			assert.Equal(t, tt.expected, waitingAnyNodeToReportPlan(clusterState))
+		})
+	}
+}
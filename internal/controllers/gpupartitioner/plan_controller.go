@@ -0,0 +1,170 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// PlanStatusController updates the status of the PartitioningPlan resources
+// according to the partitioning plans reported by the nodes.
+type PlanStatusController struct {
+	client.Client
+}
+
+func NewPlanStatusController(client client.Client) PlanStatusController {
+	return PlanStatusController{Client: client}
+}
+
+//+kubebuilder:rbac:groups=nos.nebuly.com,resources=partitioningplans,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=nos.nebuly.com,resources=partitioningplans/status,verbs=get;update;patch
+
+func (c *PlanStatusController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch instance
+	var instance v1.Node
+	if err := c.Get(ctx, client.ObjectKey{Name: req.Name}, &instance); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Fetch the plans including the node
+	var planList v1alpha1.PartitioningPlanList
+	if err := c.List(ctx, &planList, client.MatchingFields{constant.PartitioningPlanNodeNameKey: instance.Name}); err != nil {
+		logger.Error(err, "unable to list partitioning plans")
+		return ctrl.Result{}, err
+	}
+
+	// Update the plans whose phase on the node changed
+	for _, plan := range planList.Items {
+		phase, ok := plan.Status.GetNodePhase(instance.Name)
+		if !ok {
+			continue
+		}
+		newPhase, msg, changed := getNodePhase(instance, plan.Name, phase)
+		if !changed {
+			continue
+		}
+		updated := plan.DeepCopy()
+		updated.Status.SetNodePhase(instance.Name, newPhase, msg)
+		if err := c.Status().Patch(ctx, updated, client.MergeFrom(&plan)); err != nil {
+			logger.Error(err, "unable to update partitioning plan status", "plan", plan.Name)
+			return ctrl.Result{}, err
+		}
+		logger.V(1).Info("partitioning plan status updated", "plan", plan.Name, "phase", updated.Status.Phase)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// getNodePhase returns the phase that the node must have in the status of the partitioning plan with the ID
+// provided as argument, given its current phase, together with a message explaining it. It returns false if
+// the phase does not have to change.
+func getNodePhase(
+	node v1.Node,
+	planId string,
+	phase v1alpha1.NodePartitioningPhase,
+) (v1alpha1.NodePartitioningPhase, string, bool) {
+	reported := isPlanReported(node, planId)
+	// Plans that failed on the node are applied if the node then manages to apply them by retrying
+	if phase == v1alpha1.NodePartitioningPhaseFailed && reported {
+		return v1alpha1.NodePartitioningPhaseApplied, "plan reported by the node", true
+	}
+	if phase != v1alpha1.NodePartitioningPhasePending {
+		return phase, "", false
+	}
+	if reported {
+		return v1alpha1.NodePartitioningPhaseApplied, "plan reported by the node", true
+	}
+	if status, ok := gpu.GetLastPlanStatus(node); ok && status.PlanId == planId && status.IsFailed() {
+		return v1alpha1.NodePartitioningPhaseFailed, status.Message(), true
+	}
+	if currentPlan := getCurrentPlanId(node); currentPlan != "" && currentPlan != planId {
+		return v1alpha1.NodePartitioningPhaseFailed, fmt.Sprintf("plan superseded by plan %s", currentPlan), true
+	}
+	return phase, "", false
+}
+
+// getCurrentPlanId returns the ID of the last partitioning plan applied to the node
+func getCurrentPlanId(node v1.Node) string {
+	return node.Annotations[v1alpha1.AnnotationPartitioningPlan]
+}
+
+// isPlanReported returns true if the node reported the partitioning plan with the ID provided as argument.
+//
+// Plans are reported by the mig-agent once the MIG geometry of the plan has been applied, and by the
+// gpu-agent once the NVIDIA device plugin exposes the devices defined by the config of the plan. On nodes
+// with hybrid partitioning, the gpu-agent reports the plan only after the mig-agent applied its MIG geometry.
+func isPlanReported(node v1.Node, planId string) bool {
+	if node.Annotations[v1alpha1.AnnotationPartitioningPlan] != planId {
+		return false
+	}
+	return node.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] == planId
+}
+
+func (c *PlanStatusController) SetupWithManager(mgr ctrl.Manager, name string) error {
+	// Reconcile only nodes with GPU partitioning enabled
+	selectorPredicate, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      v1alpha1.LabelGpuPartitioning,
+			Operator: metav1.LabelSelectorOpExists,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	// Plans are recorded after being applied, so the nodes may report them before the PartitioningPlan
+	// resources get created: reconcile the nodes of each new plan to catch up with their reports.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(&v1.Node{}, builder.WithPredicates(selectorPredicate)).
+		Watches(
+			&source.Kind{Type: &v1alpha1.PartitioningPlan{}},
+			handler.EnqueueRequestsFromMapFunc(mapPlanToNodes),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Complete(c)
+}
+
+// mapPlanToNodes returns the requests for reconciling the nodes targeted
+// by the PartitioningPlan provided as argument
+func mapPlanToNodes(o client.Object) []reconcile.Request {
+	plan, ok := o.(*v1alpha1.PartitioningPlan)
+	if !ok {
+		return nil
+	}
+	res := make([]reconcile.Request, 0, len(plan.Spec.Nodes))
+	for _, n := range plan.Spec.Nodes {
+		res = append(res, reconcile.Request{NamespacedName: client.ObjectKey{Name: n.Name}})
+	}
+	return res
+}
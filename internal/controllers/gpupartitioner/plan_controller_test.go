@@ -0,0 +1,163 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpupartitioner_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nebuly-ai/nos/internal/controllers/gpupartitioner"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlanStatusController__Reconcile(t *testing.T) {
+	planId := gpu.NewPlanId(gpu.PartitioningKindMig)
+	failedStatus := gpu.NewPlanStatus(planId, nil, []gpu.PlanOperationResult{
+		{Type: gpu.PlanOperationCreate, GpuIndex: 0, Profile: "1g.10gb", Quantity: 1, Error: "error"},
+	})
+
+	testCases := []struct {
+		name         string
+		node         v1.Node
+		planId       string
+		initialPhase v1alpha1.NodePartitioningPhase
+		expected     v1alpha1.NodePartitioningPhase
+	}{
+		{
+			name: "MIG node did not report the plan yet, node should stay pending",
+			node: factory.BuildNode("node-1").
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         "plan-2",
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			planId:   "plan-2",
+			expected: v1alpha1.NodePartitioningPhasePending,
+		},
+		{
+			name: "MIG node reported the plan, node should be applied",
+			node: factory.BuildNode("node-1").
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         "plan-2",
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-2",
+				}).
+				Get(),
+			planId:   "plan-2",
+			expected: v1alpha1.NodePartitioningPhaseApplied,
+		},
+		{
+			name: "MIG node got a newer plan, node should be failed",
+			node: factory.BuildNode("node-1").
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         "plan-3",
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-1",
+				}).
+				Get(),
+			planId:   "plan-2",
+			expected: v1alpha1.NodePartitioningPhaseFailed,
+		},
+		{
+			name: "MPS node has the device plugin config of the plan but did not report it yet, node should stay pending",
+			node: factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					constant.LabelNvidiaDevicePluginConfig: "node-1-plan-2",
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan: "plan-2",
+				}).
+				Get(),
+			planId:   "plan-2",
+			expected: v1alpha1.NodePartitioningPhasePending,
+		},
+		{
+			name: "MPS node reported the plan, node should be applied",
+			node: factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					constant.LabelNvidiaDevicePluginConfig: "node-1-plan-2",
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         "plan-2",
+					v1alpha1.AnnotationReportedPartitioningPlan: "plan-2",
+				}).
+				Get(),
+			planId:   "plan-2",
+			expected: v1alpha1.NodePartitioningPhaseApplied,
+		},
+		{
+			name: "MIG node reported the failure of the plan, node should be failed",
+			node: factory.BuildNode("node-1").
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:       planId.String(),
+					v1alpha1.AnnotationPartitioningPlanStatus: failedStatus.String(),
+				}).
+				Get(),
+			planId:   planId.String(),
+			expected: v1alpha1.NodePartitioningPhaseFailed,
+		},
+		{
+			name: "MIG node reported a failed plan after retrying it, node should be applied",
+			node: factory.BuildNode("node-1").
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationPartitioningPlan:         planId.String(),
+					v1alpha1.AnnotationReportedPartitioningPlan: planId.String(),
+					v1alpha1.AnnotationPartitioningPlanStatus:   gpu.NewPlanStatus(planId, nil, nil).String(),
+				}).
+				Get(),
+			planId:       planId.String(),
+			initialPhase: v1alpha1.NodePartitioningPhaseFailed,
+			expected:     v1alpha1.NodePartitioningPhaseApplied,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := v1alpha1.PartitioningPlan{ObjectMeta: metav1.ObjectMeta{Name: tt.planId}}
+			initialPhase := tt.initialPhase
+			if initialPhase == "" {
+				initialPhase = v1alpha1.NodePartitioningPhasePending
+			}
+			plan.Status.SetNodePhase(tt.node.Name, initialPhase, "")
+
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			utilruntime.Must(v1alpha1.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&tt.node, &plan).Build()
+
+			controller := gpupartitioner.NewPlanStatusController(c)
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: tt.node.Name}})
+			assert.NoError(t, err)
+
+			var updated v1alpha1.PartitioningPlan
+			assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: tt.planId}, &updated))
+			phase, ok := updated.Status.GetNodePhase(tt.node.Name)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, phase)
+		})
+	}
+}
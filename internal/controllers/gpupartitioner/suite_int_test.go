@@ -91,7 +91,15 @@ var _ = BeforeSuite(func() {
 	clusterState = state.NewClusterState(map[string]framework.NodeInfo{})
 
 	// Setup Node Controller
-	reporter := gpupartitioner.NewNodeController(k8sClient, scheme.Scheme, migNodeInitializer, clusterState)
+	reporter := gpupartitioner.NewNodeController(
+		k8sClient,
+		scheme.Scheme,
+		k8sManager.GetEventRecorderFor("NodeController"),
+		migNodeInitializer,
+		clusterState,
+		0,
+		false,
+	)
 	Expect(reporter.SetupWithManager(k8sManager, "NodeController")).To(Succeed())
 
 	go func() {
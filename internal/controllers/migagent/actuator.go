@@ -24,6 +24,8 @@ import (
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/util"
 	"github.com/nebuly-ai/nos/pkg/util/predicate"
 	v1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -39,6 +41,9 @@ type MigActuator struct {
 	sharedState  *SharedState
 	nodeName     string
 	devicePlugin gpu.DevicePluginClient
+	// drainer evicts the Pods using the MIG devices that must be deleted. If nil,
+	// MIG devices in use are never deleted.
+	drainer *Drainer
 
 	// lastAppliedPlan is the latest applied plan
 	lastAppliedPlan *plan.MigConfigPlan
@@ -46,13 +51,20 @@ type MigActuator struct {
 	lastAppliedStatus *gpu.StatusAnnotationList
 }
 
-func NewActuator(client client.Client, migClient mig.Client, sharedState *SharedState, nodeName string) MigActuator {
+func NewActuator(
+	client client.Client,
+	migClient mig.Client,
+	sharedState *SharedState,
+	nodeName string,
+	drainer *Drainer,
+) MigActuator {
 	return MigActuator{
 		Client:       client,
 		migClient:    migClient,
 		nodeName:     nodeName,
 		sharedState:  sharedState,
 		devicePlugin: gpu.NewDevicePluginClient(client),
+		drainer:      drainer,
 	}
 }
 
@@ -67,6 +79,8 @@ func (a *MigActuator) updateLastApplied(currentPlan plan.MigConfigPlan, currentS
 
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 func (a *MigActuator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := a.newLogger(ctx)
@@ -86,13 +100,20 @@ func (a *MigActuator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 		return ctrl.Result{}, err
 	}
 
-	// Update last parsed plan ID
-	a.sharedState.lastParsedPlanId = instance.Annotations[v1alpha1.AnnotationPartitioningPlan]
+	// Parse the ID of the plan of the spec. The plan is reported as applied only
+	// once the MIG config of the spec has actually been applied
+	specPlanId, err := gpu.ParsePlanId(instance.Annotations[v1alpha1.AnnotationPartitioningPlan])
+	if err != nil {
+		logger.V(1).Info("unable to parse partitioning plan id", "err", err)
+	}
 
 	// Check if reported status already matches spec
 	statusAnnotations, specAnnotations := gpu.ParseNodeAnnotations(instance)
+	statusAnnotations = statusAnnotations.Filter(mig.IsMigStatusAnnotation)
 	if mig.SpecMatchesStatus(specAnnotations, statusAnnotations) {
 		logger.Info("reported status matches desired MIG config, nothing to do")
+		a.sharedState.SetLastAppliedPlanId(specPlanId)
+		a.setLastPlanStatus(specPlanId, planResults{})
 		return ctrl.Result{}, nil
 	}
 
@@ -108,6 +129,8 @@ func (a *MigActuator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 	// Check if plan has to be applied
 	if configPlan.IsEmpty() {
 		logger.Info("MIG config plan is empty, nothing to do")
+		a.sharedState.SetLastAppliedPlanId(specPlanId)
+		a.setLastPlanStatus(specPlanId, planResults{})
 		return ctrl.Result{}, nil
 	}
 	if configPlan.Equal(a.lastAppliedPlan) && statusAnnotations.Equal(*a.lastAppliedStatus) {
@@ -116,7 +139,11 @@ func (a *MigActuator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 	}
 
 	// Apply MIG config plan
-	res, err := a.apply(ctx, configPlan)
+	res, results, err := a.apply(ctx, &instance, configPlan)
+	if err == nil {
+		a.sharedState.SetLastAppliedPlanId(specPlanId)
+	}
+	a.setLastPlanStatus(specPlanId, results)
 	a.sharedState.OnApplyDone()
 
 	return res, err
@@ -146,24 +173,74 @@ func (a *MigActuator) plan(ctx context.Context, specAnnotations gpu.SpecAnnotati
 	}
 
 	// Compute MIG config plan
-	return plan.NewMigConfigPlan(state, specAnnotations), nil
+	return plan.NewMigConfigPlan(state, specAnnotations, a.getPlacements(ctx)), nil
+}
+
+// getPlacements returns the MIG placements supported by the GPUs and the placements of the existing MIG devices.
+// If they cannot be retrieved, the returned placements are empty and the plan is computed without them.
+func (a *MigActuator) getPlacements(ctx context.Context) plan.Placements {
+	logger := a.newLogger(ctx)
+	supported, err := a.migClient.GetProfilePlacements(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get MIG profile placements, devices will be created at any placement")
+		return plan.Placements{}
+	}
+	devices, err := a.migClient.GetMigDevicePlacements(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get MIG device placements, devices will be created at any placement")
+		return plan.Placements{}
+	}
+	return plan.Placements{
+		Supported: supported,
+		Devices:   devices,
+	}
+}
+
+// setLastPlanStatus stores the status of the plan with the ID provided as argument, computed from the
+// results of its operations, so that the Reporter reports it to the partitioner
+func (a *MigActuator) setLastPlanStatus(planId gpu.PlanId, results planResults) {
+	if planId.IsZero() {
+		return
+	}
+	a.sharedState.SetLastPlanStatus(gpu.NewPlanStatus(planId, results.succeeded, results.failed))
 }
 
-func (a *MigActuator) apply(ctx context.Context, plan plan.MigConfigPlan) (ctrl.Result, error) {
+// apply applies the MIG config plan provided as argument and returns the results of its operations
+func (a *MigActuator) apply(
+	ctx context.Context,
+	node *v1.Node,
+	configPlan plan.MigConfigPlan,
+) (ctrl.Result, planResults, error) {
 	logger := a.newLogger(ctx)
 	logger.Info(
 		"applying MIG config plan",
 		"createOperations",
-		plan.CreateOperations,
+		configPlan.CreateOperations,
 		"deleteOperations",
-		plan.DeleteOperations,
+		configPlan.DeleteOperations,
 	)
 
 	var restartRequired bool
 	var atLeastOneErr bool
+	var results planResults
+
+	// If enabled, drain the used resources that must be deleted
+	deleteOperations := configPlan.DeleteOperations
+	var drained gpu.DeviceList
+	var drainErr error
+	if a.drainer != nil {
+		deleteOperations, drained, drainErr = a.drainUsedResources(ctx, node, configPlan.DeleteOperations)
+		if drainErr != nil {
+			logger.Error(drainErr, "unable to drain used MIG resources")
+			atLeastOneErr = true
+		}
+	}
 
 	// Apply delete operations first
-	for _, op := range plan.DeleteOperations {
+	for _, op := range deleteOperations {
+		if len(op.Resources) == 0 {
+			continue
+		}
 		status := a.applyDeleteOp(ctx, op)
 		if status.Err != nil {
 			logger.Error(status.Err, "unable to fulfill delete operation", "op", op)
@@ -172,10 +249,14 @@ func (a *MigActuator) apply(ctx context.Context, plan plan.MigConfigPlan) (ctrl.
 		if status.PluginRestartRequired {
 			restartRequired = true
 		}
+		results.add(newDeleteOpResult(op, status, drainErr))
+	}
+	if len(drained) > 0 {
+		a.recordDeletedResources(ctx, node, drained)
 	}
 
 	// Apply create operations
-	status := a.applyCreateOps(ctx, plan.CreateOperations)
+	status, createResults := a.applyCreateOps(ctx, configPlan.CreateOperations)
 	if status.Err != nil {
 		logger.Error(status.Err, "unable to fulfill create operations")
 		atLeastOneErr = true
@@ -183,21 +264,129 @@ func (a *MigActuator) apply(ctx context.Context, plan plan.MigConfigPlan) (ctrl.
 	if status.PluginRestartRequired {
 		restartRequired = true
 	}
+	for _, r := range createResults {
+		results.add(r)
+	}
 
 	// Restart the NVIDIA device plugin if necessary
 	if restartRequired {
 		if err := a.restartNvidiaDevicePlugin(ctx); err != nil {
 			logger.Error(err, "unable to restart nvidia device plugin")
-			return ctrl.Result{}, err
+			return ctrl.Result{}, results, err
 		}
 	}
 
 	// Check if any error happened
 	if atLeastOneErr {
-		return ctrl.Result{}, fmt.Errorf("at least one operation failed while applying desired MIG config")
+		return ctrl.Result{}, results, fmt.Errorf("at least one operation failed while applying desired MIG config")
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, results, nil
+}
+
+// planResults collects the results of the operations performed for applying a MIG config plan
+type planResults struct {
+	succeeded []gpu.PlanOperationResult
+	failed    []gpu.PlanOperationResult
+}
+
+func (r *planResults) add(result gpu.PlanOperationResult) {
+	if result.Error != "" {
+		r.failed = append(r.failed, result)
+		return
+	}
+	r.succeeded = append(r.succeeded, result)
+}
+
+// newDeleteOpResult returns the result of the delete operation provided as argument. The operation is
+// considered failed if its status has an error or if any of its resources could not be deleted because
+// it was not free, in which case drainErr, if any, is reported as the reason.
+func newDeleteOpResult(op plan.DeleteOperation, status plan.OperationStatus, drainErr error) gpu.PlanOperationResult {
+	res := gpu.PlanOperationResult{
+		Type:     gpu.PlanOperationDelete,
+		Profile:  op.GetMigProfileName().String(),
+		Quantity: len(op.Resources),
+	}
+	if len(op.Resources) > 0 {
+		res.GpuIndex = op.Resources[0].GpuIndex
+	}
+	var nNotFree int
+	for _, r := range op.Resources {
+		if !r.IsFree() {
+			nNotFree++
+		}
+	}
+	switch {
+	case status.Err != nil:
+		res.Error = status.Err.Error()
+	case nNotFree > 0 && drainErr != nil:
+		res.Error = fmt.Sprintf("%d MIG devices are not free: %s", nNotFree, drainErr)
+	case nNotFree > 0:
+		res.Error = fmt.Sprintf("%d MIG devices are not free", nNotFree)
+	}
+	return res
+}
+
+// drainUsedResources evicts the Pods using the resources of the delete operations provided as argument and
+// waits for the resources to be released. It returns a copy of the delete operations in which the drained
+// resources are marked as free, together with the list of drained resources.
+//
+// If the drain fails, the returned delete operations are equal to the ones provided as argument.
+func (a *MigActuator) drainUsedResources(
+	ctx context.Context,
+	node *v1.Node,
+	ops plan.DeleteOperationList,
+) (plan.DeleteOperationList, gpu.DeviceList, error) {
+	used := make(gpu.DeviceList, 0)
+	for _, op := range ops {
+		for _, r := range op.Resources {
+			if r.IsUsed() {
+				used = append(used, r)
+			}
+		}
+	}
+	if len(used) == 0 {
+		return ops, nil, nil
+	}
+
+	if err := a.drainer.Drain(ctx, node, used); err != nil {
+		return ops, nil, err
+	}
+
+	res := make(plan.DeleteOperationList, len(ops))
+	for i, op := range ops {
+		resources := make(gpu.DeviceList, len(op.Resources))
+		for j, r := range op.Resources {
+			if r.IsUsed() {
+				r.Status = resource.StatusFree
+			}
+			resources[j] = r
+		}
+		res[i] = plan.DeleteOperation{Resources: resources}
+	}
+	return res, used, nil
+}
+
+// recordDeletedResources records an Event on the node for each of the drained resources
+// provided as argument that do not exist anymore
+func (a *MigActuator) recordDeletedResources(ctx context.Context, node *v1.Node, drained gpu.DeviceList) {
+	logger := a.newLogger(ctx)
+	current, err := a.migClient.GetMigDevices(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get MIG devices, cannot record deleted MIG resources")
+		return
+	}
+	existing := make(map[string]bool, len(current))
+	for _, r := range current {
+		existing[r.DeviceId] = true
+	}
+	deleted := make(gpu.DeviceList, 0, len(drained))
+	for _, r := range drained {
+		if !existing[r.DeviceId] {
+			deleted = append(deleted, r)
+		}
+	}
+	a.drainer.RecordDeleted(node, deleted)
 }
 
 // restartNvidiaDevicePlugin deletes the Nvidia Device Plugin pod and blocks until it is successfully recreated by
@@ -253,12 +442,18 @@ func (a *MigActuator) applyDeleteOp(ctx context.Context, op plan.DeleteOperation
 	}
 }
 
-func (a *MigActuator) applyCreateOps(ctx context.Context, ops plan.CreateOperationList) plan.OperationStatus {
+// applyCreateOps applies the create operations provided as argument, returning their overall status
+// together with the result of each of them
+func (a *MigActuator) applyCreateOps(
+	ctx context.Context,
+	ops plan.CreateOperationList,
+) (plan.OperationStatus, []gpu.PlanOperationResult) {
 	logger := a.newLogger(ctx)
 	logger.Info("applying create operations", "migProfiles", ops)
 
 	profileList := ops.Flatten()
 	created, err := a.migClient.CreateMigDevices(ctx, profileList)
+	results := newCreateOpResults(ops, created, err)
 	if err != nil {
 		nCreated := len(created)
 		return plan.OperationStatus{
@@ -269,12 +464,48 @@ func (a *MigActuator) applyCreateOps(ctx context.Context, ops plan.CreateOperati
 				len(profileList),
 				err,
 			),
-		}
+		}, results
 	}
 	return plan.OperationStatus{
 		PluginRestartRequired: true,
 		Err:                   nil,
+	}, results
+}
+
+// newCreateOpResults returns the result of each of the create operations provided as argument, given the
+// profiles that have been created and the error returned while creating them, if any
+func newCreateOpResults(ops plan.CreateOperationList, created mig.ProfileList, err error) []gpu.PlanOperationResult {
+	type profileKey struct {
+		gpuIndex int
+		name     mig.ProfileName
+	}
+	nCreated := make(map[profileKey]int)
+	for _, p := range created {
+		nCreated[profileKey{gpuIndex: p.GpuIndex, name: p.Name}]++
+	}
+
+	results := make([]gpu.PlanOperationResult, 0, len(ops))
+	for _, op := range ops {
+		if op.Quantity == 0 {
+			continue
+		}
+		res := gpu.PlanOperationResult{
+			Type:     gpu.PlanOperationCreate,
+			GpuIndex: op.MigProfile.GpuIndex,
+			Profile:  op.MigProfile.Name.String(),
+			Quantity: op.Quantity,
+		}
+		if err != nil {
+			key := profileKey{gpuIndex: op.MigProfile.GpuIndex, name: op.MigProfile.Name}
+			n := util.Min(nCreated[key], op.Quantity)
+			nCreated[key] -= n
+			if n < op.Quantity {
+				res.Error = fmt.Sprintf("created %d out of %d MIG devices: %s", n, op.Quantity, err)
+			}
+		}
+		results = append(results, res)
 	}
+	return results
 }
 
 func (a *MigActuator) SetupWithManager(mgr ctrl.Manager, controllerName string) error {
@@ -18,12 +18,21 @@ package migagent
 
 import (
 	"context"
+	"fmt"
 	"github.com/nebuly-ai/nos/internal/controllers/migagent/plan"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
 	migtest "github.com/nebuly-ai/nos/pkg/test/mocks/mig"
+	mockedresource "github.com/nebuly-ai/nos/pkg/test/mocks/resource"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	"testing"
+	"time"
 )
 
 func TestMigActuator_applyDeleteOp(t *testing.T) {
@@ -119,6 +128,181 @@ func TestMigActuator_applyDeleteOp(t *testing.T) {
 	}
 }
 
+func TestMigActuator_drainUsedResources(t *testing.T) {
+	freeDevice := gpu.Device{
+		Device: resource.Device{
+			ResourceName: "nvidia.com/mig-1g.10gb",
+			DeviceId:     "uid-1",
+			Status:       resource.StatusFree,
+		},
+		GpuIndex: 0,
+	}
+	usedDevice := gpu.Device{
+		Device: resource.Device{
+			ResourceName: "nvidia.com/mig-1g.10gb",
+			DeviceId:     "uid-2",
+			Status:       resource.StatusUsed,
+		},
+		GpuIndex: 0,
+	}
+	drainedDevice := usedDevice
+	drainedDevice.Status = resource.StatusFree
+
+	testCases := []struct {
+		name          string
+		ops           plan.DeleteOperationList
+		usedDevices   []resource.Device
+		expectedOps   plan.DeleteOperationList
+		expectedDrain gpu.DeviceList
+		errorExpected bool
+	}{
+		{
+			name:          "No used resources, nothing to drain",
+			ops:           plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice}}},
+			expectedOps:   plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice}}},
+			expectedDrain: nil,
+		},
+		{
+			name:          "Drained resources are marked as free",
+			ops:           plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice, usedDevice}}},
+			usedDevices:   []resource.Device{},
+			expectedOps:   plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice, drainedDevice}}},
+			expectedDrain: gpu.DeviceList{usedDevice},
+		},
+		{
+			name:          "Drain fails, operations are unchanged",
+			ops:           plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice, usedDevice}}},
+			usedDevices:   []resource.Device{usedDevice.Device},
+			expectedOps:   plan.DeleteOperationList{{Resources: gpu.DeviceList{freeDevice, usedDevice}}},
+			expectedDrain: nil,
+			errorExpected: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			resourceClient := mockedresource.Client{}
+			resourceClient.On("GetPodsUsingDevices", mock.Anything, mock.Anything).Return([]types.NamespacedName{}, nil)
+			resourceClient.On("GetUsedDevices", mock.Anything).Return(tt.usedDevices, nil)
+			drainer := NewDrainer(fake.NewSimpleClientset(), &resourceClient, record.NewFakeRecorder(10), 20*time.Millisecond)
+			drainer.pollInterval = 5 * time.Millisecond
+			actuator := MigActuator{drainer: drainer}
+
+			node := factory.BuildNode("node-1").Get()
+			ops, drained, err := actuator.drainUsedResources(context.Background(), &node, tt.ops)
+			if tt.errorExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedOps, ops)
+			assert.Equal(t, tt.expectedDrain, drained)
+			assert.Equal(t, resource.StatusUsed, usedDevice.Status)
+		})
+	}
+}
+
+func TestNewCreateOpResults(t *testing.T) {
+	ops := plan.CreateOperationList{
+		{MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1g10gb}, Quantity: 2},
+		{MigProfile: mig.Profile{GpuIndex: 1, Name: mig.Profile2g20gb}, Quantity: 1},
+		{MigProfile: mig.Profile{GpuIndex: 1, Name: mig.Profile1g10gb}, Quantity: 0},
+	}
+
+	t.Run("All profiles created", func(t *testing.T) {
+		results := newCreateOpResults(ops, ops.Flatten(), nil)
+		assert.Len(t, results, 2)
+		for _, r := range results {
+			assert.Empty(t, r.Error)
+		}
+	})
+
+	t.Run("Some profiles could not be created", func(t *testing.T) {
+		created := mig.ProfileList{{GpuIndex: 0, Name: mig.Profile1g10gb}}
+		results := newCreateOpResults(ops, created, fmt.Errorf("an error"))
+		assert.Equal(t, []gpu.PlanOperationResult{
+			{
+				Type:     gpu.PlanOperationCreate,
+				GpuIndex: 0,
+				Profile:  "1g.10gb",
+				Quantity: 2,
+				Error:    "created 1 out of 2 MIG devices: an error",
+			},
+			{
+				Type:     gpu.PlanOperationCreate,
+				GpuIndex: 1,
+				Profile:  "2g.20gb",
+				Quantity: 1,
+				Error:    "created 0 out of 1 MIG devices: an error",
+			},
+		}, results)
+	})
+}
+
+func TestNewDeleteOpResult(t *testing.T) {
+	op := plan.DeleteOperation{
+		Resources: gpu.DeviceList{
+			{
+				Device: resource.Device{
+					ResourceName: "nvidia.com/mig-1g.10gb",
+					DeviceId:     "uid-1",
+					Status:       resource.StatusFree,
+				},
+				GpuIndex: 1,
+			},
+			{
+				Device: resource.Device{
+					ResourceName: "nvidia.com/mig-1g.10gb",
+					DeviceId:     "uid-2",
+					Status:       resource.StatusUsed,
+				},
+				GpuIndex: 1,
+			},
+		},
+	}
+	freeOp := plan.DeleteOperation{Resources: op.Resources[:1]}
+
+	testCases := []struct {
+		name          string
+		op            plan.DeleteOperation
+		status        plan.OperationStatus
+		drainErr      error
+		expectedError string
+	}{
+		{
+			name:          "All resources deleted",
+			op:            freeOp,
+			expectedError: "",
+		},
+		{
+			name:          "Delete error",
+			op:            freeOp,
+			status:        plan.OperationStatus{Err: fmt.Errorf("an error")},
+			expectedError: "an error",
+		},
+		{
+			name:          "Used resources are not deleted",
+			op:            op,
+			expectedError: "1 MIG devices are not free",
+		},
+		{
+			name:          "Used resources could not be drained",
+			op:            op,
+			drainErr:      fmt.Errorf("drain error"),
+			expectedError: "1 MIG devices are not free: drain error",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newDeleteOpResult(tt.op, tt.status, tt.drainErr)
+			assert.Equal(t, gpu.PlanOperationDelete, res.Type)
+			assert.Equal(t, 1, res.GpuIndex)
+			assert.Equal(t, "1g.10gb", res.Profile)
+			assert.Equal(t, len(tt.op.Resources), res.Quantity)
+			assert.Equal(t, tt.expectedError, res.Error)
+		})
+	}
+}
+
 //func TestMigActuator_applyCreateOps(t *testing.T) {
 //	testCases := []struct {
 //		name                string
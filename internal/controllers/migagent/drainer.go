@@ -0,0 +1,243 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migagent
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultDrainPollInterval = 1 * time.Second
+
+// Drainer evicts the Pods using MIG devices that must be deleted, so that
+// the devices can be deleted once the Pods have released them.
+type Drainer struct {
+	kubeClient     kubernetes.Interface
+	resourceClient resource.Client
+	recorder       record.EventRecorder
+	timeout        time.Duration
+	pollInterval   time.Duration
+}
+
+func NewDrainer(
+	kubeClient kubernetes.Interface,
+	resourceClient resource.Client,
+	recorder record.EventRecorder,
+	timeout time.Duration,
+) *Drainer {
+	return &Drainer{
+		kubeClient:     kubeClient,
+		resourceClient: resourceClient,
+		recorder:       recorder,
+		timeout:        timeout,
+		pollInterval:   defaultDrainPollInterval,
+	}
+}
+
+// Drain evicts the Pods using the devices provided as argument and blocks until the devices are released
+// according to the Kubelet PodResources API, or until the drain timeout expires.
+//
+// Pods are evicted through the Eviction API, so PodDisruptionBudgets are respected, starting from the ones
+// with the lowest priority. Pods with system-critical priority are never evicted.
+// Each step of the drain is recorded as an Event on the node.
+func (d *Drainer) Drain(ctx context.Context, node *v1.Node, devices gpu.DeviceList) error {
+	logger := log.FromContext(ctx).WithName("Drainer")
+	if len(devices) == 0 {
+		return nil
+	}
+	deviceIds := getDeviceIds(devices)
+
+	// Fetch the Pods using the devices
+	podKeys, err := d.resourceClient.GetPodsUsingDevices(ctx, deviceIds)
+	if err != nil {
+		return fmt.Errorf("unable to get Pods using MIG devices: %s", err)
+	}
+	pods := make([]v1.Pod, 0, len(podKeys))
+	for _, key := range podKeys {
+		pod, err := d.kubeClient.CoreV1().Pods(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get Pod %s: %s", key, err)
+		}
+		pods = append(pods, *pod)
+	}
+
+	// Check priorities before evicting anything, so that the drain is never partially applied
+	// because of a Pod that cannot be evicted
+	sort.SliceStable(pods, func(i, j int) bool {
+		return getPodPriority(pods[i]) < getPodPriority(pods[j])
+	})
+	for _, pod := range pods {
+		if getPodPriority(pod) >= scheduling.SystemCriticalPriority {
+			d.recorder.Eventf(
+				node,
+				v1.EventTypeWarning,
+				v1alpha1.ReasonMigEvictionBlocked,
+				"Pod %s/%s using MIG devices to delete has system-critical priority and cannot be evicted",
+				pod.Namespace,
+				pod.Name,
+			)
+			return fmt.Errorf("pod %s/%s has system-critical priority and cannot be evicted", pod.Namespace, pod.Name)
+		}
+	}
+
+	// Evict Pods
+	if len(pods) > 0 {
+		d.recorder.Eventf(
+			node,
+			v1.EventTypeNormal,
+			v1alpha1.ReasonMigDrainStarted,
+			"Evicting %d Pods using MIG devices to delete: %s",
+			len(pods),
+			strings.Join(deviceIds, ", "),
+		)
+	}
+	for _, pod := range pods {
+		logger.Info("evicting pod", "pod", pod.Name, "namespace", pod.Namespace)
+		err = d.evict(ctx, pod)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if errors.IsTooManyRequests(err) {
+			d.recorder.Eventf(
+				node,
+				v1.EventTypeWarning,
+				v1alpha1.ReasonMigEvictionBlocked,
+				"Eviction of Pod %s/%s blocked by a PodDisruptionBudget",
+				pod.Namespace,
+				pod.Name,
+			)
+			return fmt.Errorf("eviction of pod %s/%s blocked by a PodDisruptionBudget: %s", pod.Namespace, pod.Name, err)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to evict pod %s/%s: %s", pod.Namespace, pod.Name, err)
+		}
+		d.recorder.Eventf(
+			node,
+			v1.EventTypeNormal,
+			v1alpha1.ReasonMigPodEvicted,
+			"Evicted Pod %s/%s for deleting the MIG devices it uses",
+			pod.Namespace,
+			pod.Name,
+		)
+	}
+
+	// Wait for the devices to be released
+	logger.Info("waiting for MIG devices to be released", "devices", deviceIds)
+	err = wait.PollImmediateWithContext(ctx, d.pollInterval, d.timeout, func(ctx context.Context) (bool, error) {
+		released, err := d.devicesReleased(ctx, deviceIds)
+		if err != nil {
+			logger.Error(err, "unable to check if MIG devices have been released")
+		}
+		return released, nil
+	})
+	if err != nil {
+		d.recorder.Eventf(
+			node,
+			v1.EventTypeWarning,
+			v1alpha1.ReasonMigDrainTimeout,
+			"MIG devices have not been released within %s: %s",
+			d.timeout,
+			strings.Join(deviceIds, ", "),
+		)
+		return fmt.Errorf("MIG devices have not been released within %s: %s", d.timeout, err)
+	}
+	d.recorder.Eventf(
+		node,
+		v1.EventTypeNormal,
+		v1alpha1.ReasonMigDevicesReleased,
+		"MIG devices to delete have been released: %s",
+		strings.Join(deviceIds, ", "),
+	)
+
+	return nil
+}
+
+// RecordDeleted records an Event on the node for each of the drained devices provided as argument
+// that have been deleted.
+func (d *Drainer) RecordDeleted(node *v1.Node, devices gpu.DeviceList) {
+	for _, device := range devices {
+		d.recorder.Eventf(
+			node,
+			v1.EventTypeNormal,
+			v1alpha1.ReasonMigDeviceDeleted,
+			"Deleted drained MIG device %s (%s) of GPU %d",
+			device.DeviceId,
+			device.ResourceName,
+			device.GpuIndex,
+		)
+	}
+}
+
+func (d *Drainer) evict(ctx context.Context, pod v1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+	return d.kubeClient.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+}
+
+func (d *Drainer) devicesReleased(ctx context.Context, deviceIds []string) (bool, error) {
+	used, err := d.resourceClient.GetUsedDevices(ctx)
+	if err != nil {
+		return false, err
+	}
+	lookup := make(map[string]bool, len(deviceIds))
+	for _, id := range deviceIds {
+		lookup[id] = true
+	}
+	for _, device := range used {
+		if lookup[device.DeviceId] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func getDeviceIds(devices gpu.DeviceList) []string {
+	res := make([]string, len(devices))
+	for i, d := range devices {
+		res[i] = d.DeviceId
+	}
+	return res
+}
+
+func getPodPriority(pod v1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
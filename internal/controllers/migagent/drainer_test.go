@@ -0,0 +1,206 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migagent
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	mockedresource "github.com/nebuly-ai/nos/pkg/test/mocks/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrainer_Drain(t *testing.T) {
+	devices := gpu.DeviceList{
+		{
+			Device: resource.Device{
+				ResourceName: "nvidia.com/mig-1g.10gb",
+				DeviceId:     "uid-1",
+				Status:       resource.StatusUsed,
+			},
+			GpuIndex: 0,
+		},
+		{
+			Device: resource.Device{
+				ResourceName: "nvidia.com/mig-2g.20gb",
+				DeviceId:     "uid-2",
+				Status:       resource.StatusUsed,
+			},
+			GpuIndex: 0,
+		},
+	}
+	lowPriorityPod := factory.BuildPod("ns-1", "pod-low").WithPriority(1).Get()
+	highPriorityPod := factory.BuildPod("ns-2", "pod-high").WithPriority(100).Get()
+	criticalPod := factory.BuildPod("kube-system", "pod-critical").WithPriority(scheduling.SystemCriticalPriority).Get()
+
+	testCases := []struct {
+		name           string
+		pods           []v1.Pod
+		podsUsingDevs  []types.NamespacedName
+		usedDevices    []resource.Device
+		evictionErrors map[string]error
+
+		expectedEvictions []string
+		expectedReasons   []string
+		expectedErr       bool
+	}{
+		{
+			name:              "Devices not used by any Pod",
+			podsUsingDevs:     []types.NamespacedName{},
+			usedDevices:       []resource.Device{},
+			expectedEvictions: nil,
+			expectedReasons:   []string{v1alpha1.ReasonMigDevicesReleased},
+			expectedErr:       false,
+		},
+		{
+			name: "Pods are evicted starting from the lowest priority",
+			pods: []v1.Pod{highPriorityPod, lowPriorityPod},
+			podsUsingDevs: []types.NamespacedName{
+				{Namespace: highPriorityPod.Namespace, Name: highPriorityPod.Name},
+				{Namespace: lowPriorityPod.Namespace, Name: lowPriorityPod.Name},
+			},
+			usedDevices:       []resource.Device{},
+			expectedEvictions: []string{"ns-1/pod-low", "ns-2/pod-high"},
+			expectedReasons: []string{
+				v1alpha1.ReasonMigDrainStarted,
+				v1alpha1.ReasonMigPodEvicted,
+				v1alpha1.ReasonMigPodEvicted,
+				v1alpha1.ReasonMigDevicesReleased,
+			},
+			expectedErr: false,
+		},
+		{
+			name: "Pods that do not exist anymore are ignored",
+			pods: []v1.Pod{lowPriorityPod},
+			podsUsingDevs: []types.NamespacedName{
+				{Namespace: "ns-1", Name: "deleted"},
+				{Namespace: lowPriorityPod.Namespace, Name: lowPriorityPod.Name},
+			},
+			usedDevices:       []resource.Device{},
+			expectedEvictions: []string{"ns-1/pod-low"},
+			expectedReasons: []string{
+				v1alpha1.ReasonMigDrainStarted,
+				v1alpha1.ReasonMigPodEvicted,
+				v1alpha1.ReasonMigDevicesReleased,
+			},
+			expectedErr: false,
+		},
+		{
+			name: "Pods with system-critical priority are never evicted",
+			pods: []v1.Pod{lowPriorityPod, criticalPod},
+			podsUsingDevs: []types.NamespacedName{
+				{Namespace: lowPriorityPod.Namespace, Name: lowPriorityPod.Name},
+				{Namespace: criticalPod.Namespace, Name: criticalPod.Name},
+			},
+			expectedEvictions: nil,
+			expectedReasons:   []string{v1alpha1.ReasonMigEvictionBlocked},
+			expectedErr:       true,
+		},
+		{
+			name: "Eviction blocked by PodDisruptionBudget",
+			pods: []v1.Pod{lowPriorityPod, highPriorityPod},
+			podsUsingDevs: []types.NamespacedName{
+				{Namespace: lowPriorityPod.Namespace, Name: lowPriorityPod.Name},
+				{Namespace: highPriorityPod.Namespace, Name: highPriorityPod.Name},
+			},
+			evictionErrors: map[string]error{
+				"ns-2/pod-high": errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0),
+			},
+			expectedEvictions: []string{"ns-1/pod-low", "ns-2/pod-high"},
+			expectedReasons: []string{
+				v1alpha1.ReasonMigDrainStarted,
+				v1alpha1.ReasonMigPodEvicted,
+				v1alpha1.ReasonMigEvictionBlocked,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Devices are not released within the timeout",
+			pods: []v1.Pod{lowPriorityPod},
+			podsUsingDevs: []types.NamespacedName{
+				{Namespace: lowPriorityPod.Namespace, Name: lowPriorityPod.Name},
+			},
+			usedDevices:       []resource.Device{devices[1].Device},
+			expectedEvictions: []string{"ns-1/pod-low"},
+			expectedReasons: []string{
+				v1alpha1.ReasonMigDrainStarted,
+				v1alpha1.ReasonMigPodEvicted,
+				v1alpha1.ReasonMigDrainTimeout,
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := make([]runtime.Object, len(tt.pods))
+			for i := range tt.pods {
+				objects[i] = &tt.pods[i]
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			var evictions []string
+			kubeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+				key := eviction.Namespace + "/" + eviction.Name
+				evictions = append(evictions, key)
+				return true, nil, tt.evictionErrors[key]
+			})
+
+			resourceClient := mockedresource.Client{}
+			resourceClient.On("GetPodsUsingDevices", mock.Anything, []string{"uid-1", "uid-2"}).Return(tt.podsUsingDevs, nil)
+			resourceClient.On("GetUsedDevices", mock.Anything).Return(tt.usedDevices, nil).Maybe()
+
+			recorder := record.NewFakeRecorder(10)
+			drainer := NewDrainer(kubeClient, &resourceClient, recorder, 50*time.Millisecond)
+			drainer.pollInterval = 10 * time.Millisecond
+
+			node := factory.BuildNode("node-1").Get()
+			err := drainer.Drain(context.Background(), &node, devices)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedEvictions, evictions)
+
+			close(recorder.Events)
+			reasons := make([]string, 0)
+			for e := range recorder.Events {
+				reasons = append(reasons, strings.Fields(e)[1])
+			}
+			assert.Equal(t, tt.expectedReasons, reasons)
+		})
+	}
+}
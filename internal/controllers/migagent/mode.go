@@ -0,0 +1,369 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migagent
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/util/predicate"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+const defaultMigModeRetryInterval = 30 * time.Second
+
+// MigModeManager enables or disables MIG mode on the GPUs of the node according to the desired
+// MIG mode specified by the v1alpha1.AnnotationMigModeSpecFormat annotations of the node, and reports
+// the MIG mode of each GPU through the v1alpha1.AnnotationMigModeStatusFormat annotations.
+//
+// Before changing the MIG mode of a GPU, the devices of the GPU in use are drained and, when disabling
+// MIG mode, its MIG devices are deleted. If the GPU needs to be reset for the new mode to take effect,
+// its status is reported as pending-reset until the reset happens.
+type MigModeManager struct {
+	client.Client
+	nvmlClient     nvml.Client
+	migClient      mig.Client
+	resourceClient resource.Client
+	sharedState    *SharedState
+	nodeName       string
+	devicePlugin   gpu.DevicePluginClient
+	recorder       record.EventRecorder
+	// drainer evicts the Pods using the devices of the GPUs whose MIG mode must be changed. If nil,
+	// the MIG mode of a GPU is changed only once its devices are released.
+	drainer       *Drainer
+	retryInterval time.Duration
+}
+
+func NewMigModeManager(
+	client client.Client,
+	nvmlClient nvml.Client,
+	migClient mig.Client,
+	resourceClient resource.Client,
+	sharedState *SharedState,
+	nodeName string,
+	recorder record.EventRecorder,
+	drainer *Drainer,
+) MigModeManager {
+	return MigModeManager{
+		Client:         client,
+		nvmlClient:     nvmlClient,
+		migClient:      migClient,
+		resourceClient: resourceClient,
+		sharedState:    sharedState,
+		nodeName:       nodeName,
+		devicePlugin:   gpu.NewDevicePluginClient(client),
+		recorder:       recorder,
+		drainer:        drainer,
+		retryInterval:  defaultMigModeRetryInterval,
+	}
+}
+
+func (m *MigModeManager) newLogger(ctx context.Context) logr.Logger {
+	return log.FromContext(ctx).WithName("MigModeManager")
+}
+
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+func (m *MigModeManager) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := m.newLogger(ctx)
+
+	m.sharedState.Lock()
+	defer m.sharedState.Unlock()
+
+	var instance v1.Node
+	if err := m.Client.Get(ctx, client.ObjectKey{Name: req.Name, Namespace: req.Namespace}, &instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	gpuIndexes, err := m.nvmlClient.GetGpuIndexes()
+	if err != nil {
+		logger.Error(err, "unable to get GPU indexes")
+		return ctrl.Result{}, err
+	}
+
+	desiredModes := mig.GetDesiredModes(instance)
+	reportedStatuses := mig.GetModeStatuses(instance)
+	statuses := make(map[int]mig.ModeStatus, len(gpuIndexes))
+	var restartRequired bool
+	for _, gpuIndex := range gpuIndexes {
+		status, changed, ok := m.reconcileGpu(ctx, &instance, gpuIndex, desiredModes)
+		if !ok {
+			continue
+		}
+		statuses[gpuIndex] = status
+		// The device plugin must be restarted for exposing the devices corresponding to the new MIG mode,
+		// either because the mode has just been changed or because the pending change took effect
+		if changed || reportedStatuses[gpuIndex] == mig.ModeStatusPendingReset && isModeStatus(status) {
+			restartRequired = true
+		}
+	}
+
+	if restartRequired {
+		logger.Info("MIG mode changed, restarting NVIDIA device plugin")
+		if err := m.devicePlugin.Restart(ctx, m.nodeName, 1*time.Minute); err != nil {
+			logger.Error(err, "unable to restart nvidia device plugin")
+			return ctrl.Result{}, err
+		}
+		m.sharedState.OnApplyDone()
+	}
+
+	if err := m.reportStatuses(ctx, &instance, reportedStatuses, statuses); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for _, status := range statuses {
+		if !isModeStatus(status) {
+			return ctrl.Result{RequeueAfter: m.retryInterval}, nil
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileGpu changes the MIG mode of the GPU with the index provided as argument if it does not match
+// its desired mode, if any. It returns the resulting status of the MIG mode of the GPU, true if the MIG mode
+// of the GPU has been changed, and false if the MIG mode of the GPU cannot be retrieved, which happens
+// for instance when the GPU does not support MIG.
+func (m *MigModeManager) reconcileGpu(
+	ctx context.Context,
+	node *v1.Node,
+	gpuIndex int,
+	desiredModes map[int]mig.Mode,
+) (mig.ModeStatus, bool, bool) {
+	logger := m.newLogger(ctx).WithValues("gpuIndex", gpuIndex)
+
+	current, gpuErr := m.nvmlClient.GetMigMode(gpuIndex)
+	if gpuErr != nil {
+		logger.V(1).Info("unable to get MIG mode, skipping GPU", "err", gpuErr)
+		return "", false, false
+	}
+	desired, ok := desiredModes[gpuIndex]
+	if !ok {
+		return getModeStatus(current), false, true
+	}
+	if current.PendingEnabled == desired.IsEnabled() {
+		return getModeStatus(current), false, true
+	}
+
+	// Make sure the devices of the GPU are not in use
+	used, err := m.getUsedDevices(ctx, gpuIndex, current.Enabled)
+	if err != nil {
+		logger.Error(err, "unable to get used devices")
+		return mig.ModeStatusFailed, false, true
+	}
+	if len(used) > 0 {
+		if m.drainer == nil {
+			logger.Info("GPU is in use, waiting for its devices to be released before changing MIG mode", "devices", used)
+			return mig.ModeStatusDraining, false, true
+		}
+		if err = m.drainer.Drain(ctx, node, used); err != nil {
+			logger.Error(err, "unable to drain GPU devices")
+			return mig.ModeStatusDraining, false, true
+		}
+	}
+
+	// MIG mode can be disabled only if the GPU has no MIG devices
+	if current.Enabled && !desired.IsEnabled() {
+		if err = m.deleteMigDevices(ctx, gpuIndex); err != nil {
+			logger.Error(err, "unable to delete MIG devices")
+			m.recordChangeFailed(node, gpuIndex, desired, err)
+			return mig.ModeStatusFailed, false, true
+		}
+	}
+
+	// Change MIG mode
+	logger.Info("changing MIG mode", "mode", desired)
+	if err := m.nvmlClient.SetMigMode(gpuIndex, desired.IsEnabled()); err != nil {
+		logger.Error(err, "unable to change MIG mode", "mode", desired)
+		m.recordChangeFailed(node, gpuIndex, desired, err)
+		return mig.ModeStatusFailed, false, true
+	}
+	updated, gpuErr := m.nvmlClient.GetMigMode(gpuIndex)
+	if gpuErr != nil {
+		logger.Error(gpuErr, "unable to get MIG mode")
+		return mig.ModeStatusFailed, false, true
+	}
+	if updated.IsPendingReset() {
+		logger.Info("MIG mode change is pending, GPU requires a reset", "mode", desired)
+		m.recorder.Eventf(
+			node,
+			v1.EventTypeWarning,
+			v1alpha1.ReasonMigModePendingReset,
+			"MIG mode of GPU %d set to %s, the change takes effect after the GPU is reset",
+			gpuIndex,
+			desired,
+		)
+		return mig.ModeStatusPendingReset, false, true
+	}
+	m.recorder.Eventf(
+		node,
+		v1.EventTypeNormal,
+		v1alpha1.ReasonMigModeChanged,
+		"MIG mode of GPU %d changed to %s",
+		gpuIndex,
+		desired,
+	)
+	return getModeStatus(updated), updated.Enabled != current.Enabled, true
+}
+
+// getUsedDevices returns the devices of the GPU with the index provided as argument that are in use:
+// its MIG devices if the GPU has MIG mode enabled, or the GPU itself otherwise
+func (m *MigModeManager) getUsedDevices(ctx context.Context, gpuIndex int, migEnabled bool) (gpu.DeviceList, error) {
+	if migEnabled {
+		devices, err := m.migClient.GetUsedMigDevices(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res := make(gpu.DeviceList, 0)
+		for _, d := range devices {
+			if d.GpuIndex == gpuIndex {
+				res = append(res, d)
+			}
+		}
+		return res, nil
+	}
+
+	devices, err := m.resourceClient.GetUsedDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(gpu.DeviceList, 0)
+	for _, d := range devices {
+		if d.ResourceName != constant.ResourceNvidiaGPU {
+			continue
+		}
+		index, err := m.nvmlClient.GetGpuIndex(d.DeviceId)
+		if err != nil {
+			return nil, err
+		}
+		if index == gpuIndex {
+			res = append(res, gpu.Device{Device: d, GpuIndex: index})
+		}
+	}
+	return res, nil
+}
+
+// deleteMigDevices deletes all the MIG devices of the GPU with the index provided as argument
+func (m *MigModeManager) deleteMigDevices(ctx context.Context, gpuIndex int) error {
+	devices, err := m.migClient.GetMigDevices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		if d.GpuIndex != gpuIndex {
+			continue
+		}
+		if err = m.migClient.DeleteMigDevice(ctx, d); gpu.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("unable to delete MIG device %s: %s", d.DeviceId, err)
+		}
+	}
+	return nil
+}
+
+func (m *MigModeManager) recordChangeFailed(node *v1.Node, gpuIndex int, desired mig.Mode, err error) {
+	m.recorder.Eventf(
+		node,
+		v1.EventTypeWarning,
+		v1alpha1.ReasonMigModeChangeFailed,
+		"Unable to set MIG mode of GPU %d to %s: %s",
+		gpuIndex,
+		desired,
+		err,
+	)
+}
+
+// reportStatuses updates the MIG mode status annotations of the node with the statuses provided as argument,
+// if they differ from the reported ones
+func (m *MigModeManager) reportStatuses(
+	ctx context.Context,
+	node *v1.Node,
+	reported map[int]mig.ModeStatus,
+	statuses map[int]mig.ModeStatus,
+) error {
+	logger := m.newLogger(ctx)
+	if modeStatusesEqual(reported, statuses) {
+		return nil
+	}
+
+	updated := node.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	for gpuIndex := range reported {
+		delete(updated.Annotations, fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, gpuIndex))
+	}
+	for gpuIndex, status := range statuses {
+		updated.Annotations[fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, gpuIndex)] = string(status)
+	}
+	if err := m.Client.Patch(ctx, updated, client.MergeFrom(node)); err != nil {
+		logger.Error(err, "unable to update node MIG mode status annotations")
+		return err
+	}
+	logger.Info("reported MIG mode status", "statuses", statuses)
+	return nil
+}
+
+func getModeStatus(mode nvml.MigMode) mig.ModeStatus {
+	if mode.IsPendingReset() {
+		return mig.ModeStatusPendingReset
+	}
+	return mig.NewModeStatus(mig.NewMode(mode.Enabled))
+}
+
+// isModeStatus returns true if the status provided as argument corresponds to a MIG mode, namely if
+// the MIG mode of the GPU is not being changed
+func isModeStatus(status mig.ModeStatus) bool {
+	return status == mig.ModeStatusEnabled || status == mig.ModeStatusDisabled
+}
+
+func modeStatusesEqual(a, b map[int]mig.ModeStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MigModeManager) SetupWithManager(mgr ctrl.Manager, controllerName string) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(
+			&v1.Node{},
+			builder.WithPredicates(
+				predicate.ExcludeDelete{},
+				predicate.MatchingName{Name: m.nodeName},
+				predicate.AnnotationsChangedPredicate{},
+			),
+		).
+		Named(controllerName).
+		Complete(m)
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migagent
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/nebuly-ai/nos/pkg/test/mocks"
+	mockednvml "github.com/nebuly-ai/nos/pkg/test/mocks/nvml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// simulatedResourceClient exposes the devices of a simulated NVML client,
+// considering as used the ones provided in the used list
+type simulatedResourceClient struct {
+	nvmlClient *nvml.SimulatedClient
+	used       []resource.Device
+}
+
+func (c simulatedResourceClient) GetAllocatableDevices(ctx context.Context) ([]resource.Device, error) {
+	return c.nvmlClient.GetAllocatableDevices(ctx)
+}
+
+func (c simulatedResourceClient) GetUsedDevices(_ context.Context) ([]resource.Device, error) {
+	return c.used, nil
+}
+
+func (c simulatedResourceClient) GetPodsUsingDevices(_ context.Context, _ []string) ([]types.NamespacedName, error) {
+	return nil, nil
+}
+
+func (c simulatedResourceClient) GetDevicePods(_ context.Context) (map[string]types.NamespacedName, error) {
+	return nil, nil
+}
+
+func newModeManagerTestClient(node v1.Node) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(&node).Build()
+}
+
+func TestMigModeManager__Reconcile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		gpus        []nvml.SimulatedGpu
+		migDevices  map[int][]string
+		used        []resource.Device
+		annotations map[string]string
+
+		expectedStatuses map[int]mig.ModeStatus
+		expectedModes    []bool
+		expectedRestart  bool
+		expectedRequeue  bool
+	}{
+		{
+			name: "No desired MIG mode, MIG mode of GPUs is only reported",
+			gpus: []nvml.SimulatedGpu{
+				{Model: gpu.GPUModel_A30},
+				{Model: gpu.GPUModel_A30, MigEnabled: true},
+			},
+			expectedStatuses: map[int]mig.ModeStatus{
+				0: mig.ModeStatusDisabled,
+				1: mig.ModeStatusEnabled,
+			},
+			expectedModes:   []bool{false, true},
+			expectedRestart: false,
+			expectedRequeue: false,
+		},
+		{
+			name: "Desired MIG mode already matches",
+			gpus: []nvml.SimulatedGpu{
+				{Model: gpu.GPUModel_A30, MigEnabled: true},
+			},
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-0":   "enabled",
+				"nos.nebuly.com/status-mig-mode-gpu-0": "enabled",
+			},
+			expectedStatuses: map[int]mig.ModeStatus{
+				0: mig.ModeStatusEnabled,
+			},
+			expectedModes:   []bool{true},
+			expectedRestart: false,
+			expectedRequeue: false,
+		},
+		{
+			name: "Enable MIG mode on free GPU",
+			gpus: []nvml.SimulatedGpu{
+				{Model: gpu.GPUModel_A30},
+				{Model: gpu.GPUModel_A30},
+			},
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-0": "enabled",
+			},
+			expectedStatuses: map[int]mig.ModeStatus{
+				0: mig.ModeStatusEnabled,
+				1: mig.ModeStatusDisabled,
+			},
+			expectedModes:   []bool{true, false},
+			expectedRestart: true,
+			expectedRequeue: false,
+		},
+		{
+			name: "Disable MIG mode deletes free MIG devices of the GPU",
+			gpus: []nvml.SimulatedGpu{
+				{Model: gpu.GPUModel_A30, MigEnabled: true},
+				{Model: gpu.GPUModel_A30, MigEnabled: true},
+			},
+			migDevices: map[int][]string{
+				0: {"2g.12gb"},
+				1: {"1g.6gb", "2g.12gb"},
+			},
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-1": "disabled",
+			},
+			expectedStatuses: map[int]mig.ModeStatus{
+				0: mig.ModeStatusEnabled,
+				1: mig.ModeStatusDisabled,
+			},
+			expectedModes:   []bool{true, false},
+			expectedRestart: true,
+			expectedRequeue: false,
+		},
+		{
+			name: "GPU in use is not changed if drain is disabled",
+			gpus: []nvml.SimulatedGpu{
+				{Model: gpu.GPUModel_A30},
+			},
+			used: []resource.Device{
+				{
+					ResourceName: constant.ResourceNvidiaGPU,
+					DeviceId:     "GPU-simulated-0",
+					Status:       resource.StatusUsed,
+				},
+			},
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-0": "enabled",
+			},
+			expectedStatuses: map[int]mig.ModeStatus{
+				0: mig.ModeStatusDraining,
+			},
+			expectedModes:   []bool{false},
+			expectedRestart: false,
+			expectedRequeue: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nvmlClient, err := nvml.NewSimulatedClient(tt.gpus)
+			require.NoError(t, err)
+			for gpuIndex, profiles := range tt.migDevices {
+				require.NoError(t, nvmlClient.CreateMigDevices(profiles, gpuIndex))
+			}
+			resourceClient := simulatedResourceClient{nvmlClient: nvmlClient, used: tt.used}
+
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).Get()
+			k8sClient := newModeManagerTestClient(node)
+			devicePlugin := mocks.NewDevicePluginClient(t)
+			if tt.expectedRestart {
+				devicePlugin.On("Restart", mock.Anything, "node-1", mock.Anything).Return(nil).Once()
+			}
+
+			manager := NewMigModeManager(
+				k8sClient,
+				nvmlClient,
+				mig.NewClient(resourceClient, nvmlClient),
+				resourceClient,
+				NewSharedState(),
+				"node-1",
+				record.NewFakeRecorder(10),
+				nil,
+			)
+			manager.devicePlugin = devicePlugin
+
+			res, err := manager.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-1"}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedRequeue, res.RequeueAfter > 0)
+
+			var updated v1.Node
+			require.NoError(t, k8sClient.Get(context.Background(), client.ObjectKey{Name: "node-1"}, &updated))
+			assert.Equal(t, tt.expectedStatuses, mig.GetModeStatuses(updated))
+			for gpuIndex, enabled := range tt.expectedModes {
+				mode, err := nvmlClient.GetMigMode(gpuIndex)
+				assert.NoError(t, err)
+				assert.Equal(t, enabled, mode.Enabled, "GPU %d", gpuIndex)
+			}
+		})
+	}
+}
+
+func TestMigModeManager__PendingReset(t *testing.T) {
+	t.Run("Change pending reset is reported", func(t *testing.T) {
+		nvmlClient := mockednvml.NewClient(t)
+		nvmlClient.On("GetGpuIndexes").Return([]int{0}, nil)
+		nvmlClient.On("GetMigMode", 0).Return(nvml.MigMode{Enabled: false, PendingEnabled: false}, nil).Once()
+		nvmlClient.On("SetMigMode", 0, true).Return(nil).Once()
+		nvmlClient.On("GetMigMode", 0).Return(nvml.MigMode{Enabled: false, PendingEnabled: true}, nil)
+
+		node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+			"nos.nebuly.com/spec-mig-mode-gpu-0": "enabled",
+		}).Get()
+		k8sClient := newModeManagerTestClient(node)
+		resourceClient := simulatedResourceClient{}
+		recorder := record.NewFakeRecorder(10)
+		manager := NewMigModeManager(
+			k8sClient,
+			nvmlClient,
+			mig.NewClient(resourceClient, nvmlClient),
+			resourceClient,
+			NewSharedState(),
+			"node-1",
+			recorder,
+			nil,
+		)
+		manager.devicePlugin = mocks.NewDevicePluginClient(t)
+
+		res, err := manager.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMigModeRetryInterval, res.RequeueAfter)
+		var updated v1.Node
+		require.NoError(t, k8sClient.Get(context.Background(), client.ObjectKey{Name: "node-1"}, &updated))
+		assert.Equal(t, map[int]mig.ModeStatus{0: mig.ModeStatusPendingReset}, mig.GetModeStatuses(updated))
+		assert.Len(t, recorder.Events, 1)
+
+		// Next reconcile does not change the MIG mode again
+		res, err = manager.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMigModeRetryInterval, res.RequeueAfter)
+		assert.Len(t, recorder.Events, 1)
+	})
+
+	t.Run("Device plugin is restarted once the reset takes effect", func(t *testing.T) {
+		nvmlClient := mockednvml.NewClient(t)
+		nvmlClient.On("GetGpuIndexes").Return([]int{0}, nil)
+		nvmlClient.On("GetMigMode", 0).Return(nvml.MigMode{Enabled: true, PendingEnabled: true}, nil)
+
+		node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+			"nos.nebuly.com/spec-mig-mode-gpu-0":   "enabled",
+			"nos.nebuly.com/status-mig-mode-gpu-0": "pending-reset",
+		}).Get()
+		k8sClient := newModeManagerTestClient(node)
+		resourceClient := simulatedResourceClient{}
+		devicePlugin := mocks.NewDevicePluginClient(t)
+		devicePlugin.On("Restart", mock.Anything, "node-1", mock.Anything).Return(nil).Once()
+		manager := NewMigModeManager(
+			k8sClient,
+			nvmlClient,
+			mig.NewClient(resourceClient, nvmlClient),
+			resourceClient,
+			NewSharedState(),
+			"node-1",
+			record.NewFakeRecorder(10),
+			nil,
+		)
+		manager.devicePlugin = devicePlugin
+
+		res, err := manager.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-1"}})
+		assert.NoError(t, err)
+		assert.Zero(t, res.RequeueAfter)
+		var updated v1.Node
+		require.NoError(t, k8sClient.Get(context.Background(), client.ObjectKey{Name: "node-1"}, &updated))
+		assert.Equal(t, map[int]mig.ModeStatus{0: mig.ModeStatusEnabled}, mig.GetModeStatuses(updated))
+	})
+}
@@ -27,6 +27,9 @@ type CreateOperation struct {
 	MigProfile mig.Profile
 	// Quantity is the amount of MigProfiles that need to be created
 	Quantity int
+	// Placements are the placements at which the MigProfiles have to be created, one for each profile.
+	// If empty, the profiles can be created at any placement.
+	Placements []mig.Placement
 }
 
 type DeleteOperation struct {
@@ -59,7 +62,11 @@ func (c CreateOperationList) Flatten() mig.ProfileList {
 	res := make(mig.ProfileList, 0)
 	for _, op := range c {
 		for i := 0; i < op.Quantity; i++ {
-			res = append(res, op.MigProfile)
+			profile := op.MigProfile
+			if i < len(op.Placements) {
+				profile.Placement = op.Placements[i]
+			}
+			res = append(res, profile)
 		}
 	}
 	return res
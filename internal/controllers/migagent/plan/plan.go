@@ -21,6 +21,7 @@ import (
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/util"
+	"sort"
 )
 
 type MigConfigPlan struct {
@@ -28,7 +29,24 @@ type MigConfigPlan struct {
 	CreateOperations CreateOperationList
 }
 
-func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList) MigConfigPlan {
+// Placements contains the information about the MIG placements of the GPUs, which is used for computing
+// the exact placements at which the MIG devices of a plan have to be created.
+type Placements struct {
+	// Supported are the MIG placements supported by each GPU, indexed by GPU index
+	Supported map[int]mig.ProfilePlacements
+	// Devices are the placements of the existing MIG devices, indexed by device ID
+	Devices map[string]mig.Placement
+}
+
+// NewMigConfigPlan computes the plan for changing the MIG devices of the state provided as argument
+// into the devices specified by the desired annotations.
+//
+// If the placements of a GPU are known, the plan specifies the exact placement of each device to create,
+// keeping the existing devices in place and leaving the largest contiguous range of free memory slices.
+// Free devices are deleted and re-created at a different placement only if there is no other way to
+// create the new ones. If the placements are unknown, the plan re-creates all the free devices of the GPU,
+// so that the number of orders in which the devices can be created is larger.
+func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList, placements Placements) MigConfigPlan {
 	plan := MigConfigPlan{
 		DeleteOperations: make(DeleteOperationList, 0),
 		CreateOperations: make(CreateOperationList, 0),
@@ -44,7 +62,7 @@ func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList) MigConfigP
 	stateResourcesByGpu := state.Flatten().SortByDeviceId().GroupByGpuIndex()
 	for gpuIndex, gpuAnnotations := range desired.GroupByGpuIndex() {
 		gpuStateResources := mig.GroupDevicesByMigProfile(stateResourcesByGpu[gpuIndex])
-		nCreateOp := 0
+		gpuCreateOps := make(CreateOperationList, 0)
 		for migProfile, migProfileAnnotations := range mig.GroupSpecAnnotationsByMigProfile(gpuAnnotations) {
 			// init actual resources of current GPU and current MIG profile
 			actualMigProfileResources := gpuStateResources[migProfile]
@@ -60,8 +78,7 @@ func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList) MigConfigP
 
 			diff := totalDesiredQuantity - len(actualMigProfileResources)
 			if diff > 0 {
-				plan.addCreateOp(CreateOperation{MigProfile: migProfile, Quantity: diff})
-				nCreateOp++
+				gpuCreateOps = append(gpuCreateOps, CreateOperation{MigProfile: migProfile, Quantity: diff})
 			}
 			if diff < 0 {
 				toDelete := extractCandidatesForDeletion(actualMigProfileResources, util.Abs(diff))
@@ -71,13 +88,34 @@ func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList) MigConfigP
 		}
 
 		// no create operations on this GPU, we don't need to clean up free devices
-		if nCreateOp == 0 {
+		if len(gpuCreateOps) == 0 {
+			continue
+		}
+
+		// if the placements of the GPU are known, create the devices at the computed placements
+		placedCreateOps, resourcesToRecreate, ok := placeCreateOps(
+			gpuIndex,
+			gpuCreateOps,
+			stateResourcesByGpu[gpuIndex],
+			plan,
+			placements,
+		)
+		if ok {
+			if len(resourcesToRecreate) > 0 {
+				plan.addDeleteOp(DeleteOperation{Resources: resourcesToRecreate})
+			}
+			for _, op := range placedCreateOps {
+				plan.addCreateOp(op)
+			}
 			continue
 		}
 
-		// if there's any create op on the GPU, then re-create existing *free* resources so that
+		// otherwise, re-create existing *free* resources so that
 		// when applying the create operations the number of possible MIG permutations to try is larger
-		resourcesToRecreate := extractResourcesToRecreate(stateResourcesByGpu[gpuIndex], plan)
+		for _, op := range gpuCreateOps {
+			plan.addCreateOp(op)
+		}
+		resourcesToRecreate = extractResourcesToRecreate(stateResourcesByGpu[gpuIndex], plan)
 		if len(resourcesToRecreate) > 0 {
 			// delete free resources not already included in plan
 			plan.addDeleteOp(DeleteOperation{Resources: resourcesToRecreate})
@@ -91,6 +129,197 @@ func NewMigConfigPlan(state MigState, desired gpu.SpecAnnotationList) MigConfigP
 	return plan
 }
 
+// placeCreateOps computes the placements of the devices created by the operations provided as argument,
+// which all refer to the GPU with the provided index.
+//
+// The function first tries to place the new devices without moving any existing device. If this is not possible,
+// it tries to place them together with the free devices of the GPU, which therefore have to be re-created.
+// The returned operations create all the devices at the computed placements, and the returned device list contains
+// the free devices that have to be deleted in order to be re-created.
+//
+// Devices of compute instance profiles (e.g. 1c.3g.20gb) are placed at the placement of the GPU instance they
+// belong to: they are first added to the existing GPU instances hosting devices of the same profile, and
+// the remaining ones are packed into new GPU instances. Existing devices of compute instance profiles are never
+// moved, since their GPU instance is shared with other devices.
+//
+// It returns false if the placements of the GPU or of any of its devices are unknown, or if the devices
+// cannot be placed on the GPU.
+func placeCreateOps(
+	gpuIndex int,
+	ops CreateOperationList,
+	gpuResources gpu.DeviceList,
+	currentPlan MigConfigPlan,
+	placements Placements,
+) (CreateOperationList, gpu.DeviceList, bool) {
+	supported := placements.Supported[gpuIndex]
+	if len(supported) == 0 {
+		return nil, nil, false
+	}
+
+	// Get the placements of the devices that are not deleted by the plan
+	toBeDeletedLookup := make(map[string]bool)
+	for _, r := range currentPlan.getResourcesToDelete() {
+		toBeDeletedLookup[r.DeviceId] = true
+	}
+	var fixed = make([]mig.PlacedProfile, 0)
+	var movable = make(gpu.DeviceList, 0)
+	var movablePlaced = make([]mig.PlacedProfile, 0)
+	var sharedGpuInstances = make([]sharedGpuInstance, 0)
+	for _, r := range gpuResources {
+		if toBeDeletedLookup[r.DeviceId] {
+			continue
+		}
+		placement, ok := placements.Devices[r.DeviceId]
+		if !ok {
+			return nil, nil, false
+		}
+		profile := mig.GetMigProfileName(r)
+		if profile.IsComputeInstanceProfile() {
+			sharedGpuInstances = addToSharedGpuInstances(sharedGpuInstances, profile, placement)
+			continue
+		}
+		placed := mig.PlacedProfile{Name: profile, Placement: placement}
+		if r.IsFree() {
+			movable = append(movable, r)
+			movablePlaced = append(movablePlaced, placed)
+			continue
+		}
+		fixed = append(fixed, placed)
+	}
+	for _, gi := range sharedGpuInstances {
+		fixed = append(fixed, mig.PlacedProfile{Name: gi.profile.GetGpuInstanceProfile(), Placement: gi.placement})
+	}
+
+	// Add the new devices of compute instance profiles to the existing GPU instances with spare capacity,
+	// and compute the GPU instances that have to be created for the remaining ones
+	var sharedProfiles = make([]mig.ProfileName, 0)
+	var sharedPlacements = make([]mig.Placement, 0)
+	var newGpuInstances = make([]newGpuInstance, 0)
+	for _, op := range ops {
+		profile := op.MigProfile.Name
+		if !profile.IsComputeInstanceProfile() {
+			for i := 0; i < op.Quantity; i++ {
+				newGpuInstances = append(newGpuInstances, newGpuInstance{profile: profile, devices: 1})
+			}
+			continue
+		}
+		devicesPerGi := profile.GetDevicesPerGpuInstance()
+		if devicesPerGi == 0 {
+			return nil, nil, false
+		}
+		remaining := op.Quantity
+		for i := range sharedGpuInstances {
+			gi := &sharedGpuInstances[i]
+			for gi.profile == profile && gi.devices < devicesPerGi && remaining > 0 {
+				sharedProfiles = append(sharedProfiles, profile)
+				sharedPlacements = append(sharedPlacements, gi.placement)
+				gi.devices++
+				remaining--
+			}
+		}
+		for remaining > 0 {
+			devices := util.Min(devicesPerGi, remaining)
+			newGpuInstances = append(newGpuInstances, newGpuInstance{profile: profile, devices: devices})
+			remaining -= devices
+		}
+	}
+
+	// Try to place the new devices keeping all the existing ones in place
+	if placed, ok := supported.FindPlacements(append(fixed, movablePlaced...), gpuInstanceProfiles(newGpuInstances)); ok {
+		profiles, devicePlacements := expandGpuInstances(newGpuInstances, placed)
+		profiles = append(profiles, sharedProfiles...)
+		devicePlacements = append(devicePlacements, sharedPlacements...)
+		return newPlacedCreateOps(gpuIndex, profiles, devicePlacements), nil, true
+	}
+
+	// Try to place the new devices together with the free ones
+	if len(movable) == 0 {
+		return nil, nil, false
+	}
+	allGpuInstances := newGpuInstances
+	for _, m := range movablePlaced {
+		allGpuInstances = append(allGpuInstances, newGpuInstance{profile: m.Name, devices: 1})
+	}
+	if placed, ok := supported.FindPlacements(fixed, gpuInstanceProfiles(allGpuInstances)); ok {
+		profiles, devicePlacements := expandGpuInstances(allGpuInstances, placed)
+		profiles = append(profiles, sharedProfiles...)
+		devicePlacements = append(devicePlacements, sharedPlacements...)
+		return newPlacedCreateOps(gpuIndex, profiles, devicePlacements), movable, true
+	}
+
+	return nil, nil, false
+}
+
+// sharedGpuInstance is an existing GPU instance hosting the devices of a compute instance profile
+type sharedGpuInstance struct {
+	profile   mig.ProfileName
+	placement mig.Placement
+	devices   int
+}
+
+func addToSharedGpuInstances(gpuInstances []sharedGpuInstance, profile mig.ProfileName, placement mig.Placement) []sharedGpuInstance {
+	for i := range gpuInstances {
+		if gpuInstances[i].placement == placement {
+			gpuInstances[i].devices++
+			return gpuInstances
+		}
+	}
+	return append(gpuInstances, sharedGpuInstance{profile: profile, placement: placement, devices: 1})
+}
+
+// newGpuInstance is a GPU instance that has to be created for hosting new devices of the profile
+type newGpuInstance struct {
+	profile mig.ProfileName
+	devices int
+}
+
+// gpuInstanceProfiles returns the GPU instance profiles of the GPU instances provided as argument
+func gpuInstanceProfiles(gpuInstances []newGpuInstance) []mig.ProfileName {
+	res := make([]mig.ProfileName, len(gpuInstances))
+	for i, gi := range gpuInstances {
+		res[i] = gi.profile.GetGpuInstanceProfile()
+	}
+	return res
+}
+
+// expandGpuInstances returns the profile and the placement of each device hosted by the new GPU instances
+// provided as argument, which are created at the respective placements
+func expandGpuInstances(gpuInstances []newGpuInstance, placements []mig.Placement) ([]mig.ProfileName, []mig.Placement) {
+	profiles := make([]mig.ProfileName, 0, len(gpuInstances))
+	devicePlacements := make([]mig.Placement, 0, len(gpuInstances))
+	for i, gi := range gpuInstances {
+		for j := 0; j < gi.devices; j++ {
+			profiles = append(profiles, gi.profile)
+			devicePlacements = append(devicePlacements, placements[i])
+		}
+	}
+	return profiles, devicePlacements
+}
+
+// newPlacedCreateOps returns the operations that create the devices of the provided profiles at the
+// respective placements, grouping the devices by profile
+func newPlacedCreateOps(gpuIndex int, profiles []mig.ProfileName, placements []mig.Placement) CreateOperationList {
+	placementsByProfile := make(map[mig.ProfileName][]mig.Placement)
+	for i, p := range profiles {
+		placementsByProfile[p] = append(placementsByProfile[p], placements[i])
+	}
+	res := make(CreateOperationList, 0, len(placementsByProfile))
+	for profile, profilePlacements := range placementsByProfile {
+		sort.SliceStable(profilePlacements, func(i, j int) bool {
+			return profilePlacements[i].Start < profilePlacements[j].Start
+		})
+		res = append(res, CreateOperation{
+			MigProfile: mig.Profile{GpuIndex: gpuIndex, Name: profile},
+			Quantity:   len(profilePlacements),
+			Placements: profilePlacements,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].MigProfile.Name < res[j].MigProfile.Name
+	})
+	return res
+}
+
 func extractResourcesToRecreate(resources gpu.DeviceList, currentPlan MigConfigPlan) gpu.DeviceList {
 	// lookup
 	alreadyToBeDeletedLookup := make(map[string]gpu.Device)
@@ -383,7 +383,7 @@ func TestNewMigConfigPlan(t *testing.T) {
 				assert.NoError(t, err)
 				annotations = append(annotations, a)
 			}
-			plan := NewMigConfigPlan(tt.state, annotations)
+			plan := NewMigConfigPlan(tt.state, annotations, Placements{})
 			assert.ElementsMatch(t, tt.expectedDeleteOps, plan.DeleteOperations)
 			assert.ElementsMatch(t, tt.expectedCreateOps, plan.CreateOperations)
 		})
@@ -615,3 +615,240 @@ func TestMigConfigPlan__Equals(t *testing.T) {
 		})
 	}
 }
+
+func TestNewMigConfigPlan__Placements(t *testing.T) {
+	a100Placements := mig.ProfilePlacements{
+		mig.Profile1g5gb: {
+			{Start: 0, Size: 1},
+			{Start: 1, Size: 1},
+			{Start: 2, Size: 1},
+			{Start: 3, Size: 1},
+			{Start: 4, Size: 1},
+			{Start: 5, Size: 1},
+			{Start: 6, Size: 1},
+		},
+		mig.Profile2g10gb: {{Start: 0, Size: 2}, {Start: 2, Size: 2}, {Start: 4, Size: 2}},
+		mig.Profile3g20gb: {{Start: 0, Size: 4}, {Start: 4, Size: 4}},
+		mig.Profile4g20gb: {{Start: 0, Size: 4}},
+		mig.Profile7g40gb: {{Start: 0, Size: 8}},
+	}
+	newDevice := func(id string, profile mig.ProfileName, status resource.Status) gpu.Device {
+		return gpu.Device{
+			Device: resource.Device{
+				ResourceName: profile.AsResourceName(),
+				DeviceId:     id,
+				Status:       status,
+			},
+			GpuIndex: 0,
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		state             MigState
+		devicePlacements  map[string]mig.Placement
+		specAnnotations   map[string]string
+		expectedCreateOps CreateOperationList
+		expectedDeleteOps DeleteOperationList
+	}{
+		{
+			name:  "Empty state, devices are created at the computed placements",
+			state: MigState{},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile3g20gb): "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb):  "2",
+			},
+			expectedDeleteOps: DeleteOperationList{},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1g5gb},
+					Quantity:   2,
+					Placements: []mig.Placement{{Start: 4, Size: 1}, {Start: 5, Size: 1}},
+				},
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile3g20gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 0, Size: 4}},
+				},
+			},
+		},
+		{
+			name: "New devices fit the free slices, existing devices are not re-created",
+			state: MigState{
+				0: {
+					newDevice("used", mig.Profile3g20gb, resource.StatusUsed),
+					newDevice("free", mig.Profile1g5gb, resource.StatusFree),
+				},
+			},
+			devicePlacements: map[string]mig.Placement{
+				"used": {Start: 4, Size: 4},
+				"free": {Start: 0, Size: 1},
+			},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile3g20gb): "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb):  "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile2g10gb): "1",
+			},
+			expectedDeleteOps: DeleteOperationList{},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile2g10gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 2, Size: 2}},
+				},
+			},
+		},
+		{
+			name: "Free devices block the new devices, they are re-created at a different placement",
+			state: MigState{
+				0: {
+					newDevice("used", mig.Profile1g5gb, resource.StatusUsed),
+					newDevice("free", mig.Profile1g5gb, resource.StatusFree),
+				},
+			},
+			devicePlacements: map[string]mig.Placement{
+				"used": {Start: 0, Size: 1},
+				"free": {Start: 4, Size: 1},
+			},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb):  "2",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile3g20gb): "1",
+			},
+			expectedDeleteOps: DeleteOperationList{
+				{Resources: gpu.DeviceList{newDevice("free", mig.Profile1g5gb, resource.StatusFree)}},
+			},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1g5gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 1, Size: 1}},
+				},
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile3g20gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 4, Size: 4}},
+				},
+			},
+		},
+		{
+			name:  "Compute instance profile, devices are packed into the fewest GPU instances",
+			state: MigState{},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1c3g20gb): "4",
+			},
+			expectedDeleteOps: DeleteOperationList{},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1c3g20gb},
+					Quantity:   4,
+					Placements: []mig.Placement{
+						{Start: 0, Size: 4},
+						{Start: 0, Size: 4},
+						{Start: 0, Size: 4},
+						{Start: 4, Size: 4},
+					},
+				},
+			},
+		},
+		{
+			name: "Compute instance profile, new devices are added to the existing GPU instance",
+			state: MigState{
+				0: {
+					newDevice("ci-used", mig.Profile1c3g20gb, resource.StatusUsed),
+					newDevice("free", mig.Profile1g5gb, resource.StatusFree),
+				},
+			},
+			devicePlacements: map[string]mig.Placement{
+				"ci-used": {Start: 4, Size: 4},
+				"free":    {Start: 0, Size: 1},
+			},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1c3g20gb): "3",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb):    "2",
+			},
+			expectedDeleteOps: DeleteOperationList{},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1c3g20gb},
+					Quantity:   2,
+					Placements: []mig.Placement{{Start: 4, Size: 4}, {Start: 4, Size: 4}},
+				},
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1g5gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 1, Size: 1}},
+				},
+			},
+		},
+		{
+			name: "Compute instance profile, full GPU instances are not used for new devices",
+			state: MigState{
+				0: {
+					newDevice("ci-1", mig.Profile1c3g20gb, resource.StatusUsed),
+					newDevice("ci-2", mig.Profile1c3g20gb, resource.StatusUsed),
+					newDevice("ci-3", mig.Profile1c3g20gb, resource.StatusFree),
+				},
+			},
+			devicePlacements: map[string]mig.Placement{
+				"ci-1": {Start: 0, Size: 4},
+				"ci-2": {Start: 0, Size: 4},
+				"ci-3": {Start: 0, Size: 4},
+			},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1c3g20gb): "4",
+			},
+			expectedDeleteOps: DeleteOperationList{},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1c3g20gb},
+					Quantity:   1,
+					Placements: []mig.Placement{{Start: 4, Size: 4}},
+				},
+			},
+		},
+		{
+			name: "Unknown device placements, free devices are re-created at any placement",
+			state: MigState{
+				0: {
+					newDevice("free", mig.Profile1g5gb, resource.StatusFree),
+				},
+			},
+			devicePlacements: map[string]mig.Placement{},
+			specAnnotations: map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb):  "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile3g20gb): "1",
+			},
+			expectedDeleteOps: DeleteOperationList{
+				{Resources: gpu.DeviceList{newDevice("free", mig.Profile1g5gb, resource.StatusFree)}},
+			},
+			expectedCreateOps: CreateOperationList{
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile3g20gb},
+					Quantity:   1,
+				},
+				{
+					MigProfile: mig.Profile{GpuIndex: 0, Name: mig.Profile1g5gb},
+					Quantity:   1,
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := make(gpu.SpecAnnotationList, 0)
+			for k, v := range tt.specAnnotations {
+				a, err := gpu.ParseSpecAnnotation(k, v)
+				assert.NoError(t, err)
+				annotations = append(annotations, a)
+			}
+			placements := Placements{
+				Supported: map[int]mig.ProfilePlacements{0: a100Placements},
+				Devices:   tt.devicePlacements,
+			}
+			plan := NewMigConfigPlan(tt.state, annotations, placements)
+			assert.ElementsMatch(t, tt.expectedDeleteOps, plan.DeleteOperations)
+			assert.ElementsMatch(t, tt.expectedCreateOps, plan.CreateOperations)
+		})
+	}
+}
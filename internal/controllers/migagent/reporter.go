@@ -21,13 +21,15 @@ import (
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/resource"
 	"github.com/nebuly-ai/nos/pkg/util/predicate"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 )
 
@@ -35,21 +37,24 @@ type MigReporter struct {
 	client.Client
 	migClient       mig.Client
 	refreshInterval time.Duration
+	deviceWatcher   *resource.Watcher
 	sharedState     *SharedState
 }
 
-func NewReporter(client client.Client, migClient mig.Client, sharedState *SharedState, refreshInterval time.Duration) MigReporter {
+func NewReporter(client client.Client, migClient mig.Client, sharedState *SharedState, refreshInterval time.Duration, deviceWatcher *resource.Watcher) MigReporter {
 	reporter := MigReporter{
 		Client:          client,
 		migClient:       migClient,
 		sharedState:     sharedState,
 		refreshInterval: refreshInterval,
+		deviceWatcher:   deviceWatcher,
 	}
 	return reporter
 }
 
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
 
 func (r *MigReporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := klog.FromContext(ctx).WithName("Reporter")
@@ -75,13 +80,25 @@ func (r *MigReporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 	logger.V(3).Info("loaded used MIG devices", "usedMIGs", usedMigs)
 	newStatusAnnotations := migResources.AsStatusAnnotation(mig.ExtractProfileNameStr)
 
-	// Get current status annotations and compare with new ones
+	// Get current status annotations and compare with new ones. Consider only
+	// MIG annotations, since on nodes with hybrid partitioning the status of the
+	// other GPUs is reported by the gpu-agent
 	oldStatusAnnotations, _ := gpu.ParseNodeAnnotations(instance)
-	if newStatusAnnotations.Equal(oldStatusAnnotations) {
-		if instance.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] == r.sharedState.lastParsedPlanId {
-			logger.Info("current status is equal to last reported status, nothing to do")
-			return ctrl.Result{RequeueAfter: r.refreshInterval}, nil
-		}
+	oldStatusAnnotations = oldStatusAnnotations.Filter(mig.IsMigStatusAnnotation)
+	// Report the last applied plan only if it is not older than the reported one, so that
+	// the report of a stale plan never overwrites the report of a newer one
+	planAnnotation := getReportedPlanAnnotation(instance)
+	planId := r.sharedState.GetLastAppliedPlanId()
+	reportedPlanId, _ := gpu.ParsePlanId(instance.Annotations[planAnnotation])
+	reportPlan := !planId.IsZero() && planId != reportedPlanId && !planId.IsOlderThan(reportedPlanId)
+	// Report the status of the last handled plan if it changed since the last report
+	planStatus, reportPlanStatus := r.getPlanStatusToReport(instance)
+	// Report the MIG placements supported by the GPUs if they changed since the last report
+	placements := r.getPlacementsAnnotationValue(ctx)
+	reportPlacements := placements != "" && placements != instance.Annotations[v1alpha1.AnnotationMigPlacements]
+	if newStatusAnnotations.Equal(oldStatusAnnotations) && !reportPlan && !reportPlanStatus && !reportPlacements {
+		logger.Info("current status is equal to last reported status, nothing to do")
+		return ctrl.Result{RequeueAfter: r.refreshInterval}, nil
 	}
 
 	// Update node
@@ -90,15 +107,21 @@ func (r *MigReporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 	if updated.Annotations == nil {
 		updated.Annotations = make(map[string]string)
 	}
-	for k := range updated.Annotations {
-		if strings.HasPrefix(k, v1alpha1.AnnotationGpuStatusPrefix) {
-			delete(updated.Annotations, k)
-		}
+	for _, a := range oldStatusAnnotations {
+		delete(updated.Annotations, a.String())
 	}
 	for _, a := range newStatusAnnotations {
 		updated.Annotations[a.String()] = a.GetValue()
 	}
-	updated.Annotations[v1alpha1.AnnotationReportedPartitioningPlan] = r.sharedState.lastParsedPlanId
+	if reportPlan {
+		updated.Annotations[planAnnotation] = planId.String()
+	}
+	if reportPlanStatus {
+		updated.Annotations[v1alpha1.AnnotationPartitioningPlanStatus] = planStatus.String()
+	}
+	if reportPlacements {
+		updated.Annotations[v1alpha1.AnnotationMigPlacements] = placements
+	}
 	if err := r.Client.Patch(ctx, updated, client.MergeFrom(&instance)); err != nil {
 		logger.Error(err, "unable to update node status annotations", "annotations", updated.Annotations)
 		return ctrl.Result{}, err
@@ -108,8 +131,64 @@ func (r *MigReporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Res
 	return ctrl.Result{RequeueAfter: r.refreshInterval}, nil
 }
 
+// getReportedPlanAnnotation returns the annotation through which the mig-agent reports the plans applied
+// to the node provided as argument. On nodes with hybrid partitioning, the mig-agent reports only that the
+// MIG geometry of the plan has been applied, and the plan is then reported by the gpu-agent once the
+// device plugin exposes the GPU slices of the plan.
+func getReportedPlanAnnotation(node v1.Node) string {
+	if gpu.IsHybridPartitioningEnabled(node) {
+		return v1alpha1.AnnotationReportedMigPartitioningPlan
+	}
+	return v1alpha1.AnnotationReportedPartitioningPlan
+}
+
+// getPlanStatusToReport returns the status of the last plan handled by the Actuator, and true if it has to be
+// reported because it differs from the one currently reported by the node. As for the plan IDs, the status of
+// a plan older than the reported one is never reported.
+func (r *MigReporter) getPlanStatusToReport(node v1.Node) (gpu.PlanStatus, bool) {
+	status, ok := r.sharedState.GetLastPlanStatus()
+	if !ok {
+		return gpu.PlanStatus{}, false
+	}
+	reported, err := gpu.ParsePlanStatus(node.Annotations[v1alpha1.AnnotationPartitioningPlanStatus])
+	if err != nil {
+		return status, true
+	}
+	if status.Equal(reported) {
+		return status, false
+	}
+	planId, _ := gpu.ParsePlanId(status.PlanId)
+	reportedPlanId, _ := gpu.ParsePlanId(reported.PlanId)
+	return status, !planId.IsOlderThan(reportedPlanId)
+}
+
+// getPlacementsAnnotationValue returns the value of the annotation exposing the MIG placements supported by
+// the GPUs of the node, or an empty string if they cannot be retrieved. All the GPUs of a node are of the same
+// model, so the placements of the MIG-enabled GPU with the lowest index are reported for the whole node.
+func (r *MigReporter) getPlacementsAnnotationValue(ctx context.Context) string {
+	logger := klog.FromContext(ctx).WithName("Reporter")
+	placementsByGpu, err := r.migClient.GetProfilePlacements(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get MIG profile placements")
+		return ""
+	}
+	gpuIndex := -1
+	for i, placements := range placementsByGpu {
+		if len(placements) == 0 {
+			continue
+		}
+		if gpuIndex < 0 || i < gpuIndex {
+			gpuIndex = i
+		}
+	}
+	if gpuIndex < 0 {
+		return ""
+	}
+	return placementsByGpu[gpuIndex].String()
+}
+
 func (r *MigReporter) SetupWithManager(mgr ctrl.Manager, controllerName string, nodeName string) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(
 			&v1.Node{},
 			builder.WithPredicates(
@@ -118,6 +197,9 @@ func (r *MigReporter) SetupWithManager(mgr ctrl.Manager, controllerName string,
 				predicate.NodeResourcesChanged{},
 			),
 		).
-		Named(controllerName).
-		Complete(r)
+		Named(controllerName)
+	if r.deviceWatcher != nil {
+		b = b.Watches(&source.Channel{Source: r.deviceWatcher.Events()}, &handler.EnqueueRequestForObject{})
+	}
+	return b.Complete(r)
 }
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/resource"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -51,6 +52,7 @@ var _ = Describe("MigAgent - Reporter", func() {
 	})
 
 	AfterEach(func() {
+		reporterMigClient.ReturnedProfilePlacements = nil
 	})
 
 	When("New MIG resources are created on the node", func() {
@@ -83,10 +85,10 @@ var _ = Describe("MigAgent - Reporter", func() {
 			}
 			expectedAnnotationOne := fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "1g.10gb", resource.StatusFree)
 			expectedAnnotationTwo := fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, "2g.20gb", resource.StatusUsed)
+			// we're not using a real shared state in tests, so no plan is reported
 			expectedAnnotations := map[string]string{
-				expectedAnnotationOne:                       "1",
-				expectedAnnotationTwo:                       "1",
-				v1alpha1.AnnotationReportedPartitioningPlan: "", // we're not using a real shared state in tests, so it does not get updated
+				expectedAnnotationOne: "1",
+				expectedAnnotationTwo: "1",
 			}
 			Eventually(func() map[string]string {
 				var updatedNode v1.Node
@@ -98,4 +100,28 @@ var _ = Describe("MigAgent - Reporter", func() {
 			}, timeout, interval).Should(Equal(expectedAnnotations))
 		})
 	})
+
+	When("The GPUs of the node support MIG profile placements", func() {
+		It("Should expose the placements of the first MIG-enabled GPU on the node", func() {
+			By("Mocking the placements of the MIG-enabled GPUs")
+			placements := mig.ProfilePlacements{
+				mig.Profile1g10gb: {{Start: 0, Size: 1}, {Start: 1, Size: 1}},
+				mig.Profile7g79gb: {{Start: 0, Size: 8}},
+			}
+			reporterMigClient.ReturnedProfilePlacements = map[int]mig.ProfilePlacements{
+				1: {mig.Profile7g40gb: {{Start: 0, Size: 8}}},
+				0: placements,
+			}
+
+			By("Checking that after some time the node exposes the placements as annotation")
+			Eventually(func() string {
+				var updatedNode v1.Node
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: reporterNodeName, Namespace: ""}, &updatedNode)
+				if err != nil {
+					return ""
+				}
+				return updatedNode.Annotations[v1alpha1.AnnotationMigPlacements]
+			}, timeout, interval).Should(Equal(placements.String()))
+		})
+	})
 })
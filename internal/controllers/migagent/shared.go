@@ -16,15 +16,20 @@
 
 package migagent
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/nebuly-ai/nos/pkg/gpu"
+)
 
 type empty struct{}
 
 // SharedState contains the information shared between the Actuator and the Reporter processes
 type SharedState struct {
 	sync.Mutex
-	lastParsedPlanId string
-	reportsChan      chan empty
+	lastAppliedPlanId gpu.PlanId
+	lastPlanStatus    *gpu.PlanStatus
+	reportsChan       chan empty
 }
 
 func NewSharedState() *SharedState {
@@ -33,6 +38,46 @@ func NewSharedState() *SharedState {
 	}
 }
 
+// GetLastAppliedPlanId returns the ID of the last partitioning plan applied by the Actuator
+func (s *SharedState) GetLastAppliedPlanId() gpu.PlanId {
+	return s.lastAppliedPlanId
+}
+
+// SetLastAppliedPlanId sets the ID of the last partitioning plan applied by the Actuator.
+// Plans are applied in order of generation, so IDs of plans older than the last applied one are ignored.
+func (s *SharedState) SetLastAppliedPlanId(planId gpu.PlanId) {
+	if planId.IsOlderThan(s.lastAppliedPlanId) {
+		return
+	}
+	s.lastAppliedPlanId = planId
+}
+
+// GetLastPlanStatus returns the status of the last partitioning plan handled by the Actuator,
+// and false if the Actuator has not handled any plan yet
+func (s *SharedState) GetLastPlanStatus() (gpu.PlanStatus, bool) {
+	if s.lastPlanStatus == nil {
+		return gpu.PlanStatus{}, false
+	}
+	return *s.lastPlanStatus, true
+}
+
+// SetLastPlanStatus sets the status of the last partitioning plan handled by the Actuator.
+// Statuses of plans older than the last handled one are ignored, as well as statuses equal to the
+// current one, so that re-applying a plan with the same outcome does not trigger a new report.
+func (s *SharedState) SetLastPlanStatus(status gpu.PlanStatus) {
+	if s.lastPlanStatus != nil {
+		if status.Equal(*s.lastPlanStatus) {
+			return
+		}
+		newPlanId, _ := gpu.ParsePlanId(status.PlanId)
+		lastPlanId, _ := gpu.ParsePlanId(s.lastPlanStatus.PlanId)
+		if newPlanId.IsOlderThan(lastPlanId) {
+			return
+		}
+	}
+	s.lastPlanStatus = &status
+}
+
 func (s *SharedState) OnReportDone() {
 	select {
 	case s.reportsChan <- struct{}{}:
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migagent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedState_SetLastAppliedPlanId(t *testing.T) {
+	s := NewSharedState()
+	assert.True(t, s.GetLastAppliedPlanId().IsZero())
+
+	older := gpu.NewPlanId(gpu.PartitioningKindMig)
+	newer := gpu.NewPlanId(gpu.PartitioningKindMig)
+
+	s.SetLastAppliedPlanId(newer)
+	assert.Equal(t, newer, s.GetLastAppliedPlanId())
+
+	// Older plans must never replace newer ones
+	s.SetLastAppliedPlanId(older)
+	assert.Equal(t, newer, s.GetLastAppliedPlanId())
+}
+
+func TestSharedState_SetLastPlanStatus(t *testing.T) {
+	s := NewSharedState()
+	_, ok := s.GetLastPlanStatus()
+	assert.False(t, ok)
+
+	older := gpu.NewPlanId(gpu.PartitioningKindMig)
+	newer := gpu.NewPlanId(gpu.PartitioningKindMig)
+	failed := []gpu.PlanOperationResult{{Type: gpu.PlanOperationCreate, Profile: "1g.10gb", Quantity: 1, Error: "err"}}
+
+	newerStatus := gpu.NewPlanStatus(newer, nil, failed)
+	s.SetLastPlanStatus(newerStatus)
+	status, ok := s.GetLastPlanStatus()
+	assert.True(t, ok)
+	assert.Equal(t, newerStatus, status)
+
+	// Statuses of older plans must never replace the ones of newer plans
+	s.SetLastPlanStatus(gpu.NewPlanStatus(older, nil, nil))
+	status, _ = s.GetLastPlanStatus()
+	assert.Equal(t, newerStatus, status)
+
+	// Statuses equal to the current one, except for the time, must not replace it
+	sameStatus := newerStatus
+	sameStatus.Time = newerStatus.Time.Add(time.Minute)
+	s.SetLastPlanStatus(sameStatus)
+	status, _ = s.GetLastPlanStatus()
+	assert.Equal(t, newerStatus, status)
+
+	// Different statuses of the same plan replace the current one
+	appliedStatus := gpu.NewPlanStatus(newer, nil, nil)
+	s.SetLastPlanStatus(appliedStatus)
+	status, _ = s.GetLastPlanStatus()
+	assert.Equal(t, appliedStatus, status)
+}
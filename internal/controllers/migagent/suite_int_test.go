@@ -123,12 +123,12 @@ var _ = BeforeSuite(func() {
 	reporterSharedState = NewSharedState()
 
 	// Setup Reporter
-	reporter := NewReporter(k8sClient, reporterMigClient, reporterSharedState, 3*time.Second)
+	reporter := NewReporter(k8sClient, reporterMigClient, reporterSharedState, 3*time.Second, nil)
 	err = reporter.SetupWithManager(k8sManager, "MIGReporter", reporterNodeName)
 	Expect(err).ToNot(HaveOccurred())
 
 	// Setup Actuator
-	actuator = NewActuator(k8sClient, actuatorMigClient, actuatorSharedState, actuatorNodeName)
+	actuator = NewActuator(k8sClient, actuatorMigClient, actuatorSharedState, actuatorNodeName, nil)
 	err = actuator.SetupWithManager(k8sManager, "MIGActuator")
 	Expect(err).ToNot(HaveOccurred())
 
@@ -19,6 +19,9 @@ package core
 import (
 	"context"
 	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu"
 	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -50,17 +53,72 @@ func (a actuator) Apply(ctx context.Context, snapshot Snapshot, plan Partitionin
 		return false, nil
 	}
 
-	for nodeName, partitioningState := range plan.DesiredState {
+	// Partition only the nodes whose partitioning changes, so that the device plugin
+	// is not restarted on the other ones
+	changedNodes := getChangedNodes(snapshot.GetPartitioningState(), plan.DesiredState)
+	logger.Info("reconfiguration cost", "cost", GetReconfigurationCost(snapshot.GetPartitioningState(), changedNodes))
+
+	recorder := newPlanRecorder(plan, changedNodes)
+	defer recorder.save(ctx, a.Client)
+
+	for nodeName, partitioningState := range changedNodes {
 		node := v1.Node{}
 		if err := a.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
-			return false, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+			err = fmt.Errorf("failed to get node %s: %w", nodeName, err)
+			recorder.failed(nodeName, err)
+			return false, err
 		}
 		logger.Info("partitioning node", "node", node.Name, "partitioning", partitioningState)
+		kind, _ := gpu.GetPartitioningKind(node)
 		if err = a.ApplyPartitioning(ctx, node, plan.GetId(), partitioningState); err != nil {
+			metrics.ObserveNodeApply(kind, nodeName, metrics.OutcomeFailure)
+			recorder.failed(nodeName, err)
 			return false, fmt.Errorf("error partitioning node %s: %w", nodeName, err)
 		}
+		metrics.ObserveNodeApply(kind, nodeName, metrics.OutcomeSuccess)
+		recorder.applied(nodeName)
 	}
 	logger.Info("plan applied")
 
 	return true, nil
 }
+
+type dryRunActuator struct {
+}
+
+// NewDryRunActuator returns an Actuator that never applies the partitioning plans, but that
+// just logs which nodes would be partitioned together with the explanation of each plan.
+func NewDryRunActuator() Actuator {
+	return dryRunActuator{}
+}
+
+func (a dryRunActuator) Apply(ctx context.Context, snapshot Snapshot, plan PartitioningPlan) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	changedNodes := getChangedNodes(snapshot.GetPartitioningState(), plan.DesiredState)
+
+	logger.Info(
+		"dry-run mode enabled, partitioning plan not applied",
+		"plan",
+		plan.GetId(),
+		"partitioning",
+		changedNodes,
+		"explanation",
+		plan.Explanation,
+	)
+
+	return false, nil
+}
+
+// getChangedNodes returns the partitioning of the nodes whose desired partitioning
+// is different from the current one
+func getChangedNodes(current, desired state.PartitioningState) state.PartitioningState {
+	res := make(state.PartitioningState)
+	for nodeName, desiredNode := range desired {
+		if currentNode, ok := current[nodeName]; ok && currentNode.Equal(desiredNode) {
+			continue
+		}
+		res[nodeName] = desiredNode
+	}
+	return res
+}
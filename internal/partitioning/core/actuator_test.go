@@ -21,11 +21,19 @@ import (
 	"errors"
 	"github.com/nebuly-ai/nos/internal/partitioning/core"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/test/factory"
 	"github.com/nebuly-ai/nos/pkg/test/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
 )
@@ -39,8 +47,9 @@ func TestActuator__Apply(t *testing.T) {
 		mockedClientNode        v1.Node
 		mockedPartitionerReturn error
 
-		expectedRes bool
-		expectedErr bool
+		expectedRes       bool
+		expectedErr       bool
+		expectedPlanPhase v1alpha1.PartitioningPlanPhase
 	}{
 		{
 			name: "Empty plan, should do nothing",
@@ -56,7 +65,7 @@ func TestActuator__Apply(t *testing.T) {
 					},
 				},
 			},
-			plan: core.NewPartitioningPlan(state.PartitioningState{}),
+			plan: core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{}),
 
 			mockedClientNode:        v1.Node{},
 			mockedPartitionerReturn: nil,
@@ -78,7 +87,7 @@ func TestActuator__Apply(t *testing.T) {
 					},
 				},
 			},
-			plan: core.NewPartitioningPlan(state.PartitioningState{
+			plan: core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
 				"node-1": {
 					GPUs: []state.GPUPartitioning{
 						{
@@ -111,7 +120,7 @@ func TestActuator__Apply(t *testing.T) {
 					},
 				},
 			},
-			plan: core.NewPartitioningPlan(state.PartitioningState{
+			plan: core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
 				"node-1": {
 					GPUs: []state.GPUPartitioning{
 						{
@@ -127,8 +136,9 @@ func TestActuator__Apply(t *testing.T) {
 			mockedClientNode:        v1.Node{},
 			mockedPartitionerReturn: nil,
 
-			expectedRes: false,
-			expectedErr: true,
+			expectedRes:       false,
+			expectedErr:       true,
+			expectedPlanPhase: v1alpha1.PartitioningPlanPhaseFailed,
 		},
 		{
 			name: "Partitioner returns error when applying plan, should do nothing and return error",
@@ -144,7 +154,7 @@ func TestActuator__Apply(t *testing.T) {
 					},
 				},
 			},
-			plan: core.NewPartitioningPlan(state.PartitioningState{
+			plan: core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
 				"node-1": {
 					GPUs: []state.GPUPartitioning{
 						{
@@ -160,8 +170,9 @@ func TestActuator__Apply(t *testing.T) {
 			mockedClientNode:        factory.BuildNode("node-1").Get(),
 			mockedPartitionerReturn: errors.New("error"),
 
-			expectedRes: false,
-			expectedErr: true,
+			expectedRes:       false,
+			expectedErr:       true,
+			expectedPlanPhase: v1alpha1.PartitioningPlanPhaseFailed,
 		},
 		{
 			name: "Plan is applied",
@@ -177,7 +188,7 @@ func TestActuator__Apply(t *testing.T) {
 					},
 				},
 			},
-			plan: core.NewPartitioningPlan(state.PartitioningState{
+			plan: core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
 				"node-1": {
 					GPUs: []state.GPUPartitioning{
 						{
@@ -193,8 +204,9 @@ func TestActuator__Apply(t *testing.T) {
 			mockedClientNode:        factory.BuildNode("node-1").Get(),
 			mockedPartitionerReturn: nil,
 
-			expectedRes: true,
-			expectedErr: false,
+			expectedRes:       true,
+			expectedErr:       false,
+			expectedPlanPhase: v1alpha1.PartitioningPlanPhasePending,
 		},
 	}
 
@@ -208,7 +220,10 @@ func TestActuator__Apply(t *testing.T) {
 				mock.Anything,
 				mock.Anything,
 			).Return(tt.mockedPartitionerReturn).Maybe()
-			mockClient := fake.NewClientBuilder().WithObjects(&tt.mockedClientNode).Build()
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			utilruntime.Must(v1alpha1.AddToScheme(scheme))
+			mockClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&tt.mockedClientNode).Build()
 			actuator := core.NewActuator(mockClient, mockPartitioner)
 
 			mockSnapshot := mocks.NewSnapshot(t)
@@ -221,6 +236,150 @@ func TestActuator__Apply(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+
+			// Check the partitioning plan resource recording the plan
+			var planResource v1alpha1.PartitioningPlan
+			err = mockClient.Get(context.Background(), client.ObjectKey{Name: tt.plan.GetId()}, &planResource)
+			if tt.expectedPlanPhase == "" {
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPlanPhase, planResource.Status.Phase)
+			assert.Len(t, planResource.Spec.Nodes, len(tt.plan.DesiredState))
+			assert.Equal(t, tt.plan.GetKind().String(), planResource.Spec.PartitioningKind)
+			assert.Equal(t, tt.plan.GetKind().String(), planResource.Labels[v1alpha1.LabelGpuPartitioning])
+		})
+	}
+}
+
+func TestActuator__Apply__OnlyChangedNodes(t *testing.T) {
+	unchanged := state.NodePartitioning{
+		GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/gpu-10gb": 1}},
+		},
+	}
+	changed := state.NodePartitioning{
+		GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/gpu-10gb": 2}},
+		},
+	}
+	plan := core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
+		"node-1": changed,
+		"node-2": unchanged,
+	})
+
+	mockPartitioner := mocks.NewPartitioner(t)
+	mockPartitioner.On(
+		"ApplyPartitioning",
+		mock.Anything,
+		mock.MatchedBy(func(n v1.Node) bool { return n.Name == "node-1" }),
+		plan.GetId(),
+		changed,
+	).Return(nil).Once()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	node1 := factory.BuildNode("node-1").Get()
+	node2 := factory.BuildNode("node-2").Get()
+	mockClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&node1, &node2).Build()
+	actuator := core.NewActuator(mockClient, mockPartitioner)
+
+	mockSnapshot := mocks.NewSnapshot(t)
+	mockSnapshot.On("GetPartitioningState").Return(state.PartitioningState{
+		"node-1": unchanged,
+		"node-2": unchanged,
+	})
+
+	applied, err := actuator.Apply(context.Background(), mockSnapshot, plan)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	// The plan resource must record only the partitioned node
+	var planResource v1alpha1.PartitioningPlan
+	assert.NoError(t, mockClient.Get(context.Background(), client.ObjectKey{Name: plan.GetId()}, &planResource))
+	assert.Len(t, planResource.Spec.Nodes, 1)
+	assert.Equal(t, "node-1", planResource.Spec.Nodes[0].Name)
+}
+
+func TestActuator__Apply__PrunesOldPlans(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+
+	// Existing plan resources: the maximum number of MIG plans, and an old MPS plan
+	node := factory.BuildNode("node-1").Get()
+	objects := []client.Object{&node}
+	oldestMigPlan := gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: 1}.String()
+	for i := 1; i <= core.MaxPartitioningPlans; i++ {
+		objects = append(objects, &v1alpha1.PartitioningPlan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: int64(i)}.String(),
+				Labels: map[string]string{v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String()},
+			},
 		})
 	}
+	mpsPlan := gpu.PlanId{Kind: gpu.PartitioningKindMps, Generation: 1}.String()
+	objects = append(objects, &v1alpha1.PartitioningPlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   mpsPlan,
+			Labels: map[string]string{v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMps.String()},
+		},
+	})
+	mockClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	mockPartitioner := mocks.NewPartitioner(t)
+	mockPartitioner.On("ApplyPartitioning", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	actuator := core.NewActuator(mockClient, mockPartitioner)
+	mockSnapshot := mocks.NewSnapshot(t)
+	mockSnapshot.On("GetPartitioningState").Return(state.PartitioningState{})
+	plan := core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
+		"node-1": {
+			GPUs: []state.GPUPartitioning{
+				{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-1g.10gb": 1}},
+			},
+		},
+	})
+
+	applied, err := actuator.Apply(context.Background(), mockSnapshot, plan)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	// The oldest MIG plan must be deleted, while the new one and the MPS plan must be kept
+	var migPlans v1alpha1.PartitioningPlanList
+	assert.NoError(t, mockClient.List(
+		context.Background(),
+		&migPlans,
+		client.MatchingLabels{v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String()},
+	))
+	assert.Len(t, migPlans.Items, core.MaxPartitioningPlans)
+	var planResource v1alpha1.PartitioningPlan
+	err = mockClient.Get(context.Background(), client.ObjectKey{Name: oldestMigPlan}, &planResource)
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.NoError(t, mockClient.Get(context.Background(), client.ObjectKey{Name: plan.GetId()}, &planResource))
+	assert.NoError(t, mockClient.Get(context.Background(), client.ObjectKey{Name: mpsPlan}, &planResource))
+}
+
+func TestDryRunActuator__Apply(t *testing.T) {
+	mockSnapshot := mocks.NewSnapshot(t)
+	mockSnapshot.On("GetPartitioningState").Return(state.PartitioningState{}).Maybe()
+	plan := core.NewPartitioningPlan(gpu.PartitioningKindMig, state.PartitioningState{
+		"node-1": {
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-10gb": 1,
+					},
+				},
+			},
+		},
+	})
+
+	// The dry-run actuator does not use any partitioner, so applying
+	// the plan must never change the cluster
+	actuator := core.NewDryRunActuator()
+	applied, err := actuator.Apply(context.Background(), mockSnapshot, plan)
+	assert.NoError(t, err)
+	assert.False(t, applied)
 }
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type compactor struct {
+	kind         gpu.PartitioningKind
+	partitioner  PartitionCalculator
+	nodeCooldown time.Duration
+	clock        Clock
+}
+
+// NewCompactor returns a Compactor that compacts the free slices of all the nodes of the snapshot
+// implementing CompactableNode. Nodes partitioned less than nodeCooldown ago are not compacted.
+func NewCompactor(kind gpu.PartitioningKind, partitioner PartitionCalculator, nodeCooldown time.Duration) Compactor {
+	return NewCompactorWithClock(kind, partitioner, nodeCooldown, nil)
+}
+
+// NewCompactorWithClock returns a Compactor like NewCompactor, which uses the clock provided as argument
+// for generating the plan IDs and for checking the node cooldown
+func NewCompactorWithClock(
+	kind gpu.PartitioningKind,
+	partitioner PartitionCalculator,
+	nodeCooldown time.Duration,
+	clock Clock,
+) Compactor {
+	return compactor{
+		kind:         kind,
+		partitioner:  partitioner,
+		nodeCooldown: nodeCooldown,
+		clock:        clock,
+	}
+}
+
+func (c compactor) Compact(ctx context.Context, snapshot Snapshot) (PartitioningPlan, error) {
+	logger := log.FromContext(ctx)
+	partitioningState := snapshot.GetPartitioningState()
+
+	nodes := make([]PartitionableNode, 0, len(snapshot.GetNodes()))
+	for _, n := range snapshot.GetNodes() {
+		nodes = append(nodes, n)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].GetName() < nodes[j].GetName()
+	})
+
+	now := c.clock.now()
+	for _, n := range nodes {
+		compactable, ok := n.Clone().(CompactableNode)
+		if !ok {
+			continue
+		}
+		if c.nodeCooldown > 0 {
+			if until, cooling := isCoolingDown(n, c.nodeCooldown, now); cooling {
+				logger.V(1).Info("skipping node partitioned too recently", "node", n.GetName(), "cooldownUntil", until)
+				continue
+			}
+		}
+		compacted, err := compactable.Compact()
+		if err != nil {
+			return PartitioningPlan{}, err
+		}
+		if !compacted {
+			continue
+		}
+		logger.V(1).Info("compacted node geometry", "node", n.GetName(), "geometry", compactable.Geometry())
+		partitioningState[n.GetName()] = c.partitioner.GetPartitioning(compactable)
+	}
+
+	return c.clock.newPartitioningPlan(c.kind, partitioningState), nil
+}
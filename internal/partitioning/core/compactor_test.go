@@ -0,0 +1,82 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	partitioning_mig "github.com/nebuly-ai/nos/internal/partitioning/mig"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	nosresource "github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCompactor__Compact(t *testing.T) {
+	a30Node := func(name string, annotations map[string]string) v1.Node {
+		return factory.BuildNode(name).
+			WithAnnotations(annotations).
+			WithLabels(map[string]string{
+				constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+				constant.LabelNvidiaCount:     "1",
+				v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+			}).
+			Get()
+	}
+	nodes := []v1.Node{
+		// Only free devices, should be compacted
+		a30Node("node-1", map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile1g6gb, nosresource.StatusFree): "4",
+		}),
+		// Used devices, should not be compacted
+		a30Node("node-2", map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile1g6gb, nosresource.StatusUsed): "1",
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile1g6gb, nosresource.StatusFree): "3",
+		}),
+		// Only free devices but partitioned too recently, should not be compacted
+		a30Node("node-3", map[string]string{
+			v1alpha1.AnnotationPartitioningPlan: gpu.NewPlanId(gpu.PartitioningKindMig).String(),
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile1g6gb, nosresource.StatusFree): "4",
+		}),
+	}
+	snapshot := newSnapshotFromNodes(nodes, partitioning_mig.NewSnapshotTaker())
+	current := snapshot.GetPartitioningState()
+
+	compactor := partitioning_mig.NewCompactor(time.Hour)
+	plan, err := compactor.Compact(context.Background(), snapshot)
+	assert.NoError(t, err)
+
+	expected := state.NodePartitioning{
+		GPUs: []state.GPUPartitioning{
+			{GPUIndex: 0, Resources: map[v1.ResourceName]int{mig.Profile4g24gb.AsResourceName(): 1}},
+		},
+	}
+	assert.True(t, expected.Equal(plan.DesiredState["node-1"]), "got %v", plan.DesiredState["node-1"])
+	assert.True(t, current["node-2"].Equal(plan.DesiredState["node-2"]))
+	assert.True(t, current["node-3"].Equal(plan.DesiredState["node-3"]))
+
+	// The snapshot must not be changed
+	assert.True(t, current.Equal(snapshot.GetPartitioningState()))
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	reconfiguredGPUWeight     = 1
+	devicePluginRestartWeight = 2
+	touchedNodeWeight         = 1
+)
+
+// ReconfigurationCost measures the disruption caused by changing the GPU partitioning of the cluster
+type ReconfigurationCost struct {
+	// ReconfiguredGPUs is the number of GPUs whose partitioning changes
+	ReconfiguredGPUs int
+	// DevicePluginRestarts is the number of restarts of the NVIDIA device plugin required for applying
+	// the changes: MIG changes require the MIG agent to restart the plugin, while any other change
+	// requires the plugin to reload its config
+	DevicePluginRestarts int
+	// TouchedNodes is the number of nodes whose partitioning changes
+	TouchedNodes int
+}
+
+// Add returns the sum of the two costs
+func (c ReconfigurationCost) Add(other ReconfigurationCost) ReconfigurationCost {
+	return ReconfigurationCost{
+		ReconfiguredGPUs:     c.ReconfiguredGPUs + other.ReconfiguredGPUs,
+		DevicePluginRestarts: c.DevicePluginRestarts + other.DevicePluginRestarts,
+		TouchedNodes:         c.TouchedNodes + other.TouchedNodes,
+	}
+}
+
+// Score returns a single value summarizing the cost, which can be used for comparing costs
+func (c ReconfigurationCost) Score() int {
+	return c.ReconfiguredGPUs*reconfiguredGPUWeight +
+		c.DevicePluginRestarts*devicePluginRestartWeight +
+		c.TouchedNodes*touchedNodeWeight
+}
+
+// Less returns true if the cost is lower than the other cost
+func (c ReconfigurationCost) Less(other ReconfigurationCost) bool {
+	return c.Score() < other.Score()
+}
+
+// GetReconfigurationCost returns the cost of changing the partitioning of the cluster
+// from the current partitioning state to the desired one
+func GetReconfigurationCost(current, desired state.PartitioningState) ReconfigurationCost {
+	var res ReconfigurationCost
+	for nodeName, desiredNode := range desired {
+		res = res.Add(GetNodeReconfigurationCost(current[nodeName], desiredNode))
+	}
+	return res
+}
+
+// GetNodeReconfigurationCost returns the cost of changing the partitioning of a node
+// from the current partitioning to the desired one
+func GetNodeReconfigurationCost(current, desired state.NodePartitioning) ReconfigurationCost {
+	currentGPUs := make(map[int]state.GPUPartitioning, len(current.GPUs))
+	for _, g := range current.GPUs {
+		currentGPUs[g.GPUIndex] = g
+	}
+
+	var res ReconfigurationCost
+	var migChanged, otherChanged bool
+	for _, desiredGPU := range desired.GPUs {
+		currentGPU := currentGPUs[desiredGPU.GPUIndex]
+		if currentGPU.Equal(desiredGPU) {
+			continue
+		}
+		res.ReconfiguredGPUs++
+		for _, resources := range []map[v1.ResourceName]int{currentGPU.Resources, desiredGPU.Resources} {
+			for r := range resources {
+				if currentGPU.Resources[r] == desiredGPU.Resources[r] {
+					continue
+				}
+				if mig.IsNvidiaMigDevice(r) {
+					migChanged = true
+				} else {
+					otherChanged = true
+				}
+			}
+		}
+	}
+
+	if res.ReconfiguredGPUs > 0 {
+		res.TouchedNodes = 1
+	}
+	if migChanged {
+		res.DevicePluginRestarts++
+	}
+	if otherChanged {
+		res.DevicePluginRestarts++
+	}
+	return res
+}
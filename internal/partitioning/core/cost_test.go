@@ -0,0 +1,137 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core_test
+
+import (
+	"testing"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetReconfigurationCost(t *testing.T) {
+	gpuPartitioning := func(index int, resources map[v1.ResourceName]int) state.GPUPartitioning {
+		return state.GPUPartitioning{GPUIndex: index, Resources: resources}
+	}
+	migResources := func(profile mig.ProfileName, quantity int) map[v1.ResourceName]int {
+		return map[v1.ResourceName]int{profile.AsResourceName(): quantity}
+	}
+
+	testCases := []struct {
+		name     string
+		current  state.PartitioningState
+		desired  state.PartitioningState
+		expected core.ReconfigurationCost
+	}{
+		{
+			name:     "Empty states",
+			current:  state.PartitioningState{},
+			desired:  state.PartitioningState{},
+			expected: core.ReconfigurationCost{},
+		},
+		{
+			name: "Equal states",
+			current: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+			},
+			desired: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+			},
+			expected: core.ReconfigurationCost{},
+		},
+		{
+			name: "Zero quantities are ignored",
+			current: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+			},
+			desired: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, map[v1.ResourceName]int{
+					mig.Profile1g6gb.AsResourceName():  4,
+					mig.Profile2g12gb.AsResourceName(): 0,
+				})}},
+			},
+			expected: core.ReconfigurationCost{},
+		},
+		{
+			name: "MIG GPUs changed on the same node require a single device plugin restart",
+			current: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{
+					gpuPartitioning(0, migResources(mig.Profile1g6gb, 4)),
+					gpuPartitioning(1, migResources(mig.Profile1g6gb, 4)),
+					gpuPartitioning(2, migResources(mig.Profile1g6gb, 4)),
+				}},
+			},
+			desired: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{
+					gpuPartitioning(0, migResources(mig.Profile4g24gb, 1)),
+					gpuPartitioning(1, migResources(mig.Profile2g12gb, 2)),
+					gpuPartitioning(2, migResources(mig.Profile1g6gb, 4)),
+				}},
+			},
+			expected: core.ReconfigurationCost{ReconfiguredGPUs: 2, DevicePluginRestarts: 1, TouchedNodes: 1},
+		},
+		{
+			name: "MIG and shared GPUs changed on the same node require two device plugin restarts",
+			current: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{
+					gpuPartitioning(0, migResources(mig.Profile1g6gb, 4)),
+					gpuPartitioning(1, map[v1.ResourceName]int{"nvidia.com/gpu-10gb": 2}),
+				}},
+			},
+			desired: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{
+					gpuPartitioning(0, migResources(mig.Profile4g24gb, 1)),
+					gpuPartitioning(1, map[v1.ResourceName]int{"nvidia.com/gpu-5gb": 4}),
+				}},
+			},
+			expected: core.ReconfigurationCost{ReconfiguredGPUs: 2, DevicePluginRestarts: 2, TouchedNodes: 1},
+		},
+		{
+			name: "Multiple nodes, nodes not in the current state are counted as changed",
+			current: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+				"node-2": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+			},
+			desired: state.PartitioningState{
+				"node-1": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile4g24gb, 1))}},
+				"node-2": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, migResources(mig.Profile1g6gb, 4))}},
+				"node-3": {GPUs: []state.GPUPartitioning{gpuPartitioning(0, map[v1.ResourceName]int{"nvidia.com/gpu-10gb": 2})}},
+			},
+			expected: core.ReconfigurationCost{ReconfiguredGPUs: 2, DevicePluginRestarts: 2, TouchedNodes: 2},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, core.GetReconfigurationCost(tt.current, tt.desired))
+		})
+	}
+}
+
+func TestReconfigurationCost__Less(t *testing.T) {
+	oneGPU := core.ReconfigurationCost{ReconfiguredGPUs: 1, DevicePluginRestarts: 1, TouchedNodes: 1}
+	twoGPUsSameNode := core.ReconfigurationCost{ReconfiguredGPUs: 2, DevicePluginRestarts: 1, TouchedNodes: 1}
+	twoGPUsTwoNodes := oneGPU.Add(oneGPU)
+
+	assert.True(t, core.ReconfigurationCost{}.Less(oneGPU))
+	assert.True(t, oneGPU.Less(twoGPUsSameNode))
+	assert.True(t, twoGPUsSameNode.Less(twoGPUsTwoNodes))
+	assert.False(t, oneGPU.Less(oneGPU))
+}
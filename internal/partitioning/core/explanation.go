@@ -0,0 +1,132 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+)
+
+// PlanExplanation explains the decisions taken by the Planner while computing a PartitioningPlan
+type PlanExplanation struct {
+	Pods []PodExplanation `json:"pods"`
+}
+
+// PodExplanation explains whether a candidate Pod fits the GPU partitioning of a PartitioningPlan
+type PodExplanation struct {
+	// Pod is the namespaced name of the Pod
+	Pod string `json:"pod"`
+	// Fits is true if the Pod can be scheduled on any node with the partitioning of the plan
+	Fits bool `json:"fits"`
+	// Node is the node on which the Pod has been placed, if any. It is empty for Pods that fit
+	// the current partitioning, when no repartitioning is needed for scheduling the candidate Pods
+	Node string `json:"node,omitempty"`
+	// Rejections contains, for each node on which the Pod has been tried, the reasons
+	// why it could not be placed on it
+	Rejections map[string][]string `json:"rejections,omitempty"`
+}
+
+// PlacedPods returns the number of candidate pods that the plan places on a node. Pods that fit
+// the current partitioning, when no repartitioning is needed, are not counted.
+func (e PlanExplanation) PlacedPods() int {
+	var res int
+	for _, p := range e.Pods {
+		if p.Fits && p.Node != "" {
+			res++
+		}
+	}
+	return res
+}
+
+// planExplainer records the outcome of placing the candidate pods on the nodes while computing a plan
+type planExplainer struct {
+	pods   []string
+	lookup map[string]*PodExplanation
+}
+
+func newPlanExplainer(candidatePods []v1.Pod) *planExplainer {
+	e := planExplainer{
+		pods:   make([]string, 0, len(candidatePods)),
+		lookup: make(map[string]*PodExplanation, len(candidatePods)),
+	}
+	for _, pod := range candidatePods {
+		key := util.GetNamespacedName(&pod).String()
+		e.pods = append(e.pods, key)
+		e.lookup[key] = &PodExplanation{Pod: key}
+	}
+	return &e
+}
+
+func (e *planExplainer) placed(pod v1.Pod, nodeName string) {
+	explanation, ok := e.lookup[util.GetNamespacedName(&pod).String()]
+	if !ok || explanation.Fits {
+		return
+	}
+	explanation.Fits = true
+	explanation.Node = nodeName
+}
+
+// fitCurrentPartitioning records that the pods requesting GPU slices fit the current partitioning
+// of the nodes, which is the case when no GPU slices are lacking and no repartitioning is therefore needed.
+// Pods that do not request any slice computed by the calculator provided as argument are left untouched.
+func (e *planExplainer) fitCurrentPartitioning(pods []v1.Pod, calculator gpu.SliceCalculator) {
+	for _, pod := range pods {
+		explanation, ok := e.lookup[util.GetNamespacedName(&pod).String()]
+		if !ok || len(calculator.GetRequestedSlices(pod)) == 0 {
+			continue
+		}
+		explanation.Fits = true
+	}
+}
+
+func (e *planExplainer) rejected(pod v1.Pod, nodeName string, reasons []string) {
+	explanation, ok := e.lookup[util.GetNamespacedName(&pod).String()]
+	if !ok || explanation.Fits {
+		return
+	}
+	if explanation.Rejections == nil {
+		explanation.Rejections = make(map[string][]string)
+	}
+	explanation.Rejections[nodeName] = append(explanation.Rejections[nodeName], reasons...)
+}
+
+func (e *planExplainer) explanation() PlanExplanation {
+	res := PlanExplanation{Pods: make([]PodExplanation, 0, len(e.pods))}
+	for _, key := range e.pods {
+		res.Pods = append(res.Pods, *e.lookup[key])
+	}
+	return res
+}
+
+// lackingSlicesReason returns the explanation of a Pod rejection due to lacking slices
+func lackingSlicesReason(lackingSlices map[gpu.Slice]int) string {
+	slices := make([]string, 0, len(lackingSlices))
+	for s, q := range lackingSlices {
+		slices = append(slices, fmt.Sprintf("%s=%d", s, q))
+	}
+	sort.Strings(slices)
+	return fmt.Sprintf("lacking GPU slices %v", slices)
+}
+
+// pluginStatusReason returns the explanation of a Pod rejection due to the status of a scheduler plugin
+func pluginStatusReason(extensionPoint string, plugin string, status *framework.Status) string {
+	return fmt.Sprintf("%s/%s: %s (%s)", extensionPoint, plugin, status.Message(), status.Code())
+}
@@ -28,6 +28,12 @@ type Planner interface {
 	Plan(ctx context.Context, snapshot Snapshot, pendingPods []v1.Pod) (PartitioningPlan, error)
 }
 
+// Compactor computes plans that compact the free slices of the nodes, which otherwise would
+// stay around after the pods they were created for finish, fragmenting the GPUs
+type Compactor interface {
+	Compact(ctx context.Context, snapshot Snapshot) (PartitioningPlan, error)
+}
+
 type Actuator interface {
 	Apply(ctx context.Context, snapshot Snapshot, plan PartitioningPlan) (bool, error)
 }
@@ -42,6 +48,22 @@ type PartitionableNode interface {
 	HasFreeCapacity() bool
 }
 
+// GeometrySearchableNode is a PartitionableNode whose GPUs can be partitioned only according to a finite set of
+// geometries, which can be explored one GPU at a time for finding the best partitioning of the node.
+type GeometrySearchableNode interface {
+	PartitionableNode
+	GetGPUIndexes() []int
+	GetCandidateGeometries(gpuIndex int) []gpu.Geometry
+	ApplyGeometry(gpuIndex int, geometry gpu.Geometry) error
+}
+
+// CompactableNode is a PartitionableNode whose free slices can be compacted, for instance by resetting
+// the GPUs without used slices to the geometry with the fewest slices.
+type CompactableNode interface {
+	PartitionableNode
+	Compact() (bool, error)
+}
+
 type PartitionCalculator interface {
 	GetPartitioning(node PartitionableNode) state.NodePartitioning
 }
@@ -0,0 +1,389 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultBeamWidth is the number of candidate partitionings kept at each step by the optimizing planner
+// when no beam width is specified
+const DefaultBeamWidth = 10
+
+// DefaultMaxSearchExpansions is the number of partial partitionings that the optimizing planner can explore
+// before falling back to the greedy planner, when no limit is specified
+const DefaultMaxSearchExpansions = 20000
+
+// errSearchBudgetExceeded is returned when the optimizing planner explores more partial partitionings than allowed
+var errSearchBudgetExceeded = errors.New("search budget exceeded")
+
+// searchBudget counts the partial partitionings explored by the optimizing planner
+type searchBudget struct {
+	expansions    int
+	maxExpansions int
+}
+
+// expand records the exploration of a new partial partitioning, and returns errSearchBudgetExceeded
+// if the planner explored more partial partitionings than allowed
+func (b *searchBudget) expand() error {
+	b.expansions++
+	if b.expansions > b.maxExpansions {
+		return errSearchBudgetExceeded
+	}
+	return nil
+}
+
+// searchState is a partial solution explored by the optimizing planner: the candidate nodes up to the
+// current search step have been partitioned and the candidate pods have been placed on them.
+type searchState struct {
+	nodes     []PartitionableNode
+	placed    []string
+	podPlaced []bool
+	weight    int64
+	// cost is the reconfiguration cost of all the nodes partitioned so far
+	cost ReconfigurationCost
+	// settledCost is the reconfiguration cost of the nodes partitioned before the current search step
+	settledCost ReconfigurationCost
+}
+
+func (s searchState) fork() searchState {
+	res := searchState{
+		nodes:       make([]PartitionableNode, len(s.nodes)),
+		placed:      make([]string, len(s.placed)),
+		podPlaced:   make([]bool, len(s.podPlaced)),
+		weight:      s.weight,
+		cost:        s.cost,
+		settledCost: s.settledCost,
+	}
+	copy(res.nodes, s.nodes)
+	copy(res.placed, s.placed)
+	copy(res.podPlaced, s.podPlaced)
+	return res
+}
+
+// betterThan returns true if s schedules pods with a higher overall priority weight than other or,
+// if the weights are equal, if it has a lower reconfiguration cost
+func (s searchState) betterThan(other searchState) bool {
+	if s.weight != other.weight {
+		return s.weight > other.weight
+	}
+	return s.cost.Less(other.cost)
+}
+
+type optimizingPlanner struct {
+	planner
+	beamWidth     int
+	maxExpansions int
+}
+
+// NewOptimizingPlanner returns a Planner that, instead of greedily updating the geometry of the
+// candidate nodes one after the other, runs a beam search over the geometries of each single GPU
+// of the candidate nodes.
+//
+// Among the explored partitionings, the planner chooses the one that maximizes the overall priority
+// weight of the pods that can be scheduled and, among the ones scheduling the same pods, the one
+// with the lowest reconfiguration cost (see ReconfigurationCost).
+// Nodes whose GPUs cannot be explored one at a time (e.g. nodes that do not implement
+// GeometrySearchableNode) are partitioned by updating their whole geometry at once.
+//
+// The beamWidth argument is the number of best partial partitionings kept at each search step:
+// higher values allow to find better partitionings, at the cost of longer planning times.
+// The maxExpansions argument is the number of partial partitionings that the planner can explore:
+// if the search needs more, the planner falls back to the greedy partitioning of NewPlanner.
+func NewOptimizingPlanner(
+	kind gpu.PartitioningKind,
+	partitioner PartitionCalculator,
+	sliceCalculator gpu.SliceCalculator,
+	schedulerFramework framework.Framework,
+	beamWidth int,
+	maxExpansions int,
+) Planner {
+	return newOptimizingPlanner(newPlanner(kind, partitioner, sliceCalculator, schedulerFramework), beamWidth, maxExpansions)
+}
+
+func newOptimizingPlanner(p planner, beamWidth int, maxExpansions int) optimizingPlanner {
+	if beamWidth <= 0 {
+		beamWidth = DefaultBeamWidth
+	}
+	if maxExpansions <= 0 {
+		maxExpansions = DefaultMaxSearchExpansions
+	}
+	return optimizingPlanner{
+		planner:       p,
+		beamWidth:     beamWidth,
+		maxExpansions: maxExpansions,
+	}
+}
+
+func (p optimizingPlanner) Plan(ctx context.Context, snapshot Snapshot, candidatePods []v1.Pod) (PartitioningPlan, error) {
+	logger := log.FromContext(ctx)
+	logger.V(3).Info("planning desired GPU partitioning", "candidatePods", len(candidatePods))
+
+	partitioningState := snapshot.GetPartitioningState()
+	tracker := NewSliceTracker(snapshot, p.sliceCalculator, candidatePods)
+	metrics.ObserveLackingSlices(p.kind, tracker.GetRequestedSlices(), tracker.GetLackingSlices())
+
+	// No lacking slices, nothing to do
+	if len(tracker.GetLackingSlices()) == 0 {
+		logger.V(1).Info("no lacking profiles, nothing to do")
+		explainer := newPlanExplainer(candidatePods)
+		explainer.fitCurrentPartitioning(candidatePods, p.sliceCalculator)
+		plan := p.clock.newPartitioningPlan(p.kind, partitioningState)
+		plan.Explanation = explainer.explanation()
+		return plan, nil
+	}
+
+	sortedCandidatePods := p.sorter.Sort(candidatePods)
+	candidateNodes := p.getCandidateNodes(ctx, snapshot)
+	logger.V(1).Info(fmt.Sprintf("found %d candidate nodes", len(candidateNodes)))
+
+	// Run beam search
+	beam := []searchState{{
+		nodes:     candidateNodes,
+		placed:    make([]string, len(sortedCandidatePods)),
+		podPlaced: make([]bool, len(sortedCandidatePods)),
+	}}
+	budget := searchBudget{maxExpansions: p.maxExpansions}
+	for i := range candidateNodes {
+		var err error
+		beam, err = p.searchNode(ctx, &budget, beam, i, sortedCandidatePods, partitioningState)
+		if errors.Is(err, errSearchBudgetExceeded) {
+			logger.Info(
+				"search budget exceeded, falling back to greedy planning",
+				"maxExpansions",
+				p.maxExpansions,
+			)
+			return p.planner.Plan(ctx, snapshot, candidatePods)
+		}
+		if err != nil {
+			return PartitioningPlan{}, err
+		}
+	}
+	best := beam[0]
+	logger.V(1).Info(
+		"found best partitioning",
+		"weight",
+		best.weight,
+		"cost",
+		best.cost,
+	)
+
+	// Build plan from the best partitioning
+	explainer := newPlanExplainer(candidatePods)
+	for _, n := range best.nodes {
+		partitioningState[n.GetName()] = p.partitioner.GetPartitioning(n)
+	}
+	for i, pod := range sortedCandidatePods {
+		if best.podPlaced[i] {
+			explainer.placed(pod, best.placed[i])
+			continue
+		}
+		for _, n := range best.nodes {
+			explainer.rejected(pod, n.GetName(), p.rejectionReasons(ctx, pod, n))
+		}
+	}
+	plan := p.clock.newPartitioningPlan(p.kind, partitioningState)
+	plan.Explanation = explainer.explanation()
+	return plan, nil
+}
+
+// searchNode expands each state of the beam by exploring the partitionings of the node with the
+// provided index, and returns the new beam made of the best states found.
+// Each explored state consumes the search budget provided as argument.
+func (p optimizingPlanner) searchNode(
+	ctx context.Context,
+	budget *searchBudget,
+	beam []searchState,
+	nodeIdx int,
+	pods []v1.Pod,
+	current state.PartitioningState,
+) ([]searchState, error) {
+	// Keep the nodes as they are, just placing the pods on them
+	expanded := make([]searchState, 0, len(beam))
+	for _, s := range beam {
+		if err := budget.expand(); err != nil {
+			return nil, err
+		}
+		forked := s.fork()
+		forked.settledCost = forked.cost
+		forked.nodes[nodeIdx] = forked.nodes[nodeIdx].Clone().(PartitionableNode)
+		p.placePods(ctx, &forked, nodeIdx, pods)
+		expanded = append(expanded, forked)
+	}
+	beam = p.prune(expanded)
+
+	// If the node can be explored one GPU at a time, search the geometry of each GPU
+	if searchable, ok := beam[0].nodes[nodeIdx].(GeometrySearchableNode); ok {
+		for _, gpuIndex := range searchable.GetGPUIndexes() {
+			expanded = make([]searchState, 0, len(beam))
+			for _, s := range beam {
+				expanded = append(expanded, s)
+				node := s.nodes[nodeIdx].(GeometrySearchableNode)
+				for _, geometry := range node.GetCandidateGeometries(gpuIndex) {
+					if err := budget.expand(); err != nil {
+						return nil, err
+					}
+					forked := s.fork()
+					cloned := node.Clone().(GeometrySearchableNode)
+					if err := cloned.ApplyGeometry(gpuIndex, geometry); err != nil {
+						return nil, err
+					}
+					forked.nodes[nodeIdx] = cloned
+					forked.cost = forked.settledCost.Add(p.nodeCost(current, cloned))
+					// Keep the new geometry only if it allows to place more pods
+					if p.placePods(ctx, &forked, nodeIdx, pods) > 0 {
+						expanded = append(expanded, forked)
+					}
+				}
+			}
+			beam = p.prune(expanded)
+		}
+		return beam, nil
+	}
+
+	// Otherwise, update the geometry of the whole node at once
+	expanded = make([]searchState, 0, 2*len(beam))
+	for _, s := range beam {
+		expanded = append(expanded, s)
+		if err := budget.expand(); err != nil {
+			return nil, err
+		}
+		forked := s.fork()
+		cloned := s.nodes[nodeIdx].Clone().(PartitionableNode)
+		updated, err := cloned.UpdateGeometryFor(p.unplacedSlices(s, pods))
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			continue
+		}
+		forked.nodes[nodeIdx] = cloned
+		forked.cost = forked.settledCost.Add(p.nodeCost(current, cloned))
+		if p.placePods(ctx, &forked, nodeIdx, pods) > 0 {
+			expanded = append(expanded, forked)
+		}
+	}
+	return p.prune(expanded), nil
+}
+
+// placePods tries to place the pods not placed yet on the node with the provided index,
+// and returns the number of placed pods
+func (p optimizingPlanner) placePods(ctx context.Context, s *searchState, nodeIdx int, pods []v1.Pod) int {
+	var nPlaced int
+	node := s.nodes[nodeIdx]
+	for i, pod := range pods {
+		if s.podPlaced[i] {
+			continue
+		}
+		if !fitsScalarResources(pod, node.NodeInfo()) {
+			continue
+		}
+		if canSchedule, _ := p.canSchedulePod(ctx, pod, node.NodeInfo()); !canSchedule {
+			continue
+		}
+		if err := node.AddPod(pod); err != nil {
+			continue
+		}
+		s.podPlaced[i] = true
+		s.placed[i] = node.GetName()
+		s.weight += podWeight(pod)
+		nPlaced++
+	}
+	return nPlaced
+}
+
+// prune returns the best states, sorted from the best one, keeping at most beamWidth states
+func (p optimizingPlanner) prune(states []searchState) []searchState {
+	sort.SliceStable(states, func(i, j int) bool {
+		return states[i].betterThan(states[j])
+	})
+	if len(states) > p.beamWidth {
+		return states[:p.beamWidth]
+	}
+	return states
+}
+
+// unplacedSlices returns the slices requested by the pods not placed yet in the state provided as argument
+func (p optimizingPlanner) unplacedSlices(s searchState, pods []v1.Pod) map[gpu.Slice]int {
+	res := make(map[gpu.Slice]int)
+	for i, pod := range pods {
+		if s.podPlaced[i] {
+			continue
+		}
+		for slice, quantity := range p.sliceCalculator.GetRequestedSlices(pod) {
+			res[slice] += quantity
+		}
+	}
+	return res
+}
+
+// rejectionReasons returns the reasons why the pod cannot be placed on the node
+func (p optimizingPlanner) rejectionReasons(ctx context.Context, pod v1.Pod, node PartitionableNode) []string {
+	if !fitsScalarResources(pod, node.NodeInfo()) {
+		return []string{"node does not provide enough free resources"}
+	}
+	if canSchedule, reasons := p.canSchedulePod(ctx, pod, node.NodeInfo()); !canSchedule {
+		return reasons
+	}
+	return []string{"node resources are not provided by a single GPU"}
+}
+
+// fitsScalarResources returns true if the node has enough free scalar resources
+// (e.g. GPU slices) for the scalar resources requested by the pod
+func fitsScalarResources(pod v1.Pod, nodeInfo framework.NodeInfo) bool {
+	request := resource.FromListToFramework(resource.ComputePodRequest(pod))
+	for r, q := range request.ScalarResources {
+		var allocatable, requested int64
+		if nodeInfo.Allocatable != nil {
+			allocatable = nodeInfo.Allocatable.ScalarResources[r]
+		}
+		if nodeInfo.Requested != nil {
+			requested = nodeInfo.Requested.ScalarResources[r]
+		}
+		if allocatable-requested < q {
+			return false
+		}
+	}
+	return true
+}
+
+// podWeight returns the weight of a pod when computing the overall weight of the scheduled pods:
+// pods with higher priority have higher weight, and any pod has at least weight 1
+func podWeight(pod v1.Pod) int64 {
+	priority := int64(corev1.PodPriority(&pod))
+	if priority < 0 {
+		return 1
+	}
+	return priority + 1
+}
+
+// nodeCost returns the cost of reconfiguring the node from its current partitioning
+func (p optimizingPlanner) nodeCost(current state.PartitioningState, node PartitionableNode) ReconfigurationCost {
+	return GetNodeReconfigurationCost(current[node.GetName()], p.partitioner.GetPartitioning(node))
+}
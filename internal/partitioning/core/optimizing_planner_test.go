@@ -0,0 +1,321 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	partitioning_mig "github.com/nebuly-ai/nos/internal/partitioning/mig"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	nosresource "github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	scheduler_mock "github.com/nebuly-ai/nos/pkg/test/mocks/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+func TestOptimizingPlanner__Plan(t *testing.T) {
+	a30Node := func(name string, gpuCount int, annotations map[string]string, allocatable v1.ResourceList) v1.Node {
+		return factory.BuildNode(name).
+			WithAnnotations(annotations).
+			WithAllocatableResources(allocatable).
+			WithLabels(map[string]string{
+				constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+				constant.LabelNvidiaCount:     fmt.Sprintf("%d", gpuCount),
+				v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+			}).
+			Get()
+	}
+	migPod := func(name string, profile mig.ProfileName, priority int32) v1.Pod {
+		return factory.BuildPod("ns-1", name).
+			WithPriority(priority).
+			WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(profile.AsResourceName(), 1).
+					Get(),
+			).
+			Get()
+	}
+
+	testCases := []struct {
+		name          string
+		snapshotNodes []v1.Node
+		candidatePods []v1.Pod
+
+		expectedPartitioning state.PartitioningState
+		expectedPlacedPods   []string
+	}{
+		{
+			name: "Pods with higher priority are preferred over a higher number of pods",
+			snapshotNodes: []v1.Node{
+				a30Node("node-1", 1, map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile4g24gb, nosresource.StatusFree): "1",
+				}, v1.ResourceList{
+					mig.Profile4g24gb.AsResourceName(): *resource.NewQuantity(1, resource.DecimalSI),
+				}),
+			},
+			candidatePods: []v1.Pod{
+				migPod("pd-1", mig.Profile1g6gb, 0),
+				migPod("pd-2", mig.Profile1g6gb, 0),
+				migPod("pd-3", mig.Profile4g24gb, 100),
+			},
+			expectedPartitioning: state.PartitioningState{
+				"node-1": {
+					GPUs: []state.GPUPartitioning{
+						{
+							GPUIndex:  0,
+							Resources: map[v1.ResourceName]int{mig.Profile4g24gb.AsResourceName(): 1},
+						},
+					},
+				},
+			},
+			expectedPlacedPods: []string{"ns-1/pd-3"},
+		},
+		{
+			name: "Only the GPUs required for scheduling the pods are reconfigured",
+			snapshotNodes: []v1.Node{
+				a30Node("node-1", 2, map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile4g24gb, nosresource.StatusFree): "1",
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, mig.Profile4g24gb, nosresource.StatusFree): "1",
+				}, v1.ResourceList{
+					mig.Profile4g24gb.AsResourceName(): *resource.NewQuantity(2, resource.DecimalSI),
+				}),
+			},
+			candidatePods: []v1.Pod{
+				migPod("pd-1", mig.Profile2g12gb, 0),
+				migPod("pd-2", mig.Profile2g12gb, 0),
+			},
+			expectedPartitioning: state.PartitioningState{
+				"node-1": {
+					GPUs: []state.GPUPartitioning{
+						{
+							GPUIndex:  0,
+							Resources: map[v1.ResourceName]int{mig.Profile2g12gb.AsResourceName(): 2},
+						},
+						{
+							GPUIndex:  1,
+							Resources: map[v1.ResourceName]int{mig.Profile4g24gb.AsResourceName(): 1},
+						},
+					},
+				},
+			},
+			expectedPlacedPods: []string{"ns-1/pd-1", "ns-1/pd-2"},
+		},
+		{
+			name: "Pods are spread across nodes",
+			snapshotNodes: []v1.Node{
+				a30Node("node-1", 1, map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile2g12gb, nosresource.StatusUsed): "2",
+				}, v1.ResourceList{
+					mig.Profile2g12gb.AsResourceName(): *resource.NewQuantity(2, resource.DecimalSI),
+				}),
+				a30Node("node-2", 1, map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile4g24gb, nosresource.StatusFree): "1",
+				}, v1.ResourceList{
+					mig.Profile4g24gb.AsResourceName(): *resource.NewQuantity(1, resource.DecimalSI),
+				}),
+			},
+			candidatePods: []v1.Pod{
+				migPod("pd-1", mig.Profile1g6gb, 0),
+				migPod("pd-2", mig.Profile1g6gb, 0),
+			},
+			expectedPartitioning: state.PartitioningState{
+				"node-1": {
+					GPUs: []state.GPUPartitioning{
+						{
+							GPUIndex:  0,
+							Resources: map[v1.ResourceName]int{mig.Profile2g12gb.AsResourceName(): 2},
+						},
+					},
+				},
+				"node-2": {
+					GPUs: []state.GPUPartitioning{
+						{
+							GPUIndex: 0,
+							Resources: map[v1.ResourceName]int{
+								mig.Profile1g6gb.AsResourceName():  2,
+								mig.Profile2g12gb.AsResourceName(): 1,
+							},
+						},
+					},
+				},
+			},
+			expectedPlacedPods: []string{"ns-1/pd-1", "ns-1/pd-2"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockedScheduler := scheduler_mock.NewFramework(t)
+			mockedScheduler.On(
+				"RunPreFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+			mockedScheduler.On(
+				"RunFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
+
+			snapshot := newSnapshotFromNodes(tt.snapshotNodes, partitioning_mig.NewSnapshotTaker())
+			planner := partitioning_mig.NewPlanner(mockedScheduler, core.PlannerOptions{Optimizing: true})
+			plan, err := planner.Plan(context.Background(), snapshot, tt.candidatePods)
+			assert.NoError(t, err)
+
+			assert.True(
+				t,
+				tt.expectedPartitioning.Equal(plan.DesiredState),
+				"expected %v, got %v",
+				tt.expectedPartitioning,
+				plan.DesiredState,
+			)
+			placedPods := make([]string, 0)
+			for _, p := range plan.Explanation.Pods {
+				if p.Fits {
+					placedPods = append(placedPods, p.Pod)
+				}
+			}
+			assert.ElementsMatch(t, tt.expectedPlacedPods, placedPods)
+		})
+	}
+}
+
+func TestOptimizingPlanner__Plan__SearchBudgetExceeded(t *testing.T) {
+	nodes := make([]v1.Node, 0, 3)
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, newA100Node(fmt.Sprintf("node-%d", i), 2))
+	}
+	pods := newMigPods(6, mig.Profile1g5gb, mig.Profile2g10gb, mig.Profile3g20gb)
+
+	plan := func(opts core.PlannerOptions) core.PartitioningPlan {
+		snapshot := newSnapshotFromNodes(nodes, partitioning_mig.NewSnapshotTaker())
+		planner := partitioning_mig.NewPlanner(newSuccessfulScheduler(t), opts)
+		res, err := planner.Plan(context.Background(), snapshot, pods)
+		assert.NoError(t, err)
+		return res
+	}
+
+	// The search exceeds the budget, so the planner falls back to the greedy plan instead of failing
+	greedy := plan(core.PlannerOptions{})
+	fallback := plan(core.PlannerOptions{Optimizing: true, MaxSearchExpansions: 1})
+	assert.Equal(t, len(pods), greedy.Explanation.PlacedPods())
+	assert.Equal(t, greedy.Explanation.PlacedPods(), fallback.Explanation.PlacedPods())
+
+	// With the default budget the search completes
+	optimizing := plan(core.PlannerOptions{Optimizing: true})
+	assert.Equal(t, len(pods), optimizing.Explanation.PlacedPods())
+}
+
+func BenchmarkOptimizingPlanner__Plan(b *testing.B) {
+	for _, nNodes := range []int{1, 10, 50} {
+		nodes := make([]v1.Node, 0, nNodes)
+		for i := 0; i < nNodes; i++ {
+			nodes = append(nodes, newA100Node(fmt.Sprintf("node-%d", i), 8))
+		}
+		pods := newMigPods(4*nNodes, mig.Profile1g5gb, mig.Profile2g10gb, mig.Profile3g20gb, mig.Profile4g20gb)
+
+		for _, opts := range []core.PlannerOptions{
+			{},
+			{Optimizing: true},
+			{Optimizing: true, MaxSearchExpansions: 1 << 30},
+		} {
+			name := fmt.Sprintf("nodes=%d/optimizing=%t/maxSearchExpansions=%d", nNodes, opts.Optimizing, opts.MaxSearchExpansions)
+			b.Run(name, func(b *testing.B) {
+				planner := partitioning_mig.NewPlanner(newSuccessfulScheduler(b), opts)
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					snapshot := newSnapshotFromNodes(nodes, partitioning_mig.NewSnapshotTaker())
+					b.StartTimer()
+					if _, err := planner.Plan(context.Background(), snapshot, pods); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// newA100Node returns a MIG node with A100 40GB GPUs whose geometry is a free 7g.40gb device per GPU
+func newA100Node(name string, gpuCount int) v1.Node {
+	annotations := make(map[string]string, gpuCount)
+	for i := 0; i < gpuCount; i++ {
+		annotations[fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, i, mig.Profile7g40gb, nosresource.StatusFree)] = "1"
+	}
+	return factory.BuildNode(name).
+		WithAnnotations(annotations).
+		WithAllocatableResources(v1.ResourceList{
+			mig.Profile7g40gb.AsResourceName(): *resource.NewQuantity(int64(gpuCount), resource.DecimalSI),
+		}).
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct:   string(gpu.GPUModel_A100_SXM4_40GB),
+			constant.LabelNvidiaCount:     fmt.Sprintf("%d", gpuCount),
+			v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+		}).
+		Get()
+}
+
+// newMigPods returns n pods, each requesting one of the profiles provided as argument in round-robin order
+func newMigPods(n int, profiles ...mig.ProfileName) []v1.Pod {
+	pods := make([]v1.Pod, 0, n)
+	for i := 0; i < n; i++ {
+		pods = append(pods, factory.BuildPod("ns-1", fmt.Sprintf("pd-%d", i)).
+			WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(profiles[i%len(profiles)].AsResourceName(), 1).
+					Get(),
+			).
+			Get(),
+		)
+	}
+	return pods
+}
+
+// newSuccessfulScheduler returns a scheduler framework whose plugins always succeed
+func newSuccessfulScheduler(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *scheduler_mock.Framework {
+	mockedScheduler := scheduler_mock.NewFramework(t)
+	mockedScheduler.On(
+		"RunPreFilterPlugins",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+	mockedScheduler.On(
+		"RunFilterPlugins",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
+	return mockedScheduler
+}
@@ -19,28 +19,58 @@ package core
 import (
 	"context"
 	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/metrics"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"strconv"
+	"sort"
 	"time"
 )
 
 type PartitioningPlan struct {
 	DesiredState state.PartitioningState
+	Explanation  PlanExplanation
 	id           string
+	kind         gpu.PartitioningKind
 }
 
-func NewPartitioningPlanId() string {
-	return strconv.FormatInt(time.Now().UTC().Unix(), 10)
+// NewPartitioningPlanId returns a new unique ID for a partitioning plan of the kind provided as argument
+func NewPartitioningPlanId(kind gpu.PartitioningKind) string {
+	return gpu.NewPlanId(kind).String()
 }
 
-func NewPartitioningPlan(s state.PartitioningState) PartitioningPlan {
+func NewPartitioningPlan(kind gpu.PartitioningKind, s state.PartitioningState) PartitioningPlan {
 	return PartitioningPlan{
 		DesiredState: s,
-		id:           NewPartitioningPlanId(),
+		id:           NewPartitioningPlanId(kind),
+		kind:         kind,
+	}
+}
+
+// Clock returns the current time. The planners and the compactors use it for generating the IDs of the
+// partitioning plans and for checking the node cooldown. A nil Clock uses the system clock.
+type Clock func() time.Time
+
+func (c Clock) now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c()
+}
+
+// newPartitioningPlan returns a new plan whose ID is generated from the current time of the clock.
+// With the system clock the IDs are unique, whereas a fixed clock makes them reproducible.
+func (c Clock) newPartitioningPlan(kind gpu.PartitioningKind, s state.PartitioningState) PartitioningPlan {
+	if c == nil {
+		return NewPartitioningPlan(kind, s)
+	}
+	return PartitioningPlan{
+		DesiredState: s,
+		id:           gpu.PlanId{Kind: kind, Generation: c().UTC().UnixMicro()}.String(),
+		kind:         kind,
 	}
 }
 
@@ -48,15 +78,37 @@ func (p PartitioningPlan) GetId() string {
 	return p.id
 }
 
+func (p PartitioningPlan) GetKind() gpu.PartitioningKind {
+	return p.kind
+}
+
 type planner struct {
+	kind               gpu.PartitioningKind
 	sliceCalculator    gpu.SliceCalculator
 	schedulerFramework framework.Framework
 	partitioner        PartitionCalculator
 	sorter             Sorter
+	nodeCooldown       time.Duration
+	clock              Clock
 }
 
-func NewPlanner(partitioner PartitionCalculator, sliceCalculator gpu.SliceCalculator, schedulerFramework framework.Framework) Planner {
+func NewPlanner(
+	kind gpu.PartitioningKind,
+	partitioner PartitionCalculator,
+	sliceCalculator gpu.SliceCalculator,
+	schedulerFramework framework.Framework,
+) Planner {
+	return newPlanner(kind, partitioner, sliceCalculator, schedulerFramework)
+}
+
+func newPlanner(
+	kind gpu.PartitioningKind,
+	partitioner PartitionCalculator,
+	sliceCalculator gpu.SliceCalculator,
+	schedulerFramework framework.Framework,
+) planner {
 	return planner{
+		kind:               kind,
 		partitioner:        partitioner,
 		sliceCalculator:    sliceCalculator,
 		schedulerFramework: schedulerFramework,
@@ -64,118 +116,322 @@ func NewPlanner(partitioner PartitionCalculator, sliceCalculator gpu.SliceCalcul
 	}
 }
 
+// PlannerOptions defines the Planner used for computing the partitioning plans
+type PlannerOptions struct {
+	// Optimizing enables the optimizing planner (see NewOptimizingPlanner) instead of the greedy one
+	Optimizing bool
+	// BeamWidth is the beam width of the optimizing planner
+	BeamWidth int
+	// MaxSearchExpansions is the number of partial partitionings that the optimizing planner can explore
+	// before falling back to the greedy planner. Zero uses DefaultMaxSearchExpansions.
+	MaxSearchExpansions int
+	// NodeCooldown is the minimum amount of time that must elapse between two partitionings
+	// of the same node. Nodes partitioned more recently are not considered as candidates for
+	// a new partitioning. Zero disables the cooldown.
+	NodeCooldown time.Duration
+	// Clock provides the time used for generating the plan IDs and for checking the node cooldown.
+	// Nil uses the system clock.
+	Clock Clock
+}
+
+// NewPlannerWithOptions returns the Planner defined by the options provided as argument
+func NewPlannerWithOptions(
+	kind gpu.PartitioningKind,
+	partitioner PartitionCalculator,
+	sliceCalculator gpu.SliceCalculator,
+	schedulerFramework framework.Framework,
+	opts PlannerOptions,
+) Planner {
+	p := newPlanner(kind, partitioner, sliceCalculator, schedulerFramework)
+	p.nodeCooldown = opts.NodeCooldown
+	p.clock = opts.Clock
+	if opts.Optimizing {
+		return newOptimizingPlanner(p, opts.BeamWidth, opts.MaxSearchExpansions)
+	}
+	return p
+}
+
+// Plan greedily partitions the candidate nodes one after the other, starting from the nodes whose geometry
+// can be updated for providing the lacking slices with the lowest reconfiguration cost. For each node, the planner
+// changes the node geometry only if the new geometry allows to place more candidate pods than the current one,
+// so that nodes are not reconfigured when the pods fit them as they are.
 func (p planner) Plan(ctx context.Context, snapshot Snapshot, candidatePods []v1.Pod) (PartitioningPlan, error) {
 	logger := log.FromContext(ctx)
 	logger.V(3).Info("planning desired GPU partitioning", "candidatePods", len(candidatePods))
-	var err error
 
 	partitioningState := snapshot.GetPartitioningState()
+	explainer := newPlanExplainer(candidatePods)
+	newPlan := func() PartitioningPlan {
+		plan := p.clock.newPartitioningPlan(p.kind, partitioningState)
+		plan.Explanation = explainer.explanation()
+		return plan
+	}
 	tracker := NewSliceTracker(
 		snapshot,
 		p.sliceCalculator,
 		candidatePods,
 	)
+	metrics.ObserveLackingSlices(p.kind, tracker.GetRequestedSlices(), tracker.GetLackingSlices())
 
 	// No lacking slices, nothing to do
 	if len(tracker.GetLackingSlices()) == 0 {
 		logger.V(1).Info("no lacking profiles, nothing to do")
-		return NewPartitioningPlan(partitioningState), nil
+		explainer.fitCurrentPartitioning(candidatePods, p.sliceCalculator)
+		return newPlan(), nil
 	}
 
 	// Sort candidate pods
 	sortedCandidatePods := p.sorter.Sort(candidatePods)
+	podPlaced := make([]bool, len(sortedCandidatePods))
 
-	// Get candidate nodes
-	candidateNodes := snapshot.GetCandidateNodes()
+	// Get candidate nodes, ranked by reconfiguration cost
+	candidateNodes := p.getCandidateNodes(ctx, snapshot)
 	logger.V(1).Info(fmt.Sprintf("found %d candidate nodes", len(candidateNodes)))
+	candidateNodes, err := p.rankCandidateNodes(ctx, candidateNodes, partitioningState, tracker.GetLackingSlices())
+	if err != nil {
+		return PartitioningPlan{}, err
+	}
 
 	for _, n := range candidateNodes {
 		// If there are no more lacking slices we can stop
 		lackingSlices := tracker.GetLackingSlices()
 		if len(lackingSlices) == 0 {
-			return NewPartitioningPlan(partitioningState), nil
+			return newPlan(), nil
 		}
 
-		// Fork the state
-		if err = snapshot.Fork(); err != nil {
-			return PartitioningPlan{}, fmt.Errorf("error forking snapshot, this should never happen: %v", err)
+		// Try to add candidate pods to the node as it is
+		current, err := p.tryNode(ctx, snapshot, n, sortedCandidatePods, podPlaced)
+		if err != nil {
+			return PartitioningPlan{}, err
 		}
+		snapshot.Revert()
 
-		// Try to update geometry
-		nodeGeometryUpdated, err := n.UpdateGeometryFor(tracker.GetLackingSlices())
+		// Try to add candidate pods to the node with an updated geometry
+		updatedNode := n.Clone().(PartitionableNode)
+		nodeGeometryUpdated, err := updatedNode.UpdateGeometryFor(lackingSlices)
 		if err != nil {
 			return PartitioningPlan{}, err
 		}
+		best, useUpdated := current, false
 		if nodeGeometryUpdated {
-			logger.V(1).Info("updated node geometry", "node", n.GetName(), "geometry", n.Geometry())
-			snapshot.SetNode(n)
+			logger.V(1).Info("updated node geometry", "node", n.GetName(), "geometry", updatedNode.Geometry())
+			updated, err := p.tryNode(ctx, snapshot, updatedNode, sortedCandidatePods, podPlaced)
+			if err != nil {
+				return PartitioningPlan{}, err
+			}
+			logger.V(1).Info(
+				"tried updated node geometry",
+				"node",
+				n.GetName(),
+				"placedWithCurrentGeometry",
+				len(current.placed),
+				"placedWithUpdatedGeometry",
+				len(updated.placed),
+			)
+			// The current geometry has no reconfiguration cost, so the new geometry
+			// is used only if it allows to place pods with a higher overall weight
+			if updated.weight > current.weight {
+				best, useUpdated = updated, true
+			} else {
+				snapshot.Revert()
+			}
+			// If no pods can be added to the node, explain why they do not fit the updated geometry
+			if len(updated.placed) == 0 && len(current.placed) == 0 {
+				best = updated
+			}
 		}
 
-		// Try to add candidate pods to the node with the updated geometry
-		var addedPods int
-		for _, pod := range sortedCandidatePods {
-			if added := p.tryAddPod(ctx, pod, n.GetName(), snapshot); !added {
-				logger.V(1).Info(
-					"pod does not fit node",
-					"namespace",
-					pod.Namespace,
-					"pod",
-					pod.Name,
-					"node",
-					n.GetName,
-				)
-				continue
+		// If no pods can be added to the node then there is nothing to commit
+		if len(best.placed) == 0 {
+			for _, r := range best.rejected {
+				explainer.rejected(r.pod, n.GetName(), r.reasons)
 			}
+			continue
+		}
+
+		// Otherwise, commit the changes of the best attempt
+		if !useUpdated {
+			if best, err = p.tryNode(ctx, snapshot, n, sortedCandidatePods, podPlaced); err != nil {
+				return PartitioningPlan{}, err
+			}
+		}
+		snapshot.Commit()
+		for _, r := range best.rejected {
+			explainer.rejected(r.pod, n.GetName(), r.reasons)
+		}
+		for _, i := range best.placed {
+			podPlaced[i] = true
+			explainer.placed(sortedCandidatePods[i], n.GetName())
+			tracker.Remove(sortedCandidatePods[i])
+		}
+		partitioningState[n.GetName()] = p.partitioner.GetPartitioning(best.node)
+	}
+
+	return newPlan(), nil
+}
+
+// rankCandidateNodes sorts the candidate nodes by the cost of reconfiguring them with the geometry
+// that provides the lacking slices provided as argument, so that the cheapest nodes are partitioned first.
+// Nodes whose geometry does not need to change have no cost. Nodes with the same cost keep their order.
+func (p planner) rankCandidateNodes(
+	ctx context.Context,
+	nodes []PartitionableNode,
+	current state.PartitioningState,
+	lackingSlices map[gpu.Slice]int,
+) ([]PartitionableNode, error) {
+	logger := log.FromContext(ctx)
+	costs := make(map[string]ReconfigurationCost, len(nodes))
+	for _, n := range nodes {
+		updatedNode := n.Clone().(PartitionableNode)
+		updated, err := updatedNode.UpdateGeometryFor(lackingSlices)
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			continue
+		}
+		costs[n.GetName()] = GetNodeReconfigurationCost(current[n.GetName()], p.partitioner.GetPartitioning(updatedNode))
+		logger.V(1).Info("computed node reconfiguration cost", "node", n.GetName(), "cost", costs[n.GetName()])
+	}
+	res := make([]PartitionableNode, len(nodes))
+	copy(res, nodes)
+	sort.SliceStable(res, func(i, j int) bool {
+		return costs[res[i].GetName()].Less(costs[res[j].GetName()])
+	})
+	return res, nil
+}
+
+// getCandidateNodes returns the candidate nodes of the snapshot, excluding the nodes
+// that have been partitioned too recently according to the node cooldown
+func (p planner) getCandidateNodes(ctx context.Context, snapshot Snapshot) []PartitionableNode {
+	candidateNodes := snapshot.GetCandidateNodes()
+	if p.nodeCooldown <= 0 {
+		return candidateNodes
+	}
+	logger := log.FromContext(ctx)
+	res := make([]PartitionableNode, 0, len(candidateNodes))
+	for _, n := range candidateNodes {
+		if until, cooling := isCoolingDown(n, p.nodeCooldown, p.clock.now()); cooling {
+			logger.V(1).Info("skipping node partitioned too recently", "node", n.GetName(), "cooldownUntil", until)
+			continue
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// isCoolingDown returns true if the last partitioning plan of the node has been created less than
+// cooldown before now, together with the time at which the cooldown of the node expires.
+// Plans applied for initializing the partitioning of the node do not trigger any cooldown.
+func isCoolingDown(n PartitionableNode, cooldown time.Duration, now time.Time) (time.Time, bool) {
+	nodeInfo := n.NodeInfo()
+	node := nodeInfo.Node()
+	if node == nil {
+		return time.Time{}, false
+	}
+	planId, ok := gpu.GetLastPlanId(*node)
+	if !ok {
+		return time.Time{}, false
+	}
+	if node.Annotations[v1alpha1.AnnotationInitialPartitioningPlan] == planId.String() {
+		return time.Time{}, false
+	}
+	until := planId.CreationTime().Add(cooldown)
+	return until, now.Before(until)
+}
+
+// nodeAttempt is the outcome of trying to add the candidate pods to a node
+type nodeAttempt struct {
+	node PartitionableNode
+	// placed contains the indexes of the candidate pods added to the node
+	placed   []int
+	rejected []podRejection
+	weight   int64
+}
+
+type podRejection struct {
+	pod     v1.Pod
+	reasons []string
+}
+
+// tryNode forks the snapshot, sets the node provided as argument and tries to add to it the candidate
+// pods that have not been placed yet. The snapshot is left forked, so that the caller can either
+// commit or revert the changes.
+func (p planner) tryNode(
+	ctx context.Context,
+	snapshot Snapshot,
+	n PartitionableNode,
+	pods []v1.Pod,
+	podPlaced []bool,
+) (nodeAttempt, error) {
+	logger := log.FromContext(ctx)
+	if err := snapshot.Fork(); err != nil {
+		return nodeAttempt{}, fmt.Errorf("error forking snapshot, this should never happen: %v", err)
+	}
+	node := n.Clone().(PartitionableNode)
+	snapshot.SetNode(node)
+
+	res := nodeAttempt{node: node}
+	for i, pod := range pods {
+		if podPlaced[i] {
+			continue
+		}
+		if added, reasons := p.tryAddPod(ctx, pod, n.GetName(), snapshot); !added {
+			res.rejected = append(res.rejected, podRejection{pod: pod, reasons: reasons})
 			logger.V(1).Info(
-				"pod fits node",
+				"pod does not fit node",
 				"namespace",
 				pod.Namespace,
 				"pod",
 				pod.Name,
 				"node",
-				n.GetName,
+				n.GetName(),
 			)
-			partitioningState[n.GetName()] = p.partitioner.GetPartitioning(n)
-			tracker.Remove(pod)
-			addedPods++
-		}
-
-		// If the new geometry allowed to add any pod then commit changes, otherwise revert
-		if addedPods == 0 {
-			snapshot.Revert()
-		}
-		if addedPods > 0 {
-			snapshot.Commit()
+			continue
 		}
+		logger.V(1).Info(
+			"pod fits node",
+			"namespace",
+			pod.Namespace,
+			"pod",
+			pod.Name,
+			"node",
+			n.GetName(),
+		)
+		res.placed = append(res.placed, i)
+		res.weight += podWeight(pod)
 	}
-
-	return NewPartitioningPlan(partitioningState), nil
+	return res, nil
 }
 
-func (p planner) tryAddPod(ctx context.Context, pod v1.Pod, nodeName string, snapshot Snapshot) bool {
+// tryAddPod tries to add the Pod to the node with the provided name. If the Pod cannot be added,
+// it returns false together with the reasons why it does not fit the node.
+func (p planner) tryAddPod(ctx context.Context, pod v1.Pod, nodeName string, snapshot Snapshot) (bool, []string) {
 	// First we check if there are any lacking slices,
 	// if so we avoid running a scheduler cycle
 	// since we already know that it is going to fail
-	if len(snapshot.GetLackingSlices(pod)) > 0 {
-		return false
+	if lackingSlices := snapshot.GetLackingSlices(pod); len(lackingSlices) > 0 {
+		return false, []string{lackingSlicesReason(lackingSlices)}
 	}
 	// Simulate scheduling
 	nodeInfo, ok := snapshot.GetNode(nodeName)
 	if !ok {
-		return false
+		return false, []string{"node not found in snapshot"}
 	}
-	if !p.canSchedulePod(ctx, pod, nodeInfo.NodeInfo()) {
-		return false
+	if canSchedule, reasons := p.canSchedulePod(ctx, pod, nodeInfo.NodeInfo()); !canSchedule {
+		return false, reasons
 	}
 	// Add Pod to snapshot
 	if err := snapshot.AddPod(nodeName, pod); err != nil {
-		return false
+		return false, []string{err.Error()}
 	}
-	return true
+	return true, nil
 }
 
-// canSchedulePod runs a scheduler cycle to check whether the Pod can be scheduled on the specified Node
-func (p planner) canSchedulePod(ctx context.Context, pod v1.Pod, node framework.NodeInfo) bool {
+// canSchedulePod runs a scheduler cycle to check whether the Pod can be scheduled on the specified Node.
+// If the Pod cannot be scheduled, it returns false together with the statuses of the scheduler
+// plugins that rejected it.
+func (p planner) canSchedulePod(ctx context.Context, pod v1.Pod, node framework.NodeInfo) (bool, []string) {
 	logger := log.FromContext(ctx)
 	logger.V(1).Info("simulating pod scheduling", "pod", pod.Name, "namespace", pod.Namespace)
 	cycleState := framework.NewCycleState()
@@ -190,11 +446,12 @@ func (p planner) canSchedulePod(ctx context.Context, pod v1.Pod, node framework.
 		preFilterStatus,
 	)
 	if !preFilterStatus.IsSuccess() {
-		return false
+		return false, []string{pluginStatusReason("PreFilter", preFilterStatus.FailedPlugin(), preFilterStatus)}
 	}
 
 	// Run Filter plugins
-	filterStatus := p.schedulerFramework.RunFilterPlugins(ctx, cycleState, &pod, &node).Merge()
+	filterStatuses := p.schedulerFramework.RunFilterPlugins(ctx, cycleState, &pod, &node)
+	filterStatus := filterStatuses.Merge()
 	logger.V(1).Info(
 		"scheduler Filter status",
 		"statusCode",
@@ -202,6 +459,15 @@ func (p planner) canSchedulePod(ctx context.Context, pod v1.Pod, node framework.
 		"status",
 		filterStatus,
 	)
-
-	return filterStatus.IsSuccess()
+	if filterStatus.IsSuccess() {
+		return true, nil
+	}
+	reasons := make([]string, 0, len(filterStatuses))
+	for plugin, status := range filterStatuses {
+		if !status.IsSuccess() {
+			reasons = append(reasons, pluginStatusReason("Filter", plugin, status))
+		}
+	}
+	sort.Strings(reasons)
+	return false, reasons
 }
@@ -38,6 +38,7 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestPlanner__Plan__MIG(t *testing.T) {
@@ -480,7 +481,7 @@ func TestPlanner__Plan__MIG(t *testing.T) {
 			).Return(framework.PluginToStatus{"": tt.schedulerFilterStatus}).Maybe()
 
 			snapshot := newSnapshotFromNodes(tt.snapshotNodes, partitioning_mig.NewSnapshotTaker())
-			planner := partitioning_mig.NewPlanner(mockedScheduler)
+			planner := partitioning_mig.NewPlanner(mockedScheduler, core.PlannerOptions{})
 			plan, err := planner.Plan(context.Background(), snapshot, tt.candidatePods)
 
 			// Compute overall partitioning ignoring GPU index
@@ -507,6 +508,217 @@ func TestPlanner__Plan__MIG(t *testing.T) {
 	}
 }
 
+func TestPlanner__Plan__Explanation(t *testing.T) {
+	node := factory.BuildNode("node-1").
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+			constant.LabelNvidiaCount:     "1",
+			v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+		}).
+		Get()
+	pod := factory.BuildPod("ns-1", "pd-1").
+		WithContainer(
+			factory.BuildContainer("test", "test").
+				WithScalarResourceRequest(mig.Profile1g6gb.AsResourceName(), 1).
+				Get(),
+		).
+		Get()
+
+	testCases := []struct {
+		name                  string
+		schedulerFilterStatus framework.PluginToStatus
+		expected              core.PlanExplanation
+	}{
+		{
+			name:                  "Pod fits node",
+			schedulerFilterStatus: framework.PluginToStatus{"": framework.NewStatus(framework.Success)},
+			expected: core.PlanExplanation{
+				Pods: []core.PodExplanation{
+					{
+						Pod:  "ns-1/pd-1",
+						Fits: true,
+						Node: "node-1",
+					},
+				},
+			},
+		},
+		{
+			name: "Pod rejected by scheduler Filter plugin",
+			schedulerFilterStatus: framework.PluginToStatus{
+				"TaintToleration": framework.NewStatus(framework.Success),
+				"NodeAffinity":    framework.NewStatus(framework.UnschedulableAndUnresolvable, "node(s) didn't match selector"),
+			},
+			expected: core.PlanExplanation{
+				Pods: []core.PodExplanation{
+					{
+						Pod:  "ns-1/pd-1",
+						Fits: false,
+						Rejections: map[string][]string{
+							"node-1": {"Filter/NodeAffinity: node(s) didn't match selector (UnschedulableAndUnresolvable)"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockedScheduler := scheduler_mock.NewFramework(t)
+			mockedScheduler.On(
+				"RunPreFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+			mockedScheduler.On(
+				"RunFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(tt.schedulerFilterStatus).Maybe()
+
+			snapshot := newSnapshotFromNodes([]v1.Node{node}, partitioning_mig.NewSnapshotTaker())
+			planner := partitioning_mig.NewPlanner(mockedScheduler, core.PlannerOptions{})
+			plan, err := planner.Plan(context.Background(), snapshot, []v1.Pod{pod})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, plan.Explanation)
+		})
+	}
+}
+
+func TestPlanner__Plan__Explanation__NoLackingSlices(t *testing.T) {
+	node := factory.BuildNode("node-1").
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+			constant.LabelNvidiaCount:     "1",
+			v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+		}).
+		WithAnnotations(map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile1g6gb, nosresource.StatusFree): "1",
+		}).
+		WithAllocatableResources(v1.ResourceList{
+			mig.Profile1g6gb.AsResourceName(): *resource.NewQuantity(1, resource.DecimalSI),
+		}).
+		Get()
+	migPod := factory.BuildPod("ns-1", "pd-1").
+		WithContainer(
+			factory.BuildContainer("test", "test").
+				WithScalarResourceRequest(mig.Profile1g6gb.AsResourceName(), 1).
+				Get(),
+		).
+		Get()
+	cpuPod := factory.BuildPod("ns-1", "pd-2").
+		WithContainer(
+			factory.BuildContainer("test", "test").
+				WithCPUMilliRequest(100).
+				Get(),
+		).
+		Get()
+	expected := core.PlanExplanation{
+		Pods: []core.PodExplanation{
+			{Pod: "ns-1/pd-1", Fits: true},
+			{Pod: "ns-1/pd-2", Fits: false},
+		},
+	}
+
+	for _, opts := range []core.PlannerOptions{{}, {Optimizing: true}} {
+		t.Run(fmt.Sprintf("optimizing=%t", opts.Optimizing), func(t *testing.T) {
+			snapshot := newSnapshotFromNodes([]v1.Node{node}, partitioning_mig.NewSnapshotTaker())
+			planner := partitioning_mig.NewPlanner(scheduler_mock.NewFramework(t), opts)
+			plan, err := planner.Plan(context.Background(), snapshot, []v1.Pod{migPod, cpuPod})
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, plan.Explanation)
+			assert.Equal(t, 0, plan.Explanation.PlacedPods())
+		})
+	}
+}
+
+func TestPlanExplanation__PlacedPods(t *testing.T) {
+	explanation := core.PlanExplanation{
+		Pods: []core.PodExplanation{
+			{Pod: "ns-1/pd-1", Fits: true, Node: "node-1"},
+			{Pod: "ns-1/pd-2", Fits: true},
+			{Pod: "ns-1/pd-3", Fits: false, Rejections: map[string][]string{"node-1": {"lacking GPU slices"}}},
+			{Pod: "ns-1/pd-4", Fits: true, Node: "node-2"},
+		},
+	}
+	assert.Equal(t, 2, explanation.PlacedPods())
+	assert.Equal(t, 0, core.PlanExplanation{}.PlacedPods())
+}
+
+func TestPlanner__Plan__RanksNodesByReconfigurationCost(t *testing.T) {
+	mpsNode := func(name string, gpuCount int, annotations map[string]string, allocatable v1.ResourceList) v1.Node {
+		return factory.BuildNode(name).
+			WithAnnotations(annotations).
+			WithLabels(map[string]string{
+				constant.LabelNvidiaProduct:   string(gpu.GPUModel_A100_PCIe_80GB),
+				constant.LabelNvidiaCount:     strconv.Itoa(gpuCount),
+				constant.LabelNvidiaMemory:    strconv.Itoa(40000),
+				v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMps.String(),
+			}).
+			WithAllocatableResources(allocatable).
+			Get()
+	}
+	pod := func(name string) v1.Pod {
+		return factory.BuildPod("ns-1", name).
+			WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(slicing.ProfileName("20gb").AsResourceName(), 1).
+					Get(),
+			).
+			Get()
+	}
+
+	// Both nodes can provide the lacking slices, but node-1 would need to reconfigure
+	// both its GPUs while node-2 only its single GPU
+	nodes := []v1.Node{
+		mpsNode(
+			"node-1",
+			2,
+			map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "10gb", nosresource.StatusUsed): "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, "10gb", nosresource.StatusUsed): "1",
+			},
+			v1.ResourceList{
+				slicing.ProfileName("10gb").AsResourceName(): *resource.NewQuantity(2, resource.DecimalSI),
+			},
+		),
+		mpsNode("node-2", 1, map[string]string{}, v1.ResourceList{}),
+	}
+
+	mockedScheduler := scheduler_mock.NewFramework(t)
+	mockedScheduler.On(
+		"RunPreFilterPlugins",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+	mockedScheduler.On(
+		"RunFilterPlugins",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
+
+	snapshot := newSnapshotFromNodes(nodes, partitioning_ts.NewSnapshotTaker())
+	current := snapshot.GetPartitioningState()
+	planner := partitioning_ts.NewPlanner(mockedScheduler, core.PlannerOptions{})
+	plan, err := planner.Plan(context.Background(), snapshot, []v1.Pod{pod("pd-1"), pod("pd-2")})
+	assert.NoError(t, err)
+
+	assert.True(t, current["node-1"].Equal(plan.DesiredState["node-1"]))
+	assert.False(t, current["node-2"].Equal(plan.DesiredState["node-2"]))
+	for _, p := range plan.Explanation.Pods {
+		assert.True(t, p.Fits)
+		assert.Equal(t, "node-2", p.Node)
+	}
+}
+
 func TestPlanner__Plan__MPS(t *testing.T) {
 	testCases := []struct {
 		name                     string
@@ -834,7 +1046,7 @@ func TestPlanner__Plan__MPS(t *testing.T) {
 			).Return(framework.PluginToStatus{"": tt.schedulerFilterStatus}).Maybe()
 
 			snapshot := newSnapshotFromNodes(tt.snapshotNodes, partitioning_ts.NewSnapshotTaker())
-			planner := partitioning_ts.NewPlanner(mockedScheduler)
+			planner := partitioning_ts.NewPlanner(mockedScheduler, core.PlannerOptions{})
 			plan, err := planner.Plan(context.Background(), snapshot, tt.candidatePods)
 
 			overallGpuPartitioning := make([]state.GPUPartitioning, 0)
@@ -853,6 +1065,175 @@ func TestPlanner__Plan__MPS(t *testing.T) {
 	}
 }
 
+func TestPlanner__Plan__NodeCooldown(t *testing.T) {
+	a30Node := func(name string, planId string, initialPlanId string) v1.Node {
+		return factory.BuildNode(name).
+			WithAnnotations(map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:                                                           planId,
+				v1alpha1.AnnotationInitialPartitioningPlan:                                                    initialPlanId,
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile4g24gb, nosresource.StatusFree): "1",
+			}).
+			WithAllocatableResources(v1.ResourceList{
+				mig.Profile4g24gb.AsResourceName(): *resource.NewQuantity(1, resource.DecimalSI),
+			}).
+			WithLabels(map[string]string{
+				constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+				constant.LabelNvidiaCount:     "1",
+				v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+			}).
+			Get()
+	}
+	pod := factory.BuildPod("ns-1", "pd-1").
+		WithContainer(
+			factory.BuildContainer("test", "test").
+				WithScalarResourceRequest(mig.Profile1g6gb.AsResourceName(), 1).
+				Get(),
+		).
+		Get()
+
+	recentPlanId := gpu.NewPlanId(gpu.PartitioningKindMig).String()
+	oldPlanId := gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: 1}.String()
+
+	testCases := []struct {
+		name      string
+		opts      core.PlannerOptions
+		node2Plan string
+		// node2InitialPlan is the ID of the plan applied for initializing node-2
+		node2InitialPlan string
+	}{
+		{
+			name:      "Greedy planner",
+			opts:      core.PlannerOptions{NodeCooldown: time.Hour},
+			node2Plan: oldPlanId,
+		},
+		{
+			name:      "Optimizing planner",
+			opts:      core.PlannerOptions{NodeCooldown: time.Hour, Optimizing: true},
+			node2Plan: oldPlanId,
+		},
+		{
+			name:             "Greedy planner, node just initialized should not be cooling down",
+			opts:             core.PlannerOptions{NodeCooldown: time.Hour},
+			node2Plan:        recentPlanId,
+			node2InitialPlan: recentPlanId,
+		},
+		{
+			name:             "Optimizing planner, node just initialized should not be cooling down",
+			opts:             core.PlannerOptions{NodeCooldown: time.Hour, Optimizing: true},
+			node2Plan:        recentPlanId,
+			node2InitialPlan: recentPlanId,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockedScheduler := scheduler_mock.NewFramework(t)
+			mockedScheduler.On(
+				"RunPreFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+			mockedScheduler.On(
+				"RunFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
+
+			// node-1 has just been partitioned after being initialized, so only node-2 can be partitioned again
+			nodes := []v1.Node{
+				a30Node("node-1", recentPlanId, oldPlanId),
+				a30Node("node-2", tt.node2Plan, tt.node2InitialPlan),
+			}
+			snapshot := newSnapshotFromNodes(nodes, partitioning_mig.NewSnapshotTaker())
+			current := snapshot.GetPartitioningState()
+			planner := partitioning_mig.NewPlanner(mockedScheduler, tt.opts)
+			plan, err := planner.Plan(context.Background(), snapshot, []v1.Pod{pod})
+			assert.NoError(t, err)
+
+			assert.True(t, current["node-1"].Equal(plan.DesiredState["node-1"]))
+			assert.False(t, current["node-2"].Equal(plan.DesiredState["node-2"]))
+			assert.Len(t, plan.Explanation.Pods, 1)
+			assert.True(t, plan.Explanation.Pods[0].Fits)
+			assert.Equal(t, "node-2", plan.Explanation.Pods[0].Node)
+		})
+	}
+}
+
+func TestPlanner__Plan__Clock(t *testing.T) {
+	a30Node := func(name string, planId string) v1.Node {
+		return factory.BuildNode(name).
+			WithAnnotations(map[string]string{
+				v1alpha1.AnnotationPartitioningPlan: planId,
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, mig.Profile4g24gb, nosresource.StatusFree): "1",
+			}).
+			WithAllocatableResources(v1.ResourceList{
+				mig.Profile4g24gb.AsResourceName(): *resource.NewQuantity(1, resource.DecimalSI),
+			}).
+			WithLabels(map[string]string{
+				constant.LabelNvidiaProduct:   string(gpu.GPUModel_A30),
+				constant.LabelNvidiaCount:     "1",
+				v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMig.String(),
+			}).
+			Get()
+	}
+	pod := factory.BuildPod("ns-1", "pd-1").
+		WithContainer(
+			factory.BuildContainer("test", "test").
+				WithScalarResourceRequest(mig.Profile1g6gb.AsResourceName(), 1).
+				Get(),
+		).
+		Get()
+
+	lastPlanTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := lastPlanTime.Add(30 * time.Minute)
+	clock := func() time.Time { return now }
+
+	for _, optimizing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Optimizing %t", optimizing), func(t *testing.T) {
+			mockedScheduler := scheduler_mock.NewFramework(t)
+			mockedScheduler.On(
+				"RunPreFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(nil, framework.NewStatus(framework.Success)).Maybe()
+			mockedScheduler.On(
+				"RunFilterPlugins",
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
+
+			// According to the clock, node-1 is still cooling down while the cooldown of node-2 is over
+			nodes := []v1.Node{
+				a30Node("node-1", gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: lastPlanTime.UnixMicro()}.String()),
+				a30Node("node-2", gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: lastPlanTime.Add(-2 * time.Hour).UnixMicro()}.String()),
+			}
+			opts := core.PlannerOptions{NodeCooldown: time.Hour, Optimizing: optimizing, Clock: clock}
+			planner := partitioning_mig.NewPlanner(mockedScheduler, opts)
+
+			var planIds []string
+			for i := 0; i < 2; i++ {
+				snapshot := newSnapshotFromNodes(nodes, partitioning_mig.NewSnapshotTaker())
+				current := snapshot.GetPartitioningState()
+				plan, err := planner.Plan(context.Background(), snapshot, []v1.Pod{pod})
+				assert.NoError(t, err)
+				assert.True(t, current["node-1"].Equal(plan.DesiredState["node-1"]))
+				assert.False(t, current["node-2"].Equal(plan.DesiredState["node-2"]))
+				planIds = append(planIds, plan.GetId())
+			}
+
+			// Plan IDs are generated from the clock
+			expectedPlanId := gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: now.UnixMicro()}.String()
+			assert.Equal(t, []string{expectedPlanId, expectedPlanId}, planIds)
+		})
+	}
+}
+
 // TODO: benchmark Plan
 //func BenchmarkPlanner_Plan(b *testing.B) {
 //	benchmarks := []struct {
@@ -891,7 +1272,7 @@ func TestPlanner__Plan__MPS(t *testing.T) {
 //		mock.Anything,
 //		mock.Anything,
 //	).Return(framework.PluginToStatus{"": framework.NewStatus(framework.Success)}).Maybe()
-//	planner := partitioning_mig.NewPlanner(mockedScheduler)
+//	planner := partitioning_mig.NewPlanner(mockedScheduler, core.PlannerOptions{})
 //
 //	for _, bb := range benchmarks {
 //		ctx := context.Background()
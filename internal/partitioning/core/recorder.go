@@ -0,0 +1,187 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MaxPartitioningPlans is the maximum number of PartitioningPlan resources of each partitioning kind
+// kept in the cluster. When a new plan is recorded, the oldest resources beyond this limit are deleted.
+const MaxPartitioningPlans = 100
+
+// planRecorder keeps track of the outcome of applying a PartitioningPlan to each node,
+// and persists it as a PartitioningPlan resource.
+type planRecorder struct {
+	plan PartitioningPlan
+	// nodes contains the partitioning of the nodes changed by the plan
+	nodes    state.PartitioningState
+	kind     gpu.PartitioningKind
+	statuses map[string]v1alpha1.NodePartitioningStatus
+}
+
+func newPlanRecorder(plan PartitioningPlan, nodes state.PartitioningState) *planRecorder {
+	return &planRecorder{
+		plan:     plan,
+		nodes:    nodes,
+		kind:     plan.GetKind(),
+		statuses: make(map[string]v1alpha1.NodePartitioningStatus, len(nodes)),
+	}
+}
+
+func (r *planRecorder) applied(nodeName string) {
+	r.statuses[nodeName] = v1alpha1.NodePartitioningStatus{
+		Name:    nodeName,
+		Phase:   v1alpha1.NodePartitioningPhasePending,
+		Message: "waiting for the node to report the plan",
+	}
+}
+
+func (r *planRecorder) failed(nodeName string, err error) {
+	r.statuses[nodeName] = v1alpha1.NodePartitioningStatus{
+		Name:    nodeName,
+		Phase:   v1alpha1.NodePartitioningPhaseFailed,
+		Message: err.Error(),
+	}
+}
+
+// resource returns the PartitioningPlan resource recording the plan. Nodes for which
+// no outcome has been recorded are considered failed, since the plan has been aborted
+// before partitioning them.
+func (r *planRecorder) resource() v1alpha1.PartitioningPlan {
+	res := v1alpha1.PartitioningPlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   r.plan.GetId(),
+			Labels: map[string]string{v1alpha1.LabelGpuPartitioning: r.kind.String()},
+		},
+		Spec: v1alpha1.PartitioningPlanSpec{
+			PartitioningKind: r.kind.String(),
+		},
+	}
+
+	// Nodes
+	nodeNames := make([]string, 0, len(r.nodes))
+	for nodeName := range r.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		nodeSpec := v1alpha1.NodePartitioningSpec{Name: nodeName}
+		for _, g := range r.nodes[nodeName].GPUs {
+			nodeSpec.GPUs = append(nodeSpec.GPUs, v1alpha1.GpuPartitioningSpec{
+				Index:     g.GPUIndex,
+				Resources: g.Resources,
+			})
+		}
+		res.Spec.Nodes = append(res.Spec.Nodes, nodeSpec)
+
+		status, ok := r.statuses[nodeName]
+		if !ok {
+			status = v1alpha1.NodePartitioningStatus{
+				Phase:   v1alpha1.NodePartitioningPhaseFailed,
+				Message: "plan aborted before partitioning the node",
+			}
+		}
+		res.Status.SetNodePhase(nodeName, status.Phase, status.Message)
+	}
+
+	// Pods
+	for _, p := range r.plan.Explanation.Pods {
+		namespace, name, _ := strings.Cut(p.Pod, "/")
+		res.Spec.Pods = append(res.Spec.Pods, v1alpha1.PlanPodReference{
+			Namespace: namespace,
+			Name:      name,
+			Fits:      p.Fits,
+			Node:      p.Node,
+		})
+	}
+
+	return res
+}
+
+// save creates the PartitioningPlan resource recording the plan, and deletes the oldest resources
+// recording plans of the same kind so that at most MaxPartitioningPlans of them are kept.
+// Since the resources are just an audit trail of the plans, errors are logged and never returned.
+func (r *planRecorder) save(ctx context.Context, c client.Client) {
+	defer r.prune(ctx, c)
+	logger := log.FromContext(ctx)
+	res := r.resource()
+	status := res.Status
+	if err := c.Create(ctx, &res); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			logger.Info("partitioning plan resource already exists, skipping", "plan", res.Name)
+			return
+		}
+		logger.Error(err, "unable to create partitioning plan resource", "plan", res.Name)
+		return
+	}
+	res.Status = status
+	if err := c.Status().Update(ctx, &res); err != nil {
+		logger.Error(err, "unable to update partitioning plan resource status", "plan", res.Name)
+	}
+}
+
+// prune deletes the oldest PartitioningPlan resources recording plans of the same kind
+// as the recorded one, keeping at most MaxPartitioningPlans of them
+func (r *planRecorder) prune(ctx context.Context, c client.Client) {
+	logger := log.FromContext(ctx)
+	var planList v1alpha1.PartitioningPlanList
+	if err := c.List(ctx, &planList, client.MatchingLabels{v1alpha1.LabelGpuPartitioning: r.kind.String()}); err != nil {
+		logger.Error(err, "unable to list partitioning plan resources")
+		return
+	}
+	if len(planList.Items) <= MaxPartitioningPlans {
+		return
+	}
+
+	// Plans are named after their IDs, which tell which plan is the most recent one
+	plans := planList.Items
+	sort.Slice(plans, func(i, j int) bool {
+		return isOlderPlan(plans[i], plans[j])
+	})
+	for i := 0; i < len(plans)-MaxPartitioningPlans; i++ {
+		if err := c.Delete(ctx, &plans[i]); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to delete partitioning plan resource", "plan", plans[i].Name)
+			continue
+		}
+		logger.V(1).Info("deleted old partitioning plan resource", "plan", plans[i].Name)
+	}
+}
+
+// isOlderPlan returns true if the PartitioningPlan resource a records a plan older than the one recorded by b.
+// Resources whose name is not a valid plan ID are considered older than the other ones.
+func isOlderPlan(a, b v1alpha1.PartitioningPlan) bool {
+	idA, errA := gpu.ParsePlanId(a.Name)
+	idB, errB := gpu.ParsePlanId(b.Name)
+	if errA != nil || errB != nil {
+		if errA != nil && errB != nil {
+			return a.Name < b.Name
+		}
+		return errA != nil
+	}
+	return idA.IsOlderThan(idB)
+}
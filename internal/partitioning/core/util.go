@@ -17,7 +17,9 @@
 package core
 
 import (
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	nodeutil "github.com/nebuly-ai/nos/pkg/util/node"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/component-helpers/scheduling/corev1"
 	"sort"
@@ -81,3 +83,18 @@ func IsNodeInitialized(node v1.Node) bool {
 	_, specAnnotations := gpu.ParseNodeAnnotations(node)
 	return count == len(specAnnotations.GroupByGpuIndex())
 }
+
+// IsExcludedFromPartitioning returns true if the node must be excluded from GPU partitioning, either because
+// it did not report the last partitioning plan applied to it within the plan report timeout or because the
+// agents required for partitioning its GPUs are not running
+func IsExcludedFromPartitioning(node v1.Node) bool {
+	for _, t := range []v1.NodeConditionType{
+		v1alpha1.NodeConditionPartitioningPlanNotReported,
+		v1alpha1.NodeConditionPartitioningAgentNotReady,
+	} {
+		if condition, found := nodeutil.GetCondition(node, t); found && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
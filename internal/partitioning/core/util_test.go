@@ -209,3 +209,55 @@ func TestIsNodeInitialized(t *testing.T) {
 		})
 	}
 }
+
+func TestIsExcludedFromPartitioning(t *testing.T) {
+	testCases := []struct {
+		name       string
+		conditions []v1.NodeCondition
+		expected   bool
+	}{
+		{
+			name:       "Node without conditions",
+			conditions: nil,
+			expected:   false,
+		},
+		{
+			name: "Plan not reported within timeout",
+			conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue},
+				{Type: v1alpha1.NodeConditionPartitioningPlanNotReported, Status: v1.ConditionTrue},
+			},
+			expected: true,
+		},
+		{
+			name: "Plan reported after timeout",
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningPlanNotReported, Status: v1.ConditionFalse},
+			},
+			expected: false,
+		},
+		{
+			name: "Partitioning agent not ready",
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningPlanNotReported, Status: v1.ConditionFalse},
+				{Type: v1alpha1.NodeConditionPartitioningAgentNotReady, Status: v1.ConditionTrue},
+			},
+			expected: true,
+		},
+		{
+			name: "Partitioning agent ready",
+			conditions: []v1.NodeCondition{
+				{Type: v1alpha1.NodeConditionPartitioningAgentNotReady, Status: v1.ConditionFalse},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").Get()
+			node.Status.Conditions = tt.conditions
+			assert.Equal(t, tt.expected, core.IsExcludedFromPartitioning(node))
+		})
+	}
+}
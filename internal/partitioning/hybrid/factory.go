@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid
+
+import (
+	"github.com/nebuly-ai/nos/internal/controllers/gpupartitioner"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+func NewActuator(client client.Client, cmReader client.Reader, devicePluginCM types.NamespacedName) core.Actuator {
+	return core.NewActuator(
+		client,
+		NewPartitioner(
+			client,
+			cmReader,
+			devicePluginCM,
+		),
+	)
+}
+
+func NewPlanner(scheduler framework.Framework, opts core.PlannerOptions) core.Planner {
+	return core.NewPlannerWithOptions(
+		gpu.PartitioningKindHybrid,
+		NewPartitionCalculator(),
+		NewSliceCalculator(),
+		scheduler,
+		opts,
+	)
+}
+
+func NewController(
+	scheme *runtime.Scheme,
+	client client.Client,
+	cmReader client.Reader,
+	podBatcher util.Batcher[v1.Pod],
+	clusterState *state.ClusterState,
+	scheduler framework.Framework,
+	devicePluginCM types.NamespacedName,
+	plannerOpts core.PlannerOptions,
+	dryRun bool,
+) gpupartitioner.Controller {
+	var actuator = NewActuator(client, cmReader, devicePluginCM)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
+	return gpupartitioner.NewController(
+		scheme,
+		client,
+		podBatcher,
+		clusterState,
+		gpu.PartitioningKindHybrid,
+		NewPlanner(scheduler, plannerOpts),
+		actuator,
+		NewSnapshotTaker(),
+	)
+}
+
+func NewCompactor(nodeCooldown time.Duration) core.Compactor {
+	return core.NewCompactor(gpu.PartitioningKindHybrid, NewPartitionCalculator(), nodeCooldown)
+}
+
+func NewCompactionController(
+	client client.Client,
+	cmReader client.Reader,
+	clusterState *state.ClusterState,
+	devicePluginCM types.NamespacedName,
+	idleThreshold time.Duration,
+	nodeCooldown time.Duration,
+	dryRun bool,
+) gpupartitioner.CompactionController {
+	var actuator = NewActuator(client, cmReader, devicePluginCM)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
+	return gpupartitioner.NewCompactionController(
+		client,
+		clusterState,
+		gpu.PartitioningKindHybrid,
+		NewCompactor(nodeCooldown),
+		actuator,
+		NewSnapshotTaker(),
+		idleThreshold,
+	)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/mig"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	gpumig "github.com/nebuly-ai/nos/pkg/gpu/mig"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ core.Partitioner = partitioner{}
+
+// partitioner applies hybrid partitioning by delegating the MIG GPUs to the MIG partitioner,
+// which writes the GPU spec annotations read by the mig-agent, and the whole node partitioning
+// to the MPS partitioner, which writes the config of the NVIDIA device plugin.
+type partitioner struct {
+	migPartitioner core.Partitioner
+	mpsPartitioner core.Partitioner
+}
+
+func NewPartitioner(
+	client client.Client,
+	cmReader client.Reader,
+	devicePluginCM types.NamespacedName,
+) core.Partitioner {
+	return partitioner{
+		migPartitioner: mig.NewPartitioner(client),
+		mpsPartitioner: mps.NewPartitioner(client, cmReader, devicePluginCM),
+	}
+}
+
+func (p partitioner) ApplyPartitioning(ctx context.Context, node v1.Node, planId string, partitioning state.NodePartitioning) error {
+	// Apply MIG partitioning first, so that MIG devices get created before
+	// the device plugin gets restarted with the new config
+	if err := p.migPartitioner.ApplyPartitioning(ctx, node, planId, getMigPartitioning(partitioning)); err != nil {
+		return err
+	}
+	// Apply the device plugin config of the whole node, including both MIG and MPS GPUs
+	return p.mpsPartitioner.ApplyPartitioning(ctx, node, planId, partitioning)
+}
+
+// getMigPartitioning returns the partitioning of the MIG GPUs included in the partitioning provided as argument
+func getMigPartitioning(partitioning state.NodePartitioning) state.NodePartitioning {
+	res := state.NodePartitioning{GPUs: make([]state.GPUPartitioning, 0)}
+	for _, g := range partitioning.GPUs {
+		migResources := make(map[v1.ResourceName]int)
+		for r, q := range g.Resources {
+			if gpumig.IsNvidiaMigDevice(r) {
+				migResources[r] = q
+			}
+		}
+		if len(migResources) == 0 {
+			continue
+		}
+		res.GPUs = append(res.GPUs, state.GPUPartitioning{
+			GPUIndex:  g.GPUIndex,
+			Resources: migResources,
+		})
+	}
+	return res
+}
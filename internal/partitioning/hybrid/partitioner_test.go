@@ -0,0 +1,101 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/hybrid"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestPartitioner__ApplyPartitioning(t *testing.T) {
+	node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+		fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 1, mig.Profile7g40gb): "1",
+	}).Get()
+	devicePluginCM := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      "test-name",
+		},
+	}
+	cmNamespacedName := types.NamespacedName{
+		Namespace: devicePluginCM.Namespace,
+		Name:      devicePluginCM.Name,
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithObjects(&node).
+		WithObjects(&devicePluginCM).
+		Build()
+
+	partitioner := hybrid.NewPartitioner(k8sClient, k8sClient, cmNamespacedName)
+	partitioning := state.NodePartitioning{
+		GPUs: []state.GPUPartitioning{
+			{
+				GPUIndex: 0,
+				Resources: map[v1.ResourceName]int{
+					mig.Profile1g5gb.AsResourceName(): 2,
+				},
+			},
+			{
+				GPUIndex: 1,
+				Resources: map[v1.ResourceName]int{
+					slicing.ProfileName("10gb").AsResourceName(): 2,
+				},
+			},
+			{
+				GPUIndex:  2,
+				Resources: map[v1.ResourceName]int{},
+			},
+		},
+	}
+	ctx := context.Background()
+	err := partitioner.ApplyPartitioning(ctx, node, "plan-1", partitioning)
+	assert.NoError(t, err)
+
+	// Node should include only the spec annotations of the MIG GPUs, and the device plugin config label
+	var updatedNode v1.Node
+	assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&node), &updatedNode))
+	expectedKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, "plan-1")
+	assert.Equal(
+		t,
+		map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile1g5gb): "2",
+			v1alpha1.AnnotationPartitioningPlan:                                "plan-1",
+		},
+		updatedNode.Annotations,
+	)
+	assert.Equal(t, expectedKey, updatedNode.Labels[constant.LabelNvidiaDevicePluginConfig])
+
+	// Device plugin config should include the node config
+	var cm v1.ConfigMap
+	assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &cm))
+	assert.Contains(t, cm.Data, expectedKey)
+}
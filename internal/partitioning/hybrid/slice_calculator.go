@@ -0,0 +1,47 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid
+
+import (
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ gpu.SliceCalculator = sliceCalculator{}
+
+type sliceCalculator struct {
+}
+
+// GetRequestedSlices returns both the MIG profiles and the GPU slices requested by the Pod
+func (s sliceCalculator) GetRequestedSlices(pod v1.Pod) map[gpu.Slice]int {
+	requestedMigProfiles := mig.GetRequestedProfiles(pod)
+	requestedSlicingProfiles := slicing.GetRequestedProfiles(pod)
+	res := make(map[gpu.Slice]int, len(requestedMigProfiles)+len(requestedSlicingProfiles))
+	for p, q := range requestedMigProfiles {
+		res[p] = q
+	}
+	for p, q := range requestedSlicingProfiles {
+		res[p] = q
+	}
+	return res
+}
+
+func NewSliceCalculator() gpu.SliceCalculator {
+	return sliceCalculator{}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+const (
+	namespace = "nos"
+	subsystem = "gpu_partitioner"
+
+	labelKind    = "kind"
+	labelProfile = "profile"
+	labelOutcome = "outcome"
+	labelNode    = "node"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	batchSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "batch_size",
+			Help:      "Number of pending pods included in the batches processed by the GPU partitioner.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		},
+		[]string{labelKind},
+	)
+	planDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "plan_duration_seconds",
+			Help:      "Time taken for computing a partitioning plan.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		},
+		[]string{labelKind},
+	)
+	planPlacedPods = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "plan_placed_pods",
+			Help:      "Number of candidate pods placed on a node by a partitioning plan.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		},
+		[]string{labelKind},
+	)
+	lackingSlices = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "lacking_slices",
+			Help: "Number of GPU slices lacking for scheduling the candidate pods, " +
+				"as computed by the last plan in which the slice profile was requested.",
+		},
+		[]string{labelKind, labelProfile},
+	)
+	nodeApplyTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "node_apply_total",
+			Help:      "Number of times a partitioning plan has been applied to a node, by outcome.",
+		},
+		[]string{labelKind, labelNode, labelOutcome},
+	)
+	compactionTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "compaction_total",
+			Help:      "Number of plans applied for compacting the free GPU slices of idle nodes.",
+		},
+		[]string{labelKind},
+	)
+	nodePlanReportTimeout = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "node_plan_report_timeout",
+			Help: "Whether the node did not report the last partitioning plan applied to it within the timeout (1) " +
+				"and is therefore excluded from GPU partitioning, or it reported the plan afterwards (0).",
+		},
+		[]string{labelKind, labelNode},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		batchSize,
+		planDuration,
+		planPlacedPods,
+		lackingSlices,
+		nodeApplyTotal,
+		compactionTotal,
+		nodePlanReportTimeout,
+	)
+}
+
+// ObserveBatchSize records the size of a batch of pending pods processed by
+// the partitioner of the kind provided as argument
+func ObserveBatchSize(kind gpu.PartitioningKind, size int) {
+	batchSize.WithLabelValues(kind.String()).Observe(float64(size))
+}
+
+// ObservePlan records the duration of a plan computed by the partitioner of the kind provided
+// as argument, together with the number of candidate pods that the plan places on a node
+func ObservePlan(kind gpu.PartitioningKind, duration time.Duration, placedPods int) {
+	planDuration.WithLabelValues(kind.String()).Observe(duration.Seconds())
+	planPlacedPods.WithLabelValues(kind.String()).Observe(float64(placedPods))
+}
+
+// ObserveLackingSlices records, for each requested slice, the number of slices that are lacking
+// according to the plan computed by the partitioner of the kind provided as argument.
+// Requested slices that are not lacking are recorded as zero.
+func ObserveLackingSlices(kind gpu.PartitioningKind, requested map[gpu.Slice]int, lacking map[gpu.Slice]int) {
+	for s := range requested {
+		lackingSlices.WithLabelValues(kind.String(), s.String()).Set(float64(lacking[s]))
+	}
+	for s, q := range lacking {
+		lackingSlices.WithLabelValues(kind.String(), s.String()).Set(float64(q))
+	}
+}
+
+// ObserveNodeApply records the outcome of applying a partitioning plan to the node
+// provided as argument, with the partitioning kind provided as argument
+func ObserveNodeApply(kind gpu.PartitioningKind, node string, outcome string) {
+	nodeApplyTotal.WithLabelValues(kind.String(), node, outcome).Inc()
+}
+
+// ObserveCompaction records a plan applied for compacting the free slices of the nodes
+// with the partitioning kind provided as argument
+func ObserveCompaction(kind gpu.PartitioningKind) {
+	compactionTotal.WithLabelValues(kind.String()).Inc()
+}
+
+// ObservePlanReportTimeout records whether the node provided as argument, with the partitioning kind
+// provided as argument, did not report the last partitioning plan applied to it within the timeout
+func ObservePlanReportTimeout(kind gpu.PartitioningKind, node string, timedOut bool) {
+	var value float64
+	if timedOut {
+		value = 1
+	}
+	nodePlanReportTimeout.DeletePartialMatch(prometheus.Labels{labelNode: node})
+	nodePlanReportTimeout.WithLabelValues(kind.String(), node).Set(value)
+}
+
+// DeleteNode deletes the metrics of the node provided as argument, to be called when the node
+// is deleted or its GPU partitioning is disabled
+func DeleteNode(node string) {
+	nodeApplyTotal.DeletePartialMatch(prometheus.Labels{labelNode: node})
+	nodePlanReportTimeout.DeletePartialMatch(prometheus.Labels{labelNode: node})
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestObserveLackingSlices(t *testing.T) {
+	lackingSlices.Reset()
+
+	// First plan: 1g.10gb is lacking, 10gb is requested but not lacking
+	ObserveLackingSlices(
+		gpu.PartitioningKindHybrid,
+		map[gpu.Slice]int{
+			mig.Profile1g10gb:           3,
+			slicing.ProfileName("10gb"): 1,
+		},
+		map[gpu.Slice]int{
+			mig.Profile1g10gb: 2,
+		},
+	)
+	assert.Equal(t, float64(2), testutil.ToFloat64(lackingSlices.WithLabelValues("hybrid", mig.Profile1g10gb.String())))
+	assert.Equal(t, float64(0), testutil.ToFloat64(lackingSlices.WithLabelValues("hybrid", "10gb")))
+
+	// Plan of another kind: 10gb is lacking, the slices of the other kind are not affected
+	ObserveLackingSlices(
+		gpu.PartitioningKindMps,
+		map[gpu.Slice]int{
+			slicing.ProfileName("10gb"): 2,
+		},
+		map[gpu.Slice]int{
+			slicing.ProfileName("10gb"): 2,
+		},
+	)
+	assert.Equal(t, float64(2), testutil.ToFloat64(lackingSlices.WithLabelValues("mps", "10gb")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(lackingSlices.WithLabelValues("hybrid", "10gb")))
+
+	// Second plan: 1g.10gb is not lacking anymore
+	ObserveLackingSlices(
+		gpu.PartitioningKindHybrid,
+		map[gpu.Slice]int{
+			mig.Profile1g10gb: 1,
+		},
+		map[gpu.Slice]int{},
+	)
+	assert.Equal(t, float64(0), testutil.ToFloat64(lackingSlices.WithLabelValues("hybrid", mig.Profile1g10gb.String())))
+}
+
+func TestObservePlan(t *testing.T) {
+	planDuration.Reset()
+	planPlacedPods.Reset()
+
+	ObservePlan(gpu.PartitioningKindMig, 10*time.Millisecond, 5)
+	ObservePlan(gpu.PartitioningKindMig, 20*time.Millisecond, 3)
+	ObservePlan(gpu.PartitioningKindMps, 20*time.Millisecond, 3)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(planDuration))
+	assert.Equal(t, 2, testutil.CollectAndCount(planPlacedPods))
+}
+
+func TestObserveNodeApply(t *testing.T) {
+	nodeApplyTotal.Reset()
+
+	ObserveNodeApply(gpu.PartitioningKindMig, "node-1", OutcomeSuccess)
+	ObserveNodeApply(gpu.PartitioningKindMig, "node-1", OutcomeSuccess)
+	ObserveNodeApply(gpu.PartitioningKindMig, "node-2", OutcomeSuccess)
+	ObserveNodeApply(gpu.PartitioningKindMps, "node-3", OutcomeFailure)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(nodeApplyTotal.WithLabelValues("mig", "node-1", OutcomeSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodeApplyTotal.WithLabelValues("mig", "node-2", OutcomeSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodeApplyTotal.WithLabelValues("mps", "node-3", OutcomeFailure)))
+
+	// node-1 is deleted
+	DeleteNode("node-1")
+	assert.Equal(t, 2, testutil.CollectAndCount(nodeApplyTotal))
+}
+
+func TestObserveCompaction(t *testing.T) {
+	compactionTotal.Reset()
+
+	ObserveCompaction(gpu.PartitioningKindMig)
+	ObserveCompaction(gpu.PartitioningKindMig)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(compactionTotal.WithLabelValues("mig")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(compactionTotal.WithLabelValues("mps")))
+}
+
+func TestObservePlanReportTimeout(t *testing.T) {
+	nodePlanReportTimeout.Reset()
+
+	ObservePlanReportTimeout(gpu.PartitioningKindMig, "node-1", true)
+	ObservePlanReportTimeout(gpu.PartitioningKindMps, "node-2", true)
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mig", "node-1")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mps", "node-2")))
+
+	// node-1 reports the plan afterwards
+	ObservePlanReportTimeout(gpu.PartitioningKindMig, "node-1", false)
+	assert.Equal(t, float64(0), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mig", "node-1")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mps", "node-2")))
+
+	// node-1 switches to MPS partitioning, the series of its previous kind is deleted
+	ObservePlanReportTimeout(gpu.PartitioningKindMps, "node-1", true)
+	assert.Equal(t, 2, testutil.CollectAndCount(nodePlanReportTimeout))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mps", "node-1")))
+
+	// node-1 is deleted
+	DeleteNode("node-1")
+	assert.Equal(t, 1, testutil.CollectAndCount(nodePlanReportTimeout))
+	assert.Equal(t, float64(1), testutil.ToFloat64(nodePlanReportTimeout.WithLabelValues("mps", "node-2")))
+}
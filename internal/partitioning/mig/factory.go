@@ -26,13 +26,16 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
 )
 
-func NewPlanner(scheduler framework.Framework) core.Planner {
-	return core.NewPlanner(
+func NewPlanner(scheduler framework.Framework, opts core.PlannerOptions) core.Planner {
+	return core.NewPlannerWithOptions(
+		gpu.PartitioningKindMig,
 		NewPartitionCalculator(),
 		NewSliceCalculator(),
 		scheduler,
+		opts,
 	)
 }
 
@@ -49,16 +52,47 @@ func NewController(
 	podBatcher util.Batcher[v1.Pod],
 	clusterState *state.ClusterState,
 	scheduler framework.Framework,
+	plannerOpts core.PlannerOptions,
+	dryRun bool,
 ) gpupartitioner.Controller {
-
+	var actuator = NewActuator(client)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
 	return gpupartitioner.NewController(
 		scheme,
 		client,
 		podBatcher,
 		clusterState,
 		gpu.PartitioningKindMig,
-		NewPlanner(scheduler),
-		NewActuator(client),
+		NewPlanner(scheduler, plannerOpts),
+		actuator,
+		NewSnapshotTaker(),
+	)
+}
+
+func NewCompactor(nodeCooldown time.Duration) core.Compactor {
+	return core.NewCompactor(gpu.PartitioningKindMig, NewPartitionCalculator(), nodeCooldown)
+}
+
+func NewCompactionController(
+	client client.Client,
+	clusterState *state.ClusterState,
+	idleThreshold time.Duration,
+	nodeCooldown time.Duration,
+	dryRun bool,
+) gpupartitioner.CompactionController {
+	var actuator = NewActuator(client)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
+	return gpupartitioner.NewCompactionController(
+		client,
+		clusterState,
+		gpu.PartitioningKindMig,
+		NewCompactor(nodeCooldown),
+		actuator,
 		NewSnapshotTaker(),
+		idleThreshold,
 	)
 }
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	v1 "k8s.io/api/core/v1"
@@ -29,6 +30,7 @@ import (
 )
 
 type nodeInitializer struct {
+	client.Client
 	partitioner         core.Partitioner
 	partitionCalculator core.PartitionCalculator
 }
@@ -36,6 +38,7 @@ type nodeInitializer struct {
 func NewNodeInitializer(client client.Client) core.NodeInitializer {
 	p := NewPartitioner(client)
 	return nodeInitializer{
+		Client:              client,
 		partitioner:         p,
 		partitionCalculator: NewPartitionCalculator(),
 	}
@@ -73,10 +76,21 @@ func (n nodeInitializer) InitNodePartitioning(ctx context.Context, node v1.Node)
 		return nil
 	}
 
+	// Mark the plan as initial, so that the node is not subject to the partitioning cooldown
+	planId := core.NewPartitioningPlanId(gpu.PartitioningKindMig)
+	original := node.DeepCopy()
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[v1alpha1.AnnotationInitialPartitioningPlan] = planId
+	if err = n.Patch(ctx, &node, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("error patching node annotations: %v", err)
+	}
+
 	// Apply new partitioning
 	nodePartitioning := n.partitionCalculator.GetPartitioning(&migNode)
 	logger.Info("applying partitioning", "node", node.Name, "partitioning", nodePartitioning)
-	if err = n.partitioner.ApplyPartitioning(ctx, node, core.NewPartitioningPlanId(), nodePartitioning); err != nil {
+	if err = n.partitioner.ApplyPartitioning(ctx, node, planId, nodePartitioning); err != nil {
 		return fmt.Errorf("error applying partitioning: %v", err)
 	}
 	return nil
@@ -216,7 +216,7 @@ func TestActuator__Apply(t *testing.T) {
 
 			fakeClient := fakeClientBuilder.Build()
 			actuator := mig_partitioner.NewActuator(fakeClient)
-			plan := core.NewPartitioningPlan(tt.desiredState)
+			plan := core.NewPartitioningPlan(gpu.PartitioningKindMig, tt.desiredState)
 			applied, err := actuator.Apply(context.Background(), snapshot, plan)
 
 			if tt.expectedErr {
@@ -34,6 +34,9 @@ func (s snapshotTaker) TakeSnapshot(clusterState *state.ClusterState) (core.Snap
 		if v.Node() == nil {
 			continue
 		}
+		if core.IsExcludedFromPartitioning(*v.Node()) {
+			continue
+		}
 		if !gpu.IsMigPartitioningEnabled(*v.Node()) {
 			continue
 		}
@@ -30,43 +30,78 @@ import (
 	"time"
 )
 
-func NewActuator(client client.Client, devicePluginCM types.NamespacedName, devicePluginDelay time.Duration) core.Actuator {
+func NewActuator(client client.Client, cmReader client.Reader, devicePluginCM types.NamespacedName) core.Actuator {
 	return core.NewActuator(
 		client,
 		NewPartitioner(
 			client,
+			cmReader,
 			devicePluginCM,
-			devicePluginDelay,
 		),
 	)
 }
 
-func NewPlanner(scheduler framework.Framework) core.Planner {
-	return core.NewPlanner(
+func NewPlanner(scheduler framework.Framework, opts core.PlannerOptions) core.Planner {
+	return core.NewPlannerWithOptions(
+		gpu.PartitioningKindMps,
 		NewPartitionCalculator(),
 		NewSliceCalculator(),
 		scheduler,
+		opts,
 	)
 }
 
 func NewController(
 	scheme *runtime.Scheme,
 	client client.Client,
+	cmReader client.Reader,
 	podBatcher util.Batcher[v1.Pod],
 	clusterState *state.ClusterState,
 	scheduler framework.Framework,
 	devicePluginCM types.NamespacedName,
-	devicePluginDelay time.Duration,
+	plannerOpts core.PlannerOptions,
+	dryRun bool,
 ) gpupartitioner.Controller {
-
+	var actuator = NewActuator(client, cmReader, devicePluginCM)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
 	return gpupartitioner.NewController(
 		scheme,
 		client,
 		podBatcher,
 		clusterState,
 		gpu.PartitioningKindMps,
-		NewPlanner(scheduler),
-		NewActuator(client, devicePluginCM, devicePluginDelay),
+		NewPlanner(scheduler, plannerOpts),
+		actuator,
+		NewSnapshotTaker(),
+	)
+}
+
+func NewCompactor(nodeCooldown time.Duration) core.Compactor {
+	return core.NewCompactor(gpu.PartitioningKindMps, NewPartitionCalculator(), nodeCooldown)
+}
+
+func NewCompactionController(
+	client client.Client,
+	cmReader client.Reader,
+	clusterState *state.ClusterState,
+	devicePluginCM types.NamespacedName,
+	idleThreshold time.Duration,
+	nodeCooldown time.Duration,
+	dryRun bool,
+) gpupartitioner.CompactionController {
+	var actuator = NewActuator(client, cmReader, devicePluginCM)
+	if dryRun {
+		actuator = core.NewDryRunActuator()
+	}
+	return gpupartitioner.NewCompactionController(
+		client,
+		clusterState,
+		gpu.PartitioningKindMps,
+		NewCompactor(nodeCooldown),
+		actuator,
 		NewSnapshotTaker(),
+		idleThreshold,
 	)
 }
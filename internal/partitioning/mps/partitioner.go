@@ -21,16 +21,19 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	nvidiav1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/nebuly-ai/nos/internal/partitioning/core"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
 	"github.com/nebuly-ai/nos/pkg/util"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
@@ -38,52 +41,59 @@ import (
 
 const DevicePluginConfigKeyFormat = "%s-%s"
 
+// minMicrosecondsGeneration is the smallest generation of the plan IDs with kind, which are Unix times in
+// microseconds. Legacy plan IDs without kind are Unix times in seconds, so they are always lower.
+const minMicrosecondsGeneration int64 = 1e15
+
 var _ core.Partitioner = partitioner{}
 
+// PluginConfigFunc converts the partitioning of the node provided as argument to the config of the NVIDIA device plugin
+type PluginConfigFunc func(node v1.Node, partitioning state.NodePartitioning) (PluginConfig, error)
+
 type partitioner struct {
 	client.Client
-	devicePluginCM    types.NamespacedName
-	devicePluginDelay time.Duration
+	cmReader       client.Reader
+	devicePluginCM types.NamespacedName
+	toPluginConfig PluginConfigFunc
 }
 
 func NewPartitioner(
 	client client.Client,
+	cmReader client.Reader,
 	devicePluginCM types.NamespacedName,
-	devicePluginDelay time.Duration,
 ) core.Partitioner {
+	return NewPartitionerWithPluginConfig(client, cmReader, devicePluginCM, ToPluginConfig)
+}
 
+// NewPartitionerWithPluginConfig returns a Partitioner that applies the partitioning of the nodes by
+// writing the device plugin config generated by the function provided as argument to the device plugin
+// ConfigMap, and by updating the node labels so that the device plugin picks up the new config.
+//
+// The device plugin ConfigMap is read through the cmReader, which should not be backed by the
+// cache of the client, so that the retries of conflicting updates read the latest version of the ConfigMap.
+//
+// The partitioning is applied asynchronously: the partitioner does not wait for the device plugin to load
+// the new config, which is confirmed by the gpu-agent by reporting the plan once the devices exposed
+// by the device plugin match the config.
+func NewPartitionerWithPluginConfig(
+	client client.Client,
+	cmReader client.Reader,
+	devicePluginCM types.NamespacedName,
+	toPluginConfig PluginConfigFunc,
+) core.Partitioner {
 	return partitioner{
-		Client:            client,
-		devicePluginCM:    devicePluginCM,
-		devicePluginDelay: devicePluginDelay,
+		Client:         client,
+		cmReader:       cmReader,
+		devicePluginCM: devicePluginCM,
+		toPluginConfig: toPluginConfig,
 	}
 }
 
 func (p partitioner) ApplyPartitioning(ctx context.Context, node v1.Node, planId string, partitioning state.NodePartitioning) error {
 	logger := log.FromContext(ctx)
 
-	var devicePluginCm v1.ConfigMap
-	var err error
-
-	// Fetch nvidia-device-plugin config
-	if devicePluginCm, err = p.getDevicePluginCM(ctx); err != nil {
-		return err
-	}
-	if devicePluginCm.Data == nil {
-		devicePluginCm.Data = map[string]string{}
-	}
-	originalCm := devicePluginCm.DeepCopy()
-
-	// Delete old node config
-	for k := range devicePluginCm.Data {
-		if strings.HasPrefix(k, node.Name) {
-			delete(devicePluginCm.Data, k)
-		}
-	}
-
-	// Update ConfigMap with new node config
-	key := fmt.Sprintf(DevicePluginConfigKeyFormat, node.Name, planId)
-	pluginConfig, err := ToPluginConfig(partitioning)
+	// Compute new node config
+	pluginConfig, err := p.toPluginConfig(node, partitioning)
 	if err != nil {
 		return fmt.Errorf("unable to convert node partitioning state to device plugin config: %v", err)
 	}
@@ -91,21 +101,29 @@ func (p partitioner) ApplyPartitioning(ctx context.Context, node v1.Node, planId
 	if err != nil {
 		return fmt.Errorf("unable to marshal nvidia device plugin config: %v", err)
 	}
-	devicePluginCm.Data[key] = string(pluginConfigYaml)
-	if err = p.Patch(ctx, &devicePluginCm, client.MergeFrom(originalCm)); err != nil {
-		return err
-	}
 
-	// Wait for CM propagation time
-	logger.Info(fmt.Sprintf("waiting %f seconds for device plugin config config propagation...", p.devicePluginDelay.Seconds()))
-	time.Sleep(p.devicePluginDelay)
+	// Update ConfigMap with new node config. The ConfigMap is shared among multiple nodes,
+	// so it is patched with optimistic locking and the update is retried on conflicts.
+	devicePluginCM := GetDevicePluginCM(node, p.devicePluginCM)
+	key := fmt.Sprintf(DevicePluginConfigKeyFormat, node.Name, planId)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return p.updateDevicePluginCM(ctx, devicePluginCM, node.Name, key, string(pluginConfigYaml))
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update device plugin ConfigMap %s: %v", devicePluginCM, err)
+	}
 
-	// Update node labels to apply new config
+	// Update node labels to apply new config, and annotate the node with the plan
+	// so that the gpu-agent reports it once the device plugin has loaded the config
 	originalNode := node.DeepCopy()
 	if node.Labels == nil {
 		node.Labels = make(map[string]string)
 	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
 	node.Labels[constant.LabelNvidiaDevicePluginConfig] = key
+	node.Annotations[v1alpha1.AnnotationPartitioningPlan] = planId
 	if err = p.Patch(ctx, &node, client.MergeFrom(originalNode)); err != nil {
 		return err
 	}
@@ -114,25 +132,99 @@ func (p partitioner) ApplyPartitioning(ctx context.Context, node v1.Node, planId
 	return nil
 }
 
-func (p partitioner) getDevicePluginCM(ctx context.Context) (v1.ConfigMap, error) {
-	var res v1.ConfigMap
-	cmObjectKey := client.ObjectKey{Name: p.devicePluginCM.Name, Namespace: p.devicePluginCM.Namespace}
-	err := p.Client.Get(ctx, cmObjectKey, &res)
-	return res, err
+// updateDevicePluginCM replaces the configs of the node stored in the device plugin ConfigMap
+// with the config provided as argument, failing with a conflict error if the ConfigMap
+// has been modified since it has been fetched.
+func (p partitioner) updateDevicePluginCM(ctx context.Context, cmName types.NamespacedName, nodeName, key, config string) error {
+	var cm v1.ConfigMap
+	if err := p.cmReader.Get(ctx, cmName, &cm); err != nil {
+		return err
+	}
+	original := cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	// Delete old node configs
+	for k := range cm.Data {
+		if IsNodeConfigKey(k, nodeName) {
+			delete(cm.Data, k)
+		}
+	}
+
+	cm.Data[key] = config
+	return p.Patch(ctx, &cm, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
 }
 
-func ToPluginConfig(partitioning state.NodePartitioning) (nvidiav1.Config, error) {
-	replicatedResources := make([]nvidiav1.MPSResource, 0)
+// hasMigEnabledGPUs returns true if the mig-agent reported that the node provided as argument
+// has at least one GPU with MIG mode enabled
+func hasMigEnabledGPUs(node v1.Node) bool {
+	for _, status := range mig.GetModeStatuses(node) {
+		if status == mig.ModeStatusEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDevicePluginCM returns the namespaced name of the ConfigMap containing the device plugin configs
+// of the node provided as argument. Nodes can override the name of the default ConfigMap through the
+// label v1alpha1.LabelDevicePluginConfigMap, so that each node pool can use its own ConfigMap.
+func GetDevicePluginCM(node v1.Node, defaultCM types.NamespacedName) types.NamespacedName {
+	if name := node.Labels[v1alpha1.LabelDevicePluginConfigMap]; name != "" {
+		return types.NamespacedName{Name: name, Namespace: defaultCM.Namespace}
+	}
+	return defaultCM
+}
+
+// IsNodeConfigKey returns true if the device plugin config key provided as argument belongs to the node
+// with the name provided as argument, namely if it has either the format <node-name>-<kind>-<generation>,
+// where the generation is in microseconds, or the legacy format <node-name>-<seconds>.
+//
+// Matching the whole key, and not just its prefix, prevents the configs of a node from being mistaken
+// for the ones of nodes whose names share the same prefix: for instance, the legacy key
+// "worker-mps-1680000000" belongs to the node "worker-mps" and not to the node "worker", since
+// the generation of a plan with kind "mps" is never in seconds.
+func IsNodeConfigKey(key, nodeName string) bool {
+	prefix := nodeName + "-"
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	planId, err := gpu.ParsePlanId(strings.TrimPrefix(key, prefix))
+	if err != nil {
+		return false
+	}
+	if planId.Kind != "" {
+		return planId.Generation >= minMicrosecondsGeneration
+	}
+	return planId.Generation < minMicrosecondsGeneration
+}
+
+// ToPluginConfig converts the node partitioning provided as argument to the config of the NVIDIA device plugin.
+// The compute share of the slicing profiles is set as the active thread percentage of the MPS resources.
+//
+// MIG resources, which are present only on nodes with hybrid partitioning, are not included in the
+// MPS resources. The plugin is configured with the "mixed" MIG strategy, so that it exposes the MIG devices
+// of the MIG GPUs too, only if the node actually has MIG GPUs, namely GPUs with MIG mode enabled
+// or with MIG resources.
+func ToPluginConfig(node v1.Node, partitioning state.NodePartitioning) (PluginConfig, error) {
+	replicatedResources := make([]MPSResource, 0)
+	hasMigResources := false
 	for _, g := range partitioning.GPUs {
 		for r, q := range g.Resources {
+			if mig.IsNvidiaMigDevice(r) {
+				hasMigResources = true
+				continue
+			}
 			slicingProfile, err := slicing.ExtractProfileName(r)
 			if err != nil {
-				return nvidiav1.Config{}, err
+				return PluginConfig{}, err
 			}
-			mpsResource := nvidiav1.MPSResource{
-				Name:     nvidiav1.ResourceName(constant.ResourceNvidiaGPU),
-				Rename:   nvidiav1.ResourceName(strings.TrimPrefix(r.String(), constant.NvidiaResourcePrefix)),
-				MemoryGB: slicingProfile.GetMemorySizeGB(),
+			mpsResource := MPSResource{
+				Name:                   nvidiav1.ResourceName(constant.ResourceNvidiaGPU),
+				Rename:                 nvidiav1.ResourceName(strings.TrimPrefix(r.String(), constant.NvidiaResourcePrefix)),
+				MemoryGB:               slicingProfile.GetMemorySizeGB(),
+				ActiveThreadPercentage: slicingProfile.GetComputePercent(),
 				Devices: []nvidiav1.ReplicatedDeviceRef{
 					nvidiav1.ReplicatedDeviceRef(strconv.Itoa(g.GPUIndex)),
 				},
@@ -141,15 +233,19 @@ func ToPluginConfig(partitioning state.NodePartitioning) (nvidiav1.Config, error
 			replicatedResources = append(replicatedResources, mpsResource)
 		}
 	}
-	return nvidiav1.Config{
+	migStrategy := "none"
+	if hasMigResources || hasMigEnabledGPUs(node) {
+		migStrategy = "mixed"
+	}
+	return PluginConfig{
 		Version: nvidiav1.Version,
 		Flags: &nvidiav1.Flags{
 			CommandLineFlags: nvidiav1.CommandLineFlags{
-				MigStrategy: util.StringAddr("none"),
+				MigStrategy: util.StringAddr(migStrategy),
 			},
 		},
-		Sharing: &nvidiav1.Sharing{
-			MPS: &nvidiav1.MPS{
+		Sharing: &Sharing{
+			MPS: &MPS{
 				Resources:                  replicatedResources,
 				FailRequestsGreaterThanOne: true,
 			},
@@ -20,11 +20,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
-	"time"
 
+	nvidiav1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/nebuly-ai/nos/internal/partitioning/mps"
 	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
 	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/test/factory"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -37,7 +39,7 @@ import (
 func TestToPluginConfig(t *testing.T) {
 	t.Run("Empty node partitioning", func(t *testing.T) {
 		nodePartitioning := state.NodePartitioning{GPUs: []state.GPUPartitioning{}}
-		config, err := mps.ToPluginConfig(nodePartitioning)
+		config, err := mps.ToPluginConfig(v1.Node{}, nodePartitioning)
 		assert.NoError(t, err)
 		assert.Empty(t, config.Sharing.MPS.Resources)
 	})
@@ -61,11 +63,102 @@ func TestToPluginConfig(t *testing.T) {
 				},
 			},
 		}
-		config, err := mps.ToPluginConfig(nodePartitioning)
+		config, err := mps.ToPluginConfig(v1.Node{}, nodePartitioning)
 		assert.NoError(t, err)
 		assert.Len(t, config.Sharing.MPS.Resources, 4)
 	})
 
+	t.Run("MIG resources, should be skipped and MIG strategy should be mixed", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/mig-1g.5gb": 2,
+					},
+				},
+				{
+					GPUIndex: 1,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-10gb": 2,
+					},
+				},
+			},
+		}
+		config, err := mps.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.NoError(t, err)
+		assert.Len(t, config.Sharing.MPS.Resources, 1)
+		assert.Equal(t, "mixed", *config.Flags.MigStrategy)
+	})
+
+	t.Run("No MIG GPUs, MIG strategy should be none", func(t *testing.T) {
+		node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "disabled",
+		}).Get()
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-10gb": 2,
+					},
+				},
+			},
+		}
+		config, err := mps.ToPluginConfig(node, nodePartitioning)
+		assert.NoError(t, err)
+		assert.Equal(t, "none", *config.Flags.MigStrategy)
+	})
+
+	t.Run("GPU with MIG mode enabled but without MIG resources, MIG strategy should be mixed", func(t *testing.T) {
+		node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "disabled",
+			fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "enabled",
+		}).Get()
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-10gb": 2,
+					},
+				},
+			},
+		}
+		config, err := mps.ToPluginConfig(node, nodePartitioning)
+		assert.NoError(t, err)
+		assert.Equal(t, "mixed", *config.Flags.MigStrategy)
+	})
+
+	t.Run("Compute share of profiles should be set as active thread percentage", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-10gb.30pct": 2,
+					},
+				},
+			},
+		}
+		config, err := mps.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			[]mps.MPSResource{
+				{
+					Name:                   "nvidia.com/gpu",
+					Rename:                 "gpu-10gb.30pct",
+					MemoryGB:               10,
+					ActiveThreadPercentage: 30,
+					Replicas:               2,
+					Devices:                []nvidiav1.ReplicatedDeviceRef{"0"},
+				},
+			},
+			config.Sharing.MPS.Resources,
+		)
+	})
+
 	t.Run("Invalid resources in GPU partitioning, should return error", func(t *testing.T) {
 		nodePartitioning := state.NodePartitioning{GPUs: []state.GPUPartitioning{
 			{
@@ -75,7 +168,7 @@ func TestToPluginConfig(t *testing.T) {
 				},
 			},
 		}}
-		config, err := mps.ToPluginConfig(nodePartitioning)
+		config, err := mps.ToPluginConfig(v1.Node{}, nodePartitioning)
 		assert.Error(t, err)
 		assert.Empty(t, config.Sharing)
 	})
@@ -99,9 +192,9 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 			WithObjects(&devicePluginCM).
 			Build()
 		partitioner := mps.NewPartitioner(
+			k8sClient,
 			k8sClient,
 			cmNamespacedName,
-			1*time.Millisecond,
 		)
 		ctx := context.Background()
 
@@ -116,7 +209,7 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 		assert.NotNil(t, cm.Data)
 	})
 
-	t.Run("Should wait device-plugin-delay before updating node labels with new config", func(t *testing.T) {
+	t.Run("Should update node labels with new config and annotate node with the plan", func(t *testing.T) {
 		node := factory.BuildNode("node-1").Get()
 		devicePluginCM := v1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -135,45 +228,47 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 		}
 
 		// config
-		delay := 1 * time.Second
 		planId := "plan-id"
 		k8sClient := fake.NewClientBuilder().
 			WithObjects(&node).
 			WithObjects(&devicePluginCM).
 			Build()
 		partitioner := mps.NewPartitioner(
+			k8sClient,
 			k8sClient,
 			cmNamespacedName,
-			delay,
 		)
 		ctx := context.Background()
 
 		// apply partitioning
-		start := time.Now()
 		err := partitioner.ApplyPartitioning(ctx, node, planId, state.NodePartitioning{GPUs: []state.GPUPartitioning{}})
-		end := time.Now()
 
 		// check no errors
 		assert.NoError(t, err)
-		// check delay is enforced
-		assert.Greater(t, end.Sub(start), delay)
-		// check node labels have been updated
+		// check node labels and annotations have been updated
 		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKey{Namespace: node.Namespace, Name: node.Name}, &node))
 		assert.Contains(t, node.Labels, constant.LabelNvidiaDevicePluginConfig)
 		assert.Equal(t, fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId), node.Labels[constant.LabelNvidiaDevicePluginConfig])
+		assert.Equal(t, planId, node.Annotations[v1alpha1.AnnotationPartitioningPlan])
+		// plan should not be reported until the gpu-agent confirms the device plugin loaded the config
+		assert.NotContains(t, node.Annotations, v1alpha1.AnnotationReportedPartitioningPlan)
 	})
 
 	t.Run("Updating partitioning should delete previous node configs from device plugin CM", func(t *testing.T) {
-		node := factory.BuildNode("node-1").Get()
+		node := factory.BuildNode("gpu-1").Get()
+		oldPlan1 := gpu.NewPlanId(gpu.PartitioningKindMps).String()
+		oldPlan2 := gpu.NewPlanId(gpu.PartitioningKindTimeSlicing).String()
 		devicePluginCM := v1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: "test-namespace",
 				Name:      "test-name",
 			},
 			Data: map[string]string{
-				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, "old-plan-1"): "old-config",
-				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, "old-plan-2"): "old-config",
-				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "node-2", "old-plan-2"):  "config",
+				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, oldPlan1): "old-config",
+				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, oldPlan2): "old-config",
+				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-2", oldPlan2):   "config",
+				fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-10", oldPlan2):  "config",
+				"custom-config": "config",
 			},
 		}
 		cmNamespacedName := types.NamespacedName{
@@ -186,9 +281,9 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 			WithObjects(&devicePluginCM).
 			Build()
 		partitioner := mps.NewPartitioner(
+			k8sClient,
 			k8sClient,
 			cmNamespacedName,
-			1*time.Millisecond,
 		)
 		ctx := context.Background()
 
@@ -203,7 +298,7 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 				},
 			},
 		}
-		planId := "plan-id"
+		planId := gpu.NewPlanId(gpu.PartitioningKindMps).String()
 		err := partitioner.ApplyPartitioning(ctx, node, planId, nodePartitioning)
 		assert.NoError(t, err)
 
@@ -211,10 +306,12 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 		var updatedCm v1.ConfigMap
 		assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &updatedCm))
 
-		// Check keys
+		// Check keys: the configs of the nodes whose names share a prefix with the node must be kept
 		expectedKeys := []string{
 			fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId),
-			fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "node-2", "old-plan-2"),
+			fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-2", oldPlan2),
+			fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-10", oldPlan2),
+			"custom-config",
 		}
 		updatedCmKeys := make([]string, 0, len(updatedCm.Data))
 		for k := range updatedCm.Data {
@@ -222,4 +319,273 @@ func TestPartitioner__ApplyPartitioning(t *testing.T) {
 		}
 		assert.ElementsMatch(t, expectedKeys, updatedCmKeys)
 	})
+
+	t.Run("Concurrent update of device plugin CM should be retried without losing other node configs", func(t *testing.T) {
+		node := factory.BuildNode("node-1").Get()
+		devicePluginCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+			},
+		}
+		cmNamespacedName := types.NamespacedName{
+			Namespace: devicePluginCM.Namespace,
+			Name:      devicePluginCM.Name,
+		}
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(&node).
+			WithObjects(&devicePluginCM).
+			Build()
+
+		// The first time the CM is patched, another node config gets written to it
+		// right before the patch, as if another partitioner updated it concurrently
+		otherNodeKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "node-2", gpu.NewPlanId(gpu.PartitioningKindMps))
+		concurrentClient := &concurrentCMWriter{
+			Client: k8sClient,
+			write: func(ctx context.Context, cm *v1.ConfigMap) error {
+				var current v1.ConfigMap
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), &current); err != nil {
+					return err
+				}
+				current.Data = map[string]string{otherNodeKey: "config"}
+				return k8sClient.Update(ctx, &current)
+			},
+		}
+		partitioner := mps.NewPartitioner(concurrentClient, k8sClient, cmNamespacedName)
+		ctx := context.Background()
+
+		planId := gpu.NewPlanId(gpu.PartitioningKindMps).String()
+		err := partitioner.ApplyPartitioning(ctx, node, planId, state.NodePartitioning{GPUs: []state.GPUPartitioning{}})
+		assert.NoError(t, err)
+
+		var updatedCm v1.ConfigMap
+		assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &updatedCm))
+		assert.Contains(t, updatedCm.Data, otherNodeKey)
+		assert.Contains(t, updatedCm.Data, fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId))
+		assert.Equal(t, 2, concurrentClient.cmPatches)
+	})
+
+	t.Run("Nodes whose names share a prefix should not delete each other configs", func(t *testing.T) {
+		node := factory.BuildNode("worker").Get()
+		otherNodeLegacyKey := "worker-mps-1680000000"
+		otherNodeKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "worker-mps", gpu.NewPlanId(gpu.PartitioningKindMps))
+		nodeLegacyKey := "worker-1680000000"
+		devicePluginCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+			},
+			Data: map[string]string{
+				otherNodeLegacyKey: "config",
+				otherNodeKey:       "config",
+				nodeLegacyKey:      "config",
+			},
+		}
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(&node).
+			WithObjects(&devicePluginCM).
+			Build()
+		partitioner := mps.NewPartitioner(k8sClient, k8sClient, client.ObjectKeyFromObject(&devicePluginCM))
+		ctx := context.Background()
+
+		planId := gpu.NewPlanId(gpu.PartitioningKindMps).String()
+		err := partitioner.ApplyPartitioning(ctx, node, planId, state.NodePartitioning{GPUs: []state.GPUPartitioning{}})
+		assert.NoError(t, err)
+
+		var cm v1.ConfigMap
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&devicePluginCM), &cm))
+		cmKeys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			cmKeys = append(cmKeys, k)
+		}
+		assert.ElementsMatch(
+			t,
+			[]string{otherNodeLegacyKey, otherNodeKey, fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId)},
+			cmKeys,
+		)
+	})
+
+	t.Run("Device plugin CM should be read through the uncached reader", func(t *testing.T) {
+		node := factory.BuildNode("node-1").Get()
+		devicePluginCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+			},
+		}
+		cmNamespacedName := types.NamespacedName{
+			Namespace: devicePluginCM.Namespace,
+			Name:      devicePluginCM.Name,
+		}
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(&node).
+			WithObjects(&devicePluginCM).
+			Build()
+		ctx := context.Background()
+
+		// The cached client keeps returning the CM as it was before another node config got written to it
+		var staleCM v1.ConfigMap
+		assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &staleCM))
+		otherNodeKey := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "node-2", gpu.NewPlanId(gpu.PartitioningKindMps))
+		updatedCM := staleCM.DeepCopy()
+		updatedCM.Data = map[string]string{otherNodeKey: "config"}
+		assert.NoError(t, k8sClient.Update(ctx, updatedCM))
+		cachedClient := staleCMReader{Client: k8sClient, cm: staleCM}
+
+		partitioner := mps.NewPartitioner(cachedClient, k8sClient, cmNamespacedName)
+		planId := gpu.NewPlanId(gpu.PartitioningKindMps).String()
+		err := partitioner.ApplyPartitioning(ctx, node, planId, state.NodePartitioning{GPUs: []state.GPUPartitioning{}})
+		assert.NoError(t, err)
+
+		var cm v1.ConfigMap
+		assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &cm))
+		assert.Contains(t, cm.Data, otherNodeKey)
+		assert.Contains(t, cm.Data, fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId))
+	})
+
+	t.Run("Node with device plugin CM label should use its own CM", func(t *testing.T) {
+		node := factory.BuildNode("node-1").
+			WithLabels(map[string]string{v1alpha1.LabelDevicePluginConfigMap: "pool-configs"}).
+			Get()
+		defaultCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+			},
+		}
+		poolCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "pool-configs",
+			},
+		}
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(&node).
+			WithObjects(&defaultCM, &poolCM).
+			Build()
+		partitioner := mps.NewPartitioner(k8sClient, k8sClient, client.ObjectKeyFromObject(&defaultCM))
+		ctx := context.Background()
+
+		planId := gpu.NewPlanId(gpu.PartitioningKindMps).String()
+		err := partitioner.ApplyPartitioning(ctx, node, planId, state.NodePartitioning{GPUs: []state.GPUPartitioning{}})
+		assert.NoError(t, err)
+
+		key := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId)
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&poolCM), &poolCM))
+		assert.Contains(t, poolCM.Data, key)
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&defaultCM), &defaultCM))
+		assert.NotContains(t, defaultCM.Data, key)
+	})
+}
+
+func TestIsNodeConfigKey(t *testing.T) {
+	planId := gpu.NewPlanId(gpu.PartitioningKindTimeSlicing)
+	testCases := []struct {
+		name     string
+		key      string
+		nodeName string
+		expected bool
+	}{
+		{
+			name:     "Config of the node",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-1", planId),
+			nodeName: "gpu-1",
+			expected: true,
+		},
+		{
+			name:     "Config of the node with legacy plan id",
+			key:      "gpu-1-1680000000",
+			nodeName: "gpu-1",
+			expected: true,
+		},
+		{
+			name:     "Config of a node whose name has the node name as prefix",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu-10", planId),
+			nodeName: "gpu-1",
+			expected: false,
+		},
+		{
+			name:     "Config of a node whose name is a prefix of the node name",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "gpu", planId),
+			nodeName: "gpu-1",
+			expected: false,
+		},
+		{
+			name:     "Legacy config of node worker-mps, node worker",
+			key:      "worker-mps-1680000000",
+			nodeName: "worker",
+			expected: false,
+		},
+		{
+			name:     "Legacy config of node worker-mps, node worker-mps",
+			key:      "worker-mps-1680000000",
+			nodeName: "worker-mps",
+			expected: true,
+		},
+		{
+			name:     "Config of node worker, node worker-mps",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "worker", gpu.NewPlanId(gpu.PartitioningKindMps)),
+			nodeName: "worker-mps",
+			expected: false,
+		},
+		{
+			name:     "Config of node worker, node worker",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "worker", gpu.NewPlanId(gpu.PartitioningKindMps)),
+			nodeName: "worker",
+			expected: true,
+		},
+		{
+			name:     "Config of node worker-mps, node worker",
+			key:      fmt.Sprintf(mps.DevicePluginConfigKeyFormat, "worker-mps", gpu.NewPlanId(gpu.PartitioningKindMps)),
+			nodeName: "worker",
+			expected: false,
+		},
+		{
+			name:     "Config not created by the partitioner",
+			key:      "gpu-1-custom",
+			nodeName: "gpu-1",
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mps.IsNodeConfigKey(tt.key, tt.nodeName))
+		})
+	}
+}
+
+// concurrentCMWriter is a client that writes a ConfigMap right before patching it for the first time,
+// so that the first patch conflicts with the concurrent write
+type concurrentCMWriter struct {
+	client.Client
+	write     func(ctx context.Context, cm *v1.ConfigMap) error
+	cmPatches int
+}
+
+func (c *concurrentCMWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if cm, ok := obj.(*v1.ConfigMap); ok {
+		c.cmPatches++
+		if c.cmPatches == 1 {
+			if err := c.write(ctx, cm); err != nil {
+				return err
+			}
+		}
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// staleCMReader is a client that always returns the same version of the ConfigMaps, as a client
+// whose cache has not been updated yet
+type staleCMReader struct {
+	client.Client
+	cm v1.ConfigMap
+}
+
+func (c staleCMReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if cm, ok := obj.(*v1.ConfigMap); ok {
+		c.cm.DeepCopyInto(cm)
+		return nil
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
 }
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	nvidiav1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PluginConfig is the config of the NVIDIA device plugin written by the partitioner to the device plugin ConfigMap.
+//
+// It mirrors nvidiav1.Config, but its MPS resources also include the share of compute
+// that their clients can use, which is not part of the nvidiav1 API.
+type PluginConfig struct {
+	Version string          `json:"version"`
+	Flags   *nvidiav1.Flags `json:"flags,omitempty"`
+	Sharing *Sharing        `json:"sharing,omitempty"`
+}
+
+// Sharing encapsulates the sharing strategies of the GPUs of a node
+type Sharing struct {
+	TimeSlicing *nvidiav1.TimeSlicing `json:"timeSlicing,omitempty"`
+	MPS         *MPS                  `json:"mps,omitempty"`
+}
+
+// MPS defines the set of GPU replicas backed by MPS
+type MPS struct {
+	FailRequestsGreaterThanOne bool          `json:"failRequestsGreaterThanOne,omitempty"`
+	Resources                  []MPSResource `json:"resources,omitempty"`
+}
+
+// MPSResource is a resource replicated through MPS. Besides the memory limit, each replica can
+// have a limit on the share of the GPU threads its clients can use, which the device plugin
+// enforces through the MPS active thread percentage. Zero means no limit.
+type MPSResource struct {
+	Name                   nvidiav1.ResourceName          `json:"name"`
+	Rename                 nvidiav1.ResourceName          `json:"rename,omitempty"`
+	MemoryGB               int                            `json:"memoryGB"`
+	ActiveThreadPercentage int                            `json:"activeThreadPercentage,omitempty"`
+	Replicas               int                            `json:"replicas"`
+	Devices                []nvidiav1.ReplicatedDeviceRef `json:"devices"`
+}
+
+// GetReplicas returns the number of replicas of each resource exposed by the device plugin
+// with this config, grouped by the index of the GPU they belong to.
+func (c PluginConfig) GetReplicas() (map[int]map[v1.ResourceName]int, error) {
+	res := make(map[int]map[v1.ResourceName]int)
+	if c.Sharing == nil {
+		return res, nil
+	}
+	add := func(name, rename nvidiav1.ResourceName, devices []nvidiav1.ReplicatedDeviceRef, replicas int) error {
+		resourceName := asResourceName(name, rename)
+		for _, d := range devices {
+			gpuIndex, err := strconv.Atoi(string(d))
+			if err != nil {
+				return fmt.Errorf("device %q of resource %s is not a GPU index", d, resourceName)
+			}
+			if res[gpuIndex] == nil {
+				res[gpuIndex] = make(map[v1.ResourceName]int)
+			}
+			res[gpuIndex][resourceName] += replicas
+		}
+		return nil
+	}
+	if c.Sharing.MPS != nil {
+		for _, r := range c.Sharing.MPS.Resources {
+			if err := add(r.Name, r.Rename, r.Devices, r.Replicas); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.Sharing.TimeSlicing != nil {
+		for _, r := range c.Sharing.TimeSlicing.Resources {
+			if r.Devices == nil {
+				continue
+			}
+			if err := add(r.Name, r.Rename, r.Devices.List, r.Replicas); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// asResourceName returns the name of the resource exposed by the device plugin for
+// a replicated resource, which is renamed if the rename provided as argument is not empty.
+func asResourceName(name, rename nvidiav1.ResourceName) v1.ResourceName {
+	if rename == "" {
+		return v1.ResourceName(name)
+	}
+	if strings.HasPrefix(string(rename), constant.NvidiaResourcePrefix) {
+		return v1.ResourceName(rename)
+	}
+	return v1.ResourceName(constant.NvidiaResourcePrefix + string(rename))
+}
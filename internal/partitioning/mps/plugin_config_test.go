@@ -0,0 +1,110 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mps_test
+
+import (
+	"testing"
+
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/internal/partitioning/timeslicing"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPluginConfig__GetReplicas(t *testing.T) {
+	testCases := []struct {
+		name           string
+		toPluginConfig mps.PluginConfigFunc
+		partitioning   state.NodePartitioning
+		expected       map[int]map[v1.ResourceName]int
+	}{
+		{
+			name:           "Empty partitioning",
+			toPluginConfig: mps.ToPluginConfig,
+			partitioning:   state.NodePartitioning{GPUs: []state.GPUPartitioning{}},
+			expected:       map[int]map[v1.ResourceName]int{},
+		},
+		{
+			name:           "MPS partitioning",
+			toPluginConfig: mps.ToPluginConfig,
+			partitioning: state.NodePartitioning{
+				GPUs: []state.GPUPartitioning{
+					{
+						GPUIndex: 0,
+						Resources: map[v1.ResourceName]int{
+							"nvidia.com/gpu-10gb":      2,
+							"nvidia.com/gpu-5gb.20pct": 1,
+						},
+					},
+					{
+						GPUIndex: 1,
+						Resources: map[v1.ResourceName]int{
+							"nvidia.com/gpu-10gb": 1,
+						},
+					},
+				},
+			},
+			expected: map[int]map[v1.ResourceName]int{
+				0: {
+					"nvidia.com/gpu-10gb":      2,
+					"nvidia.com/gpu-5gb.20pct": 1,
+				},
+				1: {
+					"nvidia.com/gpu-10gb": 1,
+				},
+			},
+		},
+		{
+			name:           "Time-slicing partitioning",
+			toPluginConfig: timeslicing.ToPluginConfig,
+			partitioning: state.NodePartitioning{
+				GPUs: []state.GPUPartitioning{
+					{
+						GPUIndex: 1,
+						Resources: map[v1.ResourceName]int{
+							"nvidia.com/gpu-shared": 4,
+						},
+					},
+				},
+			},
+			expected: map[int]map[v1.ResourceName]int{
+				1: {
+					"nvidia.com/gpu-shared": 4,
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.toPluginConfig(v1.Node{}, tt.partitioning)
+			assert.NoError(t, err)
+
+			// The config is read back from the device plugin ConfigMap
+			configYaml, err := yaml.Marshal(config)
+			assert.NoError(t, err)
+			var unmarshalled mps.PluginConfig
+			assert.NoError(t, yaml.Unmarshal(configYaml, &unmarshalled))
+
+			replicas, err := unmarshalled.GetReplicas()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, replicas)
+		})
+	}
+}
@@ -53,6 +53,19 @@ func TestSliceCalculator(t *testing.T) {
 				slicing.ProfileName("nvidia.com/gpu-10gb"): 1,
 			},
 		},
+		{
+			name: "Should include profiles with compute share",
+			pod: factory.BuildPod("ns-1", "pd-1").WithContainer(
+				factory.BuildContainer("c-1", "im-1").
+					WithScalarResourceRequest(slicing.ProfileName("nvidia.com/gpu-10gb").AsResourceName(), 1).
+					WithScalarResourceRequest(slicing.ProfileName("nvidia.com/gpu-5gb.30pct").AsResourceName(), 1).
+					Get(),
+			).Get(),
+			expected: map[gpu.Slice]int{
+				slicing.ProfileName("nvidia.com/gpu-10gb"):      1,
+				slicing.ProfileName("nvidia.com/gpu-5gb.30pct"): 1,
+			},
+		},
 	}
 
 	for _, tt := range testCases {
@@ -61,6 +61,27 @@ func TestSnapshotTaker__TakeSnapshot(t *testing.T) {
 			},
 			expectedSnapshotNodes: []string{"node-3"},
 		},
+		{
+			name: "MPS snapshot should not include nodes that did not report the last plan within timeout",
+			snapshotNodes: []v1.Node{
+				factory.BuildNode("node-1").WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaCount:     "1",
+					constant.LabelNvidiaProduct:   string(gpu.GPUModel_A100_SXM4_40GB),
+					constant.LabelNvidiaMemory:    "1000",
+				}).WithConditions(v1.NodeCondition{
+					Type:   v1alpha1.NodeConditionPartitioningPlanNotReported,
+					Status: v1.ConditionTrue,
+				}).Get(),
+				factory.BuildNode("node-2").WithLabels(map[string]string{
+					v1alpha1.LabelGpuPartitioning: gpu.PartitioningKindMps.String(),
+					constant.LabelNvidiaCount:     "1",
+					constant.LabelNvidiaProduct:   string(gpu.GPUModel_A100_SXM4_40GB),
+					constant.LabelNvidiaMemory:    "1000",
+				}).Get(),
+			},
+			expectedSnapshotNodes: []string{"node-2"},
+		},
 		{
 			name: "Should return error if a node is gpu-partitioning=mps but it's missing required NVIDIA labels",
 			snapshotNodes: []v1.Node{
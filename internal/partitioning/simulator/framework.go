@@ -0,0 +1,164 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	testutil "github.com/nebuly-ai/nos/pkg/test/util"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	latestschedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config/latest"
+	schedulerscheme "k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	schedulerplugins "k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+	schedulerruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	// Ensure scheduler package is initialized.
+	_ "github.com/nebuly-ai/nos/pkg/api/scheduler"
+)
+
+// NewSchedulerFramework returns a scheduler framework that runs the in-tree plugins enabled by the
+// profile provided as argument on the nodes and pods provided as argument, without connecting to
+// any API server. Out-of-tree plugins, such as the Capacity Scheduling plugin, need an API server
+// and are therefore removed from the profile.
+func NewSchedulerFramework(
+	ctx context.Context,
+	profile schedulerconfig.KubeSchedulerProfile,
+	nodes []v1.Node,
+	pods []v1.Pod,
+) (framework.Framework, error) {
+	clientSet := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(clientSet, 0)
+
+	nodePtrs := make([]*v1.Node, 0, len(nodes))
+	for i := range nodes {
+		nodePtrs = append(nodePtrs, &nodes[i])
+	}
+	podPtrs := make([]*v1.Pod, 0, len(pods))
+	for i := range pods {
+		if pods[i].Spec.NodeName != "" {
+			podPtrs = append(podPtrs, &pods[i])
+		}
+	}
+
+	registry := schedulerplugins.NewInTreeRegistry()
+	profile, removed := withoutUnknownPlugins(profile, registry)
+	if len(removed) > 0 {
+		log.FromContext(ctx).Info("ignoring scheduler plugins not supported by the simulator", "plugins", removed)
+	}
+
+	return schedulerruntime.NewFramework(
+		registry,
+		&profile,
+		ctx.Done(),
+		schedulerruntime.WithClientSet(clientSet),
+		schedulerruntime.WithInformerFactory(informerFactory),
+		schedulerruntime.WithSnapshotSharedLister(testutil.NewFakeSharedLister(podPtrs, nodePtrs)),
+	)
+}
+
+// withoutUnknownPlugins returns a copy of the profile provided as argument without the plugins
+// that are not part of the registry, together with the names of the removed plugins
+func withoutUnknownPlugins(
+	profile schedulerconfig.KubeSchedulerProfile,
+	registry schedulerruntime.Registry,
+) (schedulerconfig.KubeSchedulerProfile, []string) {
+	res := *profile.DeepCopy()
+	removed := make([]string, 0)
+	isKnown := func(name string) bool {
+		// "*" is used for disabling all the plugins of an extension point
+		if _, ok := registry[name]; ok || name == "*" {
+			return true
+		}
+		if !util.InSlice(name, removed) {
+			removed = append(removed, name)
+		}
+		return false
+	}
+
+	if res.Plugins != nil {
+		pluginSets := []*schedulerconfig.PluginSet{
+			&res.Plugins.QueueSort,
+			&res.Plugins.PreFilter,
+			&res.Plugins.Filter,
+			&res.Plugins.PostFilter,
+			&res.Plugins.PreScore,
+			&res.Plugins.Score,
+			&res.Plugins.Reserve,
+			&res.Plugins.Permit,
+			&res.Plugins.PreBind,
+			&res.Plugins.Bind,
+			&res.Plugins.PostBind,
+			&res.Plugins.MultiPoint,
+		}
+		for _, set := range pluginSets {
+			set.Enabled = util.Filter(set.Enabled, func(p schedulerconfig.Plugin) bool {
+				return isKnown(p.Name)
+			})
+			set.Disabled = util.Filter(set.Disabled, func(p schedulerconfig.Plugin) bool {
+				return isKnown(p.Name)
+			})
+		}
+	}
+	res.PluginConfig = util.Filter(res.PluginConfig, func(c schedulerconfig.PluginConfig) bool {
+		return isKnown(c.Name)
+	})
+
+	return res, removed
+}
+
+// DefaultSchedulerProfile returns the profile of the default scheduler
+func DefaultSchedulerProfile() (schedulerconfig.KubeSchedulerProfile, error) {
+	defaultSchedulerConfig, err := latestschedulerconfig.Default()
+	if err != nil {
+		return schedulerconfig.KubeSchedulerProfile{}, fmt.Errorf("couldn't create scheduler config: %v", err)
+	}
+	if len(defaultSchedulerConfig.Profiles) != 1 || defaultSchedulerConfig.Profiles[0].SchedulerName != v1.DefaultSchedulerName {
+		return schedulerconfig.KubeSchedulerProfile{}, fmt.Errorf(
+			"unexpected scheduler config: expected default scheduler profile only (found %d profiles)",
+			len(defaultSchedulerConfig.Profiles),
+		)
+	}
+	return defaultSchedulerConfig.Profiles[0], nil
+}
+
+// LoadSchedulerProfile returns the first profile of the KubeSchedulerConfiguration defined in the file
+// provided as argument, which is the same profile used by the gpu-partitioner
+func LoadSchedulerProfile(file string) (schedulerconfig.KubeSchedulerProfile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return schedulerconfig.KubeSchedulerProfile{}, err
+	}
+	// The UniversalDecoder runs defaulting and returns the internal type by default.
+	obj, gvk, err := schedulerscheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+	if err != nil {
+		return schedulerconfig.KubeSchedulerProfile{}, err
+	}
+	schedulerConfig, ok := obj.(*schedulerconfig.KubeSchedulerConfiguration)
+	if !ok {
+		return schedulerconfig.KubeSchedulerProfile{}, fmt.Errorf("couldn't decode as KubeSchedulerConfiguration, got %s", gvk)
+	}
+	if len(schedulerConfig.Profiles) == 0 {
+		return schedulerconfig.KubeSchedulerProfile{}, fmt.Errorf("scheduler config does not contain any profile")
+	}
+	return schedulerConfig.Profiles[0], nil
+}
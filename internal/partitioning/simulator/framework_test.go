@@ -0,0 +1,89 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simulator_test
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/internal/partitioning/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const nosSchedulerConfig = `
+apiVersion: kubescheduler.config.k8s.io/v1beta3
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: nos-scheduler
+  plugins:
+    preFilter:
+      enabled:
+        - name: CapacityScheduling
+    postFilter:
+      enabled:
+        - name: CapacityScheduling
+      disabled:
+        - name: "*"
+    reserve:
+      enabled:
+        - name: CapacityScheduling
+  pluginConfig:
+    - name: CapacityScheduling
+      args:
+        nvidiaGpuResourceMemoryGB: 32
+`
+
+func TestLoadSchedulerProfile(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		content               string
+		expectedSchedulerName string
+		expectedErr           bool
+	}{
+		{
+			name:                  "Profile with out-of-tree plugins",
+			content:               nosSchedulerConfig,
+			expectedSchedulerName: "nos-scheduler",
+		},
+		{
+			name:        "Not a scheduler config",
+			content:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "scheduler-config.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.content), 0600))
+
+			profile, err := simulator.LoadSchedulerProfile(file)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSchedulerName, profile.SchedulerName)
+
+			// Out-of-tree plugins are ignored, so that the framework can be created without an API server
+			_, err = simulator.NewSchedulerFramework(context.Background(), profile, nil, nil)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corev1defaults "k8s.io/kubernetes/pkg/apis/core/v1"
+	"os"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	codecs = serializer.NewCodecFactory(scheme)
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(corev1defaults.RegisterDefaults(scheme))
+}
+
+// LoadObjectsFromFiles returns the nodes and the pods defined in the YAML or JSON files provided as argument.
+// See DecodeObjects for the supported formats.
+func LoadObjectsFromFiles(files ...string) ([]v1.Node, []v1.Pod, error) {
+	nodes := make([]v1.Node, 0)
+	pods := make([]v1.Pod, 0)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		n, p, err := DecodeObjects(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to decode %s: %v", f, err)
+		}
+		nodes = append(nodes, n...)
+		pods = append(pods, p...)
+	}
+	return nodes, pods, nil
+}
+
+// DecodeObjects returns the nodes and the pods defined in the data provided as argument.
+// The data can contain multiple YAML documents, each one defining either a single object or
+// a List of objects (e.g. the output of "kubectl get nodes -o yaml"). Objects other than
+// nodes and pods are ignored.
+//
+// The objects are defaulted as the API server would do, so that for instance
+// the pods requesting GPU resources only through their limits request them as well.
+func DecodeObjects(data []byte) ([]v1.Node, []v1.Pod, error) {
+	nodes := make([]v1.Node, 0)
+	pods := make([]v1.Pod, 0)
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nodes, pods, nil
+			}
+			return nil, nil, err
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw.Raw), []byte("null")) {
+			continue
+		}
+		objects, err := decodeObject(raw.Raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, o := range objects {
+			switch obj := o.(type) {
+			case *v1.Node:
+				nodes = append(nodes, *obj)
+			case *v1.Pod:
+				pods = append(pods, *obj)
+			}
+		}
+	}
+}
+
+func decodeObject(data []byte) ([]runtime.Object, error) {
+	obj, _, err := codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return []runtime.Object{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, ok := obj.(*v1.List)
+	if !ok {
+		scheme.Default(obj)
+		return []runtime.Object{obj}, nil
+	}
+	res := make([]runtime.Object, 0, len(list.Items))
+	for _, item := range list.Items {
+		objects, err := decodeObject(item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, objects...)
+	}
+	return res, nil
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/hybrid"
+	"github.com/nebuly-ai/nos/internal/partitioning/mig"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/internal/partitioning/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/util"
+	"github.com/nebuly-ai/nos/pkg/util/pod"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+)
+
+// Options defines how the Simulator computes the partitioning plan
+type Options struct {
+	// Kind is the kind of partitioning to simulate
+	Kind gpu.PartitioningKind
+	// PlannerOptions are the options of the planner, the same used by the gpu-partitioner
+	PlannerOptions core.PlannerOptions
+	// AllPending makes the Simulator consider as candidates all the pending pods that are not
+	// bound to any node. By default, like the gpu-partitioner, only the pending pods marked as
+	// unschedulable by the scheduler are considered.
+	AllPending bool
+}
+
+// Result is the outcome of a simulation
+type Result struct {
+	// Kind is the kind of partitioning that has been simulated
+	Kind gpu.PartitioningKind `json:"kind"`
+	// CurrentState is the partitioning of the nodes before applying the plan
+	CurrentState map[string][]GPUPartitioning `json:"currentState"`
+	// DesiredState is the partitioning of the nodes computed by the planner
+	DesiredState map[string][]GPUPartitioning `json:"desiredState"`
+	// ChangedNodes contains the names of the nodes whose partitioning is changed by the plan
+	ChangedNodes []string `json:"changedNodes"`
+	// Pods explains, for each candidate pod, whether and where it has been placed
+	Pods []core.PodExplanation `json:"pods"`
+}
+
+// GPUPartitioning is the partitioning of a single GPU
+type GPUPartitioning struct {
+	GPUIndex  int                     `json:"gpuIndex"`
+	Resources map[v1.ResourceName]int `json:"resources"`
+}
+
+// PlacedPods returns the number of candidate pods placed by the plan
+func (r Result) PlacedPods() int {
+	var res int
+	for _, p := range r.Pods {
+		if p.Fits {
+			res++
+		}
+	}
+	return res
+}
+
+// Simulator computes partitioning plans by running the same SnapshotTaker, Planner and
+// PartitionCalculator of the gpu-partitioner on a static cluster state, without any API server
+type Simulator struct {
+	opts      Options
+	scheduler framework.Framework
+}
+
+// New returns a Simulator that computes the partitioning plans of the kind specified
+// in the options. The scheduler framework is used by the planner for placing the pods on the nodes.
+func New(scheduler framework.Framework, opts Options) (*Simulator, error) {
+	switch opts.Kind {
+	case gpu.PartitioningKindMig, gpu.PartitioningKindMps, gpu.PartitioningKindHybrid, gpu.PartitioningKindTimeSlicing:
+		return &Simulator{opts: opts, scheduler: scheduler}, nil
+	default:
+		return nil, fmt.Errorf("invalid partitioning kind %q", opts.Kind)
+	}
+}
+
+// Run computes the partitioning plan for the cluster defined by the nodes and pods provided as argument.
+// Pods bound to a node are considered as running on it, whereas the pending pods are the candidates
+// of the plan.
+func (s *Simulator) Run(ctx context.Context, nodes []v1.Node, pods []v1.Pod) (Result, error) {
+	clusterState := NewClusterState(nodes, pods)
+	snapshot, err := s.snapshotTaker().TakeSnapshot(clusterState)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to take a snapshot of the cluster state: %v", err)
+	}
+	current := snapshot.GetPartitioningState()
+
+	plan, err := s.planner().Plan(ctx, snapshot.Clone(), s.candidatePods(pods))
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to plan desired partitioning state: %v", err)
+	}
+
+	res := Result{
+		Kind:         s.opts.Kind,
+		CurrentState: toResultState(current),
+		DesiredState: toResultState(plan.DesiredState),
+		ChangedNodes: make([]string, 0),
+		Pods:         plan.Explanation.Pods,
+	}
+	for node, desired := range plan.DesiredState {
+		if !desired.Equal(current[node]) {
+			res.ChangedNodes = append(res.ChangedNodes, node)
+		}
+	}
+	sort.Strings(res.ChangedNodes)
+	return res, nil
+}
+
+func (s *Simulator) candidatePods(pods []v1.Pod) []v1.Pod {
+	return util.Filter(pods, func(p v1.Pod) bool {
+		if s.opts.AllPending {
+			return !pod.IsScheduled(p) && (p.Status.Phase == v1.PodPending || p.Status.Phase == "")
+		}
+		return pod.ExtraResourcesCouldHelpScheduling(p)
+	})
+}
+
+func (s *Simulator) snapshotTaker() core.SnapshotTaker {
+	switch s.opts.Kind {
+	case gpu.PartitioningKindMig:
+		return mig.NewSnapshotTaker()
+	case gpu.PartitioningKindMps:
+		return mps.NewSnapshotTaker()
+	case gpu.PartitioningKindTimeSlicing:
+		return timeslicing.NewSnapshotTaker()
+	default:
+		return hybrid.NewSnapshotTaker()
+	}
+}
+
+func (s *Simulator) planner() core.Planner {
+	switch s.opts.Kind {
+	case gpu.PartitioningKindMig:
+		return mig.NewPlanner(s.scheduler, s.opts.PlannerOptions)
+	case gpu.PartitioningKindMps:
+		return mps.NewPlanner(s.scheduler, s.opts.PlannerOptions)
+	case gpu.PartitioningKindTimeSlicing:
+		return timeslicing.NewPlanner(s.scheduler, s.opts.PlannerOptions)
+	default:
+		return hybrid.NewPlanner(s.scheduler, s.opts.PlannerOptions)
+	}
+}
+
+// NewClusterState returns the ClusterState made of the nodes provided as argument,
+// where each node runs the pods bound to it
+func NewClusterState(nodes []v1.Node, pods []v1.Pod) *state.ClusterState {
+	nodePods := make(map[string][]v1.Pod)
+	for _, p := range pods {
+		if pod.IsScheduled(p) {
+			nodePods[p.Spec.NodeName] = append(nodePods[p.Spec.NodeName], p)
+		}
+	}
+	clusterState := state.NewEmptyClusterState()
+	for _, n := range nodes {
+		clusterState.UpdateNode(n, nodePods[n.Name])
+	}
+	return clusterState
+}
+
+func toResultState(s state.PartitioningState) map[string][]GPUPartitioning {
+	res := make(map[string][]GPUPartitioning, len(s))
+	for node, partitioning := range s {
+		gpus := make([]GPUPartitioning, 0, len(partitioning.GPUs))
+		for _, g := range partitioning.GPUs {
+			resources := make(map[v1.ResourceName]int)
+			for r, q := range g.Resources {
+				if q > 0 {
+					resources[r] = q
+				}
+			}
+			gpus = append(gpus, GPUPartitioning{GPUIndex: g.GPUIndex, Resources: resources})
+		}
+		sort.Slice(gpus, func(i, j int) bool {
+			return gpus[i].GPUIndex < gpus[j].GPUIndex
+		})
+		res[node] = gpus
+	}
+	return res
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simulator_test
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/simulator"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+const migNodes = `
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-1
+  annotations:
+    nos.nebuly.com/status-gpu-0-7g.40gb-free: "1"
+  labels:
+    nos.nebuly.com/gpu-partitioning: mig
+    nvidia.com/gpu.product: NVIDIA-A100-40GB-SXM4
+    nvidia.com/gpu.count: "1"
+status:
+  allocatable:
+    cpu: "8"
+    memory: 32Gi
+    pods: "110"
+    nvidia.com/mig-7g.40gb: "1"
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-2
+  labels:
+    nvidia.com/gpu.product: NVIDIA-A100-40GB-SXM4
+    nvidia.com/gpu.count: "1"
+status:
+  allocatable:
+    cpu: "8"
+    memory: 32Gi
+    pods: "110"
+`
+
+const pendingPods = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod-1
+    namespace: ns-1
+  spec:
+    containers:
+    - name: c
+      image: test
+      resources:
+        limits:
+          nvidia.com/mig-1g.5gb: 2
+  status:
+    phase: Pending
+    conditions:
+    - type: PodScheduled
+      status: "False"
+      reason: Unschedulable
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod-2
+    namespace: ns-1
+  spec:
+    containers:
+    - name: c
+      image: test
+      resources:
+        limits:
+          nvidia.com/mig-3g.20gb: 1
+  status:
+    phase: Pending
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+---
+apiVersion: nos.nebuly.com/v1alpha1
+kind: ElasticQuota
+metadata:
+  name: ignored
+`
+
+func TestDecodeObjects(t *testing.T) {
+	nodes, pods, err := simulator.DecodeObjects([]byte(migNodes + "---" + pendingPods))
+	require.NoError(t, err)
+
+	nodeNames := make([]string, 0)
+	for _, n := range nodes {
+		nodeNames = append(nodeNames, n.Name)
+	}
+	podNames := make([]string, 0)
+	for _, p := range pods {
+		podNames = append(podNames, p.Name)
+	}
+	assert.Equal(t, []string{"node-1", "node-2"}, nodeNames)
+	assert.Equal(t, []string{"pod-1", "pod-2"}, podNames)
+	assert.Equal(t, "mig", nodes[0].Labels["nos.nebuly.com/gpu-partitioning"])
+	assert.Equal(t, v1.PodPending, pods[0].Status.Phase)
+
+	_, _, err = simulator.DecodeObjects([]byte("kind: Node\napiVersion: v1\nmetadata: [invalid"))
+	assert.Error(t, err)
+}
+
+func TestSimulator__Run(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		kind                 gpu.PartitioningKind
+		allPending           bool
+		expectedChangedNodes []string
+		expectedDesiredState map[string][]simulator.GPUPartitioning
+		expectedPods         []core.PodExplanation
+	}{
+		{
+			name:                 "Only unschedulable pods are candidates by default",
+			kind:                 gpu.PartitioningKindMig,
+			expectedChangedNodes: []string{"node-1"},
+			expectedDesiredState: map[string][]simulator.GPUPartitioning{
+				"node-1": {
+					{
+						GPUIndex: 0,
+						Resources: map[v1.ResourceName]int{
+							"nvidia.com/mig-1g.5gb":  3,
+							"nvidia.com/mig-4g.20gb": 1,
+						},
+					},
+				},
+			},
+			expectedPods: []core.PodExplanation{
+				{Pod: "ns-1/pod-1", Fits: true, Node: "node-1"},
+			},
+		},
+		{
+			name:                 "All pending pods are candidates",
+			kind:                 gpu.PartitioningKindMig,
+			allPending:           true,
+			expectedChangedNodes: []string{"node-1"},
+			expectedDesiredState: map[string][]simulator.GPUPartitioning{
+				"node-1": {
+					{
+						GPUIndex: 0,
+						Resources: map[v1.ResourceName]int{
+							"nvidia.com/mig-1g.5gb":  3,
+							"nvidia.com/mig-3g.20gb": 1,
+						},
+					},
+				},
+			},
+			expectedPods: []core.PodExplanation{
+				{Pod: "ns-1/pod-1", Fits: true, Node: "node-1"},
+				{Pod: "ns-1/pod-2", Fits: true, Node: "node-1"},
+			},
+		},
+		{
+			name:                 "No nodes enabled for the partitioning kind",
+			kind:                 gpu.PartitioningKindMps,
+			allPending:           true,
+			expectedChangedNodes: []string{},
+			expectedDesiredState: map[string][]simulator.GPUPartitioning{},
+			expectedPods: []core.PodExplanation{
+				{Pod: "ns-1/pod-1"},
+				{Pod: "ns-1/pod-2"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodes, pods, err := simulator.DecodeObjects([]byte(migNodes + "---" + pendingPods))
+			require.NoError(t, err)
+
+			profile, err := simulator.DefaultSchedulerProfile()
+			require.NoError(t, err)
+			framework, err := simulator.NewSchedulerFramework(ctx, profile, nodes, pods)
+			require.NoError(t, err)
+			sim, err := simulator.New(framework, simulator.Options{Kind: tt.kind, AllPending: tt.allPending})
+			require.NoError(t, err)
+
+			res, err := sim.Run(ctx, nodes, pods)
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, res.Kind)
+			assert.Equal(t, tt.expectedChangedNodes, res.ChangedNodes)
+			assert.Equal(t, tt.expectedDesiredState, res.DesiredState)
+			for i := range res.Pods {
+				res.Pods[i].Rejections = nil
+			}
+			assert.Equal(t, tt.expectedPods, res.Pods)
+
+			placed := 0
+			for _, p := range tt.expectedPods {
+				if p.Fits {
+					placed++
+				}
+			}
+			assert.Equal(t, placed, res.PlacedPods())
+		})
+	}
+}
+
+func TestSimulator__Run__IsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	nodes, pods, err := simulator.DecodeObjects([]byte(migNodes + "---" + pendingPods))
+	require.NoError(t, err)
+	profile, err := simulator.DefaultSchedulerProfile()
+	require.NoError(t, err)
+	framework, err := simulator.NewSchedulerFramework(ctx, profile, nodes, pods)
+	require.NoError(t, err)
+	sim, err := simulator.New(framework, simulator.Options{Kind: gpu.PartitioningKindMig, AllPending: true})
+	require.NoError(t, err)
+
+	first, err := sim.Run(ctx, nodes, pods)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string][]simulator.GPUPartitioning{
+			"node-1": {{GPUIndex: 0, Resources: map[v1.ResourceName]int{"nvidia.com/mig-7g.40gb": 1}}},
+		},
+		first.CurrentState,
+	)
+	for i := 0; i < 5; i++ {
+		res, err := sim.Run(ctx, nodes, pods)
+		require.NoError(t, err)
+		assert.Equal(t, first, res)
+	}
+}
+
+func TestNew__InvalidKind(t *testing.T) {
+	_, err := simulator.New(nil, simulator.Options{Kind: "foo"})
+	assert.Error(t, err)
+}
@@ -26,6 +26,25 @@ type GPUPartitioning struct {
 	Resources map[v1.ResourceName]int
 }
 
+// Equal returns true if the two GPU partitionings have the same index and provide the same resources,
+// ignoring the resources with zero quantity
+func (g GPUPartitioning) Equal(other GPUPartitioning) bool {
+	if g.GPUIndex != other.GPUIndex {
+		return false
+	}
+	for r, q := range g.Resources {
+		if other.Resources[r] != q {
+			return false
+		}
+	}
+	for r, q := range other.Resources {
+		if g.Resources[r] != q {
+			return false
+		}
+	}
+	return true
+}
+
 type NodePartitioning struct {
 	GPUs []GPUPartitioning
 }
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing_test
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/internal/partitioning/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	gputimeslicing "github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/nebuly-ai/nos/pkg/test/mocks"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func newTimeSlicingNodeOrPanic(node v1.Node) *gputimeslicing.Node {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	timeSlicingNode, err := gputimeslicing.NewNode(*nodeInfo)
+	if err != nil {
+		panic(err)
+	}
+	return &timeSlicingNode
+}
+
+func TestPartitionCalculator__GetPartitioning(t *testing.T) {
+	testCases := []struct {
+		name     string
+		node     core.PartitionableNode
+		expected state.NodePartitioning
+	}{
+		{
+			name:     "Node is not time-slicing node, should return empty partitioning",
+			node:     &mocks.PartitionableNode{},
+			expected: state.NodePartitioning{GPUs: make([]state.GPUPartitioning, 0)},
+		},
+		{
+			name: "Time-slicing node without any replica",
+			node: newTimeSlicingNodeOrPanic(
+				factory.BuildNode("node-1").WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: "Tesla-T4",
+					constant.LabelNvidiaCount:   "2",
+				}).Get(),
+			),
+			expected: state.NodePartitioning{
+				GPUs: []state.GPUPartitioning{
+					{
+						GPUIndex:  0,
+						Resources: map[v1.ResourceName]int{},
+					},
+					{
+						GPUIndex:  1,
+						Resources: map[v1.ResourceName]int{},
+					},
+				},
+			},
+		},
+		{
+			name: "Time-slicing node with replica annotations",
+			node: newTimeSlicingNodeOrPanic(
+				factory.BuildNode("node-1").WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: "Tesla-T4",
+					constant.LabelNvidiaCount:   "2",
+				}).WithAnnotations(map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, gputimeslicing.ProfileShared, resource.StatusUsed): "1",
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, gputimeslicing.ProfileShared, resource.StatusFree): "3",
+				}).Get(),
+			),
+			expected: state.NodePartitioning{
+				GPUs: []state.GPUPartitioning{
+					{
+						GPUIndex:  0,
+						Resources: map[v1.ResourceName]int{},
+					},
+					{
+						GPUIndex: 1,
+						Resources: map[v1.ResourceName]int{
+							gputimeslicing.ProfileShared.AsResourceName(): 4,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			partitioning := timeslicing.NewPartitionCalculator().GetPartitioning(tt.node)
+			assert.True(t, tt.expected.Equal(partitioning))
+		})
+	}
+}
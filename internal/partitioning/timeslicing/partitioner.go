@@ -0,0 +1,95 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+import (
+	"fmt"
+	nvidiav1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/nebuly-ai/nos/internal/partitioning/core"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+// NewPartitioner returns a Partitioner that applies the time-slicing partitioning of the nodes
+// through the device plugin ConfigMap, in the same way as the MPS partitioner does.
+func NewPartitioner(
+	client client.Client,
+	cmReader client.Reader,
+	devicePluginCM types.NamespacedName,
+) core.Partitioner {
+	return mps.NewPartitionerWithPluginConfig(client, cmReader, devicePluginCM, ToPluginConfig)
+}
+
+// ToPluginConfig converts the node partitioning provided as argument to the config of the NVIDIA device plugin.
+//
+// Each GPU with replicas is exposed by the plugin only as time-sliced replicas, whereas
+// the GPUs without any replica are exposed as full GPUs.
+func ToPluginConfig(_ v1.Node, partitioning state.NodePartitioning) (mps.PluginConfig, error) {
+	replicatedResources := make([]nvidiav1.ReplicatedResource, 0)
+	for _, g := range partitioning.GPUs {
+		for r, q := range g.Resources {
+			if !timeslicing.IsGpuReplica(r) {
+				return mps.PluginConfig{}, fmt.Errorf("invalid time-slicing resource %s", r)
+			}
+			if q == 0 {
+				continue
+			}
+			if q < timeslicing.MinReplicas {
+				return mps.PluginConfig{}, fmt.Errorf(
+					"GPU %d has %d replicas, but at least %d are required",
+					g.GPUIndex,
+					q,
+					timeslicing.MinReplicas,
+				)
+			}
+			replicatedResource := nvidiav1.ReplicatedResource{
+				Name:   nvidiav1.ResourceName(constant.ResourceNvidiaGPU),
+				Rename: nvidiav1.ResourceName(strings.TrimPrefix(r.String(), constant.NvidiaResourcePrefix)),
+				Devices: &nvidiav1.ReplicatedDevices{
+					List: []nvidiav1.ReplicatedDeviceRef{
+						nvidiav1.ReplicatedDeviceRef(strconv.Itoa(g.GPUIndex)),
+					},
+				},
+				Replicas: q,
+			}
+			replicatedResources = append(replicatedResources, replicatedResource)
+		}
+	}
+
+	return mps.PluginConfig{
+		Version: nvidiav1.Version,
+		Flags: &nvidiav1.Flags{
+			CommandLineFlags: nvidiav1.CommandLineFlags{
+				MigStrategy: util.StringAddr("none"),
+			},
+		},
+		Sharing: &mps.Sharing{
+			TimeSlicing: &nvidiav1.TimeSlicing{
+				Resources:                  replicatedResources,
+				FailRequestsGreaterThanOne: true,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing_test
+
+import (
+	"context"
+	"fmt"
+	nvidiav1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/nebuly-ai/nos/internal/partitioning/mps"
+	"github.com/nebuly-ai/nos/internal/partitioning/state"
+	"github.com/nebuly-ai/nos/internal/partitioning/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+	"testing"
+)
+
+func TestToPluginConfig(t *testing.T) {
+	t.Run("Empty node partitioning", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{GPUs: []state.GPUPartitioning{}}
+		config, err := timeslicing.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.NoError(t, err)
+		assert.Empty(t, config.Sharing.TimeSlicing.Resources)
+		assert.Nil(t, config.Sharing.MPS)
+	})
+
+	t.Run("Multiple GPUs, GPUs without replicas should not be replicated", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-shared": 4,
+					},
+				},
+				{
+					GPUIndex:  1,
+					Resources: map[v1.ResourceName]int{},
+				},
+				{
+					GPUIndex: 2,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-shared": 2,
+					},
+				},
+			},
+		}
+		config, err := timeslicing.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.NoError(t, err)
+		assert.ElementsMatch(
+			t,
+			[]nvidiav1.ReplicatedResource{
+				{
+					Name:     "nvidia.com/gpu",
+					Rename:   "gpu-shared",
+					Devices:  &nvidiav1.ReplicatedDevices{List: []nvidiav1.ReplicatedDeviceRef{"0"}},
+					Replicas: 4,
+				},
+				{
+					Name:     "nvidia.com/gpu",
+					Rename:   "gpu-shared",
+					Devices:  &nvidiav1.ReplicatedDevices{List: []nvidiav1.ReplicatedDeviceRef{"2"}},
+					Replicas: 2,
+				},
+			},
+			config.Sharing.TimeSlicing.Resources,
+		)
+		assert.Equal(t, "none", *config.Flags.MigStrategy)
+	})
+
+	t.Run("Fewer replicas than the min allowed, should return error", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{GPUs: []state.GPUPartitioning{
+			{
+				GPUIndex: 0,
+				Resources: map[v1.ResourceName]int{
+					"nvidia.com/gpu-shared": 1,
+				},
+			},
+		}}
+		_, err := timeslicing.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid resources in GPU partitioning, should return error", func(t *testing.T) {
+		nodePartitioning := state.NodePartitioning{GPUs: []state.GPUPartitioning{
+			{
+				GPUIndex: 0,
+				Resources: map[v1.ResourceName]int{
+					"nvidia.com/gpu-10gb": 2,
+				},
+			},
+		}}
+		config, err := timeslicing.ToPluginConfig(v1.Node{}, nodePartitioning)
+		assert.Error(t, err)
+		assert.Empty(t, config.Sharing)
+	})
+}
+
+func TestPartitioner__ApplyPartitioning(t *testing.T) {
+	t.Run("Should write time-slicing config to device plugin CM and update node labels", func(t *testing.T) {
+		node := factory.BuildNode("node-1").Get()
+		devicePluginCM := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+			},
+		}
+		cmNamespacedName := types.NamespacedName{
+			Namespace: devicePluginCM.Namespace,
+			Name:      devicePluginCM.Name,
+		}
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(&node).
+			WithObjects(&devicePluginCM).
+			Build()
+		partitioner := timeslicing.NewPartitioner(k8sClient, k8sClient, cmNamespacedName)
+		ctx := context.Background()
+
+		planId := "plan-id"
+		nodePartitioning := state.NodePartitioning{
+			GPUs: []state.GPUPartitioning{
+				{
+					GPUIndex: 0,
+					Resources: map[v1.ResourceName]int{
+						"nvidia.com/gpu-shared": 3,
+					},
+				},
+			},
+		}
+		err := partitioner.ApplyPartitioning(ctx, node, planId, nodePartitioning)
+		assert.NoError(t, err)
+
+		// Check device plugin config
+		key := fmt.Sprintf(mps.DevicePluginConfigKeyFormat, node.Name, planId)
+		var updatedCm v1.ConfigMap
+		assert.NoError(t, k8sClient.Get(ctx, cmNamespacedName, &updatedCm))
+		assert.Contains(t, updatedCm.Data, key)
+		var config nvidiav1.Config
+		assert.NoError(t, yaml.Unmarshal([]byte(updatedCm.Data[key]), &config))
+		assert.Len(t, config.Sharing.TimeSlicing.Resources, 1)
+		assert.Equal(t, 3, config.Sharing.TimeSlicing.Resources[0].Replicas)
+
+		// Check node labels
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKey{Name: node.Name}, &node))
+		assert.Equal(t, key, node.Labels[constant.LabelNvidiaDevicePluginConfig])
+	})
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Version is the version of the nos agents, overridden at build time through ldflags
+var Version = "dev"
+
+const (
+	MigAgentName = "mig-agent"
+	GpuAgentName = "gpu-agent"
+)
+
+// Info describes a nos agent running on a node
+type Info struct {
+	// Name is the name of the agent, e.g. "mig-agent"
+	Name string
+	// Version is the version of the agent
+	Version string
+	// Capabilities are the kinds of partitioning that the agent can apply to the GPUs of the node
+	Capabilities []gpu.PartitioningKind
+	// GpuModels are the models of the GPUs of the node discovered by the agent
+	GpuModels []gpu.Model
+}
+
+// GetLeaseName returns the name of the Lease held by the agent with the name provided as argument
+// running on the node provided as argument
+func GetLeaseName(agentName string, nodeName string) string {
+	return fmt.Sprintf("%s-%s", agentName, nodeName)
+}
+
+var _ manager.LeaderElectionRunnable = &LeaseRenewer{}
+
+// LeaseRenewer periodically renews the Lease through which an agent running on a node
+// tells the GPU Partitioner that it is alive, together with its version and capabilities.
+type LeaseRenewer struct {
+	kubeClient    kubernetes.Interface
+	namespace     string
+	nodeName      string
+	info          Info
+	leaseDuration time.Duration
+}
+
+func NewLeaseRenewer(
+	kubeClient kubernetes.Interface,
+	namespace string,
+	nodeName string,
+	info Info,
+	leaseDuration time.Duration,
+) *LeaseRenewer {
+	return &LeaseRenewer{
+		kubeClient:    kubeClient,
+		namespace:     namespace,
+		nodeName:      nodeName,
+		info:          info,
+		leaseDuration: leaseDuration,
+	}
+}
+
+// Start renews the Lease of the agent until the context is cancelled. The Lease is renewed
+// four times per lease duration, so that a single failed renewal does not make it expire.
+func (r *LeaseRenewer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("LeaseRenewer")
+	logger.Info(
+		"starting agent lease renewal",
+		"lease",
+		GetLeaseName(r.info.Name, r.nodeName),
+		"namespace",
+		r.namespace,
+		"duration",
+		r.leaseDuration,
+	)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.Renew(ctx); err != nil {
+			logger.Error(err, "unable to renew agent lease")
+		}
+	}, r.leaseDuration/4)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: each agent must renew
+// its own Lease regardless of leader election
+func (r *LeaseRenewer) NeedLeaderElection() bool {
+	return false
+}
+
+// Renew renews the Lease of the agent, creating it if it does not exist
+func (r *LeaseRenewer) Renew(ctx context.Context) error {
+	leases := r.kubeClient.CoordinationV1().Leases(r.namespace)
+	now := metav1.NewMicroTime(time.Now())
+
+	lease, err := leases.Get(ctx, GetLeaseName(r.info.Name, r.nodeName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      GetLeaseName(r.info.Name, r.nodeName),
+				Namespace: r.namespace,
+			},
+		}
+		r.setLease(lease, now)
+		lease.Spec.AcquireTime = &now
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	r.setLease(lease, now)
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (r *LeaseRenewer) setLease(lease *coordinationv1.Lease, renewTime metav1.MicroTime) {
+	if lease.Labels == nil {
+		lease.Labels = make(map[string]string)
+	}
+	lease.Labels[v1alpha1.LabelAgent] = r.info.Name
+	lease.Labels[v1alpha1.LabelAgentNode] = r.nodeName
+
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string)
+	}
+	lease.Annotations[v1alpha1.AnnotationAgentVersion] = r.info.Version
+	lease.Annotations[v1alpha1.AnnotationAgentCapabilities] = joinSorted(r.info.Capabilities)
+	lease.Annotations[v1alpha1.AnnotationAgentGpuModels] = joinSorted(r.info.GpuModels)
+
+	holder := fmt.Sprintf("%s/%s", r.info.Name, r.nodeName)
+	durationSeconds := int32(r.leaseDuration.Seconds())
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &renewTime
+}
+
+func joinSorted[T ~string](values []T) string {
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		res = append(res, string(v))
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// GetLeaseExpiration returns the time at which the Lease expires if it is not renewed.
+// The returned bool is false if the Lease has never been renewed.
+func GetLeaseExpiration(lease coordinationv1.Lease) (time.Time, bool) {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}, false
+	}
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(duration), true
+}
+
+// IsLeaseFresh returns true if the Lease has been renewed within its duration
+func IsLeaseFresh(lease coordinationv1.Lease, now time.Time) bool {
+	expiration, ok := GetLeaseExpiration(lease)
+	return ok && now.Before(expiration)
+}
+
+// GetCapabilities returns the kinds of partitioning supported by the agent holding the Lease
+func GetCapabilities(lease coordinationv1.Lease) []gpu.PartitioningKind {
+	res := make([]gpu.PartitioningKind, 0)
+	for _, c := range strings.Split(lease.Annotations[v1alpha1.AnnotationAgentCapabilities], ",") {
+		if c == "" {
+			continue
+		}
+		res = append(res, gpu.PartitioningKind(c))
+	}
+	return res
+}
+
+// GetRequiredCapabilities returns the capabilities that the agents running on a node
+// must provide for partitioning its GPUs with the kind of partitioning provided as argument
+func GetRequiredCapabilities(kind gpu.PartitioningKind) []gpu.PartitioningKind {
+	if kind == gpu.PartitioningKindHybrid {
+		return []gpu.PartitioningKind{gpu.PartitioningKindMig, gpu.PartitioningKindMps}
+	}
+	return []gpu.PartitioningKind{kind}
+}
+
+// GetMissingCapabilities returns the capabilities required for the kind of partitioning provided as argument
+// that are not provided by any agent holding a fresh Lease among the ones provided as argument.
+//
+// If no capability is missing, GetMissingCapabilities also returns the time after which the Leases must be
+// checked again, since the first of the Leases providing the required capabilities expires if not renewed.
+func GetMissingCapabilities(
+	kind gpu.PartitioningKind,
+	leases []coordinationv1.Lease,
+	now time.Time,
+) ([]gpu.PartitioningKind, time.Duration) {
+	// For each capability, find the fresh Lease providing it that expires last
+	expirations := make(map[gpu.PartitioningKind]time.Time)
+	for _, l := range leases {
+		if !IsLeaseFresh(l, now) {
+			continue
+		}
+		expiration, _ := GetLeaseExpiration(l)
+		for _, c := range GetCapabilities(l) {
+			if expiration.After(expirations[c]) {
+				expirations[c] = expiration
+			}
+		}
+	}
+
+	var missing = make([]gpu.PartitioningKind, 0)
+	var checkAfter time.Duration
+	for _, c := range GetRequiredCapabilities(kind) {
+		expiration, ok := expirations[c]
+		if !ok {
+			missing = append(missing, c)
+			continue
+		}
+		if remaining := expiration.Sub(now); checkAfter == 0 || remaining < checkAfter {
+			checkAfter = remaining
+		}
+	}
+	if len(missing) > 0 {
+		return missing, 0
+	}
+	return missing, checkAfter
+}
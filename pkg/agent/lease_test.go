@@ -0,0 +1,168 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newLease(capabilities string, renewedAgo time.Duration, durationSeconds int32) coordinationv1.Lease {
+	renewTime := metav1.NewMicroTime(time.Now().Add(-renewedAgo))
+	return coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{v1alpha1.AnnotationAgentCapabilities: capabilities},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			RenewTime:            &renewTime,
+			LeaseDurationSeconds: &durationSeconds,
+		},
+	}
+}
+
+func TestLeaseRenewer_Renew(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	info := Info{
+		Name:         MigAgentName,
+		Version:      "0.1.0",
+		Capabilities: []gpu.PartitioningKind{gpu.PartitioningKindMig},
+		GpuModels:    []gpu.Model{gpu.GPUModel_A100_PCIe_40GB, gpu.GPUModel_A30},
+	}
+	renewer := NewLeaseRenewer(kubeClient, "nos-system", "node-1", info, 40*time.Second)
+	ctx := context.Background()
+
+	// First renewal creates the Lease
+	require.NoError(t, renewer.Renew(ctx))
+	lease, err := kubeClient.CoordinationV1().Leases("nos-system").Get(ctx, "mig-agent-node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, MigAgentName, lease.Labels[v1alpha1.LabelAgent])
+	assert.Equal(t, "node-1", lease.Labels[v1alpha1.LabelAgentNode])
+	assert.Equal(t, "0.1.0", lease.Annotations[v1alpha1.AnnotationAgentVersion])
+	assert.Equal(t, "mig", lease.Annotations[v1alpha1.AnnotationAgentCapabilities])
+	assert.Equal(t, "A30,NVIDIA-A100-PCIE-40GB", lease.Annotations[v1alpha1.AnnotationAgentGpuModels])
+	assert.Equal(t, int32(40), *lease.Spec.LeaseDurationSeconds)
+	assert.NotNil(t, lease.Spec.AcquireTime)
+	assert.True(t, IsLeaseFresh(*lease, time.Now()))
+	firstRenewTime := lease.Spec.RenewTime.Time
+
+	// Following renewals update the renew time
+	time.Sleep(time.Millisecond)
+	require.NoError(t, renewer.Renew(ctx))
+	lease, err = kubeClient.CoordinationV1().Leases("nos-system").Get(ctx, "mig-agent-node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, lease.Spec.RenewTime.After(firstRenewTime))
+	assert.Equal(t, []gpu.PartitioningKind{gpu.PartitioningKindMig}, GetCapabilities(*lease))
+}
+
+func TestIsLeaseFresh(t *testing.T) {
+	testCases := []struct {
+		name     string
+		lease    coordinationv1.Lease
+		expected bool
+	}{
+		{
+			name:     "Lease never renewed",
+			lease:    coordinationv1.Lease{},
+			expected: false,
+		},
+		{
+			name:     "Lease renewed within its duration",
+			lease:    newLease("mig", 10*time.Second, 40),
+			expected: true,
+		},
+		{
+			name:     "Lease expired",
+			lease:    newLease("mig", 50*time.Second, 40),
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsLeaseFresh(tt.lease, time.Now()))
+		})
+	}
+}
+
+func TestGetMissingCapabilities(t *testing.T) {
+	testCases := []struct {
+		name               string
+		kind               gpu.PartitioningKind
+		leases             []coordinationv1.Lease
+		expectedMissing    []gpu.PartitioningKind
+		expectedCheckAfter time.Duration
+	}{
+		{
+			name:               "No leases",
+			kind:               gpu.PartitioningKindMig,
+			leases:             nil,
+			expectedMissing:    []gpu.PartitioningKind{gpu.PartitioningKindMig},
+			expectedCheckAfter: 0,
+		},
+		{
+			name:               "Fresh lease providing the required capability",
+			kind:               gpu.PartitioningKindMps,
+			leases:             []coordinationv1.Lease{newLease("mps", 10*time.Second, 40)},
+			expectedMissing:    []gpu.PartitioningKind{},
+			expectedCheckAfter: 30 * time.Second,
+		},
+		{
+			name:               "Expired lease providing the required capability",
+			kind:               gpu.PartitioningKindMps,
+			leases:             []coordinationv1.Lease{newLease("mps", 50*time.Second, 40)},
+			expectedMissing:    []gpu.PartitioningKind{gpu.PartitioningKindMps},
+			expectedCheckAfter: 0,
+		},
+		{
+			name: "Hybrid node with only the MIG agent running",
+			kind: gpu.PartitioningKindHybrid,
+			leases: []coordinationv1.Lease{
+				newLease("mig", 10*time.Second, 40),
+				newLease("mps", 50*time.Second, 40),
+			},
+			expectedMissing:    []gpu.PartitioningKind{gpu.PartitioningKindMps},
+			expectedCheckAfter: 0,
+		},
+		{
+			name: "Hybrid node with both agents running, check after the first lease expires",
+			kind: gpu.PartitioningKindHybrid,
+			leases: []coordinationv1.Lease{
+				newLease("mig", 10*time.Second, 40),
+				newLease("mps", 20*time.Second, 40),
+			},
+			expectedMissing:    []gpu.PartitioningKind{},
+			expectedCheckAfter: 20 * time.Second,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, checkAfter := GetMissingCapabilities(tt.kind, tt.leases, time.Now())
+			assert.Equal(t, tt.expectedMissing, missing)
+			assert.InDelta(t, tt.expectedCheckAfter.Seconds(), checkAfter.Seconds(), 1)
+		})
+	}
+}
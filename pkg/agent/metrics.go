@@ -0,0 +1,192 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+	"time"
+)
+
+const (
+	metricsNamespace = "nos"
+	metricsSubsystem = "agent"
+
+	labelNode         = "node"
+	labelGpuIndex     = "gpu_index"
+	labelDeviceId     = "device_id"
+	labelResource     = "resource"
+	labelProfile      = "profile"
+	labelPod          = "pod"
+	labelPodNamespace = "namespace"
+
+	defaultCollectTimeout = 5 * time.Second
+)
+
+var sliceLabels = []string{
+	labelNode,
+	labelGpuIndex,
+	labelDeviceId,
+	labelResource,
+	labelProfile,
+	labelPod,
+	labelPodNamespace,
+}
+
+var (
+	sliceSmUtilizationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "slice_sm_utilization_percent"),
+		"Percent of time over the last sample period during which one or more kernels were executing on the "+
+			"GPU or MIG device backing the slice. Not reported for MIG devices, which do not expose it.",
+		sliceLabels,
+		nil,
+	)
+	sliceMemoryUsedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "slice_memory_used_bytes"),
+		"Memory allocated on the GPU or MIG device backing the slice.",
+		sliceLabels,
+		nil,
+	)
+	sliceMemoryTotalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "slice_memory_total_bytes"),
+		"Total memory of the GPU or MIG device backing the slice.",
+		sliceLabels,
+		nil,
+	)
+)
+
+// DeviceListerFunc returns the GPU slices managed by an agent
+type DeviceListerFunc func(ctx context.Context) (gpu.DeviceList, gpu.Error)
+
+// ProfileExtractorFunc returns the profile of the slices exposed with the resource name provided as argument,
+// or an error if the resource is not a slice managed by the agent
+type ProfileExtractorFunc func(resourceName v1.ResourceName) (string, error)
+
+var _ prometheus.Collector = &SliceMetricsCollector{}
+
+// SliceMetricsCollector is a Prometheus collector exposing the SM utilization and the memory usage of the
+// GPU slices of the node managed by an agent, labeled with the profile of each slice and with the Pod using it.
+//
+// Slices are mapped to the Pods using them through the Kubelet PodResources API, and to the GPU or MIG device
+// backing them through NVML. The metrics of the slices of a shared GPU refer to the whole GPU, since NVML does
+// not report the utilization of the single slices.
+type SliceMetricsCollector struct {
+	nodeName       string
+	listDevices    DeviceListerFunc
+	getProfile     ProfileExtractorFunc
+	resourceClient resource.Client
+	nvmlClient     nvml.Client
+	timeout        time.Duration
+}
+
+func NewSliceMetricsCollector(
+	nodeName string,
+	listDevices DeviceListerFunc,
+	getProfile ProfileExtractorFunc,
+	resourceClient resource.Client,
+	nvmlClient nvml.Client,
+) *SliceMetricsCollector {
+	return &SliceMetricsCollector{
+		nodeName:       nodeName,
+		listDevices:    listDevices,
+		getProfile:     getProfile,
+		resourceClient: resourceClient,
+		nvmlClient:     nvmlClient,
+		timeout:        defaultCollectTimeout,
+	}
+}
+
+func (c *SliceMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sliceSmUtilizationDesc
+	ch <- sliceMemoryUsedDesc
+	ch <- sliceMemoryTotalDesc
+}
+
+func (c *SliceMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	logger := log.FromContext(ctx).WithName("SliceMetricsCollector")
+
+	devices, err := c.listDevices(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get GPU slices")
+		return
+	}
+	pods, podsErr := c.resourceClient.GetDevicePods(ctx)
+	if podsErr != nil {
+		logger.Error(podsErr, "unable to get Pods using GPU slices")
+		return
+	}
+	utilizations, err := c.nvmlClient.GetDevicesUtilization()
+	if err != nil {
+		logger.Error(err, "unable to get GPU utilization")
+		return
+	}
+	utilizationLookup := make(map[string]nvml.DeviceUtilization, len(utilizations))
+	for _, u := range utilizations {
+		utilizationLookup[u.DeviceId] = u
+	}
+
+	for _, d := range devices {
+		profile, err := c.getProfile(d.ResourceName)
+		if err != nil {
+			continue
+		}
+		utilization, ok := utilizationLookup[slicing.ExtractGpuId(d.DeviceId)]
+		if !ok {
+			logger.V(1).Info("utilization of GPU slice not found", "deviceId", d.DeviceId)
+			continue
+		}
+		pod := pods[d.DeviceId]
+		labels := []string{
+			c.nodeName,
+			strconv.Itoa(d.GpuIndex),
+			d.DeviceId,
+			d.ResourceName.String(),
+			profile,
+			pod.Name,
+			pod.Namespace,
+		}
+		if utilization.SmUtilizationSupported {
+			ch <- prometheus.MustNewConstMetric(
+				sliceSmUtilizationDesc,
+				prometheus.GaugeValue,
+				float64(utilization.SmUtilizationPercent),
+				labels...,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			sliceMemoryUsedDesc,
+			prometheus.GaugeValue,
+			float64(utilization.MemoryUsedBytes),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			sliceMemoryTotalDesc,
+			prometheus.GaugeValue,
+			float64(utilization.MemoryTotalBytes),
+			labels...,
+		)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	mockednvml "github.com/nebuly-ai/nos/pkg/test/mocks/nvml"
+	mockedresource "github.com/nebuly-ai/nos/pkg/test/mocks/resource"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"testing"
+)
+
+func newDevice(resourceName string, deviceId string, gpuIndex int, status resource.Status) gpu.Device {
+	return gpu.Device{
+		Device: resource.Device{
+			ResourceName: v1.ResourceName(resourceName),
+			DeviceId:     deviceId,
+			Status:       status,
+		},
+		GpuIndex: gpuIndex,
+	}
+}
+
+func TestSliceMetricsCollector__MigDevices(t *testing.T) {
+	devices := gpu.DeviceList{
+		newDevice("nvidia.com/mig-1g.10gb", "MIG-1", 0, resource.StatusUsed),
+		newDevice("nvidia.com/mig-3g.40gb", "MIG-2", 0, resource.StatusFree),
+	}
+	listDevices := func(_ context.Context) (gpu.DeviceList, gpu.Error) {
+		return devices, nil
+	}
+
+	resourceClient := mockedresource.NewClient(t)
+	resourceClient.On("GetDevicePods", mock.Anything).Return(map[string]types.NamespacedName{
+		"MIG-1": {Namespace: "ns-1", Name: "pod-1"},
+	}, nil)
+	nvmlClient := mockednvml.NewClient(t)
+	nvmlClient.On("GetDevicesUtilization").Return([]nvml.DeviceUtilization{
+		{DeviceId: "GPU-0", GpuIndex: 0, SmUtilizationSupported: true, SmUtilizationPercent: 30},
+		{DeviceId: "MIG-1", GpuIndex: 0, MemoryUsedBytes: 1024, MemoryTotalBytes: 4096},
+		{DeviceId: "MIG-2", GpuIndex: 0, MemoryUsedBytes: 0, MemoryTotalBytes: 8192},
+	}, nil)
+
+	collector := NewSliceMetricsCollector("node-1", listDevices, mig.ExtractProfileNameStr, resourceClient, nvmlClient)
+	expected := `
+# HELP nos_agent_slice_memory_total_bytes Total memory of the GPU or MIG device backing the slice.
+# TYPE nos_agent_slice_memory_total_bytes gauge
+nos_agent_slice_memory_total_bytes{device_id="MIG-1",gpu_index="0",namespace="ns-1",node="node-1",pod="pod-1",profile="1g.10gb",resource="nvidia.com/mig-1g.10gb"} 4096
+nos_agent_slice_memory_total_bytes{device_id="MIG-2",gpu_index="0",namespace="",node="node-1",pod="",profile="3g.40gb",resource="nvidia.com/mig-3g.40gb"} 8192
+# HELP nos_agent_slice_memory_used_bytes Memory allocated on the GPU or MIG device backing the slice.
+# TYPE nos_agent_slice_memory_used_bytes gauge
+nos_agent_slice_memory_used_bytes{device_id="MIG-1",gpu_index="0",namespace="ns-1",node="node-1",pod="pod-1",profile="1g.10gb",resource="nvidia.com/mig-1g.10gb"} 1024
+nos_agent_slice_memory_used_bytes{device_id="MIG-2",gpu_index="0",namespace="",node="node-1",pod="",profile="3g.40gb",resource="nvidia.com/mig-3g.40gb"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+func TestSliceMetricsCollector__SharedGpuSlices(t *testing.T) {
+	devices := gpu.DeviceList{
+		newDevice("nvidia.com/gpu-10gb", "GPU-0::0", 0, resource.StatusUsed),
+		newDevice("nvidia.com/gpu-10gb", "GPU-0::1", 0, resource.StatusUsed),
+		newDevice("nvidia.com/gpu", "GPU-1", 1, resource.StatusUsed),
+	}
+	listDevices := func(_ context.Context) (gpu.DeviceList, gpu.Error) {
+		return devices, nil
+	}
+
+	resourceClient := mockedresource.NewClient(t)
+	resourceClient.On("GetDevicePods", mock.Anything).Return(map[string]types.NamespacedName{
+		"GPU-0::0": {Namespace: "ns-1", Name: "pod-1"},
+		"GPU-0::1": {Namespace: "ns-2", Name: "pod-2"},
+		"GPU-1":    {Namespace: "ns-1", Name: "pod-3"},
+	}, nil)
+	nvmlClient := mockednvml.NewClient(t)
+	nvmlClient.On("GetDevicesUtilization").Return([]nvml.DeviceUtilization{
+		{DeviceId: "GPU-0", GpuIndex: 0, SmUtilizationSupported: true, SmUtilizationPercent: 50, MemoryUsedBytes: 10, MemoryTotalBytes: 40},
+		{DeviceId: "GPU-1", GpuIndex: 1, SmUtilizationSupported: true, SmUtilizationPercent: 10, MemoryUsedBytes: 5, MemoryTotalBytes: 40},
+	}, nil)
+
+	collector := NewSliceMetricsCollector("node-1", listDevices, slicing.ExtractProfileNameStr, resourceClient, nvmlClient)
+	expected := `
+# HELP nos_agent_slice_sm_utilization_percent Percent of time over the last sample period during which one or more kernels were executing on the GPU or MIG device backing the slice. Not reported for MIG devices, which do not expose it.
+# TYPE nos_agent_slice_sm_utilization_percent gauge
+nos_agent_slice_sm_utilization_percent{device_id="GPU-0::0",gpu_index="0",namespace="ns-1",node="node-1",pod="pod-1",profile="10gb",resource="nvidia.com/gpu-10gb"} 50
+nos_agent_slice_sm_utilization_percent{device_id="GPU-0::1",gpu_index="0",namespace="ns-2",node="node-1",pod="pod-2",profile="10gb",resource="nvidia.com/gpu-10gb"} 50
+`
+	assert.NoError(t, testutil.CollectAndCompare(
+		collector,
+		strings.NewReader(expected),
+		"nos_agent_slice_sm_utilization_percent",
+	))
+	assert.Equal(t, 6, testutil.CollectAndCount(collector))
+}
+
+func TestSliceMetricsCollector__Errors(t *testing.T) {
+	listDevices := func(_ context.Context) (gpu.DeviceList, gpu.Error) {
+		return gpu.DeviceList{newDevice("nvidia.com/mig-1g.10gb", "MIG-1", 0, resource.StatusUsed)}, nil
+	}
+	resourceClient := mockedresource.NewClient(t)
+	resourceClient.On("GetDevicePods", mock.Anything).Return(map[string]types.NamespacedName{}, nil)
+	nvmlClient := mockednvml.NewClient(t)
+	nvmlClient.On("GetDevicesUtilization").Return(nil, gpu.GenericErr.Errorf("error"))
+
+	collector := NewSliceMetricsCollector("node-1", listDevices, mig.ExtractProfileNameStr, resourceClient, nvmlClient)
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
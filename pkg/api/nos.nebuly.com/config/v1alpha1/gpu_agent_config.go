@@ -27,5 +27,6 @@ import (
 type GpuAgentConfig struct {
 	metav1.TypeMeta                        `json:",inline"`
 	cfg.ControllerManagerConfigurationSpec `json:",inline"`
-	ReportConfigIntervalSeconds            time.Duration `json:"reportConfigIntervalSeconds"`
+	ReportConfigIntervalSeconds            time.Duration    `json:"reportConfigIntervalSeconds"`
+	DevicePluginConfigMap                  NamespacedObject `json:"devicePluginConfigMap,omitempty"`
 }
@@ -18,11 +18,20 @@ package v1alpha1
 
 import (
 	"errors"
+	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
 	"time"
 )
 
+const (
+	// PlannerGreedy is the planner that greedily updates the geometry of one node at a time
+	PlannerGreedy = "greedy"
+	// PlannerOptimizing is the planner that searches the geometries of each GPU for finding
+	// the partitioning that schedules the highest priority pods by reconfiguring the fewest GPUs
+	PlannerOptimizing = "optimizing"
+)
+
 // +kubebuilder:object:root=true
 
 type GpuPartitionerConfig struct {
@@ -33,7 +42,18 @@ type GpuPartitionerConfig struct {
 	BatchWindowTimeoutSeconds              time.Duration    `json:"batchWindowTimeoutSeconds"`
 	BatchWindowIdleSeconds                 time.Duration    `json:"batchWindowIdleSeconds"`
 	DevicePluginConfigMap                  NamespacedObject `json:"devicePluginConfigMap,omitempty"`
-	DevicePluginDelaySeconds               time.Duration    `json:"devicePluginDelaySeconds"`
+	DryRun                                 bool             `json:"dryRun,omitempty"`
+	Planner                                string           `json:"planner,omitempty"`
+	PlannerBeamWidth                       int              `json:"plannerBeamWidth,omitempty"`
+	PlannerMaxSearchExpansions             int              `json:"plannerMaxSearchExpansions,omitempty"`
+	NodeCooldownSeconds                    time.Duration    `json:"nodeCooldownSeconds,omitempty"`
+	CompactionIdleSeconds                  time.Duration    `json:"compactionIdleSeconds,omitempty"`
+	PlanReportTimeoutSeconds               time.Duration    `json:"planReportTimeoutSeconds,omitempty"`
+	RequireAgentLeases                     bool             `json:"requireAgentLeases,omitempty"`
+
+	// Deprecated: the plans are confirmed by the gpu-agent once the device plugin loads their config,
+	// so the GPU partitioner does not wait any delay. The field is accepted but ignored.
+	DevicePluginDelaySeconds time.Duration `json:"devicePluginDelaySeconds,omitempty"`
 }
 
 func (c *GpuPartitionerConfig) Validate() error {
@@ -43,8 +63,23 @@ func (c *GpuPartitionerConfig) Validate() error {
 	if c.BatchWindowIdleSeconds.Seconds() <= 0 {
 		return errors.New("batchWindowIdleSeconds must be greater than 0")
 	}
-	if c.DevicePluginDelaySeconds.Seconds() <= 0 {
-		return errors.New("devicePluginDelaySeconds must be greater than 0")
+	if c.Planner != "" && c.Planner != PlannerGreedy && c.Planner != PlannerOptimizing {
+		return fmt.Errorf("planner must be either %q or %q", PlannerGreedy, PlannerOptimizing)
+	}
+	if c.PlannerBeamWidth < 0 {
+		return errors.New("plannerBeamWidth must be greater than or equal to 0")
+	}
+	if c.PlannerMaxSearchExpansions < 0 {
+		return errors.New("plannerMaxSearchExpansions must be greater than or equal to 0")
+	}
+	if c.NodeCooldownSeconds < 0 {
+		return errors.New("nodeCooldownSeconds must be greater than or equal to 0")
+	}
+	if c.CompactionIdleSeconds < 0 {
+		return errors.New("compactionIdleSeconds must be greater than or equal to 0")
+	}
+	if c.PlanReportTimeoutSeconds < 0 {
+		return errors.New("planReportTimeoutSeconds must be greater than or equal to 0")
 	}
 	return nil
 }
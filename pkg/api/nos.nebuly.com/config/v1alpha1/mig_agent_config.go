@@ -28,4 +28,22 @@ type MigAgentConfig struct {
 	metav1.TypeMeta                        `json:",inline"`
 	cfg.ControllerManagerConfigurationSpec `json:",inline"`
 	ReportConfigIntervalSeconds            time.Duration `json:"reportConfigIntervalSeconds"`
+	// DrainUsedDevices enables the eviction of the Pods using MIG devices that must be deleted
+	// for applying the desired MIG geometry
+	DrainUsedDevices bool `json:"drainUsedDevices,omitempty"`
+	// DrainTimeoutSeconds is the maximum time the agent waits for the evicted Pods to release the devices
+	DrainTimeoutSeconds time.Duration `json:"drainTimeoutSeconds,omitempty"`
+	// SimulateGpus makes the agent use in-memory simulated GPUs and a simulated Kubelet PodResources
+	// server instead of NVML and the Kubelet, so that it can run on nodes without NVIDIA GPUs
+	SimulateGpus bool `json:"simulateGpus,omitempty"`
+	// SimulatedGpus are the GPUs simulated by the agent when SimulateGpus is true
+	SimulatedGpus []SimulatedGpu `json:"simulatedGpus,omitempty"`
+}
+
+// SimulatedGpu is a GPU simulated by the MIG agent
+type SimulatedGpu struct {
+	// Model is the model of the GPU, in the same format of the label nvidia.com/gpu.product
+	Model string `json:"model"`
+	// MigEnabled specifies whether the GPU has MIG mode enabled
+	MigEnabled bool `json:"migEnabled,omitempty"`
 }
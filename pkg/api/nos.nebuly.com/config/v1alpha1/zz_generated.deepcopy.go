@@ -30,6 +30,7 @@ func (in *GpuAgentConfig) DeepCopyInto(out *GpuAgentConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
+	out.DevicePluginConfigMap = in.DevicePluginConfigMap
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuAgentConfig.
@@ -81,6 +82,11 @@ func (in *MigAgentConfig) DeepCopyInto(out *MigAgentConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
+	if in.SimulatedGpus != nil {
+		in, out := &in.SimulatedGpus, &out.SimulatedGpus
+		*out = make([]SimulatedGpu, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigAgentConfig.
@@ -140,3 +146,18 @@ func (in *OperatorConfig) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SimulatedGpu) DeepCopyInto(out *SimulatedGpu) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SimulatedGpu.
+func (in *SimulatedGpu) DeepCopy() *SimulatedGpu {
+	if in == nil {
+		return nil
+	}
+	out := new(SimulatedGpu)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -22,10 +22,36 @@ const (
 	AnnotationGpuSpecPrefix   = "nos.nebuly.com/spec-gpu"
 	AnnotationGpuStatusPrefix = "nos.nebuly.com/status-gpu"
 
+	AnnotationMigModeSpecPrefix   = "nos.nebuly.com/spec-mig-mode-gpu"
+	AnnotationMigModeStatusPrefix = "nos.nebuly.com/status-mig-mode-gpu"
+
 	// AnnotationPartitioningPlan indicates the partitioning plan that was applied to the node.
 	AnnotationPartitioningPlan = "nos.nebuly.com/spec-partitioning-plan"
+	// AnnotationInitialPartitioningPlan indicates the partitioning plan applied to the node by the GPU partitioner
+	// for initializing the partitioning of its GPUs. Nodes are not subject to the partitioning cooldown
+	// while the last plan applied to them is the initial one.
+	AnnotationInitialPartitioningPlan = "nos.nebuly.com/initial-partitioning-plan"
 	// AnnotationReportedPartitioningPlan indicates the last partitioning plan reported by the node.
 	AnnotationReportedPartitioningPlan = "nos.nebuly.com/status-partitioning-plan"
+	// AnnotationReportedMigPartitioningPlan indicates the last partitioning plan whose MIG geometry has been
+	// applied to a node with hybrid partitioning. The plan is reported by the node only once the device plugin
+	// also exposes the GPU slices defined by the plan.
+	AnnotationReportedMigPartitioningPlan = "nos.nebuly.com/status-mig-partitioning-plan"
+	// AnnotationPartitioningPlanStatus exposes the outcome of the application of the last partitioning plan
+	// handled by the node, including the operations that succeeded and the ones that failed.
+	AnnotationPartitioningPlanStatus = "nos.nebuly.com/status-partitioning-plan-result"
+	// AnnotationMigPlacements exposes the MIG profiles supported by the GPUs of the node, together with
+	// the placements at which their devices can be created, as discovered by the mig-agent through NVML.
+	AnnotationMigPlacements = "nos.nebuly.com/mig-placements"
+
+	// AnnotationAgentVersion indicates the version of the nos agent holding a Lease.
+	AnnotationAgentVersion = "nos.nebuly.com/agent-version"
+	// AnnotationAgentCapabilities indicates the comma-separated kinds of partitioning that the nos agent
+	// holding a Lease can apply to the GPUs of its node.
+	AnnotationAgentCapabilities = "nos.nebuly.com/agent-capabilities"
+	// AnnotationAgentGpuModels indicates the comma-separated models of the GPUs discovered by the
+	// nos agent holding a Lease.
+	AnnotationAgentGpuModels = "nos.nebuly.com/agent-gpu-models"
 )
 
 // AnnotationGpuStatusFormat is the format of the annotation used to expose the profiles the GPUs of a node
@@ -56,3 +82,33 @@ var AnnotationGpuSpecFormat = fmt.Sprintf(
 	"%s-%%d-%%s",
 	AnnotationGpuSpecPrefix,
 )
+
+// AnnotationMigModeSpecFormat is the format of the annotation used to specify the desired MIG mode
+// of a GPU of a node, either "enabled" or "disabled"
+//
+// Format:
+//
+//	"nos.nebuly.com/spec-mig-mode-gpu-<gpu-index>"
+//
+// Example:
+//
+//	"nos.nebuly.com/spec-mig-mode-gpu-0": "enabled"
+var AnnotationMigModeSpecFormat = fmt.Sprintf(
+	"%s-%%d",
+	AnnotationMigModeSpecPrefix,
+)
+
+// AnnotationMigModeStatusFormat is the format of the annotation used to expose the MIG mode of a GPU of a node,
+// together with the progress of the change towards the desired MIG mode
+//
+// Format:
+//
+//	"nos.nebuly.com/status-mig-mode-gpu-<gpu-index>"
+//
+// Example:
+//
+//	"nos.nebuly.com/status-mig-mode-gpu-0": "pending-reset"
+var AnnotationMigModeStatusFormat = fmt.Sprintf(
+	"%s-%%d",
+	AnnotationMigModeStatusPrefix,
+)
@@ -25,3 +25,65 @@ const (
 	// ResourceGPUMemory is the name of the custom resource used by nos for specifying GPU memory GigaBytes
 	ResourceGPUMemory v1.ResourceName = "nos.nebuly.com/gpu-memory"
 )
+
+// Node conditions
+const (
+	// NodeConditionUnknownMigGpuModel is the condition set on the nodes enabled for MIG or hybrid partitioning.
+	// Its status is True if the model of the node GPUs is not associated with any known MIG geometry,
+	// in which case the GPUs of the node cannot be partitioned with MIG.
+	NodeConditionUnknownMigGpuModel v1.NodeConditionType = "UnknownMigGpuModel"
+	// NodeConditionPartitioningPlanNotReported is the condition set on the nodes that did not report
+	// the last partitioning plan applied to them within the plan report timeout of the GPU Partitioner.
+	// Nodes with this condition True are excluded from GPU partitioning until they report the plan.
+	NodeConditionPartitioningPlanNotReported v1.NodeConditionType = "PartitioningPlanNotReported"
+	// NodeConditionPartitioningAgentNotReady is the condition set on the nodes enabled for GPU partitioning.
+	// Its status is True if the agents required for partitioning the GPUs of the node are not running,
+	// as their Leases are missing or expired, in which case the node is excluded from GPU partitioning.
+	NodeConditionPartitioningAgentNotReady v1.NodeConditionType = "PartitioningAgentNotReady"
+)
+
+// Reasons of node conditions and events
+const (
+	// ReasonUnknownMigGpuModel is the reason used when the GPU model of a node is not associated
+	// with any known MIG geometry
+	ReasonUnknownMigGpuModel = "UnknownMigGpuModel"
+	// ReasonKnownMigGpuModel is the reason used when the GPU model of a node is associated
+	// with known MIG geometries
+	ReasonKnownMigGpuModel = "KnownMigGpuModel"
+	// ReasonPlanReportTimeout is the reason used when a node does not report the last partitioning
+	// plan applied to it within the plan report timeout
+	ReasonPlanReportTimeout = "PlanReportTimeout"
+	// ReasonPlanReported is the reason used when a node has reported the last partitioning plan
+	// applied to it
+	ReasonPlanReported = "PlanReported"
+	// ReasonPartitioningAgentNotReady is the reason used when the agents required for partitioning
+	// the GPUs of a node are not running
+	ReasonPartitioningAgentNotReady = "PartitioningAgentNotReady"
+	// ReasonPartitioningAgentReady is the reason used when the agents required for partitioning
+	// the GPUs of a node are running
+	ReasonPartitioningAgentReady = "PartitioningAgentReady"
+	// ReasonMigDrainStarted is the reason used when the MIG agent starts evicting the Pods using
+	// MIG devices that must be deleted
+	ReasonMigDrainStarted = "MigDrainStarted"
+	// ReasonMigPodEvicted is the reason used when the MIG agent evicts a Pod using a MIG device
+	// that must be deleted
+	ReasonMigPodEvicted = "MigPodEvicted"
+	// ReasonMigEvictionBlocked is the reason used when the MIG agent cannot evict a Pod, either because
+	// of a PodDisruptionBudget or because of the Pod priority
+	ReasonMigEvictionBlocked = "MigEvictionBlocked"
+	// ReasonMigDrainTimeout is the reason used when the evicted Pods do not release their MIG devices
+	// within the drain timeout
+	ReasonMigDrainTimeout = "MigDrainTimeout"
+	// ReasonMigDevicesReleased is the reason used when all the MIG devices to delete have been
+	// released by the evicted Pods
+	ReasonMigDevicesReleased = "MigDevicesReleased"
+	// ReasonMigDeviceDeleted is the reason used when the MIG agent deletes a drained MIG device
+	ReasonMigDeviceDeleted = "MigDeviceDeleted"
+	// ReasonMigModeChanged is the reason used when the MIG agent changes the MIG mode of a GPU
+	ReasonMigModeChanged = "MigModeChanged"
+	// ReasonMigModePendingReset is the reason used when the MIG mode of a GPU has been changed,
+	// but the change takes effect only after the GPU is reset
+	ReasonMigModePendingReset = "MigModePendingReset"
+	// ReasonMigModeChangeFailed is the reason used when the MIG agent fails to change the MIG mode of a GPU
+	ReasonMigModeChangeFailed = "MigModeChangeFailed"
+)
@@ -21,4 +21,11 @@ const (
 	LabelCapacityInfo = "nos.nebuly.com/capacity"
 	// LabelGpuPartitioning specifies the PartitioningKind that should be performed on the GPUs of a node
 	LabelGpuPartitioning = "nos.nebuly.com/gpu-partitioning"
+	// LabelAgent specifies the name of the nos agent holding a Lease
+	LabelAgent = "nos.nebuly.com/agent"
+	// LabelAgentNode specifies the name of the node on which the nos agent holding a Lease is running
+	LabelAgentNode = "nos.nebuly.com/agent-node"
+	// LabelDevicePluginConfigMap specifies the name of the ConfigMap containing the NVIDIA device plugin
+	// configs of a node, overriding the default one. It allows to use a different ConfigMap for each node pool.
+	LabelDevicePluginConfigMap = "nos.nebuly.com/device-plugin-config-map"
 )
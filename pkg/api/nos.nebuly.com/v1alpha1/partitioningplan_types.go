@@ -0,0 +1,236 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PartitioningPlanPhase is the phase of the rollout of a PartitioningPlan
+type PartitioningPlanPhase string
+
+const (
+	// PartitioningPlanPhasePending means that the plan has not been applied to all its nodes yet
+	PartitioningPlanPhasePending PartitioningPlanPhase = "Pending"
+	// PartitioningPlanPhaseApplied means that the plan has been applied to all its nodes
+	PartitioningPlanPhaseApplied PartitioningPlanPhase = "Applied"
+	// PartitioningPlanPhaseFailed means that the plan could not be applied to at least one of its nodes
+	PartitioningPlanPhaseFailed PartitioningPlanPhase = "Failed"
+)
+
+// NodePartitioningPhase is the phase of the rollout of a PartitioningPlan on a single node
+type NodePartitioningPhase string
+
+const (
+	// NodePartitioningPhasePending means that the partitioning has been requested to the node,
+	// but the node has not reported it yet
+	NodePartitioningPhasePending NodePartitioningPhase = "Pending"
+	// NodePartitioningPhaseApplied means that the node has reported the partitioning of the plan
+	NodePartitioningPhaseApplied NodePartitioningPhase = "Applied"
+	// NodePartitioningPhaseFailed means that the partitioning could not be applied to the node
+	NodePartitioningPhaseFailed NodePartitioningPhase = "Failed"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName={pp,pps}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.partitioningKind`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Applied",type=integer,JSONPath=`.status.appliedNodes`
+// +kubebuilder:printcolumn:name="Pending",type=integer,JSONPath=`.status.pendingNodes`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failedNodes`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PartitioningPlan records a GPU partitioning plan computed by the GPU partitioner,
+// together with the progress of its rollout on the target nodes.
+type PartitioningPlan struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// PartitioningPlanSpec defines the desired GPU partitioning.
+	Spec PartitioningPlanSpec `json:"spec,omitempty"`
+
+	// PartitioningPlanStatus defines the observed rollout of the plan.
+	Status PartitioningPlanStatus `json:"status,omitempty"`
+}
+
+// PartitioningPlanSpec defines the desired GPU partitioning of the nodes targeted by the plan.
+type PartitioningPlanSpec struct {
+	// PartitioningKind is the kind of GPU partitioning of the plan.
+	PartitioningKind string `json:"partitioningKind"`
+
+	// Nodes is the desired GPU partitioning of each node targeted by the plan.
+	// +optional
+	Nodes []NodePartitioningSpec `json:"nodes,omitempty"`
+
+	// Pods is the list of pending pods that triggered the plan.
+	// +optional
+	Pods []PlanPodReference `json:"pods,omitempty"`
+}
+
+// NodePartitioningSpec defines the desired GPU partitioning of a node.
+type NodePartitioningSpec struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+
+	// GPUs is the desired partitioning of each GPU of the node.
+	// +optional
+	GPUs []GpuPartitioningSpec `json:"gpus,omitempty"`
+}
+
+// GpuPartitioningSpec defines the desired partitioning of a single GPU.
+type GpuPartitioningSpec struct {
+	// Index is the index of the GPU.
+	Index int `json:"index"`
+
+	// Resources is the quantity of each GPU slice resource that the GPU should provide.
+	// +optional
+	Resources map[v1.ResourceName]int `json:"resources,omitempty"`
+}
+
+// PlanPodReference references a pending pod that triggered a PartitioningPlan.
+type PlanPodReference struct {
+	// Namespace is the namespace of the pod.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the pod.
+	Name string `json:"name"`
+
+	// Fits is true if the pod can be scheduled with the partitioning of the plan.
+	// +optional
+	Fits bool `json:"fits,omitempty"`
+
+	// Node is the node on which the pod is expected to be scheduled, if any.
+	// +optional
+	Node string `json:"node,omitempty"`
+}
+
+// PartitioningPlanStatus defines the observed rollout of a PartitioningPlan.
+type PartitioningPlanStatus struct {
+	// Phase is the overall phase of the rollout of the plan.
+	// +optional
+	Phase PartitioningPlanPhase `json:"phase,omitempty"`
+
+	// Nodes is the status of the rollout of the plan on each node.
+	// +optional
+	Nodes []NodePartitioningStatus `json:"nodes,omitempty"`
+
+	// PendingNodes is the number of nodes that have not reported the plan yet.
+	// +optional
+	PendingNodes int `json:"pendingNodes"`
+
+	// AppliedNodes is the number of nodes that have reported the plan.
+	// +optional
+	AppliedNodes int `json:"appliedNodes"`
+
+	// FailedNodes is the number of nodes to which the plan could not be applied.
+	// +optional
+	FailedNodes int `json:"failedNodes"`
+}
+
+// NodePartitioningStatus defines the observed rollout of a PartitioningPlan on a node.
+type NodePartitioningStatus struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+
+	// Phase is the phase of the rollout of the plan on the node.
+	Phase NodePartitioningPhase `json:"phase"`
+
+	// Message is a human-readable message providing details about the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time the phase changed.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// SetNodePhase sets the phase of the node with the provided name, adding the node to the status if not present,
+// and updates the overall phase of the plan accordingly.
+func (s *PartitioningPlanStatus) SetNodePhase(nodeName string, phase NodePartitioningPhase, message string) {
+	found := false
+	for i := range s.Nodes {
+		if s.Nodes[i].Name != nodeName {
+			continue
+		}
+		found = true
+		if s.Nodes[i].Phase != phase {
+			s.Nodes[i].LastTransitionTime = metav1.Now()
+		}
+		s.Nodes[i].Phase = phase
+		s.Nodes[i].Message = message
+	}
+	if !found {
+		s.Nodes = append(s.Nodes, NodePartitioningStatus{
+			Name:               nodeName,
+			Phase:              phase,
+			Message:            message,
+			LastTransitionTime: metav1.Now(),
+		})
+	}
+	s.updatePhase()
+}
+
+// GetNodePhase returns the phase of the node with the provided name, if present in the status
+func (s *PartitioningPlanStatus) GetNodePhase(nodeName string) (NodePartitioningPhase, bool) {
+	for _, n := range s.Nodes {
+		if n.Name == nodeName {
+			return n.Phase, true
+		}
+	}
+	return "", false
+}
+
+func (s *PartitioningPlanStatus) updatePhase() {
+	s.PendingNodes, s.AppliedNodes, s.FailedNodes = 0, 0, 0
+	for _, n := range s.Nodes {
+		switch n.Phase {
+		case NodePartitioningPhasePending:
+			s.PendingNodes++
+		case NodePartitioningPhaseApplied:
+			s.AppliedNodes++
+		case NodePartitioningPhaseFailed:
+			s.FailedNodes++
+		}
+	}
+	switch {
+	case s.FailedNodes > 0:
+		s.Phase = PartitioningPlanPhaseFailed
+	case s.PendingNodes > 0:
+		s.Phase = PartitioningPlanPhasePending
+	default:
+		s.Phase = PartitioningPlanPhaseApplied
+	}
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PartitioningPlanList is a list of PartitioningPlan items.
+type PartitioningPlanList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Standard list metadata.
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of PartitioningPlan objects.
+	Items []PartitioningPlan `json:"items"`
+}
@@ -19,4 +19,5 @@ package v1alpha1
 func init() {
 	SchemeBuilder.Register(&ElasticQuota{}, &ElasticQuotaList{})
 	SchemeBuilder.Register(&CompositeElasticQuota{}, &CompositeElasticQuotaList{})
+	SchemeBuilder.Register(&PartitioningPlan{}, &PartitioningPlanList{})
 }
@@ -30,12 +30,19 @@ const (
 
 // Controller names
 const (
-	ElasticQuotaControllerName          = "eq-controller"
-	CompositeElasticQuotaControllerName = "ceq-controller"
-	ClusterStateNodeControllerName      = "clusterstate-node-controller"
-	ClusterStatePodControllerName       = "clusterstate-pod-controller"
-	MigPartitionerControllerName        = "mig-partitioner-controller"
-	MpsPartitionerControllerName        = "mps-partitioner-controller"
+	ElasticQuotaControllerName           = "eq-controller"
+	CompositeElasticQuotaControllerName  = "ceq-controller"
+	ClusterStateNodeControllerName       = "clusterstate-node-controller"
+	ClusterStatePodControllerName        = "clusterstate-pod-controller"
+	MigPartitionerControllerName         = "mig-partitioner-controller"
+	MpsPartitionerControllerName         = "mps-partitioner-controller"
+	HybridPartitionerControllerName      = "hybrid-partitioner-controller"
+	TimeSlicingPartitionerControllerName = "time-slicing-partitioner-controller"
+	PartitioningPlanControllerName       = "partitioning-plan-controller"
+	MigCompactionControllerName          = "mig-compaction-controller"
+	MpsCompactionControllerName          = "mps-compaction-controller"
+	HybridCompactionControllerName       = "hybrid-compaction-controller"
+	TimeSlicingCompactionControllerName  = "time-slicing-compaction-controller"
 )
 
 // Error messages
@@ -47,8 +54,9 @@ const (
 // Common RegEx
 const (
 	// RegexNvidiaMigResource is a regex matching the name of the MIG devices exposed by the NVIDIA device plugin
-	RegexNvidiaMigResource     = `nvidia\.com\/mig-\d+g\.\d+gb`
-	RegexNvidiaMigProfile      = `\d+g\.\d+gb`
+	// The optional "<n>c." prefix matches the profiles of compute instances sharing a GPU instance (e.g. 1c.3g.20gb)
+	RegexNvidiaMigResource     = `nvidia\.com\/mig-(\d+c\.)?\d+g\.\d+gb`
+	RegexNvidiaMigProfile      = `(\d+c\.)?\d+g\.\d+gb`
 	RegexNvidiaMigFormatMemory = `\d+gb`
 )
 
@@ -69,6 +77,8 @@ const (
 const (
 	// EnvVarNodeName is the name of the env variable containing the name of the node
 	EnvVarNodeName = "NODE_NAME"
+	// EnvVarPodNamespace is the name of the env variable containing the namespace of the Pod
+	EnvVarPodNamespace = "POD_NAMESPACE"
 )
 
 // Labels
@@ -104,9 +114,23 @@ const (
 	DefaultDevicePluginCMName = "device-plugin-configs"
 	// DefaultDevicePluginCMNamespace is the default namespace of the ConfigMap used by the NVIDIA device plugin
 	DefaultDevicePluginCMNamespace = "gpu-operator"
+
+	// DefaultPlanFailureBackoff is the time during which the GPUs on which the last partitioning plan
+	// failed are excluded from partitioning
+	DefaultPlanFailureBackoff = 5 * time.Minute
+
+	// DefaultAgentLeaseDuration is the duration of the Leases renewed by the nos agents. An agent
+	// whose Lease has not been renewed within this duration is considered not running.
+	DefaultAgentLeaseDuration = 40 * time.Second
+
+	// DefaultDeviceWatcherDebounce is the time the agents wait after a change of the devices of
+	// the node before reporting them, so that a burst of changes results in a single report.
+	DefaultDeviceWatcherDebounce = 2 * time.Second
 )
 
 const (
 	PodPhaseKey    = "status.phase"
 	PodNodeNameKey = "spec.nodeName"
+
+	PartitioningPlanNodeNameKey = "spec.nodes.name"
 )
@@ -0,0 +1,485 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+)
+
+// Node is a node with hybrid GPU partitioning, namely a node in which each GPU
+// can be either MIG-partitioned or MPS-sliced.
+type Node struct {
+	Name string
+	// MigGPUs are the GPUs of the node partitioned with MIG
+	MigGPUs []mig.GPU
+	// SlicingGPUs are the GPUs of the node partitioned with MPS slicing
+	SlicingGPUs []slicing.GPU
+	// FreeGPUs are the indexes of the GPUs that are neither MIG-partitioned nor sliced yet,
+	// and that can therefore be partitioned with the kind of partitioning allowed by their MIG mode
+	FreeGPUs []int
+
+	model    gpu.Model
+	memoryGB int
+	nodeInfo framework.NodeInfo
+	// migGeometries are the MIG geometries allowed by the GPUs of the node, or nil if they are unknown
+	migGeometries []gpu.Geometry
+	// migModes are the statuses of the MIG mode of the GPUs reported by the mig-agent, indexed by GPU index
+	migModes map[int]mig.ModeStatus
+}
+
+// NewNode creates a new hybrid Node starting from the node provided as argument.
+//
+// Each GPU of the node is considered either as a MIG GPU or as a slicing GPU according to the profiles
+// of its nos.nebuly.com status annotations. GPUs without any status annotation are considered free, and
+// they can be partitioned with MIG if their MIG mode is enabled, or sliced if their MIG mode is disabled,
+// according to the MIG mode reported by the mig-agent. Free GPUs whose MIG mode has not been reported
+// or is changing are not partitioned at all.
+//
+// The following labels exposed by the NVIDIA gpu-feature-discovery tool are required:
+// - GPU product ("nvidia.com/gpu.product")
+// - GPU count ("nvidia.com/gpu.count")
+// - GPU memory ("nvidia.com/gpu.memory")
+//
+// The MIG geometries allowed by the GPUs are determined as described in mig.GetNodeAllowedGeometries.
+func NewNode(n framework.NodeInfo) (Node, error) {
+	if n.Node() == nil {
+		return Node{}, fmt.Errorf("node is nil")
+	}
+	node := *n.Node()
+	gpuModel, err := gpu.GetModel(node)
+	if err != nil {
+		return Node{}, err
+	}
+	gpuCount, err := gpu.GetCount(node)
+	if err != nil {
+		return Node{}, err
+	}
+	gpuMemoryGB, err := gpu.GetMemoryGB(node)
+	if err != nil {
+		return Node{}, err
+	}
+
+	res := Node{
+		Name:        node.Name,
+		MigGPUs:     make([]mig.GPU, 0),
+		SlicingGPUs: make([]slicing.GPU, 0),
+		FreeGPUs:    make([]int, 0),
+		model:       gpuModel,
+		memoryGB:    gpuMemoryGB,
+		nodeInfo:    n,
+		migModes:    mig.GetModeStatuses(node),
+	}
+	if migGeometries, ok := mig.GetNodeAllowedGeometries(node, gpuModel); ok {
+		res.migGeometries = migGeometries
+	}
+
+	// Init GPUs from annotations
+	statusAnnotations, _ := gpu.ParseNodeAnnotations(node)
+	annotationsByGpu := statusAnnotations.GroupByGpuIndex()
+	for gpuIndex := 0; gpuIndex < gpuCount; gpuIndex++ {
+		migAnnotations := annotationsByGpu[gpuIndex].Filter(mig.IsMigStatusAnnotation)
+		slicingAnnotations := annotationsByGpu[gpuIndex].Filter(slicing.IsSlicingStatusAnnotation)
+		if len(migAnnotations) > 0 && len(slicingAnnotations) > 0 {
+			return Node{}, fmt.Errorf("GPU %d of node %s has both MIG and slicing profiles", gpuIndex, node.Name)
+		}
+		if len(migAnnotations) > 0 {
+			g, err := res.newMigGPU(gpuIndex, migAnnotations)
+			if err != nil {
+				return Node{}, err
+			}
+			res.MigGPUs = append(res.MigGPUs, g)
+			continue
+		}
+		if len(slicingAnnotations) > 0 {
+			g, err := newSlicingGPU(gpuModel, gpuIndex, gpuMemoryGB, slicingAnnotations)
+			if err != nil {
+				return Node{}, err
+			}
+			res.SlicingGPUs = append(res.SlicingGPUs, g)
+			continue
+		}
+		res.FreeGPUs = append(res.FreeGPUs, gpuIndex)
+	}
+
+	return res, nil
+}
+
+func (n *Node) newMigGPU(index int, annotations gpu.StatusAnnotationList) (mig.GPU, error) {
+	used := make(map[mig.ProfileName]int)
+	free := make(map[mig.ProfileName]int)
+	for _, a := range annotations {
+		profileName := mig.ProfileName(a.ProfileName)
+		if a.IsUsed() {
+			used[profileName] = a.Quantity
+		}
+		if a.IsFree() {
+			free[profileName] = a.Quantity
+		}
+	}
+	return n.buildMigGPU(index, used, free)
+}
+
+// buildMigGPU returns a MIG GPU with the provided devices that allows the MIG geometries of the node,
+// excluding the ones that cannot be applied to the GPU because of a failure of the last partitioning plan.
+func (n *Node) buildMigGPU(index int, used, free map[mig.ProfileName]int) (mig.GPU, error) {
+	if n.migGeometries == nil {
+		return mig.GPU{}, fmt.Errorf("model %q is not associated with any known GPU", n.model)
+	}
+	geometries := n.migGeometries
+	if n.nodeInfo.Node() != nil {
+		geometries = mig.GetGpuAllowedGeometries(*n.nodeInfo.Node(), index, geometries)
+	}
+	return mig.NewGPUWithAllowedGeometries(n.model, index, geometries, used, free), nil
+}
+
+func newSlicingGPU(model gpu.Model, index int, memoryGB int, annotations gpu.StatusAnnotationList) (slicing.GPU, error) {
+	used := make(map[slicing.ProfileName]int)
+	free := make(map[slicing.ProfileName]int)
+	for _, a := range annotations {
+		profileName := slicing.ProfileName(a.ProfileName)
+		if a.IsUsed() {
+			used[profileName] = a.Quantity
+		}
+		if a.IsFree() {
+			free[profileName] = a.Quantity
+		}
+	}
+	return slicing.NewGPU(model, index, memoryGB, used, free)
+}
+
+func (n *Node) GetName() string {
+	return n.Name
+}
+
+func (n *Node) NodeInfo() framework.NodeInfo {
+	return n.nodeInfo
+}
+
+// Geometry returns the overall geometry of the node, which corresponds to the sum of the geometries
+// of all the MIG and slicing GPUs present in the Node.
+func (n *Node) Geometry() map[gpu.Slice]int {
+	res := make(map[gpu.Slice]int)
+	for _, g := range n.MigGPUs {
+		for p, q := range g.GetGeometry() {
+			res[p] += q
+		}
+	}
+	for _, g := range n.SlicingGPUs {
+		for p, q := range g.GetGeometry() {
+			res[p] += q
+		}
+	}
+	return res
+}
+
+// HasFreeCapacity returns true if the node has at least one free GPU, or if any of its
+// MIG or slicing GPUs has free capacity.
+func (n *Node) HasFreeCapacity() bool {
+	if len(n.FreeGPUs) > 0 {
+		return true
+	}
+	for _, g := range n.MigGPUs {
+		if g.HasFreeMigDevices() {
+			return true
+		}
+		if !g.AllowsGeometry(g.GetGeometry()) {
+			return true
+		}
+	}
+	for _, g := range n.SlicingGPUs {
+		if g.HasFreeCapacity() {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateGeometryFor tries to update the geometry of the GPUs of the node in order to create the slices
+// provided as argument.
+//
+// The geometry of MIG and slicing GPUs is updated as in the respective kinds of partitioning. Each free GPU
+// is then MIG-partitioned if its MIG mode is enabled, or sliced if its MIG mode is disabled. Free GPUs
+// whose MIG mode is unknown or changing are left untouched.
+//
+// The method returns true if it updates the geometry of any GPU, false otherwise.
+func (n *Node) UpdateGeometryFor(slices map[gpu.Slice]int) (bool, error) {
+	if n.getGpuCount() == 0 {
+		return false, nil
+	}
+	if len(slices) == 0 {
+		return false, nil
+	}
+
+	// Copy slices
+	var requiredSlices = make(map[gpu.Slice]int, len(slices))
+	for k, v := range slices {
+		requiredSlices[k] = v
+	}
+
+	var anyGpuUpdated bool
+
+	// Update MIG GPUs
+	for i := range n.MigGPUs {
+		g := &n.MigGPUs[i]
+		updated := g.UpdateGeometryFor(requiredSlices)
+		anyGpuUpdated = anyGpuUpdated || updated
+		for profile, quantity := range g.GetFreeMigDevices() {
+			subtract(requiredSlices, profile, quantity)
+		}
+	}
+
+	// Update slicing GPUs
+	for i := range n.SlicingGPUs {
+		g := &n.SlicingGPUs[i]
+		updated := g.UpdateGeometryFor(filterSlicingSlices(requiredSlices))
+		anyGpuUpdated = anyGpuUpdated || updated
+		for profile, quantity := range g.FreeProfiles {
+			subtract(requiredSlices, profile, quantity)
+		}
+	}
+
+	// Partition free GPUs
+	stillFree := make([]int, 0, len(n.FreeGPUs))
+	for _, gpuIndex := range n.FreeGPUs {
+		if len(requiredSlices) == 0 {
+			stillFree = append(stillFree, gpuIndex)
+			continue
+		}
+		var provided int
+		switch n.migModes[gpuIndex] {
+		case mig.ModeStatusEnabled:
+			var migGpu mig.GPU
+			if migGpu, provided = n.tryMigPartitioning(gpuIndex, requiredSlices); provided > 0 {
+				n.MigGPUs = append(n.MigGPUs, migGpu)
+				for profile, quantity := range migGpu.GetFreeMigDevices() {
+					subtract(requiredSlices, profile, quantity)
+				}
+			}
+		case mig.ModeStatusDisabled:
+			var slicingGpu slicing.GPU
+			if slicingGpu, provided = n.trySlicingPartitioning(gpuIndex, requiredSlices); provided > 0 {
+				n.SlicingGPUs = append(n.SlicingGPUs, slicingGpu)
+				for profile, quantity := range slicingGpu.FreeProfiles {
+					subtract(requiredSlices, profile, quantity)
+				}
+			}
+		}
+		if provided == 0 {
+			stillFree = append(stillFree, gpuIndex)
+			continue
+		}
+		anyGpuUpdated = true
+	}
+	n.FreeGPUs = stillFree
+	n.sortGPUs()
+
+	// Update node info
+	n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+
+	return anyGpuUpdated, nil
+}
+
+// Compact compacts the free slices of the GPUs of the node. MIG GPUs without used MIG devices get their
+// initial MIG geometry, slicing GPUs without used slices become free GPUs, and the free slices of the
+// other slicing GPUs are merged (see mig.GPU.Compact and slicing.GPU.Compact).
+//
+// The method returns true if it changes the geometry of any GPU, false otherwise.
+func (n *Node) Compact() (bool, error) {
+	var anyGpuCompacted bool
+	for i := range n.MigGPUs {
+		compacted := n.MigGPUs[i].Compact()
+		anyGpuCompacted = anyGpuCompacted || compacted
+	}
+	slicingGPUs := make([]slicing.GPU, 0, len(n.SlicingGPUs))
+	for _, g := range n.SlicingGPUs {
+		compacted := g.Compact()
+		anyGpuCompacted = anyGpuCompacted || compacted
+		if len(g.GetGeometry()) == 0 {
+			n.FreeGPUs = append(n.FreeGPUs, g.Index)
+			continue
+		}
+		slicingGPUs = append(slicingGPUs, g)
+	}
+	n.SlicingGPUs = slicingGPUs
+	n.sortGPUs()
+	if anyGpuCompacted {
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+	}
+	return anyGpuCompacted, nil
+}
+
+// tryMigPartitioning returns the MIG GPU obtained by partitioning the free GPU with the provided index
+// for creating the required slices, together with the number of required slices it provides.
+func (n *Node) tryMigPartitioning(gpuIndex int, requiredSlices map[gpu.Slice]int) (mig.GPU, int) {
+	g, err := n.buildMigGPU(gpuIndex, make(map[mig.ProfileName]int), make(map[mig.ProfileName]int))
+	if err != nil {
+		return mig.GPU{}, 0
+	}
+	if updated := g.UpdateGeometryFor(requiredSlices); !updated {
+		return mig.GPU{}, 0
+	}
+	return g, countProvided(g.GetGeometry(), requiredSlices)
+}
+
+// trySlicingPartitioning returns the slicing GPU obtained by partitioning the free GPU with the provided index
+// for creating the required slices, together with the number of required slices it provides.
+func (n *Node) trySlicingPartitioning(gpuIndex int, requiredSlices map[gpu.Slice]int) (slicing.GPU, int) {
+	g := slicing.NewFullGPU(n.model, gpuIndex, n.memoryGB)
+	if updated := g.UpdateGeometryFor(filterSlicingSlices(requiredSlices)); !updated {
+		return slicing.GPU{}, 0
+	}
+	return g, countProvided(g.GetGeometry(), requiredSlices)
+}
+
+func (n *Node) computeScalarResources() map[v1.ResourceName]int64 {
+	res := make(map[v1.ResourceName]int64)
+
+	// Set all non-MIG and non-slicing scalar resources
+	for r, v := range n.nodeInfo.Allocatable.ScalarResources {
+		if !mig.IsNvidiaMigDevice(r) && !slicing.IsGpuSlice(r) {
+			res[r] = v
+		}
+	}
+	// Set MIG and slicing scalar resources
+	for s, v := range n.Geometry() {
+		switch profile := s.(type) {
+		case mig.ProfileName:
+			res[profile.AsResourceName()] = int64(v)
+		case slicing.ProfileName:
+			res[profile.AsResourceName()] = int64(v)
+		}
+	}
+
+	return res
+}
+
+// AddPod adds a Pod to the node by updating the free and used slices of the Node GPUs according to the
+// MIG and slicing profiles requested by the Pod.
+//
+// AddPod returns an error if the node does not have any GPU providing enough free slices for the Pod.
+func (n *Node) AddPod(pod v1.Pod) error {
+	if len(mig.GetRequestedProfiles(pod)) > 0 {
+		if !n.addPodToMigGPUs(pod) {
+			return fmt.Errorf("not enough free MIG devices")
+		}
+	}
+	if len(slicing.GetRequestedProfiles(pod)) > 0 {
+		if !n.addPodToSlicingGPUs(pod) {
+			return fmt.Errorf("not enough free GPU slices")
+		}
+	}
+	nodeInfo := n.NodeInfo()
+	nodeInfo.AddPod(&pod)
+	return nil
+}
+
+func (n *Node) addPodToMigGPUs(pod v1.Pod) bool {
+	for i := range n.MigGPUs {
+		if err := n.MigGPUs[i].AddPod(pod); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) addPodToSlicingGPUs(pod v1.Pod) bool {
+	for i := range n.SlicingGPUs {
+		if err := n.SlicingGPUs[i].AddPod(pod); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) Clone() interface{} {
+	cloned := Node{
+		Name:        n.Name,
+		MigGPUs:     make([]mig.GPU, len(n.MigGPUs)),
+		SlicingGPUs: make([]slicing.GPU, len(n.SlicingGPUs)),
+		FreeGPUs:    make([]int, len(n.FreeGPUs)),
+		model:       n.model,
+		memoryGB:    n.memoryGB,
+		nodeInfo:    *n.nodeInfo.Clone(),
+		// allowed geometries and MIG modes are never modified, so they can be shared
+		migGeometries: n.migGeometries,
+		migModes:      n.migModes,
+	}
+	for i := range n.MigGPUs {
+		cloned.MigGPUs[i] = n.MigGPUs[i].Clone()
+	}
+	for i := range n.SlicingGPUs {
+		cloned.SlicingGPUs[i] = n.SlicingGPUs[i].Clone()
+	}
+	copy(cloned.FreeGPUs, n.FreeGPUs)
+	return &cloned
+}
+
+func (n *Node) getGpuCount() int {
+	return len(n.MigGPUs) + len(n.SlicingGPUs) + len(n.FreeGPUs)
+}
+
+func (n *Node) sortGPUs() {
+	sort.SliceStable(n.MigGPUs, func(i, j int) bool {
+		return n.MigGPUs[i].GetIndex() < n.MigGPUs[j].GetIndex()
+	})
+	sort.SliceStable(n.SlicingGPUs, func(i, j int) bool {
+		return n.SlicingGPUs[i].Index < n.SlicingGPUs[j].Index
+	})
+	sort.Ints(n.FreeGPUs)
+}
+
+// subtract removes from the required slices the provided quantity of the slice passed as argument
+func subtract(required map[gpu.Slice]int, slice gpu.Slice, quantity int) {
+	if _, ok := required[slice]; !ok {
+		return
+	}
+	required[slice] -= quantity
+	if required[slice] <= 0 {
+		delete(required, slice)
+	}
+}
+
+// countProvided returns the number of required slices provided by the geometry passed as argument
+func countProvided(geometry gpu.Geometry, required map[gpu.Slice]int) int {
+	var res int
+	for s, q := range required {
+		if provided, ok := geometry[s]; ok {
+			if provided < q {
+				res += provided
+			} else {
+				res += q
+			}
+		}
+	}
+	return res
+}
+
+func filterSlicingSlices(slices map[gpu.Slice]int) map[gpu.Slice]int {
+	res := make(map[gpu.Slice]int)
+	for s, q := range slices {
+		if _, ok := s.(slicing.ProfileName); ok {
+			res[s] = q
+		}
+	}
+	return res
+}
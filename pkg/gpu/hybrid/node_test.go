@@ -0,0 +1,474 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hybrid_test
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/hybrid"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func TestNewNode(t *testing.T) {
+	testCases := []struct {
+		name                string
+		node                v1.Node
+		expectedMigGPUs     []int
+		expectedSlicingGPUs []int
+		expectedFreeGPUs    []int
+		errExpected         bool
+	}{
+		{
+			name: "node without GPU memory label",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "1",
+			}).Get(),
+			errExpected: true,
+		},
+		{
+			name: "node without GPU count label",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaMemory:  "40000",
+			}).Get(),
+			errExpected: true,
+		},
+		{
+			name: "no status annotations, all GPUs should be free",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).Get(),
+			expectedMigGPUs:     []int{},
+			expectedSlicingGPUs: []int{},
+			expectedFreeGPUs:    []int{0, 1},
+		},
+		{
+			name: "MIG, slicing and free GPUs",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "3",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "10gb", resource.StatusFree):    "2",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, "1g.5gb", resource.StatusUsed):  "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, "3g.20gb", resource.StatusFree): "1",
+			}).Get(),
+			expectedMigGPUs:     []int{2},
+			expectedSlicingGPUs: []int{0},
+			expectedFreeGPUs:    []int{1},
+		},
+		{
+			name: "GPU with both MIG and slicing profiles",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "1",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "10gb", resource.StatusFree):   "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "1g.5gb", resource.StatusUsed): "1",
+			}).Get(),
+			errExpected: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&tt.node)
+			node, err := hybrid.NewNode(*nodeInfo)
+			if tt.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.node.Name, node.Name)
+			migIndexes := make([]int, 0)
+			for _, g := range node.MigGPUs {
+				migIndexes = append(migIndexes, g.GetIndex())
+			}
+			slicingIndexes := make([]int, 0)
+			for _, g := range node.SlicingGPUs {
+				slicingIndexes = append(slicingIndexes, g.Index)
+			}
+			assert.Equal(t, tt.expectedMigGPUs, migIndexes)
+			assert.Equal(t, tt.expectedSlicingGPUs, slicingIndexes)
+			assert.Equal(t, tt.expectedFreeGPUs, node.FreeGPUs)
+		})
+	}
+}
+
+func TestNode__UpdateGeometryFor(t *testing.T) {
+	testCases := []struct {
+		name                string
+		node                v1.Node
+		slices              map[gpu.Slice]int
+		expectedUpdated     bool
+		expectedMigGPUs     int
+		expectedSlicingGPUs int
+		expectedFreeGPUs    int
+		expectedMinGeometry map[gpu.Slice]int
+	}{
+		{
+			name: "empty slices, should not update anything",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).Get(),
+			slices:              map[gpu.Slice]int{},
+			expectedUpdated:     false,
+			expectedFreeGPUs:    2,
+			expectedMinGeometry: map[gpu.Slice]int{},
+		},
+		{
+			name: "MIG profiles only, should partition a free GPU with MIG",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "enabled",
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "enabled",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile1g5gb: 2,
+			},
+			expectedUpdated:  true,
+			expectedMigGPUs:  1,
+			expectedFreeGPUs: 1,
+			expectedMinGeometry: map[gpu.Slice]int{
+				mig.Profile1g5gb: 2,
+			},
+		},
+		{
+			name: "unknown model with reported MIG placements, should partition a free GPU with MIG",
+			node: factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: "unknown-gpu-model",
+					constant.LabelNvidiaCount:   "2",
+					constant.LabelNvidiaMemory:  "24000",
+				}).
+				WithAnnotations(map[string]string{
+					v1alpha1.AnnotationMigPlacements:                       `{"1g.6gb":[{"start":0,"size":1},{"start":1,"size":1}],"2g.12gb":[{"start":0,"size":2}]}`,
+					fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "enabled",
+					fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "enabled",
+				}).
+				Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile1g6gb: 2,
+			},
+			expectedUpdated:  true,
+			expectedMigGPUs:  1,
+			expectedFreeGPUs: 1,
+			expectedMinGeometry: map[gpu.Slice]int{
+				mig.Profile1g6gb: 2,
+			},
+		},
+		{
+			name: "slicing profiles only, should slice a free GPU",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "disabled",
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "disabled",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				slicing.ProfileName("10gb"): 3,
+			},
+			expectedUpdated:     true,
+			expectedSlicingGPUs: 1,
+			expectedFreeGPUs:    1,
+			expectedMinGeometry: map[gpu.Slice]int{
+				slicing.ProfileName("10gb"): 3,
+			},
+		},
+		{
+			name: "MIG and slicing profiles, should partition each free GPU according to its MIG mode",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "enabled",
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "disabled",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile7g40gb:           1,
+				slicing.ProfileName("20gb"): 2,
+			},
+			expectedUpdated:     true,
+			expectedMigGPUs:     1,
+			expectedSlicingGPUs: 1,
+			expectedFreeGPUs:    0,
+			expectedMinGeometry: map[gpu.Slice]int{
+				mig.Profile7g40gb:           1,
+				slicing.ProfileName("20gb"): 2,
+			},
+		},
+		{
+			name: "MIG profiles only, GPUs with MIG disabled should not be MIG-partitioned",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "disabled",
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "disabled",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile1g5gb: 2,
+			},
+			expectedUpdated:     false,
+			expectedFreeGPUs:    2,
+			expectedMinGeometry: map[gpu.Slice]int{},
+		},
+		{
+			name: "slicing profiles only, GPUs with MIG enabled should not be sliced",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "enabled",
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "enabled",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				slicing.ProfileName("10gb"): 3,
+			},
+			expectedUpdated:     false,
+			expectedFreeGPUs:    2,
+			expectedMinGeometry: map[gpu.Slice]int{},
+		},
+		{
+			name: "GPUs without a reported or with a changing MIG mode should stay free",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "pending-reset",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile1g5gb:            1,
+				slicing.ProfileName("10gb"): 1,
+			},
+			expectedUpdated:     false,
+			expectedFreeGPUs:    2,
+			expectedMinGeometry: map[gpu.Slice]int{},
+		},
+		{
+			name: "existing MIG GPU already provides the required profiles, should not partition free GPUs",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+				constant.LabelNvidiaCount:   "2",
+				constant.LabelNvidiaMemory:  "40000",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "1g.5gb", resource.StatusFree): "7",
+			}).Get(),
+			slices: map[gpu.Slice]int{
+				mig.Profile1g5gb: 3,
+			},
+			expectedUpdated:  false,
+			expectedMigGPUs:  1,
+			expectedFreeGPUs: 1,
+			expectedMinGeometry: map[gpu.Slice]int{
+				mig.Profile1g5gb: 7,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&tt.node)
+			node, err := hybrid.NewNode(*nodeInfo)
+			assert.NoError(t, err)
+
+			updated, err := node.UpdateGeometryFor(tt.slices)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedUpdated, updated)
+			assert.Len(t, node.MigGPUs, tt.expectedMigGPUs)
+			assert.Len(t, node.SlicingGPUs, tt.expectedSlicingGPUs)
+			assert.Len(t, node.FreeGPUs, tt.expectedFreeGPUs)
+
+			geometry := node.Geometry()
+			for s, q := range tt.expectedMinGeometry {
+				assert.GreaterOrEqual(t, geometry[s], q)
+			}
+
+			// Scalar resources must match the node geometry
+			scalarResources := node.NodeInfo().Allocatable.ScalarResources
+			for s, q := range geometry {
+				var resourceName v1.ResourceName
+				switch p := s.(type) {
+				case mig.ProfileName:
+					resourceName = p.AsResourceName()
+				case slicing.ProfileName:
+					resourceName = p.AsResourceName()
+				}
+				assert.Equal(t, int64(q), scalarResources[resourceName])
+			}
+		})
+	}
+}
+
+func TestNode__AddPod(t *testing.T) {
+	node := factory.BuildNode("node-1").WithLabels(map[string]string{
+		constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+		constant.LabelNvidiaCount:   "2",
+		constant.LabelNvidiaMemory:  "40000",
+	}).WithAnnotations(map[string]string{
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "1g.5gb", resource.StatusFree): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, "10gb", resource.StatusFree):   "1",
+	}).Get()
+
+	testCases := []struct {
+		name        string
+		pod         v1.Pod
+		errExpected bool
+	}{
+		{
+			name: "pod requesting a free MIG profile",
+			pod: factory.BuildPod("ns-1", "pd-1").WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(mig.Profile1g5gb.AsResourceName(), 1).
+					Get(),
+			).Get(),
+			errExpected: false,
+		},
+		{
+			name: "pod requesting a free slicing profile",
+			pod: factory.BuildPod("ns-1", "pd-1").WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(slicing.ProfileName("10gb").AsResourceName(), 1).
+					Get(),
+			).Get(),
+			errExpected: false,
+		},
+		{
+			name: "pod requesting a MIG profile not available",
+			pod: factory.BuildPod("ns-1", "pd-1").WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(mig.Profile3g20gb.AsResourceName(), 1).
+					Get(),
+			).Get(),
+			errExpected: true,
+		},
+		{
+			name: "pod requesting a slicing profile not available",
+			pod: factory.BuildPod("ns-1", "pd-1").WithContainer(
+				factory.BuildContainer("test", "test").
+					WithScalarResourceRequest(slicing.ProfileName("10gb").AsResourceName(), 2).
+					Get(),
+			).Get(),
+			errExpected: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&node)
+			n, err := hybrid.NewNode(*nodeInfo)
+			assert.NoError(t, err)
+			err = n.AddPod(tt.pod)
+			if tt.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNode__Clone(t *testing.T) {
+	node := factory.BuildNode("node-1").WithLabels(map[string]string{
+		constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+		constant.LabelNvidiaCount:   "2",
+		constant.LabelNvidiaMemory:  "40000",
+	}).WithAnnotations(map[string]string{
+		fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 0): "enabled",
+		fmt.Sprintf(v1alpha1.AnnotationMigModeStatusFormat, 1): "enabled",
+	}).Get()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	n, err := hybrid.NewNode(*nodeInfo)
+	assert.NoError(t, err)
+
+	cloned := n.Clone().(*hybrid.Node)
+	_, err = cloned.UpdateGeometryFor(map[gpu.Slice]int{mig.Profile1g5gb: 1})
+	assert.NoError(t, err)
+
+	assert.Len(t, cloned.MigGPUs, 1)
+	assert.Len(t, n.MigGPUs, 0)
+	assert.Len(t, n.FreeGPUs, 2)
+}
+
+func TestNode__Compact(t *testing.T) {
+	node := factory.BuildNode("node-1").WithLabels(map[string]string{
+		constant.LabelNvidiaProduct: string(gpu.GPUModel_A100_SXM4_40GB),
+		constant.LabelNvidiaCount:   "4",
+		constant.LabelNvidiaMemory:  "40000",
+	}).WithAnnotations(map[string]string{
+		// GPU 0: MIG GPU with only free devices
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, "1g.5gb", resource.StatusFree): "7",
+		// GPU 1: MIG GPU with used devices
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, "1g.5gb", resource.StatusUsed): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, "1g.5gb", resource.StatusFree): "6",
+		// GPU 2: slicing GPU with only free slices
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, "10gb", resource.StatusFree): "2",
+		// GPU 3: slicing GPU with used slices
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 3, "10gb", resource.StatusUsed): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 3, "10gb", resource.StatusFree): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 3, "5gb", resource.StatusFree):  "2",
+	}).Get()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	n, err := hybrid.NewNode(*nodeInfo)
+	assert.NoError(t, err)
+
+	compacted, err := n.Compact()
+	assert.NoError(t, err)
+	assert.True(t, compacted)
+
+	assert.Len(t, n.MigGPUs, 2)
+	assert.Equal(t, gpu.Geometry{mig.Profile7g40gb: 1}, n.MigGPUs[0].GetGeometry())
+	assert.Equal(t, gpu.Geometry{mig.Profile1g5gb: 7}, n.MigGPUs[1].GetGeometry())
+	assert.Len(t, n.SlicingGPUs, 1)
+	assert.Equal(t, 3, n.SlicingGPUs[0].Index)
+	assert.Equal(t, gpu.Geometry{slicing.ProfileName("10gb"): 1, slicing.ProfileName("20gb"): 1}, n.SlicingGPUs[0].GetGeometry())
+	assert.Equal(t, []int{2}, n.FreeGPUs)
+
+	// Compacting again should not change anything
+	compacted, err = n.Compact()
+	assert.NoError(t, err)
+	assert.False(t, compacted)
+}
@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	"os"
+	"sigs.k8s.io/yaml"
 )
 
 type AllowedMigGeometries struct {
@@ -80,3 +82,18 @@ func (a AllowedMigGeometriesList) GroupByModel() map[gpu.Model][]gpu.Geometry {
 	}
 	return res
 }
+
+// LoadAllowedMigGeometriesListFromFile loads the list of allowed MIG geometries from the YAML file
+// provided as argument (e.g. the file specified by the field KnownMigGeometriesFile of the
+// GPU Partitioner config)
+func LoadAllowedMigGeometriesListFromFile(file string) (AllowedMigGeometriesList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var allowedGeometries = make(AllowedMigGeometriesList, 0)
+	if err = yaml.Unmarshal(data, &allowedGeometries); err != nil {
+		return nil, err
+	}
+	return allowedGeometries, nil
+}
@@ -201,3 +201,17 @@ func TestAllowedMigGeometriesList__GroupByModel(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadAllowedMigGeometriesListFromFile(t *testing.T) {
+	t.Run("Default known geometries file", func(t *testing.T) {
+		allowedGeometries, err := mig.LoadAllowedMigGeometriesListFromFile("../../../config/gpupartitioner/manager/known_mig_geometries.yaml")
+		assert.NoError(t, err)
+		assert.NoError(t, mig.ValidateConfigs(allowedGeometries.GroupByModel()))
+		assert.Contains(t, allowedGeometries.GroupByModel(), gpu.GPUModel_A30)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := mig.LoadAllowedMigGeometriesListFromFile("missing.yaml")
+		assert.Error(t, err)
+	})
+}
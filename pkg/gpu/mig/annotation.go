@@ -48,3 +48,8 @@ func GroupSpecAnnotationsByMigProfile(annotations gpu.SpecAnnotationList) map[Pr
 	}
 	return result
 }
+
+// IsMigStatusAnnotation returns true if the status annotation provided as argument refers to a MIG profile
+func IsMigStatusAnnotation(a gpu.StatusAnnotation) bool {
+	return ProfileName(a.ProfileName).isValid()
+}
@@ -32,6 +32,8 @@ type Client interface {
 	CreateMigDevices(ctx context.Context, profileList ProfileList) (ProfileList, error)
 	DeleteMigDevice(ctx context.Context, device gpu.Device) gpu.Error
 	DeleteAllExcept(ctx context.Context, resources gpu.DeviceList) error
+	GetProfilePlacements(ctx context.Context) (map[int]ProfilePlacements, gpu.Error)
+	GetMigDevicePlacements(ctx context.Context) (map[string]Placement, gpu.Error)
 }
 
 type clientImpl struct {
@@ -53,10 +55,24 @@ func NewClient(resourceClient resource.Client, nvmlClient nvml.Client) Client {
 // CreateMigResources still tries to create the resources on the other GPUs and returns the ones that
 // it possible to create. This means that if any error happens, the returned ProfileList will be a subset
 // of the input list, otherwise the two lists will have the same length and items.
+//
+// If all the profiles of a GPU specify a placement, their devices are created exactly at those placements.
+// Otherwise, the devices of the GPU are created by trying the possible creation orders until one succeeds.
 func (c clientImpl) CreateMigDevices(_ context.Context, profileList ProfileList) (ProfileList, error) {
 	var errors = make(gpu.ErrorList, 0)
 	var createdProfiles = make(ProfileList, 0)
 	for gpuIndex, profiles := range profileList.GroupByGPU() {
+		if profiles.HavePlacements() {
+			for _, p := range profiles {
+				placement := nvml.GpuInstancePlacement{Start: p.Placement.Start, Size: p.Placement.Size}
+				if err := c.nvmlClient.CreateMigDeviceWithPlacement(p.Name.String(), placement, gpuIndex); err != nil {
+					errors = append(errors, err)
+					continue
+				}
+				createdProfiles = append(createdProfiles, p)
+			}
+			continue
+		}
 		profileNames := make([]string, 0)
 		for _, p := range profiles {
 			profileNames = append(profileNames, p.Name.String())
@@ -138,6 +154,40 @@ func (c clientImpl) DeleteAllExcept(_ context.Context, resourcesToKeep gpu.Devic
 	return c.nvmlClient.DeleteAllMigDevicesExcept(idsToKeep)
 }
 
+// GetProfilePlacements returns the MIG profiles supported by each MIG-enabled GPU, together with the
+// placements at which their devices can be created. The returned map is indexed by GPU index.
+func (c clientImpl) GetProfilePlacements(ctx context.Context) (map[int]ProfilePlacements, gpu.Error) {
+	logger := klog.FromContext(ctx)
+
+	gpuIndexes, err := c.nvmlClient.GetMigEnabledGPUs()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]ProfilePlacements, len(gpuIndexes))
+	for _, gpuIndex := range gpuIndexes {
+		profiles, err := c.nvmlClient.GetGpuInstanceProfiles(gpuIndex)
+		if err != nil {
+			logger.Error(err, "unable to fetch GPU instance profiles", "GPU index", gpuIndex)
+			return nil, err
+		}
+		res[gpuIndex] = NewProfilePlacements(profiles)
+	}
+	return res, nil
+}
+
+// GetMigDevicePlacements returns the placements of the existing MIG devices, indexed by device ID.
+func (c clientImpl) GetMigDevicePlacements(_ context.Context) (map[string]Placement, gpu.Error) {
+	placements, err := c.nvmlClient.GetMigDevicePlacements()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]Placement, len(placements))
+	for deviceId, p := range placements {
+		res[deviceId] = Placement{Start: p.Start, Size: p.Size}
+	}
+	return res, nil
+}
+
 func (c clientImpl) extractMigDevices(ctx context.Context, devices []resource.Device) ([]gpu.Device, gpu.Error) {
 	logger := klog.FromContext(ctx)
 
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
 	"github.com/nebuly-ai/nos/pkg/resource"
 	mockednvml "github.com/nebuly-ai/nos/pkg/test/mocks/nvml"
 	"github.com/stretchr/testify/assert"
@@ -375,3 +376,109 @@ func TestClient_GetAllocatableMigDevices(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_GetProfilePlacements(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		migEnabledGpus         []int
+		getMigEnabledGpusErr   gpu.Error
+		gpuInstanceProfiles    map[int][]nvml.GpuInstanceProfile
+		getInstanceProfilesErr gpu.Error
+
+		expectedError      bool
+		expectedPlacements map[int]mig.ProfilePlacements
+	}{
+		{
+			name:                 "Error fetching MIG-enabled GPUs",
+			getMigEnabledGpusErr: gpu.GenericErr.Errorf("error"),
+			expectedError:        true,
+		},
+		{
+			name:                   "Error fetching GPU instance profiles",
+			migEnabledGpus:         []int{0},
+			gpuInstanceProfiles:    map[int][]nvml.GpuInstanceProfile{0: nil},
+			getInstanceProfilesErr: gpu.GenericErr.Errorf("error"),
+			expectedError:          true,
+		},
+		{
+			name:               "No MIG-enabled GPUs",
+			migEnabledGpus:     []int{},
+			expectedPlacements: map[int]mig.ProfilePlacements{},
+		},
+		{
+			name:           "Multiple MIG-enabled GPUs",
+			migEnabledGpus: []int{0, 2},
+			gpuInstanceProfiles: map[int][]nvml.GpuInstanceProfile{
+				0: {
+					{Name: "4g.24gb", Placements: []nvml.GpuInstancePlacement{{Start: 0, Size: 4}}},
+					{Name: "2g.12gb", Placements: []nvml.GpuInstancePlacement{{Start: 2, Size: 2}, {Start: 0, Size: 2}}},
+				},
+				2: {
+					{Name: "7g.40gb", Placements: []nvml.GpuInstancePlacement{{Start: 0, Size: 8}}},
+				},
+			},
+			expectedPlacements: map[int]mig.ProfilePlacements{
+				0: {
+					mig.Profile4g24gb: {{Start: 0, Size: 4}},
+					mig.Profile2g12gb: {{Start: 0, Size: 2}, {Start: 2, Size: 2}},
+				},
+				2: {
+					mig.Profile7g40gb: {{Start: 0, Size: 8}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nvmlClient := mockednvml.Client{}
+			nvmlClient.On("GetMigEnabledGPUs").Return(tt.migEnabledGpus, tt.getMigEnabledGpusErr)
+			for gpuIndex, profiles := range tt.gpuInstanceProfiles {
+				nvmlClient.On("GetGpuInstanceProfiles", gpuIndex).Return(profiles, tt.getInstanceProfilesErr)
+			}
+			client := mig.NewClient(resource.NewClient(MockedPodResourcesListerClient{}), &nvmlClient)
+
+			placements, err := client.GetProfilePlacements(context.TODO())
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.expectedPlacements, placements)
+			}
+		})
+	}
+}
+
+func TestClient_CreateMigDevices(t *testing.T) {
+	t.Run("Profiles with placements are created at their placements", func(t *testing.T) {
+		nvmlClient := mockednvml.Client{}
+		nvmlClient.On("CreateMigDeviceWithPlacement", "3g.20gb", nvml.GpuInstancePlacement{Start: 4, Size: 4}, 0).Return(nil).Once()
+		nvmlClient.On("CreateMigDeviceWithPlacement", "1g.5gb", nvml.GpuInstancePlacement{Start: 0, Size: 1}, 0).
+			Return(gpu.GenericErr.Errorf("error")).Once()
+		client := mig.NewClient(resource.NewClient(MockedPodResourcesListerClient{}), &nvmlClient)
+
+		profiles := mig.ProfileList{
+			{GpuIndex: 0, Name: mig.Profile3g20gb, Placement: mig.Placement{Start: 4, Size: 4}},
+			{GpuIndex: 0, Name: mig.Profile1g5gb, Placement: mig.Placement{Start: 0, Size: 1}},
+		}
+		created, err := client.CreateMigDevices(context.TODO(), profiles)
+		assert.Error(t, err)
+		assert.Equal(t, profiles[:1], created)
+		nvmlClient.AssertExpectations(t)
+	})
+
+	t.Run("Profiles without placements are created at any placement", func(t *testing.T) {
+		nvmlClient := mockednvml.Client{}
+		nvmlClient.On("CreateMigDevices", []string{"3g.20gb", "1g.5gb"}, 1).Return(nil).Once()
+		client := mig.NewClient(resource.NewClient(MockedPodResourcesListerClient{}), &nvmlClient)
+
+		profiles := mig.ProfileList{
+			{GpuIndex: 1, Name: mig.Profile3g20gb, Placement: mig.Placement{Start: 4, Size: 4}},
+			{GpuIndex: 1, Name: mig.Profile1g5gb},
+		}
+		created, err := client.CreateMigDevices(context.TODO(), profiles)
+		assert.NoError(t, err)
+		assert.Equal(t, profiles, created)
+		nvmlClient.AssertExpectations(t)
+	})
+}
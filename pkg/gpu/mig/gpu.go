@@ -22,6 +22,7 @@ import (
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	"github.com/nebuly-ai/nos/pkg/util"
 	v1 "k8s.io/api/core/v1"
+	"sort"
 )
 
 type GPU struct {
@@ -45,13 +46,25 @@ func NewGPU(model gpu.Model, index int, usedMigDevices, freeMigDevices map[Profi
 	if !ok {
 		return GPU{}, fmt.Errorf("model %q is not associated with any known GPU", model)
 	}
+	return NewGPUWithAllowedGeometries(model, index, allowedGeometries, usedMigDevices, freeMigDevices), nil
+}
+
+// NewGPUWithAllowedGeometries creates a new GPU that allows the MIG geometries provided as argument,
+// regardless of the geometries known for its model (e.g. geometries discovered at runtime on the node).
+func NewGPUWithAllowedGeometries(
+	model gpu.Model,
+	index int,
+	allowedGeometries []gpu.Geometry,
+	usedMigDevices,
+	freeMigDevices map[ProfileName]int,
+) GPU {
 	return GPU{
 		index:                index,
 		model:                model,
 		allowedMigGeometries: allowedGeometries,
 		usedMigDevices:       usedMigDevices,
 		freeMigDevices:       freeMigDevices,
-	}, nil
+	}
 }
 
 func (g *GPU) Clone() GPU {
@@ -158,10 +171,11 @@ func (g *GPU) ApplyGeometry(geometry gpu.Geometry) error {
 func (g *GPU) UpdateGeometryFor(requiredProfiles map[gpu.Slice]int) bool {
 	var geometryNumProvidedProfiles = make(map[string]int)
 	var geometryLookup = make(map[string]gpu.Geometry)
+	var candidateIds = make([]string, 0)
 	var bestGeometry *gpu.Geometry
 
-	// For each allowed geometry, compute the number of required profiles that it can provide
-	for _, candidate := range g.GetAllowedGeometries() {
+	// For each candidate geometry, compute the number of required profiles that it can provide
+	for _, candidate := range g.getCandidateGeometries(requiredProfiles) {
 		for requiredProfile, requiredQuantity := range requiredProfiles {
 			requiredMigProfile, ok := requiredProfile.(ProfileName)
 			if !ok {
@@ -185,14 +199,19 @@ func (g *GPU) UpdateGeometryFor(requiredProfiles map[gpu.Slice]int) bool {
 				continue
 			}
 			candidateGeometryId := candidate.Id()
+			if _, ok := geometryLookup[candidateGeometryId]; !ok {
+				candidateIds = append(candidateIds, candidateGeometryId)
+			}
 			geometryNumProvidedProfiles[candidateGeometryId] += numProvidedProfiles
 			geometryLookup[candidateGeometryId] = candidate
 		}
 	}
 
-	// Find, if any, the geometry that provides the highest number of required profiles
+	// Find, if any, the geometry that provides the highest number of required profiles.
+	// Ties are broken by the order of the candidate geometries, so that the result is deterministic.
 	maxProvidedProfiles := 0
-	for candidateId, nProvidedProfiles := range geometryNumProvidedProfiles {
+	for _, candidateId := range candidateIds {
+		nProvidedProfiles := geometryNumProvidedProfiles[candidateId]
 		if nProvidedProfiles > maxProvidedProfiles {
 			maxProvidedProfiles = nProvidedProfiles
 			candidate := geometryLookup[candidateId]
@@ -211,16 +230,116 @@ func (g *GPU) UpdateGeometryFor(requiredProfiles map[gpu.Slice]int) bool {
 	return true
 }
 
-// AllowsGeometry returns true if the geometry provided as argument is allowed by the GPU model
+// getCandidateGeometries returns the geometries that UpdateGeometryFor considers for providing the required
+// profiles: the allowed geometries, followed by their variants in which some GPU instances are split into the
+// devices of the compute instance profiles that are either required or already present on the GPU.
+func (g *GPU) getCandidateGeometries(requiredProfiles map[gpu.Slice]int) []gpu.Geometry {
+	ciProfiles := make([]ProfileName, 0)
+	addCiProfile := func(p ProfileName) {
+		if p.IsComputeInstanceProfile() && p.GetDevicesPerGpuInstance() > 0 && !util.InSlice(p, ciProfiles) {
+			ciProfiles = append(ciProfiles, p)
+		}
+	}
+	for slice := range requiredProfiles {
+		if p, ok := slice.(ProfileName); ok {
+			addCiProfile(p)
+		}
+	}
+	for p := range g.usedMigDevices {
+		addCiProfile(p)
+	}
+	for p := range g.freeMigDevices {
+		addCiProfile(p)
+	}
+	if len(ciProfiles) == 0 {
+		return g.GetAllowedGeometries()
+	}
+	sort.Slice(ciProfiles, func(i, j int) bool {
+		return ciProfiles[i] < ciProfiles[j]
+	})
+
+	res := make([]gpu.Geometry, 0, len(g.GetAllowedGeometries()))
+	res = append(res, g.GetAllowedGeometries()...)
+	for _, allowedGeometry := range g.GetAllowedGeometries() {
+		res = append(res, splitGpuInstances(allowedGeometry, ciProfiles)...)
+	}
+	return res
+}
+
+// splitGpuInstances returns all the variants of the geometry provided as argument in which at least one
+// GPU instance is split into the devices of one of the compute instance profiles provided as argument.
+// Each GPU instance is split into the devices of a single compute instance profile.
+func splitGpuInstances(geometry gpu.Geometry, ciProfiles []ProfileName) []gpu.Geometry {
+	res := make([]gpu.Geometry, 0)
+	var visit func(i int, current gpu.Geometry, split bool)
+	visit = func(i int, current gpu.Geometry, split bool) {
+		if i == len(ciProfiles) {
+			if split {
+				res = append(res, current)
+			}
+			return
+		}
+		ciProfile := ciProfiles[i]
+		giProfile := ciProfile.GetGpuInstanceProfile()
+		for n := 0; n <= current[giProfile]; n++ {
+			next := make(gpu.Geometry, len(current)+1)
+			for k, v := range current {
+				next[k] = v
+			}
+			if n > 0 {
+				next[giProfile] -= n
+				if next[giProfile] == 0 {
+					delete(next, giProfile)
+				}
+				next[ciProfile] += n * ciProfile.GetDevicesPerGpuInstance()
+			}
+			visit(i+1, next, split || n > 0)
+		}
+	}
+	visit(0, geometry, false)
+	return res
+}
+
+// AllowsGeometry returns true if the geometry provided as argument is allowed by the GPU model.
+//
+// Geometries including compute instance profiles (e.g. 1c.3g.20gb) are allowed if the GPU instances
+// required for creating their devices form, together with the other profiles of the geometry, an allowed
+// geometry. Each GPU instance contains the devices of a single compute instance profile.
 func (g *GPU) AllowsGeometry(geometry gpu.Geometry) bool {
+	gpuInstances, ok := toGpuInstanceGeometry(geometry)
+	if !ok {
+		return false
+	}
 	for _, allowedGeometry := range g.GetAllowedGeometries() {
-		if cmp.Equal(geometry, allowedGeometry) {
+		if cmp.Equal(gpuInstances, allowedGeometry) {
 			return true
 		}
 	}
 	return false
 }
 
+// toGpuInstanceGeometry returns the geometry of the GPU instances required for creating the devices of the
+// geometry provided as argument, packing the devices of each compute instance profile into the fewest
+// GPU instances. It returns false if the geometry includes any invalid compute instance profile.
+func toGpuInstanceGeometry(geometry gpu.Geometry) (gpu.Geometry, bool) {
+	res := make(gpu.Geometry, len(geometry))
+	for slice, quantity := range geometry {
+		profile, ok := slice.(ProfileName)
+		if !ok || !profile.IsComputeInstanceProfile() {
+			res[slice] += quantity
+			continue
+		}
+		devicesPerGi := profile.GetDevicesPerGpuInstance()
+		if devicesPerGi == 0 {
+			return nil, false
+		}
+		if quantity > 0 {
+			res[profile.GetGpuInstanceProfile()] += (quantity + devicesPerGi - 1) / devicesPerGi
+		}
+	}
+	return res, true
+}
+
 // GetAllowedGeometries returns the MIG geometries allowed by the GPU model
 func (g *GPU) GetAllowedGeometries() []gpu.Geometry {
 	return g.allowedMigGeometries
@@ -246,6 +365,22 @@ func (g *GPU) AddPod(pod v1.Pod) error {
 	return nil
 }
 
+// Compact applies the initial MIG geometry of the GPU (see InitGeometry) if none of its MIG devices is used,
+// so that free MIG devices created for pods that have since finished do not fragment the GPU.
+//
+// It returns true if the geometry of the GPU changes, false otherwise.
+func (g *GPU) Compact() bool {
+	for _, quantity := range g.usedMigDevices {
+		if quantity > 0 {
+			return false
+		}
+	}
+	if sameGeometry(g.GetGeometry(), gpu.GetFewestSlicesGeometry(g.allowedMigGeometries)) {
+		return false
+	}
+	return g.InitGeometry() == nil
+}
+
 func (g *GPU) HasFreeMigDevices() bool {
 	return len(g.GetFreeMigDevices()) > 0
 }
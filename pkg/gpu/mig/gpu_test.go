@@ -422,6 +422,139 @@ func TestGPU__UpdateGeometryFor(t *testing.T) {
 	}
 }
 
+func TestGPU__UpdateGeometryFor__ComputeInstanceProfiles(t *testing.T) {
+	testCases := []struct {
+		name             string
+		gpu              mig.GPU
+		profiles         map[gpu.Slice]int
+		expectedGeometry gpu.Geometry
+		expectedUpdated  bool
+	}{
+		{
+			name: "Empty GPU, GPU instances are split only as much as required",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				map[mig.ProfileName]int{},
+				map[mig.ProfileName]int{},
+			),
+			profiles: map[gpu.Slice]int{
+				mig.Profile1c3g20gb: 2,
+			},
+			expectedGeometry: gpu.Geometry{
+				mig.Profile1c3g20gb: 3,
+				mig.Profile3g20gb:   1,
+			},
+			expectedUpdated: true,
+		},
+		{
+			name: "Used compute instances are kept",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				map[mig.ProfileName]int{
+					mig.Profile1c3g20gb: 2,
+				},
+				map[mig.ProfileName]int{
+					mig.Profile3g20gb: 1,
+				},
+			),
+			profiles: map[gpu.Slice]int{
+				mig.Profile1g5gb: 2,
+			},
+			expectedGeometry: gpu.Geometry{
+				mig.Profile1c3g20gb: 3,
+				mig.Profile1g5gb:    3,
+			},
+			expectedUpdated: true,
+		},
+		{
+			name: "Required compute instances exceed the GPU capacity, should create as many as possible",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A30,
+				0,
+				map[mig.ProfileName]int{},
+				map[mig.ProfileName]int{},
+			),
+			profiles: map[gpu.Slice]int{
+				mig.Profile1c4g24gb: 10,
+			},
+			expectedGeometry: gpu.Geometry{
+				mig.Profile1c4g24gb: 4,
+			},
+			expectedUpdated: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := tt.gpu.UpdateGeometryFor(tt.profiles)
+			assert.Equal(t, tt.expectedUpdated, updated)
+			assert.Equal(t, tt.expectedGeometry, tt.gpu.GetGeometry())
+			assert.True(t, tt.gpu.AllowsGeometry(tt.gpu.GetGeometry()))
+		})
+	}
+}
+
+func TestGPU__AllowsGeometry__ComputeInstanceProfiles(t *testing.T) {
+	g := mig.NewGpuOrPanic(gpu.GPUModel_A100_SXM4_40GB, 0, map[mig.ProfileName]int{}, map[mig.ProfileName]int{})
+	testCases := []struct {
+		name     string
+		geometry gpu.Geometry
+		expected bool
+	}{
+		{
+			name:     "Compute instances filling a GPU instance",
+			geometry: gpu.Geometry{mig.Profile1c3g20gb: 3, mig.Profile3g20gb: 1},
+			expected: true,
+		},
+		{
+			name:     "Compute instances partially filling GPU instances",
+			geometry: gpu.Geometry{mig.Profile1c3g20gb: 4},
+			expected: true,
+		},
+		{
+			name:     "Compute instances of different profiles need separate GPU instances",
+			geometry: gpu.Geometry{mig.Profile1c4g20gb: 2, mig.Profile2c4g20gb: 1},
+			expected: false,
+		},
+		{
+			name:     "Too many compute instances",
+			geometry: gpu.Geometry{mig.Profile1c3g20gb: 7},
+			expected: false,
+		},
+		{
+			name:     "Invalid compute instance profile",
+			geometry: gpu.Geometry{mig.ProfileName("4c.3g.20gb"): 1, mig.Profile3g20gb: 1},
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, g.AllowsGeometry(tt.geometry))
+		})
+	}
+}
+
+func TestGPU__UpdateGeometryFor__IsDeterministic(t *testing.T) {
+	profiles := map[gpu.Slice]int{
+		mig.Profile1g5gb:  2,
+		mig.Profile3g20gb: 1,
+	}
+	var expected gpu.Geometry
+	for i := 0; i < 20; i++ {
+		g, err := mig.NewGPU(gpu.GPUModel_A100_SXM4_40GB, 0, map[mig.ProfileName]int{}, map[mig.ProfileName]int{})
+		assert.NoError(t, err)
+		assert.True(t, g.UpdateGeometryFor(profiles))
+		if i == 0 {
+			expected = g.GetGeometry()
+			continue
+		}
+		assert.Equal(t, expected, g.GetGeometry())
+	}
+}
+
 func TestGeometry__AsResources(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -514,3 +647,68 @@ func TestGPU__ApplyInitialGeometry(t *testing.T) {
 		})
 	}
 }
+
+func TestGPU__Compact(t *testing.T) {
+	testCases := []struct {
+		name              string
+		gpu               mig.GPU
+		expectedCompacted bool
+		expectedGeometry  gpu.Geometry
+	}{
+		{
+			name: "GPU has used devices, should not change geometry",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A30,
+				0,
+				map[mig.ProfileName]int{
+					mig.Profile1g6gb: 1,
+				},
+				map[mig.ProfileName]int{
+					mig.Profile1g6gb: 3,
+				},
+			),
+			expectedCompacted: false,
+			expectedGeometry: map[gpu.Slice]int{
+				mig.Profile1g6gb: 4,
+			},
+		},
+		{
+			name: "GPU has only free devices, should apply geometry with fewest slices",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A30,
+				0,
+				map[mig.ProfileName]int{},
+				map[mig.ProfileName]int{
+					mig.Profile1g6gb: 4,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry: map[gpu.Slice]int{
+				mig.Profile4g24gb: 1,
+			},
+		},
+		{
+			name: "GPU already has geometry with fewest slices, should not change geometry",
+			gpu: mig.NewGpuOrPanic(
+				gpu.GPUModel_A30,
+				0,
+				map[mig.ProfileName]int{},
+				map[mig.ProfileName]int{
+					mig.Profile4g24gb: 1,
+				},
+			),
+			expectedCompacted: false,
+			expectedGeometry: map[gpu.Slice]int{
+				mig.Profile4g24gb: 1,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			compacted := tt.gpu.Compact()
+			assert.Equal(t, tt.expectedCompacted, compacted)
+			assert.Equal(t, tt.expectedGeometry, tt.gpu.GetGeometry())
+		})
+	}
+}
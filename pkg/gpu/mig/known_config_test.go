@@ -21,6 +21,8 @@ import (
 	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/gpu/slicing"
 	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +30,59 @@ func TestValidateDefaultKnownConfigs(t *testing.T) {
 	assert.NoError(t, mig.ValidateConfigs(mig.GetKnownGeometries()))
 }
 
+func TestIsKnownModel(t *testing.T) {
+	testCases := []struct {
+		model    gpu.Model
+		expected bool
+	}{
+		{model: gpu.GPUModel_A30, expected: true},
+		{model: gpu.GPUModel_A100_SXM4_40GB, expected: true},
+		{model: gpu.GPUModel_A100_PCIe_40GB, expected: true},
+		{model: gpu.GPUModel_A100_PCIe_80GB, expected: true},
+		{model: gpu.GPUModel_A800_SXM4_80GB, expected: true},
+		{model: gpu.GPUModel_A800_PCIe_80GB, expected: true},
+		{model: gpu.GPUModel_H100_SXM5_80GB, expected: true},
+		{model: gpu.GPUModel_H100_PCIe_80GB, expected: true},
+		{model: gpu.GPUModel_H100_NVL, expected: true},
+		{model: "Tesla-T4", expected: false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.model.String(), func(t *testing.T) {
+			assert.Equal(t, tt.expected, mig.IsKnownModel(tt.model))
+		})
+	}
+}
+
+func TestDefaultKnownConfigs__FitGPU(t *testing.T) {
+	for model, geometries := range mig.GetKnownGeometries() {
+		for _, geometry := range geometries {
+			var computeSlices int
+			for profile, quantity := range geometry {
+				slices, err := strconv.Atoi(strings.Split(profile.String(), "g.")[0])
+				assert.NoError(t, err)
+				computeSlices += slices * quantity
+			}
+			assert.LessOrEqual(t, computeSlices, 7, "model %s, geometry %v", model, geometry)
+		}
+	}
+}
+
+func TestDefaultKnownConfigs__OneProfilePerSize(t *testing.T) {
+	for model, geometries := range mig.GetKnownGeometries() {
+		profilesBySize := make(map[string]gpu.Slice)
+		for _, geometry := range geometries {
+			for profile := range geometry {
+				size := strings.Split(profile.String(), ".")[0]
+				if other, ok := profilesBySize[size]; ok {
+					assert.Equal(t, other, profile, "model %s, geometry %v", model, geometry)
+					continue
+				}
+				profilesBySize[size] = profile
+			}
+		}
+	}
+}
+
 func TestValidateConfigs(t *testing.T) {
 	testCases := []struct {
 		name        string
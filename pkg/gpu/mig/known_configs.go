@@ -38,108 +38,71 @@ var (
 				Profile1g6gb: 4,
 			},
 		},
-		gpu.GPUModel_A100_SXM4_40GB: {
-			{
-				Profile7g40gb: 1,
-			},
-			{
-				Profile4g20gb: 1,
-				Profile2g10gb: 1,
-				Profile1g5gb:  1,
-			},
-			{
-				Profile4g20gb: 1,
-				Profile1g5gb:  3,
-			},
-			{
-				Profile3g20gb: 2,
-			},
-			{
-				Profile3g20gb: 1,
-				Profile2g10gb: 1,
-				Profile1g5gb:  1,
-			},
-			{
-				Profile3g20gb: 1,
-				Profile1g5gb:  3,
-			},
-			{
-				Profile2g10gb: 2,
-				Profile3g20gb: 1,
-			},
-			{
-				Profile2g10gb: 1,
-				Profile1g5gb:  2,
-				Profile3g20gb: 1,
-			},
-			{
-				Profile2g10gb: 3,
-				Profile1g5gb:  1,
-			},
-			{
-				Profile2g10gb: 2,
-				Profile1g5gb:  3,
-			},
-			{
-				Profile2g10gb: 1,
-				Profile1g5gb:  5,
-			},
-			{
-				Profile1g5gb: 7,
-			},
+		gpu.GPUModel_A100_SXM4_40GB: sevenSlicesGeometries(Profile1g5gb, Profile2g10gb, Profile3g20gb, Profile4g20gb, Profile7g40gb),
+		gpu.GPUModel_A100_PCIe_40GB: sevenSlicesGeometries(Profile1g5gb, Profile2g10gb, Profile3g20gb, Profile4g20gb, Profile7g40gb),
+		gpu.GPUModel_A100_PCIe_80GB: sevenSlicesGeometries(Profile1g10gb, Profile2g20gb, Profile3g40gb, Profile4g40gb, Profile7g79gb),
+		gpu.GPUModel_A800_SXM4_80GB: sevenSlicesGeometries(Profile1g10gb, Profile2g20gb, Profile3g40gb, Profile4g40gb, Profile7g80gb),
+		gpu.GPUModel_A800_PCIe_80GB: sevenSlicesGeometries(Profile1g10gb, Profile2g20gb, Profile3g40gb, Profile4g40gb, Profile7g80gb),
+		gpu.GPUModel_H100_SXM5_80GB: sevenSlicesGeometries(Profile1g10gb, Profile2g20gb, Profile3g40gb, Profile4g40gb, Profile7g80gb),
+		gpu.GPUModel_H100_PCIe_80GB: sevenSlicesGeometries(Profile1g10gb, Profile2g20gb, Profile3g40gb, Profile4g40gb, Profile7g80gb),
+		gpu.GPUModel_H100_NVL:       sevenSlicesGeometries(Profile1g12gb, Profile2g24gb, Profile3g47gb, Profile4g47gb, Profile7g94gb),
+	}
+)
+
+// sevenSlicesGeometries returns the MIG geometries allowed by the GPUs with 7 compute slices and 8 memory
+// slices (e.g. A100, A800 and H100), given the names that the profiles of each size have on the GPU model
+func sevenSlicesGeometries(p1g, p2g, p3g, p4g, p7g ProfileName) []gpu.Geometry {
+	return []gpu.Geometry{
+		{
+			p7g: 1,
 		},
-		gpu.GPUModel_A100_PCIe_80GB: {
-			{
-				Profile7g79gb: 1,
-			},
-			{
-				Profile4g40gb: 1,
-				Profile2g20gb: 1,
-				Profile1g10gb: 1,
-			},
-			{
-				Profile4g40gb: 1,
-				Profile1g10gb: 3,
-			},
-			{
-				Profile3g40gb: 2,
-			},
-			{
-				Profile3g40gb: 1,
-				Profile2g20gb: 1,
-				Profile1g10gb: 1,
-			},
-			{
-				Profile3g40gb: 1,
-				Profile1g10gb: 3,
-			},
-			{
-				Profile2g20gb: 2,
-				Profile3g20gb: 1,
-			},
-			{
-				Profile2g10gb: 1,
-				Profile1g10gb: 2,
-				Profile3g40gb: 1,
-			},
-			{
-				Profile2g20gb: 3,
-				Profile1g10gb: 1,
-			},
-			{
-				Profile2g20gb: 2,
-				Profile1g10gb: 3,
-			},
-			{
-				Profile2g20gb: 1,
-				Profile1g10gb: 5,
-			},
-			{
-				Profile1g10gb: 7,
-			},
+		{
+			p4g: 1,
+			p2g: 1,
+			p1g: 1,
+		},
+		{
+			p4g: 1,
+			p1g: 3,
+		},
+		{
+			p3g: 2,
+		},
+		{
+			p3g: 1,
+			p2g: 1,
+			p1g: 1,
+		},
+		{
+			p3g: 1,
+			p1g: 3,
+		},
+		{
+			p2g: 2,
+			p3g: 1,
+		},
+		{
+			p2g: 1,
+			p1g: 2,
+			p3g: 1,
+		},
+		{
+			p2g: 3,
+			p1g: 1,
+		},
+		{
+			p2g: 2,
+			p1g: 3,
+		},
+		{
+			p2g: 1,
+			p1g: 5,
+		},
+		{
+			p1g: 7,
 		},
 	}
-)
+}
 
 func SetKnownGeometries(configs map[gpu.Model][]gpu.Geometry) error {
 	if err := ValidateConfigs(configs); err != nil {
@@ -156,6 +119,13 @@ func GetKnownGeometries() map[gpu.Model][]gpu.Geometry {
 	return knownMigGeometries
 }
 
+// IsKnownModel returns true if the MIG geometries allowed by the GPU model provided as argument are known,
+// false otherwise. GPUs of unknown models cannot be partitioned with MIG.
+func IsKnownModel(model gpu.Model) bool {
+	_, ok := GetAllowedGeometries(model)
+	return ok
+}
+
 func GetAllowedGeometries(model gpu.Model) ([]gpu.Geometry, bool) {
 	configs, ok := GetKnownGeometries()[model]
 	return configs, ok
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig
+
+import (
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// Mode is the MIG mode of a GPU
+type Mode string
+
+const (
+	ModeEnabled  Mode = "enabled"
+	ModeDisabled Mode = "disabled"
+)
+
+func NewMode(enabled bool) Mode {
+	if enabled {
+		return ModeEnabled
+	}
+	return ModeDisabled
+}
+
+func (m Mode) IsEnabled() bool {
+	return m == ModeEnabled
+}
+
+func (m Mode) isValid() bool {
+	return m == ModeEnabled || m == ModeDisabled
+}
+
+// ModeStatus is the status of the MIG mode of a GPU, including the progress of the change
+// of the MIG mode towards the desired one
+type ModeStatus string
+
+const (
+	// ModeStatusEnabled means that MIG mode is enabled on the GPU
+	ModeStatusEnabled ModeStatus = "enabled"
+	// ModeStatusDisabled means that MIG mode is disabled on the GPU
+	ModeStatusDisabled ModeStatus = "disabled"
+	// ModeStatusDraining means that the Pods using the GPU are being drained before changing its MIG mode
+	ModeStatusDraining ModeStatus = "draining"
+	// ModeStatusPendingReset means that the MIG mode of the GPU has been changed, but the change
+	// takes effect only after the GPU is reset
+	ModeStatusPendingReset ModeStatus = "pending-reset"
+	// ModeStatusFailed means that the MIG mode of the GPU could not be changed
+	ModeStatusFailed ModeStatus = "failed"
+)
+
+// NewModeStatus returns the status corresponding to the MIG mode provided as argument
+func NewModeStatus(mode Mode) ModeStatus {
+	if mode.IsEnabled() {
+		return ModeStatusEnabled
+	}
+	return ModeStatusDisabled
+}
+
+// GetDesiredModes returns the desired MIG mode of the GPUs of the node provided as argument, indexed by GPU index,
+// as specified by its v1alpha1.AnnotationMigModeSpecFormat annotations. Annotations with invalid keys or values
+// are ignored.
+func GetDesiredModes(node v1.Node) map[int]Mode {
+	res := make(map[int]Mode)
+	for k, v := range node.Annotations {
+		index, ok := parseMigModeAnnotationKey(k, v1alpha1.AnnotationMigModeSpecPrefix)
+		if !ok {
+			continue
+		}
+		if mode := Mode(v); mode.isValid() {
+			res[index] = mode
+		}
+	}
+	return res
+}
+
+// GetModeStatuses returns the status of the MIG mode of the GPUs of the node provided as argument, indexed by
+// GPU index, as exposed by its v1alpha1.AnnotationMigModeStatusFormat annotations
+func GetModeStatuses(node v1.Node) map[int]ModeStatus {
+	res := make(map[int]ModeStatus)
+	for k, v := range node.Annotations {
+		if index, ok := parseMigModeAnnotationKey(k, v1alpha1.AnnotationMigModeStatusPrefix); ok {
+			res[index] = ModeStatus(v)
+		}
+	}
+	return res
+}
+
+func parseMigModeAnnotationKey(key string, prefix string) (int, bool) {
+	if !strings.HasPrefix(key, prefix+"-") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(key, prefix+"-"))
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
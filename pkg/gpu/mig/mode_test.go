@@ -0,0 +1,83 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig
+
+import (
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetDesiredModes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    map[int]Mode
+	}{
+		{
+			name:        "No annotations",
+			annotations: nil,
+			expected:    map[int]Mode{},
+		},
+		{
+			name: "Valid annotations",
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-0": "enabled",
+				"nos.nebuly.com/spec-mig-mode-gpu-1": "disabled",
+			},
+			expected: map[int]Mode{
+				0: ModeEnabled,
+				1: ModeDisabled,
+			},
+		},
+		{
+			name: "Invalid annotations are ignored",
+			annotations: map[string]string{
+				"nos.nebuly.com/spec-mig-mode-gpu-0":   "enabled",
+				"nos.nebuly.com/spec-mig-mode-gpu-1":   "true",
+				"nos.nebuly.com/spec-mig-mode-gpu-foo": "enabled",
+				"nos.nebuly.com/spec-mig-mode-gpu--1":  "enabled",
+				"nos.nebuly.com/spec-mig-mode-gpu":     "enabled",
+				"nos.nebuly.com/status-mig-mode-gpu-2": "enabled",
+				"nos.nebuly.com/spec-gpu-0-1g.10gb":    "1",
+			},
+			expected: map[int]Mode{
+				0: ModeEnabled,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).Get()
+			assert.Equal(t, tt.expected, GetDesiredModes(node))
+		})
+	}
+}
+
+func TestGetModeStatuses(t *testing.T) {
+	node := factory.BuildNode("node-1").WithAnnotations(map[string]string{
+		"nos.nebuly.com/status-mig-mode-gpu-0": "enabled",
+		"nos.nebuly.com/status-mig-mode-gpu-1": "pending-reset",
+		"nos.nebuly.com/spec-mig-mode-gpu-2":   "enabled",
+	}).Get()
+	expected := map[int]ModeStatus{
+		0: ModeStatusEnabled,
+		1: ModeStatusPendingReset,
+	}
+	assert.Equal(t, expected, GetModeStatuses(node))
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/nebuly-ai/nos/pkg/gpu"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
 )
 
 type Node struct {
@@ -36,6 +37,10 @@ type Node struct {
 // - GPU product ("nvidia.com/gpu.product")
 // - GPU count ("nvidia.com/gpu.count")
 //
+// The MIG geometries allowed by the GPUs are the ones computed from the MIG placements reported by the
+// mig-agent, if any, and the ones known for the GPU model otherwise (see GetNodeAllowedGeometries).
+// GPUs on which the last partitioning plan failed are backed off (see GetGpuAllowedGeometries).
+//
 // If the v1.Node provided as arg does not have the GPU Product label, returned node will not contain any mig.GPU.
 func NewNode(n framework.NodeInfo) (Node, error) {
 	if n.Node() == nil {
@@ -64,6 +69,14 @@ func NewNode(n framework.NodeInfo) (Node, error) {
 
 func extractGPUs(node v1.Node, gpuModel gpu.Model, gpuCount int) ([]GPU, error) {
 	result := make([]GPU, 0)
+	allowedGeometries, knownGeometries := GetNodeAllowedGeometries(node, gpuModel)
+	var newGPU = func(index int, used, free map[ProfileName]int) (GPU, error) {
+		if !knownGeometries {
+			return GPU{}, fmt.Errorf("model %q is not associated with any known GPU", gpuModel)
+		}
+		geometries := GetGpuAllowedGeometries(node, index, allowedGeometries)
+		return NewGPUWithAllowedGeometries(gpuModel, index, geometries, used, free), nil
+	}
 
 	// Init GPUs from annotation
 	statusAnnotations, _ := gpu.ParseNodeAnnotations(node)
@@ -79,7 +92,7 @@ func extractGPUs(node v1.Node, gpuModel gpu.Model, gpuCount int) ([]GPU, error)
 				freeMigDevices[profileName] = a.Quantity
 			}
 		}
-		g, err := NewGPU(gpuModel, gpuIndex, usedMigDevices, freeMigDevices)
+		g, err := newGPU(gpuIndex, usedMigDevices, freeMigDevices)
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +102,7 @@ func extractGPUs(node v1.Node, gpuModel gpu.Model, gpuCount int) ([]GPU, error)
 	// Add missing GPUs not included in node annotations (e.g. GPUs with MIG enabled but without any MIG device)
 	nGpus := len(result)
 	for i := nGpus; i < gpuCount; i++ {
-		g, err := NewGPU(gpuModel, i, make(map[ProfileName]int), make(map[ProfileName]int))
+		g, err := newGPU(i, make(map[ProfileName]int), make(map[ProfileName]int))
 		if err != nil {
 			return nil, err
 		}
@@ -176,6 +189,83 @@ func (n *Node) UpdateGeometryFor(slices map[gpu.Slice]int) (bool, error) {
 	return anyGpuUpdated, nil
 }
 
+// Compact applies the initial MIG geometry to the GPUs of the node that do not have any used MIG device
+// (see GPU.Compact), and updates the node resources accordingly.
+//
+// The method returns true if it changes the MIG geometry of any GPU, false otherwise.
+func (n *Node) Compact() (bool, error) {
+	var anyGpuCompacted bool
+	for i := range n.GPUs {
+		compacted := n.GPUs[i].Compact()
+		anyGpuCompacted = anyGpuCompacted || compacted
+	}
+	if anyGpuCompacted {
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+	}
+	return anyGpuCompacted, nil
+}
+
+// GetGPUIndexes returns the indexes of the GPUs of the node, sorted in ascending order
+func (n *Node) GetGPUIndexes() []int {
+	res := make([]int, 0, len(n.GPUs))
+	for _, g := range n.GPUs {
+		res = append(res, g.GetIndex())
+	}
+	sort.Ints(res)
+	return res
+}
+
+// GetCandidateGeometries returns the MIG geometries, different from the current one, that can be
+// applied to the GPU with the index provided as argument without deleting any of its used MIG devices.
+func (n *Node) GetCandidateGeometries(gpuIndex int) []gpu.Geometry {
+	res := make([]gpu.Geometry, 0)
+	for _, g := range n.GPUs {
+		if g.GetIndex() != gpuIndex {
+			continue
+		}
+		current := g.GetGeometry()
+		for _, candidate := range g.GetAllowedGeometries() {
+			if sameGeometry(candidate, current) {
+				continue
+			}
+			if canApply, _ := g.CanApplyGeometry(candidate); canApply {
+				res = append(res, candidate)
+			}
+		}
+	}
+	return res
+}
+
+// ApplyGeometry applies the MIG geometry provided as argument to the GPU with the provided index,
+// and updates the node resources accordingly. It returns an error if the node does not have
+// any GPU with the provided index or if the geometry cannot be applied to the GPU.
+func (n *Node) ApplyGeometry(gpuIndex int, geometry gpu.Geometry) error {
+	for i := range n.GPUs {
+		if n.GPUs[i].GetIndex() != gpuIndex {
+			continue
+		}
+		if err := n.GPUs[i].ApplyGeometry(geometry); err != nil {
+			return err
+		}
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+		return nil
+	}
+	return fmt.Errorf("node %s does not have any GPU with index %d", n.Name, gpuIndex)
+}
+
+// sameGeometry returns true if the two geometries provide the same slices, ignoring
+// the slices with zero quantity
+func sameGeometry(a, b gpu.Geometry) bool {
+	for _, pair := range [][2]gpu.Geometry{{a, b}, {b, a}} {
+		for slice, quantity := range pair[0] {
+			if quantity != pair[1][slice] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (n *Node) computeScalarResources() map[v1.ResourceName]int64 {
 	res := make(map[v1.ResourceName]int64)
 
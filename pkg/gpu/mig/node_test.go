@@ -633,3 +633,56 @@ func TestNode__Clone(t *testing.T) {
 	}
 
 }
+
+func TestNode__GetCandidateGeometries(t *testing.T) {
+	node := factory.BuildNode("node").
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct: gpu.GPUModel_A30.String(),
+			constant.LabelNvidiaCount:   "2",
+		}).
+		WithAnnotations(map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, Profile1g6gb, resource.StatusUsed):  "1",
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, Profile1g6gb, resource.StatusFree):  "3",
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, Profile4g24gb, resource.StatusFree): "1",
+		}).
+		Get()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	n, err := NewNode(*nodeInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, n.GetGPUIndexes())
+
+	// GPU 0 has a used 1g.6gb device, so only the other geometries including it can be applied
+	assert.ElementsMatch(
+		t,
+		[]gpu.Geometry{{Profile2g12gb: 1, Profile1g6gb: 2}},
+		n.GetCandidateGeometries(0),
+	)
+	// GPU 1 is free, so all the geometries except the current one can be applied
+	assert.Len(t, n.GetCandidateGeometries(1), len(n.GPUs[1].GetAllowedGeometries())-1)
+	// Unknown GPU
+	assert.Empty(t, n.GetCandidateGeometries(2))
+}
+
+func TestNode__ApplyGeometry(t *testing.T) {
+	node := factory.BuildNode("node").
+		WithLabels(map[string]string{
+			constant.LabelNvidiaProduct: gpu.GPUModel_A30.String(),
+			constant.LabelNvidiaCount:   "1",
+		}).
+		WithAnnotations(map[string]string{
+			fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, Profile4g24gb, resource.StatusFree): "1",
+		}).
+		Get()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	n, err := NewNode(*nodeInfo)
+	assert.NoError(t, err)
+
+	assert.NoError(t, n.ApplyGeometry(0, gpu.Geometry{Profile2g12gb: 2}))
+	assert.Equal(t, map[gpu.Slice]int{Profile2g12gb: 2}, n.Geometry())
+	assert.Equal(t, int64(2), n.NodeInfo().Allocatable.ScalarResources[Profile2g12gb.AsResourceName()])
+
+	assert.Error(t, n.ApplyGeometry(0, gpu.Geometry{Profile2g12gb: 3}))
+	assert.Error(t, n.ApplyGeometry(1, gpu.Geometry{Profile2g12gb: 2}))
+}
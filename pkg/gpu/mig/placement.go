@@ -0,0 +1,350 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// placeableProfileRegex matches only the names of plain GPU instance profiles, excluding for instance the
+// names of the profiles with media extensions (e.g. 1g.10gb+me) and of the compute instance profiles
+// (e.g. 1c.3g.20gb), which are placed through the GPU instance they belong to
+var placeableProfileRegex = regexp.MustCompile(`^\d+g\.\d+gb$`)
+
+// reportedGeometries caches the MIG geometries computed from the values of the
+// v1alpha1.AnnotationMigPlacements node annotation, so that they are not computed again
+// every time a node is snapshotted.
+var reportedGeometries sync.Map
+
+// Placement is a position at which a MIG device can be created on a GPU, expressed as
+// the range of memory slices occupied by the device.
+type Placement struct {
+	Start int `json:"start"`
+	Size  int `json:"size"`
+}
+
+func (p Placement) end() int {
+	return p.Start + p.Size
+}
+
+func (p Placement) overlaps(other Placement) bool {
+	return p.Start < other.end() && other.Start < p.end()
+}
+
+// ProfilePlacements contains, for each MIG profile supported by a GPU, the placements at which
+// the MIG devices of that profile can be created on the GPU.
+type ProfilePlacements map[ProfileName][]Placement
+
+// NewProfilePlacements creates a ProfilePlacements from the GPU instance profiles returned by NVML.
+// Profiles that do not correspond to any valid MIG profile name (e.g. media extension profiles,
+// such as 1g.10gb+me) are ignored.
+func NewProfilePlacements(profiles []nvml.GpuInstanceProfile) ProfilePlacements {
+	res := make(ProfilePlacements)
+	for _, p := range profiles {
+		profileName := ProfileName(p.Name)
+		if !placeableProfileRegex.MatchString(p.Name) || len(p.Placements) == 0 {
+			continue
+		}
+		placements := make([]Placement, 0, len(p.Placements))
+		for _, placement := range p.Placements {
+			placements = append(placements, Placement{Start: placement.Start, Size: placement.Size})
+		}
+		sort.Slice(placements, func(i, j int) bool {
+			return placements[i].Start < placements[j].Start
+		})
+		res[profileName] = placements
+	}
+	return res
+}
+
+// ParseProfilePlacements parses the value of the v1alpha1.AnnotationMigPlacements annotation.
+func ParseProfilePlacements(value string) (ProfilePlacements, error) {
+	var res ProfilePlacements
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no MIG profile placements")
+	}
+	for profile, placements := range res {
+		if !placeableProfileRegex.MatchString(profile.String()) {
+			return nil, fmt.Errorf("invalid MIG profile %q", profile)
+		}
+		for _, p := range placements {
+			if p.Start < 0 || p.Size <= 0 {
+				return nil, fmt.Errorf("invalid placement %+v of MIG profile %q", p, profile)
+			}
+		}
+	}
+	return res, nil
+}
+
+// String returns the representation of the placements used as value of
+// the v1alpha1.AnnotationMigPlacements annotation.
+func (p ProfilePlacements) String() string {
+	// encoding/json sorts map keys, so the output is deterministic
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// GetAllowedGeometries returns the MIG geometries that can be created on a GPU supporting
+// the placements, namely all the combinations of non-overlapping placements that use at most
+// all the compute slices of the GPU and to which no other placement can be added.
+//
+// Geometries are returned in a deterministic order, starting from the ones
+// with the largest MIG profiles.
+func (p ProfilePlacements) GetAllowedGeometries() []gpu.Geometry {
+	type candidate struct {
+		profile   ProfileName
+		placement Placement
+	}
+	candidates := make([]candidate, 0)
+	var memorySlices, computeSlices int
+	for profile, placements := range p {
+		for _, placement := range placements {
+			candidates = append(candidates, candidate{profile: profile, placement: placement})
+			if placement.end() > memorySlices {
+				memorySlices = placement.end()
+			}
+		}
+		if profile.getGiSlices() > computeSlices {
+			computeSlices = profile.getGiSlices()
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].placement.Start != candidates[j].placement.Start {
+			return candidates[i].placement.Start < candidates[j].placement.Start
+		}
+		if candidates[i].placement.Size != candidates[j].placement.Size {
+			return candidates[i].placement.Size > candidates[j].placement.Size
+		}
+		if candidates[i].profile.getGiSlices() != candidates[j].profile.getGiSlices() {
+			return candidates[i].profile.getGiSlices() > candidates[j].profile.getGiSlices()
+		}
+		return candidates[i].profile < candidates[j].profile
+	})
+
+	res := make([]gpu.Geometry, 0)
+	seen := make(map[string]bool)
+	chosen := make([]candidate, 0)
+	var isMaximal = func(usedComputeSlices int) bool {
+		for _, c := range candidates {
+			if usedComputeSlices+c.profile.getGiSlices() > computeSlices {
+				continue
+			}
+			fits := true
+			for _, other := range chosen {
+				if c.placement.overlaps(other.placement) {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				return false
+			}
+		}
+		return true
+	}
+	// Visit the memory slices in order: each free slice can either be left empty
+	// or be occupied by any placement starting at that slice
+	var visit func(slice int, usedComputeSlices int)
+	visit = func(slice int, usedComputeSlices int) {
+		if slice >= memorySlices {
+			if len(chosen) == 0 || !isMaximal(usedComputeSlices) {
+				return
+			}
+			geometry := make(gpu.Geometry)
+			for _, c := range chosen {
+				geometry[c.profile]++
+			}
+			if key := fmt.Sprint(geometry); !seen[key] {
+				seen[key] = true
+				res = append(res, geometry)
+			}
+			return
+		}
+		for _, c := range candidates {
+			if c.placement.Start != slice {
+				continue
+			}
+			if usedComputeSlices+c.profile.getGiSlices() > computeSlices {
+				continue
+			}
+			chosen = append(chosen, c)
+			visit(c.placement.end(), usedComputeSlices+c.profile.getGiSlices())
+			chosen = chosen[:len(chosen)-1]
+		}
+		visit(slice+1, usedComputeSlices)
+	}
+	visit(0, 0)
+
+	return res
+}
+
+// GetNodeAllowedGeometries returns the MIG geometries allowed by the GPUs of the node provided as argument.
+//
+// If the node exposes the MIG placements discovered by the mig-agent through the v1alpha1.AnnotationMigPlacements
+// annotation, the geometries are computed from such placements. Otherwise, the function falls back to the
+// geometries known for the GPU model provided as argument (see GetAllowedGeometries).
+//
+// The function returns false if the allowed geometries cannot be determined.
+func GetNodeAllowedGeometries(node v1.Node, model gpu.Model) ([]gpu.Geometry, bool) {
+	if value, ok := node.Annotations[v1alpha1.AnnotationMigPlacements]; ok {
+		if geometries, ok := reportedGeometries.Load(value); ok {
+			return geometries.([]gpu.Geometry), true
+		}
+		if placements, err := ParseProfilePlacements(value); err == nil {
+			if geometries := placements.GetAllowedGeometries(); len(geometries) > 0 {
+				reportedGeometries.Store(value, geometries)
+				return geometries, true
+			}
+		}
+	}
+	return GetAllowedGeometries(model)
+}
+
+// PlacedProfile is a MIG device of a certain profile created, or to be created, at a certain placement
+type PlacedProfile struct {
+	Name      ProfileName
+	Placement Placement
+}
+
+// FindPlacements returns the placements at which the MIG devices of the profiles provided as argument can be
+// created on a GPU supporting the placements p, given the devices that already occupy the GPU.
+//
+// Among all the valid assignments, FindPlacements returns the one that leaves on the GPU the largest contiguous
+// range of free memory slices, so that larger devices can be created later on. The returned placements are in
+// the same order of the profiles provided as argument.
+//
+// The function returns false if the profiles cannot be placed on the GPU.
+func (p ProfilePlacements) FindPlacements(occupied []PlacedProfile, profiles []ProfileName) ([]Placement, bool) {
+	var memorySlices, computeSlices int
+	for profile, placements := range p {
+		for _, placement := range placements {
+			if placement.end() > memorySlices {
+				memorySlices = placement.end()
+			}
+		}
+		if profile.getGiSlices() > computeSlices {
+			computeSlices = profile.getGiSlices()
+		}
+	}
+	usedComputeSlices := 0
+	for _, o := range occupied {
+		usedComputeSlices += o.Name.getGiSlices()
+	}
+
+	// Place larger profiles first, since they have fewer valid placements
+	order := make([]int, len(profiles))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		pi, pj := profiles[order[i]], profiles[order[j]]
+		if pi.getGiSlices() != pj.getGiSlices() {
+			return pi.getGiSlices() > pj.getGiSlices()
+		}
+		return pi < pj
+	})
+
+	chosen := make([]Placement, len(profiles))
+	var best []Placement
+	var bestFreeRange = -1
+	var visit func(i int, usedComputeSlices int)
+	visit = func(i int, usedComputeSlices int) {
+		if i == len(order) {
+			allPlacements := make([]Placement, 0, len(occupied)+len(chosen))
+			for _, o := range occupied {
+				allPlacements = append(allPlacements, o.Placement)
+			}
+			allPlacements = append(allPlacements, chosen...)
+			if freeRange := largestFreeRange(allPlacements, memorySlices); freeRange > bestFreeRange {
+				bestFreeRange = freeRange
+				best = make([]Placement, len(chosen))
+				copy(best, chosen)
+			}
+			return
+		}
+		profile := profiles[order[i]]
+		if usedComputeSlices+profile.getGiSlices() > computeSlices {
+			return
+		}
+		for _, candidate := range p[profile] {
+			// Devices of the same profile are placed in increasing order, so that
+			// equivalent assignments are not visited more than once
+			if i > 0 && profiles[order[i-1]] == profile && candidate.Start <= chosen[order[i-1]].Start {
+				continue
+			}
+			if overlapsAny(candidate, occupied, chosen, order[:i]) {
+				continue
+			}
+			chosen[order[i]] = candidate
+			visit(i+1, usedComputeSlices+profile.getGiSlices())
+		}
+	}
+	visit(0, usedComputeSlices)
+
+	if best == nil {
+		return nil, false
+	}
+	return best, true
+}
+
+func overlapsAny(placement Placement, occupied []PlacedProfile, chosen []Placement, chosenIndexes []int) bool {
+	for _, o := range occupied {
+		if placement.overlaps(o.Placement) {
+			return true
+		}
+	}
+	for _, i := range chosenIndexes {
+		if placement.overlaps(chosen[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// largestFreeRange returns the length of the largest contiguous range of memory slices
+// not occupied by any of the placements provided as argument
+func largestFreeRange(placements []Placement, memorySlices int) int {
+	occupied := make([]bool, memorySlices)
+	for _, p := range placements {
+		for s := p.Start; s < p.end() && s < memorySlices; s++ {
+			occupied[s] = true
+		}
+	}
+	var largest, current int
+	for _, o := range occupied {
+		if o {
+			current = 0
+			continue
+		}
+		current++
+		if current > largest {
+			largest = current
+		}
+	}
+	return largest
+}
@@ -0,0 +1,316 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig_test
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+var (
+	a30Placements = mig.ProfilePlacements{
+		mig.Profile1g6gb:  {{Start: 0, Size: 1}, {Start: 1, Size: 1}, {Start: 2, Size: 1}, {Start: 3, Size: 1}},
+		mig.Profile2g12gb: {{Start: 0, Size: 2}, {Start: 2, Size: 2}},
+		mig.Profile4g24gb: {{Start: 0, Size: 4}},
+	}
+	a100Placements = mig.ProfilePlacements{
+		mig.Profile1g5gb: {
+			{Start: 0, Size: 1},
+			{Start: 1, Size: 1},
+			{Start: 2, Size: 1},
+			{Start: 3, Size: 1},
+			{Start: 4, Size: 1},
+			{Start: 5, Size: 1},
+			{Start: 6, Size: 1},
+		},
+		mig.Profile2g10gb: {{Start: 0, Size: 2}, {Start: 2, Size: 2}, {Start: 4, Size: 2}},
+		mig.Profile3g20gb: {{Start: 0, Size: 4}, {Start: 4, Size: 4}},
+		mig.Profile4g20gb: {{Start: 0, Size: 4}},
+		mig.Profile7g40gb: {{Start: 0, Size: 8}},
+	}
+)
+
+func TestNewProfilePlacements(t *testing.T) {
+	profiles := []nvml.GpuInstanceProfile{
+		{
+			Name:       "1g.5gb",
+			Placements: []nvml.GpuInstancePlacement{{Start: 1, Size: 1}, {Start: 0, Size: 1}},
+		},
+		{
+			Name:       "1g.5gb+me",
+			Placements: []nvml.GpuInstancePlacement{{Start: 0, Size: 1}},
+		},
+		{
+			Name:       "7g.40gb",
+			Placements: []nvml.GpuInstancePlacement{{Start: 0, Size: 8}},
+		},
+		{
+			Name:       "3g.20gb",
+			Placements: []nvml.GpuInstancePlacement{},
+		},
+	}
+	expected := mig.ProfilePlacements{
+		mig.Profile1g5gb:  {{Start: 0, Size: 1}, {Start: 1, Size: 1}},
+		mig.Profile7g40gb: {{Start: 0, Size: 8}},
+	}
+	assert.Equal(t, expected, mig.NewProfilePlacements(profiles))
+}
+
+func TestParseProfilePlacements(t *testing.T) {
+	testCases := []struct {
+		name          string
+		value         string
+		expected      mig.ProfilePlacements
+		expectedError bool
+	}{
+		{
+			name:          "Invalid JSON",
+			value:         "not-a-json",
+			expectedError: true,
+		},
+		{
+			name:          "Empty placements",
+			value:         "{}",
+			expectedError: true,
+		},
+		{
+			name:          "Invalid MIG profile",
+			value:         `{"1g.5gb+me":[{"start":0,"size":1}]}`,
+			expectedError: true,
+		},
+		{
+			name:          "Invalid placement size",
+			value:         `{"1g.5gb":[{"start":0,"size":0}]}`,
+			expectedError: true,
+		},
+		{
+			name:  "Valid placements",
+			value: `{"1g.5gb":[{"start":0,"size":1},{"start":1,"size":1}],"7g.40gb":[{"start":0,"size":8}]}`,
+			expected: mig.ProfilePlacements{
+				mig.Profile1g5gb:  {{Start: 0, Size: 1}, {Start: 1, Size: 1}},
+				mig.Profile7g40gb: {{Start: 0, Size: 8}},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			placements, err := mig.ParseProfilePlacements(tt.value)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, placements)
+			assert.Equal(t, tt.value, placements.String())
+		})
+	}
+}
+
+func TestProfilePlacements__GetAllowedGeometries(t *testing.T) {
+	t.Run("Placements of A30 result in the known A30 geometries", func(t *testing.T) {
+		known, ok := mig.GetAllowedGeometries(gpu.GPUModel_A30)
+		assert.True(t, ok)
+		geometries := a30Placements.GetAllowedGeometries()
+		assert.ElementsMatch(t, known, geometries)
+		assert.Equal(t, gpu.Geometry{mig.Profile4g24gb: 1}, geometries[0])
+	})
+
+	t.Run("Placements of A100 include all the known A100 geometries", func(t *testing.T) {
+		known, ok := mig.GetAllowedGeometries(gpu.GPUModel_A100_SXM4_40GB)
+		assert.True(t, ok)
+		geometries := a100Placements.GetAllowedGeometries()
+		for _, g := range known {
+			assert.Contains(t, geometries, g)
+		}
+	})
+
+	t.Run("Geometries never exceed the compute slices of the GPU", func(t *testing.T) {
+		for _, g := range a100Placements.GetAllowedGeometries() {
+			var computeSlices int
+			for p, q := range g {
+				var gi int
+				_, err := fmt.Sscanf(p.String(), "%dg", &gi)
+				assert.NoError(t, err)
+				computeSlices += gi * q
+			}
+			assert.LessOrEqual(t, computeSlices, 7, g)
+		}
+	})
+
+	t.Run("Geometries are deterministic", func(t *testing.T) {
+		expected := a100Placements.GetAllowedGeometries()
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, expected, a100Placements.GetAllowedGeometries())
+		}
+	})
+
+	t.Run("Empty placements", func(t *testing.T) {
+		assert.Empty(t, mig.ProfilePlacements{}.GetAllowedGeometries())
+	})
+}
+
+func TestNewNode__ReportedPlacements(t *testing.T) {
+	testCases := []struct {
+		name               string
+		model              gpu.Model
+		annotations        map[string]string
+		expectedGeometries []gpu.Geometry
+		expectedError      bool
+	}{
+		{
+			name:          "Unknown model without reported placements",
+			model:         "unknown-gpu-model",
+			expectedError: true,
+		},
+		{
+			name:  "Unknown model with reported placements",
+			model: "unknown-gpu-model",
+			annotations: map[string]string{
+				v1alpha1.AnnotationMigPlacements: a30Placements.String(),
+			},
+			expectedGeometries: a30Placements.GetAllowedGeometries(),
+		},
+		{
+			name:  "Reported placements take precedence over known geometries",
+			model: gpu.GPUModel_A100_SXM4_40GB,
+			annotations: map[string]string{
+				v1alpha1.AnnotationMigPlacements: a30Placements.String(),
+			},
+			expectedGeometries: a30Placements.GetAllowedGeometries(),
+		},
+		{
+			name:  "Invalid reported placements fall back to known geometries",
+			model: gpu.GPUModel_A30,
+			annotations: map[string]string{
+				v1alpha1.AnnotationMigPlacements: "invalid",
+			},
+			expectedGeometries: mig.GetKnownGeometries()[gpu.GPUModel_A30],
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			n := factory.BuildNode("node-1").
+				WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: tt.model.String(),
+					constant.LabelNvidiaCount:   "2",
+				}).
+				WithAnnotations(tt.annotations).
+				Get()
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&n)
+
+			node, err := mig.NewNode(*nodeInfo)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, node.GPUs, 2)
+			for _, g := range node.GPUs {
+				assert.Equal(t, tt.expectedGeometries, g.GetAllowedGeometries())
+			}
+		})
+	}
+}
+
+func TestProfilePlacements__FindPlacements(t *testing.T) {
+	testCases := []struct {
+		name               string
+		occupied           []mig.PlacedProfile
+		profiles           []mig.ProfileName
+		expectedPlacements []mig.Placement
+		expectedOk         bool
+	}{
+		{
+			name:               "Empty GPU, no profiles",
+			profiles:           []mig.ProfileName{},
+			expectedPlacements: []mig.Placement{},
+			expectedOk:         true,
+		},
+		{
+			name:     "Empty GPU, devices are packed to leave the largest free range",
+			profiles: []mig.ProfileName{mig.Profile1g5gb, mig.Profile1g5gb},
+			expectedPlacements: []mig.Placement{
+				{Start: 0, Size: 1},
+				{Start: 1, Size: 1},
+			},
+			expectedOk: true,
+		},
+		{
+			name:     "Placements are returned in the order of the profiles",
+			profiles: []mig.ProfileName{mig.Profile1g5gb, mig.Profile3g20gb},
+			expectedPlacements: []mig.Placement{
+				{Start: 4, Size: 1},
+				{Start: 0, Size: 4},
+			},
+			expectedOk: true,
+		},
+		{
+			name: "Occupied slices are not used",
+			occupied: []mig.PlacedProfile{
+				{Name: mig.Profile1g5gb, Placement: mig.Placement{Start: 3, Size: 1}},
+			},
+			profiles: []mig.ProfileName{mig.Profile3g20gb, mig.Profile2g10gb},
+			expectedPlacements: []mig.Placement{
+				{Start: 4, Size: 4},
+				{Start: 0, Size: 2},
+			},
+			expectedOk: true,
+		},
+		{
+			name: "Profiles do not fit the free slices",
+			occupied: []mig.PlacedProfile{
+				{Name: mig.Profile1g5gb, Placement: mig.Placement{Start: 0, Size: 1}},
+				{Name: mig.Profile1g5gb, Placement: mig.Placement{Start: 4, Size: 1}},
+			},
+			profiles:   []mig.ProfileName{mig.Profile3g20gb},
+			expectedOk: false,
+		},
+		{
+			name: "Profiles exceed the compute slices",
+			occupied: []mig.PlacedProfile{
+				{Name: mig.Profile4g20gb, Placement: mig.Placement{Start: 0, Size: 4}},
+				{Name: mig.Profile2g10gb, Placement: mig.Placement{Start: 4, Size: 2}},
+			},
+			profiles:   []mig.ProfileName{mig.Profile2g10gb},
+			expectedOk: false,
+		},
+		{
+			name:       "Profile not supported by the GPU",
+			profiles:   []mig.ProfileName{mig.Profile1g10gb},
+			expectedOk: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			placements, ok := a100Placements.FindPlacements(tt.occupied, tt.profiles)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.expectedPlacements, placements)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig
+
+import (
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+)
+
+// GetGpuAllowedGeometries returns the MIG geometries that can be applied to the GPU of the node with the index
+// provided as argument, starting from the geometries allowed by its model.
+//
+// If the last partitioning plan applied to the node failed on the GPU, the GPU is excluded from partitioning
+// until constant.DefaultPlanFailureBackoff has elapsed since the failure, so no geometry is returned.
+// After that, the partitioning of the GPU is retried with any of the allowed geometries except the one
+// that failed, which is the geometry still specified for the GPU by the spec annotations of the node.
+func GetGpuAllowedGeometries(node v1.Node, gpuIndex int, geometries []gpu.Geometry) []gpu.Geometry {
+	status, ok := gpu.GetLastPlanStatus(node)
+	if !ok || !status.IsFailed() || !util.InSlice(gpuIndex, status.GetFailedGpuIndexes()) {
+		return geometries
+	}
+	if time.Since(status.Time) < constant.DefaultPlanFailureBackoff {
+		return []gpu.Geometry{}
+	}
+	failed := getSpecGeometry(node, gpuIndex)
+	return util.Filter(geometries, func(g gpu.Geometry) bool {
+		return !sameGeometry(g, failed)
+	})
+}
+
+// getSpecGeometry returns the MIG geometry specified for the GPU with the provided index
+// by the spec annotations of the node
+func getSpecGeometry(node v1.Node, gpuIndex int) gpu.Geometry {
+	res := make(gpu.Geometry)
+	_, specAnnotations := gpu.ParseNodeAnnotations(node)
+	for _, a := range specAnnotations {
+		profile := ProfileName(a.ProfileName)
+		if a.Index != gpuIndex || !profile.isValid() {
+			continue
+		}
+		res[profile] += a.Quantity
+	}
+	return res
+}
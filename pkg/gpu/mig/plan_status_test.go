@@ -0,0 +1,108 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mig_test
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetGpuAllowedGeometries(t *testing.T) {
+	geometries := []gpu.Geometry{
+		{mig.Profile4g24gb: 1},
+		{mig.Profile2g12gb: 2},
+		{mig.Profile1g6gb: 4},
+	}
+	planId := gpu.NewPlanId(gpu.PartitioningKindMig)
+	failedOnGpu0 := []gpu.PlanOperationResult{
+		{Type: gpu.PlanOperationCreate, GpuIndex: 0, Profile: "2g.12gb", Quantity: 2, Error: "err"},
+	}
+	newStatus := func(failed []gpu.PlanOperationResult, age time.Duration) string {
+		status := gpu.NewPlanStatus(planId, nil, failed)
+		status.Time = time.Now().Add(-age).UTC()
+		return status.String()
+	}
+	specAnnotations := map[string]string{
+		v1alpha1.AnnotationPartitioningPlan:                                 planId.String(),
+		fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 0, mig.Profile2g12gb): "2",
+		fmt.Sprintf(v1alpha1.AnnotationGpuSpecFormat, 1, mig.Profile1g6gb):  "4",
+	}
+	withStatus := func(status string) map[string]string {
+		res := make(map[string]string)
+		for k, v := range specAnnotations {
+			res[k] = v
+		}
+		res[v1alpha1.AnnotationPartitioningPlanStatus] = status
+		return res
+	}
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		gpuIndex    int
+		expected    []gpu.Geometry
+	}{
+		{
+			name:        "No plan status, all geometries are allowed",
+			annotations: specAnnotations,
+			gpuIndex:    0,
+			expected:    geometries,
+		},
+		{
+			name:        "Plan applied, all geometries are allowed",
+			annotations: withStatus(newStatus(nil, time.Second)),
+			gpuIndex:    0,
+			expected:    geometries,
+		},
+		{
+			name:        "Plan failed on another GPU, all geometries are allowed",
+			annotations: withStatus(newStatus(failedOnGpu0, time.Second)),
+			gpuIndex:    1,
+			expected:    geometries,
+		},
+		{
+			name:        "Plan failed on the GPU within the backoff, no geometry is allowed",
+			annotations: withStatus(newStatus(failedOnGpu0, time.Second)),
+			gpuIndex:    0,
+			expected:    []gpu.Geometry{},
+		},
+		{
+			name:        "Plan failed on the GPU after the backoff, the failed geometry is not allowed",
+			annotations: withStatus(newStatus(failedOnGpu0, constant.DefaultPlanFailureBackoff+time.Minute)),
+			gpuIndex:    0,
+			expected: []gpu.Geometry{
+				{mig.Profile4g24gb: 1},
+				{mig.Profile1g6gb: 4},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).Get()
+			res := mig.GetGpuAllowedGeometries(node, tt.gpuIndex, geometries)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
@@ -44,10 +44,26 @@ const (
 	Profile3g40gb ProfileName = "3g.40gb"
 	Profile4g40gb ProfileName = "4g.40gb"
 	Profile7g79gb ProfileName = "7g.79gb"
+
+	Profile7g80gb ProfileName = "7g.80gb"
+
+	Profile1g12gb ProfileName = "1g.12gb"
+	Profile2g24gb ProfileName = "2g.24gb"
+	Profile3g47gb ProfileName = "3g.47gb"
+	Profile4g47gb ProfileName = "4g.47gb"
+	Profile7g94gb ProfileName = "7g.94gb"
+
+	Profile1c4g24gb ProfileName = "1c.4g.24gb"
+
+	Profile1c3g20gb ProfileName = "1c.3g.20gb"
+	Profile1c4g20gb ProfileName = "1c.4g.20gb"
+	Profile2c4g20gb ProfileName = "2c.4g.20gb"
+	Profile1c7g40gb ProfileName = "1c.7g.40gb"
 )
 
 var (
 	migProfileRegex = regexp.MustCompile(constant.RegexNvidiaMigProfile)
+	migCiRegex      = regexp.MustCompile(`^(\d+)c\.(\d+g\.\d+gb)$`)
 	migGiRegex      = regexp.MustCompile(`\d+g`)
 	migMemoryRegex  = regexp.MustCompile(`\d+gb`)
 )
@@ -81,6 +97,49 @@ func (p ProfileName) getGiSlices() int {
 	return asInt
 }
 
+// IsComputeInstanceProfile returns true if the profile is the profile of a compute instance that shares
+// its GPU instance with other compute instances (e.g. 1c.3g.20gb), rather than spanning the whole
+// GPU instance (e.g. 3g.20gb).
+func (p ProfileName) IsComputeInstanceProfile() bool {
+	return migCiRegex.MatchString(string(p))
+}
+
+// GetGpuInstanceProfile returns the profile of the GPU instance to which the devices of the profile belong.
+// For plain MIG profiles, it returns the profile itself.
+//
+// Example:
+//
+//	1c.3g.20gb => 3g.20gb
+//	3g.20gb => 3g.20gb
+func (p ProfileName) GetGpuInstanceProfile() ProfileName {
+	if matches := migCiRegex.FindStringSubmatch(string(p)); matches != nil {
+		return ProfileName(matches[2])
+	}
+	return p
+}
+
+// GetDevicesPerGpuInstance returns the number of devices of the profile that fit in a single GPU instance,
+// namely the number of compute instances of the profile that share the same GPU instance. For plain
+// MIG profiles it returns 1, while it returns 0 for compute instance profiles that are not valid
+// (e.g. 4c.3g.20gb).
+func (p ProfileName) GetDevicesPerGpuInstance() int {
+	ciSlices := p.getCiSlices()
+	if ciSlices <= 0 {
+		return 0
+	}
+	return p.getGiSlices() / ciSlices
+}
+
+// getCiSlices returns the compute slices of the compute instance of the profile
+func (p ProfileName) getCiSlices() int {
+	matches := migCiRegex.FindStringSubmatch(string(p))
+	if matches == nil {
+		return p.getGiSlices()
+	}
+	asInt, _ := strconv.Atoi(matches[1])
+	return asInt
+}
+
 func (p ProfileName) SmallerThan(other gpu.Slice) bool {
 	otherMig, ok := other.(ProfileName)
 	if !ok {
@@ -92,12 +151,23 @@ func (p ProfileName) SmallerThan(other gpu.Slice) bool {
 	if p.getGiSlices() < otherMig.getGiSlices() {
 		return true
 	}
+	if p.getCiSlices() < otherMig.getCiSlices() {
+		return true
+	}
 	return false
 }
 
 type Profile struct {
 	GpuIndex int
 	Name     ProfileName
+	// Placement is the placement at which the MIG device of the profile has to be created.
+	// The zero value means that the device can be created at any placement.
+	Placement Placement
+}
+
+// HasPlacement returns true if the MIG device of the profile has to be created at a specific placement
+func (p Profile) HasPlacement() bool {
+	return p.Placement.Size > 0
 }
 
 type ProfileList []Profile
@@ -112,3 +182,16 @@ func (p ProfileList) GroupByGPU() map[int]ProfileList {
 	}
 	return res
 }
+
+// HavePlacements returns true if all the profiles of the list have to be created at a specific placement
+func (p ProfileList) HavePlacements() bool {
+	if len(p) == 0 {
+		return false
+	}
+	for _, profile := range p {
+		if !profile.HasPlacement() {
+			return false
+		}
+	}
+	return true
+}
@@ -27,6 +27,72 @@ func TestProfileName__getMemorySlices(t *testing.T) {
 
 func TestProfileName__getGiSlices(t *testing.T) {
 	assert.Equal(t, 3, Profile3g20gb.getGiSlices())
+	assert.Equal(t, 3, Profile1c3g20gb.getGiSlices())
+}
+
+func TestProfileName__ComputeInstanceProfiles(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		profile              ProfileName
+		expectedValid        bool
+		expectedIsCiProfile  bool
+		expectedGiProfile    ProfileName
+		expectedDevicesPerGi int
+		expectedMemorySlices int
+	}{
+		{
+			name:                 "Plain MIG profile",
+			profile:              Profile3g20gb,
+			expectedValid:        true,
+			expectedIsCiProfile:  false,
+			expectedGiProfile:    Profile3g20gb,
+			expectedDevicesPerGi: 1,
+			expectedMemorySlices: 20,
+		},
+		{
+			name:                 "Compute instance profile",
+			profile:              Profile1c3g20gb,
+			expectedValid:        true,
+			expectedIsCiProfile:  true,
+			expectedGiProfile:    Profile3g20gb,
+			expectedDevicesPerGi: 3,
+			expectedMemorySlices: 20,
+		},
+		{
+			name:                 "Compute instance profile whose compute slices do not divide the GPU instance",
+			profile:              "2c.7g.40gb",
+			expectedValid:        true,
+			expectedIsCiProfile:  true,
+			expectedGiProfile:    Profile7g40gb,
+			expectedDevicesPerGi: 3,
+			expectedMemorySlices: 40,
+		},
+		{
+			name:                 "Compute instance larger than its GPU instance",
+			profile:              "4c.3g.20gb",
+			expectedValid:        true,
+			expectedIsCiProfile:  true,
+			expectedGiProfile:    Profile3g20gb,
+			expectedDevicesPerGi: 0,
+			expectedMemorySlices: 20,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedValid, tt.profile.isValid())
+			assert.Equal(t, tt.expectedIsCiProfile, tt.profile.IsComputeInstanceProfile())
+			assert.Equal(t, tt.expectedGiProfile, tt.profile.GetGpuInstanceProfile())
+			assert.Equal(t, tt.expectedDevicesPerGi, tt.profile.GetDevicesPerGpuInstance())
+			assert.Equal(t, tt.expectedMemorySlices, tt.profile.getMemorySlices())
+		})
+	}
+}
+
+func TestProfileName__SmallerThan(t *testing.T) {
+	assert.True(t, Profile1c3g20gb.SmallerThan(Profile3g20gb))
+	assert.False(t, Profile3g20gb.SmallerThan(Profile1c3g20gb))
+	assert.True(t, Profile1g5gb.SmallerThan(Profile1c3g20gb))
 }
 
 func TestProfileList__GroupByGpuIndex(t *testing.T) {
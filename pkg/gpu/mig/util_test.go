@@ -52,6 +52,11 @@ func TestIsNvidiaMigDevice(t *testing.T) {
 			resourceName: "nvidia.com/mig-1g.1gb",
 			expected:     true,
 		},
+		{
+			name:         "Valid NVIDIA MIG of compute instance profile",
+			resourceName: "nvidia.com/mig-1c.3g.20gb",
+			expected:     true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -183,3 +188,28 @@ func TestSpecMatchesStatusAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestIsMigStatusAnnotation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		annotation gpu.StatusAnnotation
+		expected   bool
+	}{
+		{
+			name:       "MIG profile",
+			annotation: gpu.StatusAnnotation{ProfileName: "1g.10gb", Index: 0, Status: resource.StatusFree, Quantity: 1},
+			expected:   true,
+		},
+		{
+			name:       "Slicing profile",
+			annotation: gpu.StatusAnnotation{ProfileName: "10gb", Index: 0, Status: resource.StatusFree, Quantity: 1},
+			expected:   false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsMigStatusAnnotation(tt.annotation))
+		})
+	}
+}
@@ -25,5 +25,11 @@ func (m Model) String() string {
 const (
 	GPUModel_A30            Model = "A30"
 	GPUModel_A100_SXM4_40GB Model = "NVIDIA-A100-40GB-SXM4"
+	GPUModel_A100_PCIe_40GB Model = "NVIDIA-A100-PCIE-40GB"
 	GPUModel_A100_PCIe_80GB Model = "NVIDIA-A100-80GB-PCIe"
+	GPUModel_A800_SXM4_80GB Model = "NVIDIA-A800-SXM4-80GB"
+	GPUModel_A800_PCIe_80GB Model = "NVIDIA-A800-80GB-PCIe"
+	GPUModel_H100_SXM5_80GB Model = "NVIDIA-H100-80GB-HBM3"
+	GPUModel_H100_PCIe_80GB Model = "NVIDIA-H100-PCIe"
+	GPUModel_H100_NVL       Model = "NVIDIA-H100-NVL"
 )
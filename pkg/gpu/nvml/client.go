@@ -26,6 +26,7 @@ import (
 	"github.com/nebuly-ai/nos/pkg/util"
 	nvlibdevice "gitlab.com/nvidia/cloud-native/go-nvlib/pkg/nvlib/device"
 	nvlibNvml "gitlab.com/nvidia/cloud-native/go-nvlib/pkg/nvml"
+	"strings"
 )
 
 type clientImpl struct {
@@ -188,9 +189,22 @@ func (c *clientImpl) DeleteMigDevice(id string) gpu.Error {
 		return gpu.GenericErr.Errorf("error getting GPU Instance %d: %s", giId, ret.Error())
 	}
 
-	// Delete Compute Instances
-	var numVisitedCi uint8
+	ciId, ret := d.GetComputeInstanceId()
+	if ret != nvlibNvml.SUCCESS {
+		return gpu.GenericErr.Errorf("error getting compute instance ID: %s", ret.Error())
+	}
+
+	// Delete the Compute Instance of the MIG device, which might share the GPU Instance with other ones
+	var numVisitedCi, numRemainingCi uint8
 	err := visitComputeInstances(gi, func(ci nvlibNvml.ComputeInstance, ciProfileId int, ciEngProfileId int, ciProfileInfo nvlibNvml.ComputeInstanceProfileInfo) error {
+		ciInfo, r := ci.GetInfo()
+		if r != nvlibNvml.SUCCESS {
+			return gpu.GenericErr.Errorf("error getting compute instance info: %s", r.Error())
+		}
+		if int(ciInfo.Id) != ciId {
+			numRemainingCi++
+			return nil
+		}
 		numVisitedCi++
 		c.logger.V(1).Info(
 			"deleting compute instance",
@@ -213,7 +227,11 @@ func (c *clientImpl) DeleteMigDevice(id string) gpu.Error {
 		return gpu.GenericErr.Errorf("cannot delete %s: the device does not have any compute instance associated", id)
 	}
 
-	// Delete GPU Instance
+	// Delete GPU Instance, unless other MIG devices still use it
+	if numRemainingCi > 0 {
+		c.logger.V(1).Info("GPU instance still has compute instances, not deleting it", "computeInstances", numRemainingCi)
+		return nil
+	}
 	c.logger.V(1).Info("deleting GPU instance")
 	if ret = gi.Destroy(); ret != nvlibNvml.SUCCESS {
 		return gpu.GenericErr.Errorf("error deleting GPU instance: %s", ret.Error())
@@ -283,21 +301,51 @@ func (c *clientImpl) CreateMigDevices(migProfileNames []string, gpuIndex int) gp
 		return nil
 	}
 
+	// Add the compute instances of the profiles sharing a GPU instance (e.g. 1c.3g.20gb) to the existing
+	// GPU instances with spare capacity, and pack the remaining ones into the fewest new GPU instances
+	giRequests := make([]gpuInstanceRequest, 0)
+	sharedProfiles := make([]nvlibdevice.MigProfile, 0)
+	sharedQuantities := make(map[string]int)
+	for _, mp := range mps {
+		if mp.GetInfo().C == mp.GetInfo().G {
+			giRequests = append(giRequests, gpuInstanceRequest{profile: mp, computeInstances: 1})
+			continue
+		}
+		if sharedQuantities[mp.String()] == 0 {
+			sharedProfiles = append(sharedProfiles, mp)
+		}
+		sharedQuantities[mp.String()]++
+	}
+	addedCIs := make([]nvlibNvml.ComputeInstance, 0)
+	for _, mp := range sharedProfiles {
+		added, err := c.addComputeInstances(device, mp, sharedQuantities[mp.String()])
+		addedCIs = append(addedCIs, added...)
+		if err != nil {
+			_ = cleanup(nil, addedCIs)
+			return gpu.NewGenericError(err)
+		}
+		devicesPerGi := mp.GetInfo().G / mp.GetInfo().C
+		for remaining := sharedQuantities[mp.String()] - len(added); remaining > 0; remaining -= devicesPerGi {
+			giRequests = append(giRequests, gpuInstanceRequest{profile: mp, computeInstances: util.Min(devicesPerGi, remaining)})
+		}
+	}
+
 	// Iterate permutations until success
 	// (MIG profile creation success depends on the order on which they are created)
 	var anyPermutationApplied bool
 	var nAttempts int
 	var maxAttempts = 20
-	err = util.IterPermutations(mps, func(mps []nvlibdevice.MigProfile) (bool, error) {
+	err = util.IterPermutations(giRequests, func(perm []gpuInstanceRequest) (bool, error) {
 		// TODO: optimize permutation search instead of trying all of them and limiting the max attempts
 		if nAttempts > maxAttempts {
 			return false, fmt.Errorf("could not find a valid permutation for creating MIG profiles: too many attempts")
 		}
-		c.logger.V(1).Info("trying to create MIG profiles", "permutation", mps)
+		c.logger.V(1).Info("trying to create MIG profiles", "permutation", perm)
 		nAttempts++
 		createdGIs := make([]nvlibNvml.GpuInstance, 0)
 		createdCIs := make([]nvlibNvml.ComputeInstance, 0)
-		for _, mp := range mps {
+		for _, req := range perm {
+			mp := req.profile
 			// Create GPU Instance
 			giProfileInfo, ret := device.GetGpuInstanceProfileInfo(mp.GetInfo().GIProfileID)
 			if ret != nvlibNvml.SUCCESS {
@@ -311,34 +359,216 @@ func (c *clientImpl) CreateMigDevices(migProfileNames []string, gpuIndex int) gp
 			c.logger.V(1).Info("created GPU Instance", "GpuInstanceID", mp.GetInfo().GIProfileID)
 			createdGIs = append(createdGIs, gi)
 
-			// Create Compute Instance
+			// Create Compute Instances
 			ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(mp.GetInfo().CIProfileID, mp.GetInfo().CIEngProfileID)
 			if ret != nvlibNvml.SUCCESS {
 				return false, gpu.GenericErr.Errorf("error getting compute instance profile info: %s", ret.Error())
 			}
-			ci, ret := gi.CreateComputeInstance(&ciProfileInfo)
-			if ret != nvlibNvml.SUCCESS {
-				c.logger.V(1).Info("could not create compute instance", "error", ret.Error())
-				return true, cleanup(createdGIs, createdCIs)
+			for i := 0; i < req.computeInstances; i++ {
+				ci, ret := gi.CreateComputeInstance(&ciProfileInfo)
+				if ret != nvlibNvml.SUCCESS {
+					c.logger.V(1).Info("could not create compute instance", "error", ret.Error())
+					return true, cleanup(createdGIs, createdCIs)
+				}
+				c.logger.V(1).Info("created compute Instance", "ComputeInstanceId", mp.GetInfo().CIProfileID)
+				createdCIs = append(createdCIs, ci)
 			}
-			c.logger.V(1).Info("created compute Instance", "ComputeInstanceId", mp.GetInfo().CIProfileID)
-			createdCIs = append(createdCIs, ci)
 		}
 		// all MIG profiles of the permutation have been created, stop iterating
 		anyPermutationApplied = true
-		c.logger.V(1).Info("MIG profiles successfully created", "permutations", mps)
+		c.logger.V(1).Info("MIG profiles successfully created", "permutations", perm)
 		return false, nil
 	})
 
 	if err != nil {
+		_ = cleanup(nil, addedCIs)
 		return gpu.GenericErr.Errorf("error while applying permutations: %s", err)
 	}
-	if !anyPermutationApplied {
+	if !anyPermutationApplied && len(giRequests) > 0 {
+		_ = cleanup(nil, addedCIs)
 		return gpu.GenericErr.Errorf("could not create MIG profiles: could not find any valid permutation")
 	}
 	return nil
 }
 
+// gpuInstanceRequest is a GPU instance to create, together with the number of compute instances
+// of its MIG profile to create in it
+type gpuInstanceRequest struct {
+	profile          nvlibdevice.MigProfile
+	computeInstances int
+}
+
+func (r gpuInstanceRequest) String() string {
+	return fmt.Sprintf("%s x%d", r.profile.String(), r.computeInstances)
+}
+
+// addComputeInstances creates up to n compute instances of the MIG profile provided as argument in the existing
+// GPU instances of the device that already host compute instances of the same profile, and returns the created ones
+func (c *clientImpl) addComputeInstances(device nvlibdevice.Device, mp nvlibdevice.MigProfile, n int) ([]nvlibNvml.ComputeInstance, error) {
+	created := make([]nvlibNvml.ComputeInstance, 0)
+	giProfileInfo, ret := device.GetGpuInstanceProfileInfo(mp.GetInfo().GIProfileID)
+	if ret != nvlibNvml.SUCCESS {
+		return created, fmt.Errorf("error getting GPU instance profile info: %s", ret.Error())
+	}
+	gis, ret := device.GetGpuInstances(&giProfileInfo)
+	if ret != nvlibNvml.SUCCESS {
+		return created, fmt.Errorf("error getting GPU instances: %s", ret.Error())
+	}
+	for _, gi := range gis {
+		ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(mp.GetInfo().CIProfileID, mp.GetInfo().CIEngProfileID)
+		if ret != nvlibNvml.SUCCESS {
+			return created, fmt.Errorf("error getting compute instance profile info: %s", ret.Error())
+		}
+		cis, ret := gi.GetComputeInstances(&ciProfileInfo)
+		if ret != nvlibNvml.SUCCESS {
+			return created, fmt.Errorf("error getting compute instances: %s", ret.Error())
+		}
+		if len(cis) == 0 {
+			continue
+		}
+		for i := len(cis); i < int(ciProfileInfo.InstanceCount) && len(created) < n; i++ {
+			ci, ret := gi.CreateComputeInstance(&ciProfileInfo)
+			if ret != nvlibNvml.SUCCESS {
+				c.logger.V(1).Info("could not add compute instance to GPU instance", "error", ret.Error())
+				break
+			}
+			c.logger.V(1).Info("added compute Instance to existing GPU instance", "profile", mp.String())
+			created = append(created, ci)
+		}
+	}
+	return created, nil
+}
+
+// CreateMigDeviceWithPlacement creates a MIG device of the profile provided as argument on the GPU with the
+// provided index, creating its GPU instance exactly at the provided placement. If the compute instance cannot be
+// created, the GPU instance is destroyed so that no partial device is left on the GPU.
+//
+// If the profile is the profile of a compute instance sharing its GPU instance with other ones (e.g. 1c.3g.20gb)
+// and a GPU instance of the same GPU instance profile already exists at the placement, the compute instance
+// is created in the existing GPU instance.
+func (c *clientImpl) CreateMigDeviceWithPlacement(migProfileName string, placement GpuInstancePlacement, gpuIndex int) gpu.Error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	defer c.shutdown()
+
+	mp, err := c.nvlibClient.ParseMigProfile(migProfileName)
+	if err != nil {
+		return gpu.GenericErr.Errorf("invalid MIG profile: %s", err.Error())
+	}
+
+	// The go-nvlib device does not expose the placements API, so use the NVML handle directly
+	d, ret := nvml.DeviceGetHandleByIndex(gpuIndex)
+	if ret == nvml.ERROR_NOT_FOUND {
+		return gpu.NotFoundErr.Errorf("GPU with index %d not found", gpuIndex)
+	}
+	if ret != nvml.SUCCESS {
+		return gpu.GenericErr.Errorf("error getting GPU with index %d: %s", gpuIndex, nvml.ErrorString(ret))
+	}
+
+	giProfileInfo, ret := d.GetGpuInstanceProfileInfo(mp.GetInfo().GIProfileID)
+	if ret != nvml.SUCCESS {
+		return gpu.GenericErr.Errorf("error getting GPU instance profile info: %s", nvml.ErrorString(ret))
+	}
+	giPlacement := nvml.GpuInstancePlacement{
+		Start: uint32(placement.Start),
+		Size:  uint32(placement.Size),
+	}
+
+	// Look for an existing GPU Instance at the placement that can host the Compute Instance
+	var gi nvml.GpuInstance
+	var giFound, giCreated bool
+	if mp.GetInfo().C != mp.GetInfo().G {
+		gis, ret := d.GetGpuInstances(&giProfileInfo)
+		if ret != nvml.SUCCESS {
+			return gpu.GenericErr.Errorf("error getting GPU instances: %s", nvml.ErrorString(ret))
+		}
+		for _, existing := range gis {
+			info, ret := existing.GetInfo()
+			if ret != nvml.SUCCESS {
+				return gpu.GenericErr.Errorf("error getting GPU instance info: %s", nvml.ErrorString(ret))
+			}
+			if info.Placement == giPlacement {
+				gi = existing
+				giFound = true
+				break
+			}
+		}
+	}
+
+	// Create GPU Instance
+	if !giFound {
+		gi, ret = d.CreateGpuInstanceWithPlacement(&giProfileInfo, &giPlacement)
+		if ret != nvml.SUCCESS {
+			return gpu.GenericErr.Errorf(
+				"error creating GPU instance %s at placement %d:%d: %s",
+				migProfileName,
+				placement.Start,
+				placement.Size,
+				nvml.ErrorString(ret),
+			)
+		}
+		giCreated = true
+		c.logger.V(1).Info("created GPU Instance", "profile", migProfileName, "placement", placement)
+	}
+
+	// Create Compute Instance
+	ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(mp.GetInfo().CIProfileID, mp.GetInfo().CIEngProfileID)
+	if ret == nvml.SUCCESS {
+		_, ret = gi.CreateComputeInstance(&ciProfileInfo)
+	}
+	if ret != nvml.SUCCESS {
+		if giCreated {
+			if r := gi.Destroy(); r != nvml.SUCCESS {
+				c.logger.Error(gpu.GenericErr.Errorf(nvml.ErrorString(r)), "error deleting GPU instance")
+			}
+		}
+		return gpu.GenericErr.Errorf("error creating compute instance %s: %s", migProfileName, nvml.ErrorString(ret))
+	}
+	c.logger.V(1).Info("created compute Instance", "profile", migProfileName, "placement", placement)
+
+	return nil
+}
+
+// GetMigDevicePlacements returns the placements of the GPU instances of all the existing MIG devices,
+// indexed by the UUID of the MIG devices.
+func (c *clientImpl) GetMigDevicePlacements() (map[string]GpuInstancePlacement, gpu.Error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown()
+
+	var result = make(map[string]GpuInstancePlacement)
+	err := c.nvlibClient.VisitMigDevices(func(gpuIndex int, d nvlibdevice.Device, migIndex int, m nvlibdevice.MigDevice) error {
+		uuid, ret := m.GetUUID()
+		if ret != nvlibNvml.SUCCESS {
+			return fmt.Errorf("error getting UUID of MIG device %d of GPU %d: %s", migIndex, gpuIndex, ret.Error())
+		}
+		giId, ret := m.GetGpuInstanceId()
+		if ret != nvlibNvml.SUCCESS {
+			return fmt.Errorf("error getting GPU instance ID of MIG device %s: %s", uuid, ret.Error())
+		}
+		gi, ret := d.GetGpuInstanceById(giId)
+		if ret != nvlibNvml.SUCCESS {
+			return fmt.Errorf("error getting GPU instance of MIG device %s: %s", uuid, ret.Error())
+		}
+		giInfo, ret := gi.GetInfo()
+		if ret != nvlibNvml.SUCCESS {
+			return fmt.Errorf("error getting GPU instance info of MIG device %s: %s", uuid, ret.Error())
+		}
+		result[uuid] = GpuInstancePlacement{
+			Start: int(giInfo.Placement.Start),
+			Size:  int(giInfo.Placement.Size),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gpu.NewGenericError(err)
+	}
+
+	return result, nil
+}
+
 // GetMigEnabledGPUs returns the indexes of the GPUs that have MIG mode enabled
 func (c *clientImpl) GetMigEnabledGPUs() ([]int, gpu.Error) {
 	r := nvml.Init()
@@ -371,6 +601,278 @@ func (c *clientImpl) GetMigEnabledGPUs() ([]int, gpu.Error) {
 	return indexes, nil
 }
 
+// GetGpuIndexes returns the indexes of all the GPUs of the node
+func (c *clientImpl) GetGpuIndexes() ([]int, gpu.Error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown()
+
+	count, ret := c.nvmlClient.DeviceGetCount()
+	if ret != nvlibNvml.SUCCESS {
+		return nil, gpu.GenericErr.Errorf("error getting GPU count: %s", ret.Error())
+	}
+	indexes := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// GetMigMode returns the current and pending MIG mode of the GPU with the index provided as arg.
+// Returns err if the GPU is not found or if it does not support MIG.
+func (c *clientImpl) GetMigMode(gpuIndex int) (MigMode, gpu.Error) {
+	if err := c.init(); err != nil {
+		return MigMode{}, err
+	}
+	defer c.shutdown()
+
+	device, ret := c.nvmlClient.DeviceGetHandleByIndex(gpuIndex)
+	if ret == nvlibNvml.ERROR_INVALID_ARGUMENT {
+		return MigMode{}, gpu.NotFoundErr.Errorf("GPU with index %d not found", gpuIndex)
+	}
+	if ret != nvlibNvml.SUCCESS {
+		return MigMode{}, gpu.GenericErr.Errorf("error getting GPU with index %d: %s", gpuIndex, ret.Error())
+	}
+	current, pending, ret := device.GetMigMode()
+	if ret != nvlibNvml.SUCCESS {
+		return MigMode{}, gpu.GenericErr.Errorf("error getting MIG mode of GPU %d: %s", gpuIndex, ret.Error())
+	}
+	return MigMode{
+		Enabled:        current == nvlibNvml.DEVICE_MIG_ENABLE,
+		PendingEnabled: pending == nvlibNvml.DEVICE_MIG_ENABLE,
+	}, nil
+}
+
+// SetMigMode enables or disables MIG mode on the GPU with the index provided as arg.
+//
+// The new mode might not take effect immediately: if the GPU is in use or if it requires
+// a reset, the change stays pending until the GPU gets reset. Callers can check
+// whether this is the case through GetMigMode.
+func (c *clientImpl) SetMigMode(gpuIndex int, enabled bool) gpu.Error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	defer c.shutdown()
+
+	device, ret := c.nvmlClient.DeviceGetHandleByIndex(gpuIndex)
+	if ret == nvlibNvml.ERROR_INVALID_ARGUMENT {
+		return gpu.NotFoundErr.Errorf("GPU with index %d not found", gpuIndex)
+	}
+	if ret != nvlibNvml.SUCCESS {
+		return gpu.GenericErr.Errorf("error getting GPU with index %d: %s", gpuIndex, ret.Error())
+	}
+	mode := nvlibNvml.DEVICE_MIG_DISABLE
+	if enabled {
+		mode = nvlibNvml.DEVICE_MIG_ENABLE
+	}
+	ret, activationStatus := device.SetMigMode(mode)
+	if ret != nvlibNvml.SUCCESS {
+		return gpu.GenericErr.Errorf("error setting MIG mode of GPU %d: %s", gpuIndex, ret.Error())
+	}
+	if activationStatus != nvlibNvml.SUCCESS {
+		c.logger.Info(
+			"MIG mode change is pending, GPU requires a reset",
+			"gpuIndex",
+			gpuIndex,
+			"enabled",
+			enabled,
+			"activationStatus",
+			activationStatus.Error(),
+		)
+	}
+	return nil
+}
+
+// GetDevicesUtilization returns the SM utilization and the memory usage of each GPU of the node and,
+// for the GPUs with MIG mode enabled, of each of their MIG devices
+func (c *clientImpl) GetDevicesUtilization() ([]DeviceUtilization, gpu.Error) {
+	r := nvml.Init()
+	if r != nvml.SUCCESS {
+		return nil, gpu.GenericErr.Errorf("error initializing nvml client: %s", nvml.ErrorString(r))
+	}
+	defer nvml.Shutdown()
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, gpu.GenericErr.Errorf("error getting GPU count: %s", nvml.ErrorString(ret))
+	}
+	res := make([]DeviceUtilization, 0, count)
+	for gpuIndex := 0; gpuIndex < count; gpuIndex++ {
+		device, ret := nvml.DeviceGetHandleByIndex(gpuIndex)
+		if ret != nvml.SUCCESS {
+			return nil, gpu.GenericErr.Errorf("error getting GPU %d: %s", gpuIndex, nvml.ErrorString(ret))
+		}
+		utilization, err := getDeviceUtilization(device, gpuIndex)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, utilization)
+
+		// Get the utilization of the MIG devices, if any
+		current, _, ret := device.GetMigMode()
+		if ret == nvml.ERROR_NOT_SUPPORTED {
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			return nil, gpu.GenericErr.Errorf("error getting MIG mode of GPU %d: %s", gpuIndex, nvml.ErrorString(ret))
+		}
+		if current != nvml.DEVICE_MIG_ENABLE {
+			continue
+		}
+		maxMigDevices, ret := device.GetMaxMigDeviceCount()
+		if ret != nvml.SUCCESS {
+			return nil, gpu.GenericErr.Errorf("error getting max MIG device count of GPU %d: %s", gpuIndex, nvml.ErrorString(ret))
+		}
+		for i := 0; i < maxMigDevices; i++ {
+			migDevice, ret := device.GetMigDeviceHandleByIndex(i)
+			if ret == nvml.ERROR_NOT_FOUND {
+				continue
+			}
+			if ret != nvml.SUCCESS {
+				return nil, gpu.GenericErr.Errorf("error getting MIG device %d of GPU %d: %s", i, gpuIndex, nvml.ErrorString(ret))
+			}
+			utilization, err = getDeviceUtilization(migDevice, gpuIndex)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, utilization)
+		}
+	}
+
+	return res, nil
+}
+
+func getDeviceUtilization(device nvml.Device, gpuIndex int) (DeviceUtilization, gpu.Error) {
+	uuid, ret := device.GetUUID()
+	if ret != nvml.SUCCESS {
+		return DeviceUtilization{}, gpu.GenericErr.Errorf("error getting UUID of device of GPU %d: %s", gpuIndex, nvml.ErrorString(ret))
+	}
+	memory, ret := device.GetMemoryInfo()
+	if ret != nvml.SUCCESS {
+		return DeviceUtilization{}, gpu.GenericErr.Errorf("error getting memory info of device %s: %s", uuid, nvml.ErrorString(ret))
+	}
+	res := DeviceUtilization{
+		DeviceId:         uuid,
+		GpuIndex:         gpuIndex,
+		MemoryUsedBytes:  memory.Used,
+		MemoryTotalBytes: memory.Total,
+	}
+	rates, ret := device.GetUtilizationRates()
+	switch ret {
+	case nvml.SUCCESS:
+		res.SmUtilizationSupported = true
+		res.SmUtilizationPercent = rates.Gpu
+	case nvml.ERROR_NOT_SUPPORTED:
+	default:
+		return DeviceUtilization{}, gpu.GenericErr.Errorf("error getting utilization of device %s: %s", uuid, nvml.ErrorString(ret))
+	}
+	return res, nil
+}
+
+// GetGpuModels returns the distinct models of the GPUs of the node, in the same format
+// used by the NVIDIA GPU Feature Discovery for the label nvidia.com/gpu.product
+func (c *clientImpl) GetGpuModels() ([]gpu.Model, gpu.Error) {
+	r := nvml.Init()
+	if r != nvml.SUCCESS {
+		return nil, gpu.GenericErr.Errorf("error initializing nvml client: %s", nvml.ErrorString(r))
+	}
+	defer nvml.Shutdown()
+
+	devices, err := c.nvlibClient.GetDevices()
+	if err != nil {
+		return nil, gpu.NewGenericError(err)
+	}
+
+	models := make([]gpu.Model, 0)
+	for _, d := range devices {
+		name, ret := d.GetName()
+		if ret != nvlibNvml.SUCCESS {
+			return nil, gpu.GenericErr.Errorf("error getting GPU name: %s", ret)
+		}
+		model := gpu.Model(strings.ReplaceAll(name, " ", "-"))
+		if !util.InSlice(model, models) {
+			models = append(models, model)
+		}
+	}
+
+	return models, nil
+}
+
+// GetGpuInstanceProfiles returns the GPU instance profiles supported by the GPU with the index provided as arg,
+// together with the placements at which their GPU instances can be created. Returns err if the GPU is not
+// found, if it does not have MIG mode enabled or if any error occurs while retrieving the profiles.
+func (c *clientImpl) GetGpuInstanceProfiles(gpuIndex int) ([]GpuInstanceProfile, gpu.Error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown()
+
+	c.logger.V(3).Info("retrieving GPU instance profiles", "GPUIndex", gpuIndex)
+	var result = make([]GpuInstanceProfile, 0)
+	var found bool
+	err := c.nvlibClient.VisitDevices(func(i int, d nvlibdevice.Device) error {
+		if i != gpuIndex {
+			return nil
+		}
+		found = true
+
+		isMig, err := d.IsMigEnabled()
+		if err != nil {
+			return fmt.Errorf("error checking if device is MIG enabled: %s", err)
+		}
+		if !isMig {
+			return fmt.Errorf("GPU %d does not have MIG mode enabled", gpuIndex)
+		}
+
+		migProfiles, err := d.GetMigProfiles()
+		if err != nil {
+			return fmt.Errorf("error getting MIG profiles of GPU %d: %s", gpuIndex, err)
+		}
+		// The go-nvlib device does not expose the placements API, so use the NVML handle directly
+		handle, r := nvml.DeviceGetHandleByIndex(gpuIndex)
+		if r != nvml.SUCCESS {
+			return fmt.Errorf("error getting GPU with index %d: %s", gpuIndex, nvml.ErrorString(r))
+		}
+		for _, mp := range migProfiles {
+			// Consider only the profiles whose compute instance spans the whole GPU instance
+			info := mp.GetInfo()
+			if info.C != info.G || info.CIEngProfileID != nvlibNvml.COMPUTE_INSTANCE_ENGINE_PROFILE_SHARED {
+				continue
+			}
+			giProfileInfo, ret := d.GetGpuInstanceProfileInfo(info.GIProfileID)
+			if ret != nvlibNvml.SUCCESS {
+				return fmt.Errorf("error getting GPU instance profile info of %s: %s", mp.String(), ret.Error())
+			}
+			placements, r := handle.GetGpuInstancePossiblePlacements((*nvml.GpuInstanceProfileInfo)(&giProfileInfo))
+			if r != nvml.SUCCESS {
+				return fmt.Errorf("error getting placements of %s: %s", mp.String(), nvml.ErrorString(r))
+			}
+			profile := GpuInstanceProfile{
+				Name:       mp.String(),
+				Placements: make([]GpuInstancePlacement, 0, len(placements)),
+			}
+			for _, p := range placements {
+				profile.Placements = append(profile.Placements, GpuInstancePlacement{
+					Start: int(p.Start),
+					Size:  int(p.Size),
+				})
+			}
+			c.logger.V(3).Info("found GPU instance profile", "profile", profile.Name, "placements", profile.Placements)
+			result = append(result, profile)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gpu.NewGenericError(err)
+	}
+	if !found {
+		return nil, gpu.NotFoundErr.Errorf("GPU with index %d not found", gpuIndex)
+	}
+
+	return result, nil
+}
+
 // DeleteAllMigDevicesExcept deletes all the MIG resources (Compute Instances and GPU Instances) except the ones
 // associated with the MIG devices with the provided IDs
 func (c *clientImpl) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
@@ -405,6 +907,7 @@ func (c *clientImpl) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
 			}
 
 			// Delete compute instances
+			var numKeptCi int
 			err := visitComputeInstances(gi, func(ci nvlibNvml.ComputeInstance, _ int, _ int, _ nvlibNvml.ComputeInstanceProfileInfo) error {
 				ciInfo, ret := ci.GetInfo()
 				if ret != nvlibNvml.SUCCESS {
@@ -415,6 +918,7 @@ func (c *clientImpl) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
 					return gpu.GenericErr.Errorf("error getting compute instance device UUID: %s", ret.Error())
 				}
 				if util.InSlice(ciDeviceId, migDeviceIds) {
+					numKeptCi++
 					return nil
 				}
 				ret = ci.Destroy()
@@ -431,7 +935,10 @@ func (c *clientImpl) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
 				return err
 			}
 
-			// Delete GPU instance
+			// Delete GPU instance, unless it still hosts compute instances of kept MIG devices
+			if numKeptCi > 0 {
+				return nil
+			}
 			ret = gi.Destroy()
 			if ret == nvlibNvml.ERROR_INVALID_ARGUMENT {
 				return nil
@@ -29,7 +29,72 @@ type Client interface {
 
 	CreateMigDevices(migProfileNames []string, gpuIndex int) gpu.Error
 
+	CreateMigDeviceWithPlacement(migProfileName string, placement GpuInstancePlacement, gpuIndex int) gpu.Error
+
 	GetMigEnabledGPUs() ([]int, gpu.Error)
 
 	DeleteAllMigDevicesExcept(migDeviceIds []string) error
+
+	GetGpuInstanceProfiles(gpuIndex int) ([]GpuInstanceProfile, gpu.Error)
+
+	GetMigDevicePlacements() (map[string]GpuInstancePlacement, gpu.Error)
+
+	GetGpuModels() ([]gpu.Model, gpu.Error)
+
+	GetGpuIndexes() ([]int, gpu.Error)
+
+	GetMigMode(gpuIndex int) (MigMode, gpu.Error)
+
+	SetMigMode(gpuIndex int, enabled bool) gpu.Error
+
+	GetDevicesUtilization() ([]DeviceUtilization, gpu.Error)
+}
+
+// DeviceUtilization is the utilization of a GPU or of a MIG device
+type DeviceUtilization struct {
+	// DeviceId is the UUID of the GPU or of the MIG device
+	DeviceId string
+	// GpuIndex is the index of the GPU, or of the GPU to which the MIG device belongs
+	GpuIndex int
+	// SmUtilizationSupported is false if the device does not report its SM utilization,
+	// which is the case for instance of MIG devices
+	SmUtilizationSupported bool
+	// SmUtilizationPercent is the percent of time over the last sample period during which
+	// one or more kernels were executing on the device
+	SmUtilizationPercent uint32
+	// MemoryUsedBytes is the amount of device memory allocated
+	MemoryUsedBytes uint64
+	// MemoryTotalBytes is the total amount of device memory
+	MemoryTotalBytes uint64
+}
+
+// MigMode is the MIG mode of a GPU
+type MigMode struct {
+	// Enabled is true if MIG mode is currently enabled on the GPU
+	Enabled bool
+	// PendingEnabled is true if MIG mode is enabled on the GPU once the pending MIG mode change,
+	// if any, takes effect. It differs from Enabled when the GPU has to be reset for the last
+	// change of its MIG mode to take effect.
+	PendingEnabled bool
+}
+
+// IsPendingReset returns true if the GPU has to be reset for the last change of its MIG mode to take effect
+func (m MigMode) IsPendingReset() bool {
+	return m.Enabled != m.PendingEnabled
+}
+
+// GpuInstanceProfile is a GPU instance profile supported by a GPU, together with
+// the placements at which its GPU instances can be created.
+type GpuInstanceProfile struct {
+	// Name is the name of the MIG profile corresponding to the GPU instance profile (e.g. 1g.10gb)
+	Name string
+	// Placements are the valid placements of the GPU instances of the profile
+	Placements []GpuInstancePlacement
+}
+
+// GpuInstancePlacement is a position at which a GPU instance can be created on a GPU,
+// expressed as the range of memory slices occupied by the instance.
+type GpuInstancePlacement struct {
+	Start int
+	Size  int
 }
@@ -0,0 +1,699 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nvml
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// simulatedCiProfileRegex matches the profiles of compute instances sharing a GPU instance (e.g. 1c.3g.20gb)
+var simulatedCiProfileRegex = regexp.MustCompile(`^(\d+)c\.((\d+)g\.\d+gb)$`)
+
+// simulatedProfileMemoryRegex matches the memory of a MIG profile (e.g. 20 for 3g.20gb)
+var simulatedProfileMemoryRegex = regexp.MustCompile(`\.(\d+)gb$`)
+
+// simulatedModel describes the MIG capabilities of a GPU model simulated by SimulatedClient
+type simulatedModel struct {
+	// memorySlices is the number of memory slices of the GPU
+	memorySlices int
+	// profiles are the GPU instance profiles supported by the GPU, together with their placements
+	profiles []GpuInstanceProfile
+}
+
+var simulatedModels = map[gpu.Model]simulatedModel{
+	gpu.GPUModel_A30: {
+		memorySlices: 4,
+		profiles: []GpuInstanceProfile{
+			{Name: "1g.6gb", Placements: placements(1, 0, 1, 2, 3)},
+			{Name: "2g.12gb", Placements: placements(2, 0, 2)},
+			{Name: "4g.24gb", Placements: placements(4, 0)},
+		},
+	},
+	gpu.GPUModel_A100_SXM4_40GB: eightSlicesModel("1g.5gb", "2g.10gb", "3g.20gb", "4g.20gb", "7g.40gb"),
+	gpu.GPUModel_A100_PCIe_40GB: eightSlicesModel("1g.5gb", "2g.10gb", "3g.20gb", "4g.20gb", "7g.40gb"),
+	gpu.GPUModel_A100_PCIe_80GB: eightSlicesModel("1g.10gb", "2g.20gb", "3g.40gb", "4g.40gb", "7g.79gb"),
+	gpu.GPUModel_H100_SXM5_80GB: withDoubleMemoryProfile(
+		eightSlicesModel("1g.10gb", "2g.20gb", "3g.40gb", "4g.40gb", "7g.80gb"),
+		"1g.20gb",
+	),
+	gpu.GPUModel_H100_PCIe_80GB: withDoubleMemoryProfile(
+		eightSlicesModel("1g.10gb", "2g.20gb", "3g.40gb", "4g.40gb", "7g.80gb"),
+		"1g.20gb",
+	),
+}
+
+// eightSlicesModel returns a model with 8 memory slices, such as A100 and H100,
+// supporting the profiles provided as argument
+func eightSlicesModel(p1g, p2g, p3g, p4g, p7g string) simulatedModel {
+	return simulatedModel{
+		memorySlices: 8,
+		profiles: []GpuInstanceProfile{
+			{Name: p1g, Placements: placements(1, 0, 1, 2, 3, 4, 5, 6)},
+			{Name: p2g, Placements: placements(2, 0, 2, 4)},
+			{Name: p3g, Placements: placements(4, 0, 4)},
+			{Name: p4g, Placements: placements(4, 0)},
+			{Name: p7g, Placements: placements(8, 0)},
+		},
+	}
+}
+
+// withDoubleMemoryProfile adds to the model provided as argument the profile with one compute slice
+// and two memory slices supported by H100 GPUs
+func withDoubleMemoryProfile(model simulatedModel, profile string) simulatedModel {
+	model.profiles = append(model.profiles, GpuInstanceProfile{Name: profile, Placements: placements(2, 0, 2, 4, 6)})
+	return model
+}
+
+func placements(size int, starts ...int) []GpuInstancePlacement {
+	res := make([]GpuInstancePlacement, len(starts))
+	for i, start := range starts {
+		res[i] = GpuInstancePlacement{Start: start, Size: size}
+	}
+	return res
+}
+
+// GetSimulatedModels returns the GPU models that can be simulated by SimulatedClient
+func GetSimulatedModels() []gpu.Model {
+	res := make([]gpu.Model, 0, len(simulatedModels))
+	for model := range simulatedModels {
+		res = append(res, model)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+	return res
+}
+
+// SimulatedGpu is a GPU simulated by SimulatedClient
+type SimulatedGpu struct {
+	// Model is the model of the GPU
+	Model gpu.Model
+	// MigEnabled specifies whether the GPU has MIG mode enabled
+	MigEnabled bool
+}
+
+type simulatedComputeInstance struct {
+	id      int
+	uuid    string
+	profile string
+}
+
+type simulatedGpuInstance struct {
+	id               int
+	profile          string
+	placement        GpuInstancePlacement
+	computeInstances []simulatedComputeInstance
+}
+
+type simulatedGpu struct {
+	uuid                  string
+	model                 gpu.Model
+	migEnabled            bool
+	gpuInstances          []*simulatedGpuInstance
+	nextGpuInstanceId     int
+	nextComputeInstanceId int
+}
+
+func (g *simulatedGpu) getProfile(name string) (GpuInstanceProfile, bool) {
+	for _, p := range simulatedModels[g.model].profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return GpuInstanceProfile{}, false
+}
+
+// getDeviceProfile returns the GPU instance profile of the MIG devices of the profile provided as argument,
+// together with the number of MIG devices of the profile that a single GPU instance can host. Profiles of
+// compute instances sharing a GPU instance (e.g. 1c.3g.20gb) are supported.
+func (g *simulatedGpu) getDeviceProfile(name string) (GpuInstanceProfile, int, bool) {
+	matches := simulatedCiProfileRegex.FindStringSubmatch(name)
+	if matches == nil {
+		profile, ok := g.getProfile(name)
+		return profile, 1, ok
+	}
+	profile, ok := g.getProfile(matches[2])
+	if !ok {
+		return GpuInstanceProfile{}, 0, false
+	}
+	ciSlices, _ := strconv.Atoi(matches[1])
+	giSlices, _ := strconv.Atoi(matches[3])
+	if ciSlices <= 0 || ciSlices >= giSlices {
+		return GpuInstanceProfile{}, 0, false
+	}
+	return profile, giSlices / ciSlices, true
+}
+
+// canHost returns true if the GPU instance can host another MIG device of the profile provided as argument,
+// given the number of devices of the profile that a GPU instance can host
+func (gi *simulatedGpuInstance) canHost(profile string, devicesPerGi int) bool {
+	if len(gi.computeInstances) == 0 || len(gi.computeInstances) >= devicesPerGi {
+		return false
+	}
+	for _, ci := range gi.computeInstances {
+		if ci.profile != profile {
+			return false
+		}
+	}
+	return true
+}
+
+// findPlacements returns free non-overlapping placements for the profiles provided as argument,
+// which are in addition to the ones already chosen. Returns false if the profiles do not fit on the GPU.
+func (g *simulatedGpu) findPlacements(profiles []GpuInstanceProfile, chosen []GpuInstancePlacement) ([]GpuInstancePlacement, bool) {
+	if len(profiles) == 0 {
+		return chosen, true
+	}
+	for _, p := range profiles[0].Placements {
+		if !g.isFree(p) || overlapsAny(p, chosen) {
+			continue
+		}
+		if res, ok := g.findPlacements(profiles[1:], append(chosen, p)); ok {
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+func (g *simulatedGpu) isFree(placement GpuInstancePlacement) bool {
+	for _, gi := range g.gpuInstances {
+		if overlaps(gi.placement, placement) {
+			return false
+		}
+	}
+	return true
+}
+
+func overlapsAny(placement GpuInstancePlacement, others []GpuInstancePlacement) bool {
+	for _, o := range others {
+		if overlaps(placement, o) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b GpuInstancePlacement) bool {
+	return a.Start < b.Start+b.Size && b.Start < a.Start+a.Size
+}
+
+// deleteComputeInstance deletes the compute instance of the MIG device with the UUID provided as argument,
+// deleting also its GPU instance if it does not have any other compute instance
+func (g *simulatedGpu) deleteComputeInstance(gi *simulatedGpuInstance, migDeviceId string) {
+	for i, ci := range gi.computeInstances {
+		if ci.uuid == migDeviceId {
+			gi.computeInstances = append(gi.computeInstances[:i], gi.computeInstances[i+1:]...)
+			break
+		}
+	}
+	if len(gi.computeInstances) == 0 {
+		g.deleteGpuInstance(gi.id)
+	}
+}
+
+func (g *simulatedGpu) deleteGpuInstance(id int) {
+	for i, gi := range g.gpuInstances {
+		if gi.id == id {
+			g.gpuInstances = append(g.gpuInstances[:i], g.gpuInstances[i+1:]...)
+			return
+		}
+	}
+}
+
+// SimulatedClient is an in-memory implementation of Client that simulates the GPUs of a node,
+// their MIG mode and the GPU instances and compute instances created on them, enforcing the
+// placements supported by each GPU model. It allows to run the agents on nodes without NVIDIA GPUs.
+//
+// Each compute instance is exposed as a MIG device. GPU instances of plain MIG profiles (e.g. 3g.20gb) have a
+// single compute instance spanning the whole GPU instance, while the ones created for the profiles of compute
+// instances sharing a GPU instance (e.g. 1c.3g.20gb) host up to as many compute instances as they can fit.
+type SimulatedClient struct {
+	mtx              sync.Mutex
+	gpus             []*simulatedGpu
+	nextMigDeviceSeq int
+}
+
+var _ Client = &SimulatedClient{}
+
+// NewSimulatedClient returns a SimulatedClient simulating the GPUs provided as argument, whose indexes
+// correspond to their position in the list. Returns an error if any of the GPU models cannot be simulated.
+func NewSimulatedClient(gpus []SimulatedGpu) (*SimulatedClient, error) {
+	c := &SimulatedClient{
+		gpus: make([]*simulatedGpu, 0, len(gpus)),
+	}
+	for i, g := range gpus {
+		if _, ok := simulatedModels[g.Model]; !ok {
+			return nil, fmt.Errorf("GPU model %q cannot be simulated, supported models are %v", g.Model, GetSimulatedModels())
+		}
+		c.gpus = append(c.gpus, &simulatedGpu{
+			uuid:       fmt.Sprintf("GPU-simulated-%d", i),
+			model:      g.Model,
+			migEnabled: g.MigEnabled,
+		})
+	}
+	return c, nil
+}
+
+func (c *SimulatedClient) getGpu(gpuIndex int) (*simulatedGpu, gpu.Error) {
+	if gpuIndex < 0 || gpuIndex >= len(c.gpus) {
+		return nil, gpu.NotFoundErr.Errorf("GPU with index %d not found", gpuIndex)
+	}
+	return c.gpus[gpuIndex], nil
+}
+
+func (c *SimulatedClient) getMigEnabledGpu(gpuIndex int) (*simulatedGpu, gpu.Error) {
+	g, err := c.getGpu(gpuIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !g.migEnabled {
+		return nil, gpu.GenericErr.Errorf("MIG is not enabled on GPU with index %d", gpuIndex)
+	}
+	return g, nil
+}
+
+// createGpuInstance creates on the GPU provided as argument a GPU instance of the profile provided
+// as argument at the given placement, without any compute instance
+func (c *SimulatedClient) createGpuInstance(g *simulatedGpu, profile string, placement GpuInstancePlacement) *simulatedGpuInstance {
+	gi := &simulatedGpuInstance{
+		id:        g.nextGpuInstanceId,
+		profile:   profile,
+		placement: placement,
+	}
+	g.nextGpuInstanceId++
+	g.gpuInstances = append(g.gpuInstances, gi)
+	return gi
+}
+
+// createComputeInstance creates in the GPU instance provided as argument a compute instance, which is
+// exposed as a MIG device of the profile provided as argument
+func (c *SimulatedClient) createComputeInstance(g *simulatedGpu, gi *simulatedGpuInstance, gpuIndex int, profile string) {
+	gi.computeInstances = append(gi.computeInstances, simulatedComputeInstance{
+		id:      g.nextComputeInstanceId,
+		uuid:    fmt.Sprintf("MIG-simulated-%d-%d", gpuIndex, c.nextMigDeviceSeq),
+		profile: profile,
+	})
+	g.nextComputeInstanceId++
+	c.nextMigDeviceSeq++
+}
+
+// findMigDevice returns the index of the GPU and the GPU instance of the MIG device with the UUID
+// provided as argument
+func (c *SimulatedClient) findMigDevice(migDeviceId string) (int, *simulatedGpuInstance, bool) {
+	for gpuIndex, g := range c.gpus {
+		for _, gi := range g.gpuInstances {
+			for _, ci := range gi.computeInstances {
+				if ci.uuid == migDeviceId {
+					return gpuIndex, gi, true
+				}
+			}
+		}
+	}
+	return 0, nil, false
+}
+
+func (c *SimulatedClient) GetGpuIndex(gpuId string) (int, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for i, g := range c.gpus {
+		if g.uuid == gpuId {
+			return i, nil
+		}
+	}
+	return 0, gpu.NotFoundErr.Errorf("GPU index of device %s not found", gpuId)
+}
+
+func (c *SimulatedClient) GetMigDeviceGpuIndex(migDeviceId string) (int, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	gpuIndex, _, found := c.findMigDevice(migDeviceId)
+	if !found {
+		return 0, gpu.NotFoundErr.Errorf("GPU index of MIG device %s not found", migDeviceId)
+	}
+	return gpuIndex, nil
+}
+
+func (c *SimulatedClient) DeleteMigDevice(id string) gpu.Error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	gpuIndex, gi, found := c.findMigDevice(id)
+	if !found {
+		return gpu.NotFoundErr.Errorf("MIG device %s not found", id)
+	}
+	c.gpus[gpuIndex].deleteComputeInstance(gi, id)
+	return nil
+}
+
+// CreateMigDevices creates the MIG devices of the profiles provided as argument on the GPU with the provided
+// index. Either all the devices are created or none of them is.
+//
+// The devices of the profiles of compute instances sharing a GPU instance are first added to the existing
+// GPU instances that host devices of the same profile, and the remaining ones are packed into new GPU instances.
+func (c *SimulatedClient) CreateMigDevices(migProfileNames []string, gpuIndex int) gpu.Error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	g, err := c.getMigEnabledGpu(gpuIndex)
+	if err != nil {
+		return err
+	}
+	type newGpuInstance struct {
+		profile       GpuInstanceProfile
+		deviceProfile string
+		devices       int
+	}
+	type addedDevice struct {
+		gi      *simulatedGpuInstance
+		profile string
+	}
+	added := make([]addedDevice, 0)
+	hosted := make(map[*simulatedGpuInstance]int)
+	newGpuInstances := make([]newGpuInstance, 0)
+	for _, name := range migProfileNames {
+		profile, devicesPerGi, ok := g.getDeviceProfile(name)
+		if !ok {
+			return gpu.GenericErr.Errorf("invalid MIG profile: %s", name)
+		}
+		if devicesPerGi == 1 {
+			newGpuInstances = append(newGpuInstances, newGpuInstance{profile: profile, deviceProfile: name, devices: 1})
+			continue
+		}
+		// Add the device to an existing GPU instance, if any can host it
+		var hostedByExisting bool
+		for _, gi := range g.gpuInstances {
+			if gi.canHost(name, devicesPerGi-hosted[gi]) {
+				added = append(added, addedDevice{gi: gi, profile: name})
+				hosted[gi]++
+				hostedByExisting = true
+				break
+			}
+		}
+		if hostedByExisting {
+			continue
+		}
+		// Add the device to a new GPU instance
+		var hostedByNew bool
+		for i := range newGpuInstances {
+			if newGpuInstances[i].deviceProfile == name && newGpuInstances[i].devices < devicesPerGi {
+				newGpuInstances[i].devices++
+				hostedByNew = true
+				break
+			}
+		}
+		if !hostedByNew {
+			newGpuInstances = append(newGpuInstances, newGpuInstance{profile: profile, deviceProfile: name, devices: 1})
+		}
+	}
+
+	// Look for placements at which all the new GPU instances fit, starting from the largest ones
+	sort.SliceStable(newGpuInstances, func(i, j int) bool {
+		return newGpuInstances[i].profile.Placements[0].Size > newGpuInstances[j].profile.Placements[0].Size
+	})
+	profiles := make([]GpuInstanceProfile, len(newGpuInstances))
+	for i, gi := range newGpuInstances {
+		profiles[i] = gi.profile
+	}
+	placements, ok := g.findPlacements(profiles, nil)
+	if !ok {
+		return gpu.GenericErr.Errorf("could not create MIG profiles %v: insufficient resources", migProfileNames)
+	}
+	for _, d := range added {
+		c.createComputeInstance(g, d.gi, gpuIndex, d.profile)
+	}
+	for i, newGi := range newGpuInstances {
+		gi := c.createGpuInstance(g, newGi.profile.Name, placements[i])
+		for j := 0; j < newGi.devices; j++ {
+			c.createComputeInstance(g, gi, gpuIndex, newGi.deviceProfile)
+		}
+	}
+	return nil
+}
+
+// CreateMigDeviceWithPlacement creates a MIG device of the profile provided as argument on the GPU with the
+// provided index, creating its GPU instance at the provided placement. Devices of the profiles of compute
+// instances sharing a GPU instance are added to the GPU instance at the placement, if it already exists.
+func (c *SimulatedClient) CreateMigDeviceWithPlacement(migProfileName string, placement GpuInstancePlacement, gpuIndex int) gpu.Error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	g, err := c.getMigEnabledGpu(gpuIndex)
+	if err != nil {
+		return err
+	}
+	profile, devicesPerGi, ok := g.getDeviceProfile(migProfileName)
+	if !ok {
+		return gpu.GenericErr.Errorf("invalid MIG profile: %s", migProfileName)
+	}
+	if !util.InSlice(placement, profile.Placements) {
+		return gpu.GenericErr.Errorf(
+			"error creating GPU instance %s at placement %d:%d: invalid placement",
+			migProfileName,
+			placement.Start,
+			placement.Size,
+		)
+	}
+	if devicesPerGi > 1 {
+		for _, gi := range g.gpuInstances {
+			if gi.placement == placement && gi.profile == profile.Name && gi.canHost(migProfileName, devicesPerGi) {
+				c.createComputeInstance(g, gi, gpuIndex, migProfileName)
+				return nil
+			}
+		}
+	}
+	if !g.isFree(placement) {
+		return gpu.GenericErr.Errorf(
+			"error creating GPU instance %s at placement %d:%d: insufficient resources",
+			migProfileName,
+			placement.Start,
+			placement.Size,
+		)
+	}
+	gi := c.createGpuInstance(g, profile.Name, placement)
+	c.createComputeInstance(g, gi, gpuIndex, migProfileName)
+	return nil
+}
+
+func (c *SimulatedClient) GetMigEnabledGPUs() ([]int, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	indexes := make([]int, 0)
+	for i, g := range c.gpus {
+		if g.migEnabled {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+func (c *SimulatedClient) GetGpuIndexes() ([]int, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	indexes := make([]int, 0, len(c.gpus))
+	for i := range c.gpus {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// GetMigMode returns the MIG mode of the simulated GPU. Simulated GPUs never require a reset,
+// so the pending mode is always equal to the current one.
+func (c *SimulatedClient) GetMigMode(gpuIndex int) (MigMode, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	g, err := c.getGpu(gpuIndex)
+	if err != nil {
+		return MigMode{}, err
+	}
+	return MigMode{Enabled: g.migEnabled, PendingEnabled: g.migEnabled}, nil
+}
+
+// SetMigMode enables or disables MIG mode on the simulated GPU. The change takes effect immediately.
+// Returns an error when disabling MIG mode on a GPU that still has GPU instances, as the NVML does.
+func (c *SimulatedClient) SetMigMode(gpuIndex int, enabled bool) gpu.Error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	g, err := c.getGpu(gpuIndex)
+	if err != nil {
+		return err
+	}
+	if !enabled && len(g.gpuInstances) > 0 {
+		return gpu.GenericErr.Errorf("cannot disable MIG mode on GPU %d: GPU has %d GPU instances", gpuIndex, len(g.gpuInstances))
+	}
+	g.migEnabled = enabled
+	return nil
+}
+
+func (c *SimulatedClient) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, g := range c.gpus {
+		if !g.migEnabled {
+			continue
+		}
+		kept := make([]*simulatedGpuInstance, 0, len(g.gpuInstances))
+		for _, gi := range g.gpuInstances {
+			keptCis := make([]simulatedComputeInstance, 0, len(gi.computeInstances))
+			for _, ci := range gi.computeInstances {
+				if util.InSlice(ci.uuid, migDeviceIds) {
+					keptCis = append(keptCis, ci)
+				}
+			}
+			if len(keptCis) > 0 {
+				gi.computeInstances = keptCis
+				kept = append(kept, gi)
+			}
+		}
+		g.gpuInstances = kept
+	}
+	return nil
+}
+
+func (c *SimulatedClient) GetGpuInstanceProfiles(gpuIndex int) ([]GpuInstanceProfile, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	g, err := c.getGpu(gpuIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !g.migEnabled {
+		return nil, gpu.GenericErr.Errorf("GPU %d does not have MIG mode enabled", gpuIndex)
+	}
+	profiles := simulatedModels[g.model].profiles
+	res := make([]GpuInstanceProfile, len(profiles))
+	for i, p := range profiles {
+		res[i] = GpuInstanceProfile{
+			Name:       p.Name,
+			Placements: append(make([]GpuInstancePlacement, 0, len(p.Placements)), p.Placements...),
+		}
+	}
+	return res, nil
+}
+
+func (c *SimulatedClient) GetMigDevicePlacements() (map[string]GpuInstancePlacement, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	res := make(map[string]GpuInstancePlacement)
+	for _, g := range c.gpus {
+		for _, gi := range g.gpuInstances {
+			for _, ci := range gi.computeInstances {
+				res[ci.uuid] = gi.placement
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *SimulatedClient) GetGpuModels() ([]gpu.Model, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	models := make([]gpu.Model, 0)
+	for _, g := range c.gpus {
+		if !util.InSlice(g.model, models) {
+			models = append(models, g.model)
+		}
+	}
+	return models, nil
+}
+
+// GetDevicesUtilization returns the utilization of the simulated GPUs and of their MIG devices. Simulated devices
+// are always idle, and their total memory is the one of their MIG profile, or of the largest profile of the GPU model
+// for whole GPUs.
+func (c *SimulatedClient) GetDevicesUtilization() ([]DeviceUtilization, gpu.Error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	res := make([]DeviceUtilization, 0)
+	for i, g := range c.gpus {
+		profiles := simulatedModels[g.model].profiles
+		res = append(res, DeviceUtilization{
+			DeviceId:               g.uuid,
+			GpuIndex:               i,
+			SmUtilizationSupported: true,
+			MemoryTotalBytes:       getSimulatedProfileMemoryBytes(profiles[len(profiles)-1].Name),
+		})
+		for _, gi := range g.gpuInstances {
+			for _, ci := range gi.computeInstances {
+				res = append(res, DeviceUtilization{
+					DeviceId:         ci.uuid,
+					GpuIndex:         i,
+					MemoryTotalBytes: getSimulatedProfileMemoryBytes(gi.profile),
+				})
+			}
+		}
+	}
+	return res, nil
+}
+
+// getSimulatedProfileMemoryBytes returns the memory of the MIG profile provided as argument, in bytes
+func getSimulatedProfileMemoryBytes(profile string) uint64 {
+	match := simulatedProfileMemoryRegex.FindStringSubmatch(profile)
+	if match == nil {
+		return 0
+	}
+	gb, _ := strconv.ParseUint(match[1], 10, 64)
+	return gb << 30
+}
+
+// GetAllocatableDevices returns the devices that the NVIDIA device plugin would expose to the Kubelet
+// for the simulated GPUs: the MIG devices of the GPUs with MIG mode enabled and the other GPUs as a whole.
+func (c *SimulatedClient) GetAllocatableDevices(_ context.Context) ([]resource.Device, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	devices := make([]resource.Device, 0)
+	for _, g := range c.gpus {
+		if !g.migEnabled {
+			devices = append(devices, resource.Device{
+				ResourceName: constant.ResourceNvidiaGPU,
+				DeviceId:     g.uuid,
+				Status:       resource.StatusUnknown,
+			})
+			continue
+		}
+		for _, gi := range g.gpuInstances {
+			for _, ci := range gi.computeInstances {
+				devices = append(devices, resource.Device{
+					ResourceName: v1.ResourceName(constant.NvidiaMigResourcePrefix + ci.profile),
+					DeviceId:     ci.uuid,
+					Status:       resource.StatusUnknown,
+				})
+			}
+		}
+	}
+	return devices, nil
+}
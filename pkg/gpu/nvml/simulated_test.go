@@ -0,0 +1,346 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nvml_test
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
+	"github.com/nebuly-ai/nos/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewSimulatedClient(t *testing.T) {
+	_, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{{Model: "unknown"}})
+	assert.Error(t, err)
+
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_A100_SXM4_40GB, MigEnabled: true},
+		{Model: gpu.GPUModel_A30},
+		{Model: gpu.GPUModel_A100_SXM4_40GB, MigEnabled: true},
+	})
+	require.NoError(t, err)
+
+	migGpus, err := c.GetMigEnabledGPUs()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, migGpus)
+
+	models, err := c.GetGpuModels()
+	assert.NoError(t, err)
+	assert.Equal(t, []gpu.Model{gpu.GPUModel_A100_SXM4_40GB, gpu.GPUModel_A30}, models)
+
+	_, err = c.GetGpuInstanceProfiles(1)
+	assert.Error(t, err)
+	_, err = c.GetGpuInstanceProfiles(3)
+	assert.True(t, gpu.IsNotFound(err))
+	profiles, err := c.GetGpuInstanceProfiles(0)
+	assert.NoError(t, err)
+	assert.Len(t, profiles, 5)
+}
+
+func TestSimulatedClient__CreateMigDevices(t *testing.T) {
+	testCases := []struct {
+		name        string
+		model       gpu.Model
+		existing    []string
+		profiles    []string
+		expectedErr bool
+		expectedLen int
+	}{
+		{
+			name:        "all the devices fit",
+			model:       gpu.GPUModel_A100_SXM4_40GB,
+			profiles:    []string{"1g.5gb", "1g.5gb", "2g.10gb", "3g.20gb"},
+			expectedErr: false,
+			expectedLen: 4,
+		},
+		{
+			name:        "devices are created regardless of the order of the profiles",
+			model:       gpu.GPUModel_A30,
+			profiles:    []string{"1g.6gb", "1g.6gb", "2g.12gb"},
+			expectedErr: false,
+			expectedLen: 3,
+		},
+		{
+			name:        "not enough free slices, no device is created",
+			model:       gpu.GPUModel_A30,
+			existing:    []string{"2g.12gb"},
+			profiles:    []string{"1g.6gb", "2g.12gb"},
+			expectedErr: true,
+			expectedLen: 1,
+		},
+		{
+			name:        "profile not supported by the GPU model",
+			model:       gpu.GPUModel_A30,
+			profiles:    []string{"1g.5gb"},
+			expectedErr: true,
+			expectedLen: 0,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{{Model: tt.model, MigEnabled: true}})
+			require.NoError(t, err)
+			if len(tt.existing) > 0 {
+				require.NoError(t, c.CreateMigDevices(tt.existing, 0))
+			}
+
+			err = c.CreateMigDevices(tt.profiles, 0)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			placements, err := c.GetMigDevicePlacements()
+			assert.NoError(t, err)
+			assert.Len(t, placements, tt.expectedLen)
+		})
+	}
+}
+
+func TestSimulatedClient__CreateMigDeviceWithPlacement(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_H100_SXM5_80GB, MigEnabled: true},
+		{Model: gpu.GPUModel_H100_SXM5_80GB},
+	})
+	require.NoError(t, err)
+
+	// Invalid placement for the profile
+	err = c.CreateMigDeviceWithPlacement("3g.40gb", nvml.GpuInstancePlacement{Start: 2, Size: 4}, 0)
+	assert.Error(t, err)
+
+	// Valid placements
+	err = c.CreateMigDeviceWithPlacement("3g.40gb", nvml.GpuInstancePlacement{Start: 4, Size: 4}, 0)
+	assert.NoError(t, err)
+	err = c.CreateMigDeviceWithPlacement("1g.20gb", nvml.GpuInstancePlacement{Start: 2, Size: 2}, 0)
+	assert.NoError(t, err)
+
+	// Overlapping placement
+	err = c.CreateMigDeviceWithPlacement("2g.20gb", nvml.GpuInstancePlacement{Start: 2, Size: 2}, 0)
+	assert.Error(t, err)
+
+	// GPU without MIG mode enabled
+	err = c.CreateMigDeviceWithPlacement("1g.10gb", nvml.GpuInstancePlacement{Start: 0, Size: 1}, 1)
+	assert.Error(t, err)
+
+	placements, err := c.GetMigDevicePlacements()
+	assert.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]nvml.GpuInstancePlacement{{Start: 4, Size: 4}, {Start: 2, Size: 2}},
+		valuesOf(placements),
+	)
+}
+
+func TestSimulatedClient__DeleteMigDevices(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_A30, MigEnabled: true},
+		{Model: gpu.GPUModel_A30, MigEnabled: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.CreateMigDevices([]string{"1g.6gb", "1g.6gb", "2g.12gb"}, 0))
+	require.NoError(t, c.CreateMigDevices([]string{"4g.24gb"}, 1))
+
+	devices, err := c.GetAllocatableDevices(context.Background())
+	require.NoError(t, err)
+	require.Len(t, devices, 4)
+	for _, d := range devices {
+		gpuIndex, err := c.GetMigDeviceGpuIndex(d.DeviceId)
+		assert.NoError(t, err)
+		if d.ResourceName == constant.NvidiaMigResourcePrefix+"4g.24gb" {
+			assert.Equal(t, 1, gpuIndex)
+		} else {
+			assert.Equal(t, 0, gpuIndex)
+		}
+	}
+
+	// Delete single device
+	assert.NoError(t, c.DeleteMigDevice(devices[0].DeviceId))
+	assert.True(t, gpu.IsNotFound(c.DeleteMigDevice(devices[0].DeviceId)))
+	_, err = c.GetMigDeviceGpuIndex(devices[0].DeviceId)
+	assert.True(t, gpu.IsNotFound(err))
+
+	// Delete all except one
+	assert.NoError(t, c.DeleteAllMigDevicesExcept([]string{devices[1].DeviceId}))
+	remaining, err := c.GetAllocatableDevices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, devices[1:2], remaining)
+
+	// Created devices always get new IDs
+	require.NoError(t, c.CreateMigDevices([]string{"4g.24gb"}, 1))
+	remaining, err = c.GetAllocatableDevices(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 2)
+	for _, d := range devices {
+		if d.DeviceId != devices[1].DeviceId {
+			assert.NotContains(t, remaining, d)
+		}
+	}
+}
+
+func TestSimulatedClient__GetAllocatableDevices(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_A30},
+		{Model: gpu.GPUModel_A30, MigEnabled: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.CreateMigDevices([]string{"2g.12gb"}, 1))
+
+	devices, err := c.GetAllocatableDevices(context.Background())
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, constant.ResourceNvidiaGPU, devices[0].ResourceName)
+	gpuIndex, err := c.GetGpuIndex(devices[0].DeviceId)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, gpuIndex)
+	assert.Equal(t, constant.NvidiaMigResourcePrefix+"2g.12gb", devices[1].ResourceName.String())
+}
+
+func TestSimulatedClient__SetMigMode(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_A30},
+		{Model: gpu.GPUModel_A30, MigEnabled: true},
+	})
+	require.NoError(t, err)
+
+	indexes, err := c.GetGpuIndexes()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, indexes)
+	_, err = c.GetMigMode(2)
+	assert.True(t, gpu.IsNotFound(err))
+
+	// Enable MIG mode
+	require.NoError(t, c.SetMigMode(0, true))
+	mode, err := c.GetMigMode(0)
+	assert.NoError(t, err)
+	assert.Equal(t, nvml.MigMode{Enabled: true, PendingEnabled: true}, mode)
+	assert.False(t, mode.IsPendingReset())
+	migGpus, err := c.GetMigEnabledGPUs()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, migGpus)
+
+	// MIG mode cannot be disabled while the GPU has MIG devices
+	require.NoError(t, c.CreateMigDevices([]string{"2g.12gb"}, 1))
+	assert.Error(t, c.SetMigMode(1, false))
+	require.NoError(t, c.DeleteAllMigDevicesExcept(nil))
+	require.NoError(t, c.SetMigMode(1, false))
+	mode, err = c.GetMigMode(1)
+	assert.NoError(t, err)
+	assert.Equal(t, nvml.MigMode{}, mode)
+
+	devices, err := c.GetAllocatableDevices(context.Background())
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, constant.ResourceNvidiaGPU, devices[0].ResourceName)
+}
+
+func TestSimulatedClient__ComputeInstanceProfiles(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{{Model: gpu.GPUModel_A100_SXM4_40GB, MigEnabled: true}})
+	require.NoError(t, err)
+
+	// Invalid compute instance profiles
+	assert.Error(t, c.CreateMigDevices([]string{"4c.3g.20gb"}, 0))
+	assert.Error(t, c.CreateMigDevices([]string{"1c.3g.40gb"}, 0))
+
+	// Devices are packed into the fewest GPU instances
+	require.NoError(t, c.CreateMigDevices([]string{"1c.3g.20gb", "1c.3g.20gb", "1c.3g.20gb", "1c.3g.20gb"}, 0))
+	placements, err := c.GetMigDevicePlacements()
+	require.NoError(t, err)
+	assert.Len(t, placements, 4)
+	assert.ElementsMatch(
+		t,
+		[]nvml.GpuInstancePlacement{{Start: 0, Size: 4}, {Start: 4, Size: 4}},
+		distinct(valuesOf(placements)),
+	)
+
+	// New devices are added to the GPU instance with spare capacity
+	require.NoError(t, c.CreateMigDevices([]string{"1c.3g.20gb", "1c.3g.20gb"}, 0))
+	assert.Error(t, c.CreateMigDevices([]string{"1c.3g.20gb"}, 0))
+	placements, err = c.GetMigDevicePlacements()
+	require.NoError(t, err)
+	assert.Len(t, placements, 6)
+
+	devices, err := c.GetAllocatableDevices(context.Background())
+	require.NoError(t, err)
+	for _, d := range devices {
+		assert.Equal(t, constant.NvidiaMigResourcePrefix+"1c.3g.20gb", d.ResourceName.String())
+	}
+
+	// Deleting a device keeps the GPU instance until all its devices are deleted
+	var sameGi []string
+	for id, p := range placements {
+		if p.Start == 0 {
+			sameGi = append(sameGi, id)
+		}
+	}
+	require.Len(t, sameGi, 3)
+	require.NoError(t, c.DeleteMigDevice(sameGi[0]))
+	assert.Error(t, c.CreateMigDeviceWithPlacement("3g.20gb", nvml.GpuInstancePlacement{Start: 0, Size: 4}, 0))
+	assert.NoError(t, c.CreateMigDeviceWithPlacement("1c.3g.20gb", nvml.GpuInstancePlacement{Start: 0, Size: 4}, 0))
+	assert.Error(t, c.CreateMigDeviceWithPlacement("1c.3g.20gb", nvml.GpuInstancePlacement{Start: 0, Size: 4}, 0))
+	require.NoError(t, c.DeleteAllMigDevicesExcept([]string{sameGi[1]}))
+	placements, err = c.GetMigDevicePlacements()
+	require.NoError(t, err)
+	assert.Len(t, placements, 1)
+	require.NoError(t, c.DeleteMigDevice(sameGi[1]))
+	assert.NoError(t, c.CreateMigDeviceWithPlacement("3g.20gb", nvml.GpuInstancePlacement{Start: 0, Size: 4}, 0))
+}
+
+func distinct(placements []nvml.GpuInstancePlacement) []nvml.GpuInstancePlacement {
+	res := make([]nvml.GpuInstancePlacement, 0, len(placements))
+	for _, p := range placements {
+		if !util.InSlice(p, res) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func valuesOf(m map[string]nvml.GpuInstancePlacement) []nvml.GpuInstancePlacement {
+	res := make([]nvml.GpuInstancePlacement, 0, len(m))
+	for _, v := range m {
+		res = append(res, v)
+	}
+	return res
+}
+
+func TestSimulatedClient__GetDevicesUtilization(t *testing.T) {
+	c, err := nvml.NewSimulatedClient([]nvml.SimulatedGpu{
+		{Model: gpu.GPUModel_A30},
+		{Model: gpu.GPUModel_A30, MigEnabled: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.CreateMigDevices([]string{"2g.12gb"}, 1))
+
+	utilizations, err := c.GetDevicesUtilization()
+	require.NoError(t, err)
+	require.Len(t, utilizations, 3)
+	assert.Equal(t, nvml.DeviceUtilization{
+		DeviceId:               "GPU-simulated-0",
+		GpuIndex:               0,
+		SmUtilizationSupported: true,
+		MemoryTotalBytes:       24 << 30,
+	}, utilizations[0])
+	assert.Equal(t, "GPU-simulated-1", utilizations[1].DeviceId)
+	assert.Equal(t, 1, utilizations[2].GpuIndex)
+	assert.False(t, utilizations[2].SmUtilizationSupported)
+	assert.Equal(t, uint64(12<<30), utilizations[2].MemoryTotalBytes)
+}
@@ -88,6 +88,9 @@ const (
 	PartitioningKindMig    PartitioningKind = "mig"
 	PartitioningKindMps    PartitioningKind = "mps"
 	PartitioningKindHybrid PartitioningKind = "hybrid"
+	// PartitioningKindTimeSlicing shares the GPUs by creating replicas that get time-sliced access to the
+	// whole GPU, for GPUs supporting neither MIG nor MPS memory limits
+	PartitioningKindTimeSlicing PartitioningKind = "time-slicing"
 )
 
 // IsMigPartitioningEnabled returns true if the node is enabled for automatic MIG GPU partitioning, false otherwise
@@ -109,6 +112,26 @@ func IsMpsPartitioningEnabled(node v1.Node) bool {
 	return partitioningKind == PartitioningKindMps.String()
 }
 
+// IsHybridPartitioningEnabled returns true if the node is enabled for automatic hybrid GPU partitioning,
+// in which each GPU can be either MIG-partitioned or MPS-sliced, false otherwise
+func IsHybridPartitioningEnabled(node v1.Node) bool {
+	partitioningKind, ok := node.Labels[v1alpha1.LabelGpuPartitioning]
+	if !ok {
+		return false
+	}
+	return partitioningKind == PartitioningKindHybrid.String()
+}
+
+// IsTimeSlicingPartitioningEnabled returns true if the node is enabled for
+// automatic time-slicing GPU partitioning, false otherwise
+func IsTimeSlicingPartitioningEnabled(node v1.Node) bool {
+	partitioningKind, ok := node.Labels[v1alpha1.LabelGpuPartitioning]
+	if !ok {
+		return false
+	}
+	return partitioningKind == PartitioningKindTimeSlicing.String()
+}
+
 func GetPartitioningKind(node v1.Node) (PartitioningKind, bool) {
 	partitioningKindStr, ok := node.Labels[v1alpha1.LabelGpuPartitioning]
 	if !ok {
@@ -129,6 +152,8 @@ func asPartitioningKind(kind string) (PartitioningKind, bool) {
 		return PartitioningKindMps, true
 	case PartitioningKindHybrid.String():
 		return PartitioningKindHybrid, true
+	case PartitioningKindTimeSlicing.String():
+		return PartitioningKindTimeSlicing, true
 	default:
 		return "", false
 	}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PlanId uniquely identifies a GPU partitioning plan.
+//
+// A PlanId is composed of the kind of partitioning of the plan and of its generation. Generations are
+// derived from the current time in microseconds and are strictly increasing among all the plan IDs
+// created by the same process, so two plans can never have the same ID, and the generations
+// of two plans tell which of them is the most recent one.
+type PlanId struct {
+	Kind       PartitioningKind
+	Generation int64
+}
+
+var planGeneration = struct {
+	sync.Mutex
+	last int64
+}{}
+
+// NewPlanId returns a new PlanId for the kind of partitioning provided as argument,
+// whose generation is greater than the one of any other PlanId previously returned
+func NewPlanId(kind PartitioningKind) PlanId {
+	planGeneration.Lock()
+	defer planGeneration.Unlock()
+	generation := time.Now().UTC().UnixMicro()
+	if generation <= planGeneration.last {
+		generation = planGeneration.last + 1
+	}
+	planGeneration.last = generation
+	return PlanId{Kind: kind, Generation: generation}
+}
+
+// ParsePlanId parses the string representation of a PlanId, which has the format <kind>-<generation>.
+//
+// For backward compatibility, plan IDs made only of a generation are parsed as plan IDs without kind.
+func ParsePlanId(s string) (PlanId, error) {
+	var res PlanId
+	generationStr := s
+	// Kinds may contain dashes, so the generation is the part after the last one
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		kindStr, g := s[:i], s[i+1:]
+		kind, valid := asPartitioningKind(kindStr)
+		if !valid {
+			return res, fmt.Errorf("invalid plan id %q: unknown partitioning kind %q", s, kindStr)
+		}
+		res.Kind = kind
+		generationStr = g
+	}
+	generation, err := strconv.ParseInt(generationStr, 10, 64)
+	if err != nil || generation <= 0 {
+		return PlanId{}, fmt.Errorf("invalid plan id %q: generation must be a positive integer", s)
+	}
+	res.Generation = generation
+	return res, nil
+}
+
+func (p PlanId) String() string {
+	if p.Kind == "" {
+		return strconv.FormatInt(p.Generation, 10)
+	}
+	return fmt.Sprintf("%s-%d", p.Kind, p.Generation)
+}
+
+// IsZero returns true if the PlanId does not identify any plan
+func (p PlanId) IsZero() bool {
+	return p.Generation == 0
+}
+
+// IsOlderThan returns true if the plan identified by p has been created before
+// the one identified by other
+func (p PlanId) IsOlderThan(other PlanId) bool {
+	return p.Generation < other.Generation
+}
+
+// CreationTime returns the approximate time at which the plan identified by p has been created.
+// Plan IDs without kind have generations expressed in seconds, while all the others have generations
+// expressed in microseconds.
+func (p PlanId) CreationTime() time.Time {
+	if p.Kind == "" {
+		return time.Unix(p.Generation, 0).UTC()
+	}
+	return time.UnixMicro(p.Generation).UTC()
+}
+
+// GetLastPlanId returns the ID of the last partitioning plan applied to the node, and
+// false if no valid plan has been applied to it yet.
+//
+// Nodes partitioned by the MIG agent store the plan in an annotation, while nodes partitioned only
+// through the NVIDIA device plugin store it in the name of their device plugin config.
+func GetLastPlanId(node v1.Node) (PlanId, bool) {
+	planIdStr := node.Annotations[v1alpha1.AnnotationPartitioningPlan]
+	if planIdStr == "" {
+		config := node.Labels[constant.LabelNvidiaDevicePluginConfig]
+		if !strings.HasPrefix(config, node.Name+"-") {
+			return PlanId{}, false
+		}
+		planIdStr = strings.TrimPrefix(config, node.Name+"-")
+	}
+	planId, err := ParsePlanId(planIdStr)
+	if err != nil {
+		return PlanId{}, false
+	}
+	return planId, true
+}
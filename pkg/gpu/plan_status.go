@@ -0,0 +1,180 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PlanOutcome is the outcome of the application of a partitioning plan on a node
+type PlanOutcome string
+
+const (
+	// PlanOutcomeApplied means that all the operations of the plan succeeded
+	PlanOutcomeApplied PlanOutcome = "Applied"
+	// PlanOutcomePartiallyApplied means that only some of the operations of the plan succeeded
+	PlanOutcomePartiallyApplied PlanOutcome = "PartiallyApplied"
+	// PlanOutcomeFailed means that none of the operations of the plan succeeded
+	PlanOutcomeFailed PlanOutcome = "Failed"
+)
+
+// PlanOperationType is the type of operation performed on a GPU for applying a partitioning plan
+type PlanOperationType string
+
+const (
+	PlanOperationCreate PlanOperationType = "create"
+	PlanOperationDelete PlanOperationType = "delete"
+)
+
+// PlanOperationResult is the result of an operation performed on a GPU for applying a partitioning plan
+type PlanOperationResult struct {
+	Type     PlanOperationType `json:"type"`
+	GpuIndex int               `json:"gpuIndex"`
+	Profile  string            `json:"profile"`
+	Quantity int               `json:"quantity"`
+	// Error is the reason why the operation failed, empty if the operation succeeded
+	Error string `json:"error,omitempty"`
+}
+
+func (r PlanOperationResult) String() string {
+	res := fmt.Sprintf("%s %dx%s on GPU %d", r.Type, r.Quantity, r.Profile, r.GpuIndex)
+	if r.Error != "" {
+		res = fmt.Sprintf("%s: %s", res, r.Error)
+	}
+	return res
+}
+
+// PlanStatus is the outcome of the application of a partitioning plan on a node, as reported by the
+// agent running on the node through the AnnotationPartitioningPlanStatus annotation.
+type PlanStatus struct {
+	PlanId    string                `json:"planId"`
+	Outcome   PlanOutcome           `json:"outcome"`
+	Time      time.Time             `json:"time"`
+	Succeeded []PlanOperationResult `json:"succeeded,omitempty"`
+	Failed    []PlanOperationResult `json:"failed,omitempty"`
+}
+
+// NewPlanStatus returns the status of the plan with the ID provided as argument, computing its outcome
+// from the operations that succeeded and the ones that failed. A plan without any operation is applied.
+func NewPlanStatus(planId PlanId, succeeded, failed []PlanOperationResult) PlanStatus {
+	outcome := PlanOutcomeApplied
+	if len(failed) > 0 {
+		outcome = PlanOutcomePartiallyApplied
+		if len(succeeded) == 0 {
+			outcome = PlanOutcomeFailed
+		}
+	}
+	return PlanStatus{
+		PlanId:    planId.String(),
+		Outcome:   outcome,
+		Time:      time.Now().UTC().Truncate(time.Second),
+		Succeeded: succeeded,
+		Failed:    failed,
+	}
+}
+
+// ParsePlanStatus parses the JSON representation of a PlanStatus
+func ParsePlanStatus(s string) (PlanStatus, error) {
+	var res PlanStatus
+	if err := json.Unmarshal([]byte(s), &res); err != nil {
+		return PlanStatus{}, fmt.Errorf("invalid plan status: %s", err)
+	}
+	if _, err := ParsePlanId(res.PlanId); err != nil {
+		return PlanStatus{}, fmt.Errorf("invalid plan status: %s", err)
+	}
+	switch res.Outcome {
+	case PlanOutcomeApplied, PlanOutcomePartiallyApplied, PlanOutcomeFailed:
+	default:
+		return PlanStatus{}, fmt.Errorf("invalid plan status: unknown outcome %q", res.Outcome)
+	}
+	return res, nil
+}
+
+// String returns the JSON representation of the PlanStatus
+func (s PlanStatus) String() string {
+	res, _ := json.Marshal(s)
+	return string(res)
+}
+
+// IsFailed returns true if any of the operations of the plan failed
+func (s PlanStatus) IsFailed() bool {
+	return s.Outcome != PlanOutcomeApplied
+}
+
+// Equal returns true if the two statuses refer to the same plan and report the same operations,
+// regardless of the time at which they have been reported
+func (s PlanStatus) Equal(other PlanStatus) bool {
+	s.Time = time.Time{}
+	other.Time = time.Time{}
+	return s.String() == other.String()
+}
+
+// GetFailedGpuIndexes returns the sorted indexes of the GPUs on which at least one operation failed
+func (s PlanStatus) GetFailedGpuIndexes() []int {
+	lookup := make(map[int]bool)
+	res := make([]int, 0)
+	for _, r := range s.Failed {
+		if !lookup[r.GpuIndex] {
+			lookup[r.GpuIndex] = true
+			res = append(res, r.GpuIndex)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// Message returns a human-readable summary of the failed operations of the plan
+func (s PlanStatus) Message() string {
+	if !s.IsFailed() {
+		return "all operations succeeded"
+	}
+	failed := make([]string, len(s.Failed))
+	for i, r := range s.Failed {
+		failed[i] = r.String()
+	}
+	return fmt.Sprintf(
+		"%d out of %d operations failed: %s",
+		len(s.Failed),
+		len(s.Failed)+len(s.Succeeded),
+		strings.Join(failed, "; "),
+	)
+}
+
+// GetLastPlanStatus returns the status reported by the node for the last partitioning plan applied to it,
+// and false if the node has not reported any valid status for that plan yet.
+func GetLastPlanStatus(node v1.Node) (PlanStatus, bool) {
+	planId, ok := GetLastPlanId(node)
+	if !ok {
+		return PlanStatus{}, false
+	}
+	value, ok := node.Annotations[v1alpha1.AnnotationPartitioningPlanStatus]
+	if !ok {
+		return PlanStatus{}, false
+	}
+	status, err := ParsePlanStatus(value)
+	if err != nil || status.PlanId != planId.String() {
+		return PlanStatus{}, false
+	}
+	return status, true
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpu_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlanStatus(t *testing.T) {
+	planId := gpu.NewPlanId(gpu.PartitioningKindMig)
+	succeeded := []gpu.PlanOperationResult{{Type: gpu.PlanOperationDelete, GpuIndex: 0, Profile: "1g.10gb", Quantity: 2}}
+	failed := []gpu.PlanOperationResult{{Type: gpu.PlanOperationCreate, GpuIndex: 1, Profile: "2g.20gb", Quantity: 1, Error: "err"}}
+
+	testCases := []struct {
+		name            string
+		succeeded       []gpu.PlanOperationResult
+		failed          []gpu.PlanOperationResult
+		expectedOutcome gpu.PlanOutcome
+	}{
+		{
+			name:            "No operations",
+			expectedOutcome: gpu.PlanOutcomeApplied,
+		},
+		{
+			name:            "All operations succeeded",
+			succeeded:       succeeded,
+			expectedOutcome: gpu.PlanOutcomeApplied,
+		},
+		{
+			name:            "Some operations failed",
+			succeeded:       succeeded,
+			failed:          failed,
+			expectedOutcome: gpu.PlanOutcomePartiallyApplied,
+		},
+		{
+			name:            "All operations failed",
+			failed:          failed,
+			expectedOutcome: gpu.PlanOutcomeFailed,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			status := gpu.NewPlanStatus(planId, tt.succeeded, tt.failed)
+			assert.Equal(t, planId.String(), status.PlanId)
+			assert.Equal(t, tt.expectedOutcome, status.Outcome)
+			assert.Equal(t, tt.expectedOutcome != gpu.PlanOutcomeApplied, status.IsFailed())
+
+			// String and Parse must be symmetric
+			parsed, err := gpu.ParsePlanStatus(status.String())
+			assert.NoError(t, err)
+			assert.True(t, status.Equal(parsed))
+			assert.True(t, status.Time.Equal(parsed.Time))
+		})
+	}
+}
+
+func TestParsePlanStatus(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{
+			name:        "Invalid JSON",
+			value:       "foo",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid plan ID",
+			value:       `{"planId":"foo-1","outcome":"Applied"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown outcome",
+			value:       `{"planId":"mig-1","outcome":"Unknown"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Valid status",
+			value:       `{"planId":"mig-1","outcome":"Failed","failed":[{"type":"create","gpuIndex":0,"profile":"1g.10gb","quantity":1,"error":"err"}]}`,
+			expectedErr: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gpu.ParsePlanStatus(tt.value)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPlanStatus__GetFailedGpuIndexes(t *testing.T) {
+	status := gpu.NewPlanStatus(gpu.NewPlanId(gpu.PartitioningKindMig), nil, []gpu.PlanOperationResult{
+		{Type: gpu.PlanOperationCreate, GpuIndex: 2, Profile: "1g.10gb", Quantity: 1, Error: "err"},
+		{Type: gpu.PlanOperationDelete, GpuIndex: 0, Profile: "2g.20gb", Quantity: 1, Error: "err"},
+		{Type: gpu.PlanOperationCreate, GpuIndex: 2, Profile: "2g.20gb", Quantity: 1, Error: "err"},
+	})
+	assert.Equal(t, []int{0, 2}, status.GetFailedGpuIndexes())
+	assert.Equal(
+		t,
+		"3 out of 3 operations failed: create 1x1g.10gb on GPU 2: err; delete 1x2g.20gb on GPU 0: err; create 1x2g.20gb on GPU 2: err",
+		status.Message(),
+	)
+}
+
+func TestGetLastPlanStatus(t *testing.T) {
+	planId := gpu.NewPlanId(gpu.PartitioningKindMig)
+	olderPlanId := gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: planId.Generation - 1}
+	status := gpu.NewPlanStatus(planId, nil, nil)
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expectedOk  bool
+	}{
+		{
+			name:        "No plan",
+			annotations: map[string]string{v1alpha1.AnnotationPartitioningPlanStatus: status.String()},
+			expectedOk:  false,
+		},
+		{
+			name:        "No status",
+			annotations: map[string]string{v1alpha1.AnnotationPartitioningPlan: planId.String()},
+			expectedOk:  false,
+		},
+		{
+			name: "Status of another plan",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:       planId.String(),
+				v1alpha1.AnnotationPartitioningPlanStatus: gpu.NewPlanStatus(olderPlanId, nil, nil).String(),
+			},
+			expectedOk: false,
+		},
+		{
+			name: "Invalid status",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:       planId.String(),
+				v1alpha1.AnnotationPartitioningPlanStatus: "foo",
+			},
+			expectedOk: false,
+		},
+		{
+			name: "Status of the last plan",
+			annotations: map[string]string{
+				v1alpha1.AnnotationPartitioningPlan:       planId.String(),
+				v1alpha1.AnnotationPartitioningPlanStatus: status.String(),
+			},
+			expectedOk: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).Get()
+			res, ok := gpu.GetLastPlanStatus(node)
+			assert.Equal(t, tt.expectedOk, ok)
+			if tt.expectedOk {
+				assert.True(t, status.Equal(res))
+				assert.WithinDuration(t, status.Time, res.Time, time.Second)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gpu_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlanId(t *testing.T) {
+	last := gpu.NewPlanId(gpu.PartitioningKindMig)
+	for i := 0; i < 1000; i++ {
+		kind := gpu.PartitioningKindMig
+		if i%2 == 0 {
+			kind = gpu.PartitioningKindMps
+		}
+		id := gpu.NewPlanId(kind)
+		assert.True(t, last.IsOlderThan(id))
+		assert.NotEqual(t, last.String(), id.String())
+		last = id
+	}
+}
+
+func TestParsePlanId(t *testing.T) {
+	testCases := []struct {
+		name        string
+		id          string
+		expected    gpu.PlanId
+		expectedErr bool
+	}{
+		{
+			name:        "Empty string",
+			id:          "",
+			expectedErr: true,
+		},
+		{
+			name:     "Plan id with kind",
+			id:       "mig-1680000000000000",
+			expected: gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: 1680000000000000},
+		},
+		{
+			name:     "Plan id with kind containing dashes",
+			id:       "time-slicing-1680000000000000",
+			expected: gpu.PlanId{Kind: gpu.PartitioningKindTimeSlicing, Generation: 1680000000000000},
+		},
+		{
+			name:     "Legacy plan id, without kind",
+			id:       "1680000000",
+			expected: gpu.PlanId{Generation: 1680000000},
+		},
+		{
+			name:        "Unknown kind",
+			id:          "foo-1680000000",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid generation",
+			id:          "mps-foo",
+			expectedErr: true,
+		},
+		{
+			name:        "Negative generation",
+			id:          "mps--1",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := gpu.ParsePlanId(tt.id)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+			assert.Equal(t, tt.id, res.String())
+		})
+	}
+}
+
+func TestGetLastPlanId(t *testing.T) {
+	testCases := []struct {
+		name          string
+		annotations   map[string]string
+		labels        map[string]string
+		expected      gpu.PlanId
+		expectedFound bool
+	}{
+		{
+			name:          "No plan",
+			expectedFound: false,
+		},
+		{
+			name:          "Plan from annotation",
+			annotations:   map[string]string{v1alpha1.AnnotationPartitioningPlan: "mig-10"},
+			labels:        map[string]string{constant.LabelNvidiaDevicePluginConfig: "node-1-mps-20"},
+			expected:      gpu.PlanId{Kind: gpu.PartitioningKindMig, Generation: 10},
+			expectedFound: true,
+		},
+		{
+			name:          "Plan from device plugin config",
+			labels:        map[string]string{constant.LabelNvidiaDevicePluginConfig: "node-1-mps-20"},
+			expected:      gpu.PlanId{Kind: gpu.PartitioningKindMps, Generation: 20},
+			expectedFound: true,
+		},
+		{
+			name:          "Device plugin config of another node",
+			labels:        map[string]string{constant.LabelNvidiaDevicePluginConfig: "node-10-mps-20"},
+			expectedFound: false,
+		},
+		{
+			name:          "Invalid plan",
+			annotations:   map[string]string{v1alpha1.AnnotationPartitioningPlan: "invalid"},
+			expectedFound: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := factory.BuildNode("node-1").WithAnnotations(tt.annotations).WithLabels(tt.labels).Get()
+			planId, found := gpu.GetLastPlanId(node)
+			assert.Equal(t, tt.expectedFound, found)
+			assert.Equal(t, tt.expected, planId)
+		})
+	}
+}
+
+func TestPlanId_CreationTime(t *testing.T) {
+	planId := gpu.NewPlanId(gpu.PartitioningKindMig)
+	assert.WithinDuration(t, time.Now(), planId.CreationTime(), time.Minute)
+
+	legacy, err := gpu.ParsePlanId("1672531200")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), legacy.CreationTime())
+}
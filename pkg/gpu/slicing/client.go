@@ -19,6 +19,7 @@ package slicing
 import (
 	"context"
 	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/mig"
 	"github.com/nebuly-ai/nos/pkg/gpu/nvml"
 	"github.com/nebuly-ai/nos/pkg/resource"
 	"github.com/nebuly-ai/nos/pkg/util"
@@ -59,9 +60,9 @@ func (c tsClient) GetUsedDevices(ctx context.Context) (gpu.DeviceList, gpu.Error
 	if err != nil {
 		return nil, gpu.NewGenericError(err)
 	}
-	// Consider only NVIDIA GPUs
+	// Consider only NVIDIA GPUs, excluding MIG devices of nodes with hybrid partitioning
 	var isNvidiaResource = func(d resource.Device) bool {
-		return d.IsNvidiaResource()
+		return d.IsNvidiaResource() && !mig.IsNvidiaMigDevice(d.ResourceName)
 	}
 	usedGpus := util.Filter(usedResources, isNvidiaResource)
 	// Convert to gpu.DeviceList
@@ -74,9 +75,9 @@ func (c tsClient) GetAllocatableDevices(ctx context.Context) (gpu.DeviceList, gp
 	if err != nil {
 		return nil, gpu.NewGenericError(err)
 	}
-	// Consider only NVIDIA GPUs
+	// Consider only NVIDIA GPUs, excluding MIG devices of nodes with hybrid partitioning
 	var isNvidiaResource = func(d resource.Device) bool {
-		return d.IsNvidiaResource()
+		return d.IsNvidiaResource() && !mig.IsNvidiaMigDevice(d.ResourceName)
 	}
 	allocatableGPUs := util.Filter(allocatableResources, isNvidiaResource)
 	// Extract MIG devices
@@ -22,4 +22,6 @@ const (
 	ReplicaGpuIdSeparator = "::"
 	// MinSliceMemoryGB is the smallest slice size that can be created on slicing shared GPUs.
 	MinSliceMemoryGB = 1
+	// MaxComputePercent is the overall compute share that can be assigned to the slices of a GPU.
+	MaxComputePercent = 100
 )
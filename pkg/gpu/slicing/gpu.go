@@ -65,34 +65,32 @@ func NewGpuOrPanic(model gpu.Model, index int, memoryGB int, usedProfiles, freeP
 }
 
 func (g *GPU) Validate() error {
-	var totalMemoryGB int
-	for p, q := range g.UsedProfiles {
-		mem := p.GetMemorySizeGB()
-		if mem < MinSliceMemoryGB {
-			return fmt.Errorf(
-				"min allowed slice size is %dGB, but profile %s has %dGB",
-				MinSliceMemoryGB,
-				p,
-				mem,
-			)
-		}
-		totalMemoryGB += mem * q
-	}
-	for p, q := range g.FreeProfiles {
-		mem := p.GetMemorySizeGB()
-		if mem < MinSliceMemoryGB {
-			return fmt.Errorf(
-				"min allowed slice size is %dGB, but profile %s has %dGB",
-				MinSliceMemoryGB,
-				p,
-				mem,
-			)
+	var totalMemoryGB, totalComputePercent int
+	for _, profiles := range []map[ProfileName]int{g.UsedProfiles, g.FreeProfiles} {
+		for p, q := range profiles {
+			mem := p.GetMemorySizeGB()
+			if mem < MinSliceMemoryGB {
+				return fmt.Errorf(
+					"min allowed slice size is %dGB, but profile %s has %dGB",
+					MinSliceMemoryGB,
+					p,
+					mem,
+				)
+			}
+			totalMemoryGB += mem * q
+			totalComputePercent += p.GetComputePercent() * q
 		}
-		totalMemoryGB += mem * q
 	}
 	if totalMemoryGB > g.MemoryGB {
 		return fmt.Errorf("total memory of profiles (%d) exceeds GPU memory (%d)", totalMemoryGB, g.MemoryGB)
 	}
+	if totalComputePercent > MaxComputePercent {
+		return fmt.Errorf(
+			"total compute share of profiles (%d%%) exceeds GPU compute (%d%%)",
+			totalComputePercent,
+			MaxComputePercent,
+		)
+	}
 	return nil
 }
 
@@ -156,7 +154,8 @@ func (g *GPU) AddPod(pod v1.Pod) error {
 }
 
 // UpdateGeometryFor tries to update the geometry of the GPU in order to create the highest possible number of required
-// slices provided as argument, without deleting any of the used slices.
+// slices provided as argument, without deleting any of the used slices. Slices are created only if the GPU has
+// enough spare memory and, for the profiles with a compute share, enough spare compute.
 //
 // The method returns true if the GPU geometry gets updated, false otherwise.
 func (g *GPU) UpdateGeometryFor(slices map[gpu.Slice]int) bool {
@@ -176,19 +175,16 @@ func (g *GPU) UpdateGeometryFor(slices map[gpu.Slice]int) bool {
 		sortedMissingSlices = append(sortedMissingSlices, slice)
 	}
 	sort.SliceStable(sortedMissingSlices, func(i, j int) bool {
-		first := sortedMissingSlices[i].(ProfileName)
-		second := sortedMissingSlices[j].(ProfileName)
-		return first.GetMemorySizeGB() < second.GetMemorySizeGB()
+		return sortedMissingSlices[i].SmallerThan(sortedMissingSlices[j])
 	})
 
 	for _, s := range sortedMissingSlices {
 		missingProfile := s.(ProfileName)
-		missingProfileMemory := missingProfile.GetMemorySizeGB()
 		// first try to create the missing slices by using spare capacity
 		if g.canCreateMoreSlices() {
 			q := missingSlices[missingProfile]
 			for i := 0; i < q; i++ {
-				if err := g.createSlice(missingProfileMemory); err != nil {
+				if err := g.createSlice(missingProfile); err != nil {
 					break
 				}
 				missingSlices[missingProfile]--
@@ -204,7 +200,7 @@ func (g *GPU) UpdateGeometryFor(slices map[gpu.Slice]int) bool {
 			if !g.canCreateMoreSlices() {
 				break
 			}
-			if err := g.createSlice(missingProfileMemory); err != nil {
+			if err := g.createSlice(missingProfile); err != nil {
 				break
 			}
 			missingSlices[missingProfile]--
@@ -212,13 +208,72 @@ func (g *GPU) UpdateGeometryFor(slices map[gpu.Slice]int) bool {
 		}
 		// try to restore the original free slices
 		for k, v := range originalFreeProfiles {
-			_ = g.createSlices(k.GetMemorySizeGB(), v)
+			_ = g.createSlices(k, v)
 		}
 	}
 
 	return updated
 }
 
+// Compact deletes all the slices of the GPU if none of them is used, so that the GPU is exposed as a
+// single full GPU. Otherwise, it merges the free slices of the GPU into larger slices, so that scattered
+// free slices do not prevent from creating larger slices. Only slices of the same compute class are merged:
+// the free slices without compute share are merged into a single slice without compute share, while the free
+// slices with a compute share are merged into a single slice whose compute share is the sum of their shares,
+// capped to MaxComputePercent.
+//
+// It returns true if the geometry of the GPU changes, false otherwise.
+func (g *GPU) Compact() bool {
+	var nUsed, nFree int
+	for _, q := range g.UsedProfiles {
+		nUsed += q
+	}
+	for _, q := range g.FreeProfiles {
+		nFree += q
+	}
+
+	// Reset GPU to full GPU
+	if nUsed == 0 && nFree > 0 {
+		g.UsedProfiles = make(map[ProfileName]int)
+		g.FreeProfiles = make(map[ProfileName]int)
+		return true
+	}
+
+	// Merge free slices of the same compute class
+	var nShared, nLimited, sharedMemoryGB, limitedMemoryGB, limitedComputePercent int
+	for p, q := range g.FreeProfiles {
+		if p.GetComputePercent() == 0 {
+			nShared += q
+			sharedMemoryGB += p.GetMemorySizeGB() * q
+			continue
+		}
+		nLimited += q
+		limitedMemoryGB += p.GetMemorySizeGB() * q
+		limitedComputePercent += p.GetComputePercent() * q
+	}
+	if nShared <= 1 && nLimited <= 1 {
+		return false
+	}
+	compacted := make(map[ProfileName]int)
+	for p, q := range g.FreeProfiles {
+		isShared := p.GetComputePercent() == 0
+		if isShared && nShared <= 1 || !isShared && nLimited <= 1 {
+			compacted[p] += q
+		}
+	}
+	if nShared > 1 {
+		compacted[NewProfile(sharedMemoryGB)]++
+	}
+	if nLimited > 1 {
+		if limitedComputePercent > MaxComputePercent {
+			limitedComputePercent = MaxComputePercent
+		}
+		compacted[NewProfileWithCompute(limitedMemoryGB, limitedComputePercent)]++
+	}
+	g.FreeProfiles = compacted
+	return true
+}
+
 func (g *GPU) getMissingSlices(required map[gpu.Slice]int) map[gpu.Slice]int {
 	var missingSlices = make(map[gpu.Slice]int)
 	for requiredSlice, requiredQuantity := range required {
@@ -231,17 +286,22 @@ func (g *GPU) getMissingSlices(required map[gpu.Slice]int) map[gpu.Slice]int {
 	return missingSlices
 }
 
-func (g *GPU) createSlice(sizeGb int) error {
-	return g.createSlices(sizeGb, 1)
+func (g *GPU) createSlice(profile ProfileName) error {
+	return g.createSlices(profile, 1)
 }
 
-func (g *GPU) createSlices(sizeGb, num int) error {
-	totSlicesMemory := g.getTotSlicesMemory()
-	spareMemory := g.MemoryGB - totSlicesMemory
+func (g *GPU) createSlices(profile ProfileName, num int) error {
+	sizeGb := profile.GetMemorySizeGB()
+	spareMemory := g.MemoryGB - g.getTotSlicesMemory()
 	if spareMemory < sizeGb*num {
 		return fmt.Errorf("not enough spare memory to create %d slices of size %dGB", num, sizeGb)
 	}
-	sliceProfile := NewProfile(sizeGb)
+	computePercent := profile.GetComputePercent()
+	spareCompute := MaxComputePercent - g.getTotSlicesCompute()
+	if spareCompute < computePercent*num {
+		return fmt.Errorf("not enough spare compute to create %d slices with %d%% of compute", num, computePercent)
+	}
+	sliceProfile := NewProfileWithCompute(sizeGb, computePercent)
 	g.FreeProfiles[sliceProfile] += num
 	return nil
 }
@@ -263,3 +323,14 @@ func (g *GPU) getTotSlicesMemory() int {
 	}
 	return totSlicesMemory
 }
+
+func (g *GPU) getTotSlicesCompute() int {
+	var totSlicesCompute int
+	for p, q := range g.UsedProfiles {
+		totSlicesCompute += p.GetComputePercent() * q
+	}
+	for p, q := range g.FreeProfiles {
+		totSlicesCompute += p.GetComputePercent() * q
+	}
+	return totSlicesCompute
+}
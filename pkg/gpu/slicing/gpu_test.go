@@ -48,6 +48,20 @@ func TestNewGPU(t *testing.T) {
 			expected:    slicing.GPU{},
 			expectedErr: true,
 		},
+		{
+			name:     "Sum of profiles compute share exceeds GPU compute",
+			model:    gpu.GPUModel_A100_PCIe_80GB,
+			index:    0,
+			memoryGB: 40,
+			usedProfiles: map[slicing.ProfileName]int{
+				"10gb.50pct": 1,
+			},
+			freeProfiles: map[slicing.ProfileName]int{
+				"10gb.30pct": 2,
+			},
+			expected:    slicing.GPU{},
+			expectedErr: true,
+		},
 		{
 			name:     "Sum of profiles memory equal to GPU memory",
 			model:    gpu.GPUModel_A100_PCIe_80GB,
@@ -329,6 +343,47 @@ func TestGPU_UpdateGeometryFor(t *testing.T) {
 				slicing.ProfileName("15gb"): 1,
 			},
 		},
+		{
+			name: "Slices with compute share should not exceed GPU compute",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_PCIe_80GB,
+				0,
+				80,
+				map[slicing.ProfileName]int{
+					"10gb.50pct": 1,
+				},
+				map[slicing.ProfileName]int{},
+			),
+			requiredSlices: map[gpu.Slice]int{
+				slicing.ProfileName("10gb.20pct"): 3,
+			},
+			expectedUpdate: true,
+			expectedGeometry: map[gpu.Slice]int{
+				slicing.ProfileName("10gb.50pct"): 1,
+				slicing.ProfileName("10gb.20pct"): 2,
+			},
+		},
+		{
+			name: "Slices without compute share should only be limited by memory",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_PCIe_80GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb.100pct": 1,
+				},
+				map[slicing.ProfileName]int{},
+			),
+			requiredSlices: map[gpu.Slice]int{
+				slicing.ProfileName("10gb"):       2,
+				slicing.ProfileName("10gb.10pct"): 1,
+			},
+			expectedUpdate: true,
+			expectedGeometry: map[gpu.Slice]int{
+				slicing.ProfileName("10gb.100pct"): 1,
+				slicing.ProfileName("10gb"):        2,
+			},
+		},
 	}
 
 	for _, tt := range testCases {
@@ -385,3 +440,183 @@ func TestGPU__Clone(t *testing.T) {
 		})
 	}
 }
+
+func TestGPU__Compact(t *testing.T) {
+	testCases := []struct {
+		name              string
+		gpu               slicing.GPU
+		expectedCompacted bool
+		expectedGeometry  gpu.Geometry
+	}{
+		{
+			name:              "Full GPU, should not change anything",
+			gpu:               slicing.NewFullGPU(gpu.GPUModel_A100_SXM4_40GB, 0, 40),
+			expectedCompacted: false,
+			expectedGeometry:  gpu.Geometry{},
+		},
+		{
+			name: "GPU without used slices, should become full GPU",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{},
+				map[slicing.ProfileName]int{
+					"10gb": 2,
+					"20gb": 1,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry:  gpu.Geometry{},
+		},
+		{
+			name: "GPU with used slices, should merge free slices",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				map[slicing.ProfileName]int{
+					"5gb":  2,
+					"10gb": 1,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"): 1,
+				slicing.ProfileName("20gb"): 1,
+			},
+		},
+		{
+			name: "GPU with used slices, should merge memory and compute share of free slices",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb.50pct": 1,
+				},
+				map[slicing.ProfileName]int{
+					"5gb.10pct":  2,
+					"10gb.20pct": 1,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb.50pct"): 1,
+				slicing.ProfileName("20gb.40pct"): 1,
+			},
+		},
+		{
+			name: "GPU with used slices, should merge free slices only with slices of the same compute class",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				map[slicing.ProfileName]int{
+					"5gb":        2,
+					"5gb.10pct":  1,
+					"10gb.20pct": 1,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"):       2,
+				slicing.ProfileName("15gb.30pct"): 1,
+			},
+		},
+		{
+			name: "GPU with a free slice for each compute class, should not change anything",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				map[slicing.ProfileName]int{
+					"10gb":      1,
+					"5gb.30pct": 1,
+				},
+			),
+			expectedCompacted: false,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"):      2,
+				slicing.ProfileName("5gb.30pct"): 1,
+			},
+		},
+		{
+			name: "GPU with a single free slice without compute share, should merge only slices with compute share",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				map[slicing.ProfileName]int{
+					"10gb":      1,
+					"5gb.30pct": 2,
+				},
+			),
+			expectedCompacted: true,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"):       2,
+				slicing.ProfileName("10gb.60pct"): 1,
+			},
+		},
+		{
+			name: "Compute share of merged slices exceeding the GPU compute, should be capped",
+			gpu: slicing.GPU{
+				Model:    gpu.GPUModel_A100_SXM4_40GB,
+				Index:    0,
+				MemoryGB: 40,
+				UsedProfiles: map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				FreeProfiles: map[slicing.ProfileName]int{
+					"5gb.50pct": 1,
+					"5gb.60pct": 1,
+				},
+			},
+			expectedCompacted: true,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"):        1,
+				slicing.ProfileName("10gb.100pct"): 1,
+			},
+		},
+		{
+			name: "GPU with used slices and a single free slice, should not change anything",
+			gpu: slicing.NewGpuOrPanic(
+				gpu.GPUModel_A100_SXM4_40GB,
+				0,
+				40,
+				map[slicing.ProfileName]int{
+					"10gb": 1,
+				},
+				map[slicing.ProfileName]int{
+					"20gb": 1,
+				},
+			),
+			expectedCompacted: false,
+			expectedGeometry: gpu.Geometry{
+				slicing.ProfileName("10gb"): 1,
+				slicing.ProfileName("20gb"): 1,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			compacted := tt.gpu.Compact()
+			assert.Equal(t, tt.expectedCompacted, compacted)
+			assert.Equal(t, tt.expectedGeometry, tt.gpu.GetGeometry())
+			assert.NoError(t, tt.gpu.Validate())
+		})
+	}
+}
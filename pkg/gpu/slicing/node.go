@@ -151,6 +151,22 @@ func (n *Node) UpdateGeometryFor(slices map[gpu.Slice]int) (bool, error) {
 	return anyGpuUpdated, nil
 }
 
+// Compact resets the GPUs of the node without used slices to full GPUs and merges the free slices
+// of the other GPUs (see GPU.Compact), updating the node resources accordingly.
+//
+// The method returns true if it changes the geometry of any GPU, false otherwise.
+func (n *Node) Compact() (bool, error) {
+	var anyGpuCompacted bool
+	for i := range n.GPUs {
+		compacted := n.GPUs[i].Compact()
+		anyGpuCompacted = anyGpuCompacted || compacted
+	}
+	if anyGpuCompacted {
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+	}
+	return anyGpuCompacted, nil
+}
+
 func (n *Node) computeScalarResources() map[v1.ResourceName]int64 {
 	res := make(map[v1.ResourceName]int64)
 
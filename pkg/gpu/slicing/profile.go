@@ -27,10 +27,18 @@ import (
 )
 
 var (
-	profileNamePrefix = fmt.Sprintf("%s-", constant.ResourceNvidiaGPU.String())
-	resourceRegexp    = regexp.MustCompile(`nvidia\.com/gpu-\d+gb`)
+	profileNamePrefix    = fmt.Sprintf("%s-", constant.ResourceNvidiaGPU.String())
+	resourceRegexp       = regexp.MustCompile(`nvidia\.com/gpu-\d+gb(\.\d+pct)?`)
+	profileRegexp        = regexp.MustCompile(`^\d+gb(\.\d+pct)?$`)
+	profileMemoryRegexp  = regexp.MustCompile(`(\d+)gb`)
+	profileComputeRegexp = regexp.MustCompile(`\.(\d+)pct$`)
 )
 
+// ProfileName is the name of a slicing profile, which has the format <memory>gb[.<compute>pct].
+//
+// The memory is the amount of GPU memory of the slice in GB, while the optional compute is the
+// share of the GPU threads that the clients of the slice can use, as a percentage. Slices without
+// compute share can use all the threads of the GPU.
 type ProfileName string
 
 func (p ProfileName) SmallerThan(other gpu.Slice) bool {
@@ -38,7 +46,14 @@ func (p ProfileName) SmallerThan(other gpu.Slice) bool {
 	if !ok {
 		return false
 	}
-	return p.GetMemorySizeGB() < otherProfile.GetMemorySizeGB()
+	if p.GetMemorySizeGB() != otherProfile.GetMemorySizeGB() {
+		return p.GetMemorySizeGB() < otherProfile.GetMemorySizeGB()
+	}
+	return p.GetComputePercent() < otherProfile.GetComputePercent()
+}
+
+func (p ProfileName) isValid() bool {
+	return profileRegexp.MatchString(string(p))
 }
 
 func (p ProfileName) String() string {
@@ -49,10 +64,31 @@ func NewProfile(sizeGb int) ProfileName {
 	return ProfileName(fmt.Sprintf("%dgb", sizeGb))
 }
 
+// NewProfileWithCompute returns the profile with the memory and compute share provided as argument.
+// A compute share of zero means that the slice can use all the threads of the GPU.
+func NewProfileWithCompute(sizeGb int, computePercent int) ProfileName {
+	if computePercent == 0 {
+		return NewProfile(sizeGb)
+	}
+	return ProfileName(fmt.Sprintf("%dgb.%dpct", sizeGb, computePercent))
+}
+
 func (p ProfileName) GetMemorySizeGB() int {
-	trimmed := strings.TrimPrefix(p.String(), profileNamePrefix)
-	trimmed = strings.TrimSuffix(trimmed, "gb")
-	if i, err := strconv.Atoi(trimmed); err == nil {
+	return extractProfileInt(profileMemoryRegexp, p)
+}
+
+// GetComputePercent returns the share of the GPU threads that the clients of slices with this profile can use,
+// as a percentage. It returns zero if the profile does not define any compute share.
+func (p ProfileName) GetComputePercent() int {
+	return extractProfileInt(profileComputeRegexp, p)
+}
+
+func extractProfileInt(r *regexp.Regexp, p ProfileName) int {
+	matches := r.FindStringSubmatch(strings.TrimPrefix(p.String(), profileNamePrefix))
+	if len(matches) < 2 {
+		return 0
+	}
+	if i, err := strconv.Atoi(matches[1]); err == nil {
 		return i
 	}
 	return 0
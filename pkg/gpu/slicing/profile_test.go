@@ -40,6 +40,11 @@ func TestProfileName__GetMemorySizeGB(t *testing.T) {
 			profileName: "nvidia.com/gpu-10gb",
 			expected:    10,
 		},
+		{
+			name:        "Valid format with compute share",
+			profileName: "10gb.30pct",
+			expected:    10,
+		},
 	}
 
 	for _, tt := range testCases {
@@ -49,6 +54,46 @@ func TestProfileName__GetMemorySizeGB(t *testing.T) {
 	}
 }
 
+func TestProfileName__GetComputePercent(t *testing.T) {
+	testCases := []struct {
+		name        string
+		profileName slicing.ProfileName
+		expected    int
+	}{
+		{
+			name:        "Invalid format, should return 0",
+			profileName: "foo",
+			expected:    0,
+		},
+		{
+			name:        "Profile without compute share, should return 0",
+			profileName: "10gb",
+			expected:    0,
+		},
+		{
+			name:        "Profile with compute share",
+			profileName: "10gb.30pct",
+			expected:    30,
+		},
+		{
+			name:        "Resource name with compute share",
+			profileName: "nvidia.com/gpu-10gb.30pct",
+			expected:    30,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.profileName.GetComputePercent())
+		})
+	}
+}
+
+func TestNewProfileWithCompute(t *testing.T) {
+	assert.Equal(t, slicing.ProfileName("10gb"), slicing.NewProfileWithCompute(10, 0))
+	assert.Equal(t, slicing.ProfileName("10gb.30pct"), slicing.NewProfileWithCompute(10, 30))
+}
+
 func TestProfileName__SmallerThan(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -74,6 +119,18 @@ func TestProfileName__SmallerThan(t *testing.T) {
 			second:   slicing.ProfileName("nvidia.com/gpu-20gb"),
 			expected: true,
 		},
+		{
+			name:     "Same memory, first has smaller compute share",
+			first:    slicing.ProfileName("10gb.20pct"),
+			second:   slicing.ProfileName("10gb.30pct"),
+			expected: true,
+		},
+		{
+			name:     "Same memory, first has compute share",
+			first:    slicing.ProfileName("10gb.20pct"),
+			second:   slicing.ProfileName("10gb"),
+			expected: false,
+		},
 		{
 			name:     "Not a valid format, memory should be considered 0",
 			first:    slicing.ProfileName("nvidia.com/foo"),
@@ -78,3 +78,8 @@ func AsResources(g gpu.Geometry) map[v1.ResourceName]int {
 	}
 	return res
 }
+
+// IsSlicingStatusAnnotation returns true if the status annotation provided as argument refers to a slicing profile
+func IsSlicingStatusAnnotation(a gpu.StatusAnnotation) bool {
+	return ProfileName(a.ProfileName).isValid()
+}
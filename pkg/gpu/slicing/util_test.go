@@ -115,3 +115,38 @@ func TestExtractGpuId(t *testing.T) {
 		})
 	}
 }
+
+func TestIsSlicingStatusAnnotation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		annotation gpu.StatusAnnotation
+		expected   bool
+	}{
+		{
+			name:       "Slicing profile",
+			annotation: gpu.StatusAnnotation{ProfileName: "10gb", Index: 0, Status: resource.StatusFree, Quantity: 1},
+			expected:   true,
+		},
+		{
+			name:       "Slicing profile with compute share",
+			annotation: gpu.StatusAnnotation{ProfileName: "10gb.30pct", Index: 0, Status: resource.StatusFree, Quantity: 1},
+			expected:   true,
+		},
+		{
+			name:       "MIG profile",
+			annotation: gpu.StatusAnnotation{ProfileName: "1g.10gb", Index: 0, Status: resource.StatusFree, Quantity: 1},
+			expected:   false,
+		},
+		{
+			name:       "Empty profile",
+			annotation: gpu.StatusAnnotation{ProfileName: "", Index: 0, Status: resource.StatusUsed, Quantity: 1},
+			expected:   false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, slicing.IsSlicingStatusAnnotation(tt.annotation))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+const (
+	// ProfileShared is the only profile of the time-sliced GPUs. Each replica of a time-sliced GPU
+	// gets access to the whole GPU, so all the replicas have the same profile regardless of their number.
+	ProfileShared ProfileName = "shared"
+	// ProfileFull is the profile with which the gpu-agent reports the status of the GPUs exposed
+	// as a single full GPU, namely the GPUs without any replica.
+	ProfileFull ProfileName = "full"
+	// MinReplicas is the min number of replicas of a time-sliced GPU, as required by the NVIDIA device plugin
+	MinReplicas = 2
+	// MaxReplicas is the max number of replicas that can be created on a single time-sliced GPU
+	MaxReplicas = 16
+)
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	v1 "k8s.io/api/core/v1"
+)
+
+// GPU is a GPU shared through time-slicing. A GPU without any replica is exposed as a full GPU,
+// otherwise it is exposed only as replicas, each of them getting time-sliced access to the whole GPU.
+//
+// A full GPU used by a Pod has UsedAsFullGPU set to true, and it cannot get any replica until it gets free.
+type GPU struct {
+	Model         gpu.Model
+	Index         int
+	UsedReplicas  int
+	FreeReplicas  int
+	UsedAsFullGPU bool
+}
+
+func NewFullGPU(model gpu.Model, index int) GPU {
+	return GPU{
+		Model: model,
+		Index: index,
+	}
+}
+
+func NewGPU(model gpu.Model, index int, usedReplicas, freeReplicas int) (GPU, error) {
+	g := GPU{
+		Model:        model,
+		Index:        index,
+		UsedReplicas: usedReplicas,
+		FreeReplicas: freeReplicas,
+	}
+	if err := g.Validate(); err != nil {
+		return GPU{}, err
+	}
+	return g, nil
+}
+
+func NewGpuOrPanic(model gpu.Model, index int, usedReplicas, freeReplicas int) GPU {
+	g, err := NewGPU(model, index, usedReplicas, freeReplicas)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func (g *GPU) Validate() error {
+	if g.UsedReplicas < 0 || g.FreeReplicas < 0 {
+		return fmt.Errorf("number of replicas cannot be negative")
+	}
+	if g.getReplicas() > MaxReplicas {
+		return fmt.Errorf("number of replicas (%d) exceeds max allowed replicas (%d)", g.getReplicas(), MaxReplicas)
+	}
+	return nil
+}
+
+func (g *GPU) GetGeometry() gpu.Geometry {
+	geometry := make(gpu.Geometry)
+	if replicas := g.getReplicas(); replicas > 0 {
+		geometry[ProfileShared] = replicas
+	}
+	return geometry
+}
+
+func (g *GPU) Clone() GPU {
+	return GPU{
+		Model:         g.Model,
+		Index:         g.Index,
+		UsedReplicas:  g.UsedReplicas,
+		FreeReplicas:  g.FreeReplicas,
+		UsedAsFullGPU: g.UsedAsFullGPU,
+	}
+}
+
+func (g *GPU) HasFreeCapacity() bool {
+	if g.UsedAsFullGPU {
+		return false
+	}
+	return g.FreeReplicas > 0 || g.getReplicas() < MaxReplicas
+}
+
+// AddPod adds a Pod to the GPU by updating the free and used replicas according to the ones
+// requested by the Pod.
+//
+// AddPod returns an error if the GPU does not have enough free replicas for the Pod.
+func (g *GPU) AddPod(pod v1.Pod) error {
+	requested := GetRequestedReplicas(pod)
+	if g.FreeReplicas < requested {
+		return fmt.Errorf(
+			"not enough free replicas (pod requests %d replicas, but GPU only has %d)",
+			requested,
+			g.FreeReplicas,
+		)
+	}
+	g.FreeReplicas -= requested
+	g.UsedReplicas += requested
+	return nil
+}
+
+// UpdateGeometryFor tries to update the number of replicas of the GPU in order to provide the highest
+// possible number of required replicas provided as argument, without exceeding MaxReplicas.
+//
+// A GPU can be shared only among at least MinReplicas replicas, so a full GPU always gets
+// at least MinReplicas replicas, even if fewer replicas are required. Full GPUs used by a Pod
+// never get replicas, since exposing them as replicas would break the Pod using them.
+//
+// The method returns true if the GPU geometry gets updated, false otherwise.
+func (g *GPU) UpdateGeometryFor(slices map[gpu.Slice]int) bool {
+	if g.UsedAsFullGPU {
+		return false
+	}
+	missing := slices[ProfileShared] - g.FreeReplicas
+	if missing <= 0 {
+		return false
+	}
+	replicas := g.getReplicas() + missing
+	if replicas < MinReplicas {
+		replicas = MinReplicas
+	}
+	if replicas > MaxReplicas {
+		replicas = MaxReplicas
+	}
+	if replicas <= g.getReplicas() {
+		return false
+	}
+	g.FreeReplicas += replicas - g.getReplicas()
+	return true
+}
+
+// Compact deletes all the replicas of the GPU if none of them is used, so that the GPU is exposed
+// as a single full GPU.
+//
+// It returns true if the geometry of the GPU changes, false otherwise.
+func (g *GPU) Compact() bool {
+	if g.UsedReplicas == 0 && g.FreeReplicas > 0 {
+		g.FreeReplicas = 0
+		return true
+	}
+	return false
+}
+
+func (g *GPU) getReplicas() int {
+	return g.UsedReplicas + g.FreeReplicas
+}
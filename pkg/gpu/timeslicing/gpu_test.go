@@ -0,0 +1,198 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing_test
+
+import (
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewGPU(t *testing.T) {
+	testCases := []struct {
+		name         string
+		usedReplicas int
+		freeReplicas int
+		errExpected  bool
+	}{
+		{
+			name:         "GPU without replicas",
+			usedReplicas: 0,
+			freeReplicas: 0,
+			errExpected:  false,
+		},
+		{
+			name:         "GPU with max replicas",
+			usedReplicas: 2,
+			freeReplicas: timeslicing.MaxReplicas - 2,
+			errExpected:  false,
+		},
+		{
+			name:         "Replicas exceed max replicas",
+			usedReplicas: 2,
+			freeReplicas: timeslicing.MaxReplicas,
+			errExpected:  true,
+		},
+		{
+			name:         "Negative replicas",
+			usedReplicas: -1,
+			freeReplicas: 2,
+			errExpected:  true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := timeslicing.NewGPU("Tesla-T4", 0, tt.usedReplicas, tt.freeReplicas)
+			if tt.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGPU__UpdateGeometryFor(t *testing.T) {
+	testCases := []struct {
+		name             string
+		gpu              timeslicing.GPU
+		requiredSlices   map[gpu.Slice]int
+		expectedUpdated  bool
+		expectedGeometry gpu.Geometry
+	}{
+		{
+			name:             "No required replicas",
+			gpu:              timeslicing.NewFullGPU("Tesla-T4", 0),
+			requiredSlices:   map[gpu.Slice]int{},
+			expectedUpdated:  false,
+			expectedGeometry: gpu.Geometry{},
+		},
+		{
+			name:             "Full GPU, a single required replica should create min replicas",
+			gpu:              timeslicing.NewFullGPU("Tesla-T4", 0),
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 1},
+			expectedUpdated:  true,
+			expectedGeometry: gpu.Geometry{timeslicing.ProfileShared: timeslicing.MinReplicas},
+		},
+		{
+			name: "Full GPU used by a pod, should not get replicas",
+			gpu: timeslicing.GPU{
+				Model:         "Tesla-T4",
+				Index:         0,
+				UsedAsFullGPU: true,
+			},
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 1},
+			expectedUpdated:  false,
+			expectedGeometry: gpu.Geometry{},
+		},
+		{
+			name:             "GPU already provides enough free replicas",
+			gpu:              timeslicing.NewGpuOrPanic("Tesla-T4", 0, 1, 3),
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 2},
+			expectedUpdated:  false,
+			expectedGeometry: gpu.Geometry{timeslicing.ProfileShared: 4},
+		},
+		{
+			name:             "GPU with replicas, should add missing replicas",
+			gpu:              timeslicing.NewGpuOrPanic("Tesla-T4", 0, 2, 1),
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 4},
+			expectedUpdated:  true,
+			expectedGeometry: gpu.Geometry{timeslicing.ProfileShared: 6},
+		},
+		{
+			name:             "Replicas should not exceed max replicas",
+			gpu:              timeslicing.NewGpuOrPanic("Tesla-T4", 0, 10, 0),
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 20},
+			expectedUpdated:  true,
+			expectedGeometry: gpu.Geometry{timeslicing.ProfileShared: timeslicing.MaxReplicas},
+		},
+		{
+			name:             "GPU already has max replicas",
+			gpu:              timeslicing.NewGpuOrPanic("Tesla-T4", 0, timeslicing.MaxReplicas, 0),
+			requiredSlices:   map[gpu.Slice]int{timeslicing.ProfileShared: 1},
+			expectedUpdated:  false,
+			expectedGeometry: gpu.Geometry{timeslicing.ProfileShared: timeslicing.MaxReplicas},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := tt.gpu.UpdateGeometryFor(tt.requiredSlices)
+			assert.Equal(t, tt.expectedUpdated, updated)
+			assert.Equal(t, tt.expectedGeometry, tt.gpu.GetGeometry())
+		})
+	}
+}
+
+func TestGPU__AddPod(t *testing.T) {
+	pod := factory.BuildPod("ns-1", "pd-1").WithContainer(
+		factory.BuildContainer("c-1", "im-1").
+			WithScalarResourceRequest(timeslicing.ProfileShared.AsResourceName(), 1).
+			Get(),
+	).Get()
+
+	t.Run("GPU without free replicas, should return error", func(t *testing.T) {
+		g := timeslicing.NewGpuOrPanic("Tesla-T4", 0, 2, 0)
+		assert.Error(t, g.AddPod(pod))
+	})
+
+	t.Run("GPU with free replicas, should use them", func(t *testing.T) {
+		g := timeslicing.NewGpuOrPanic("Tesla-T4", 0, 1, 1)
+		assert.NoError(t, g.AddPod(pod))
+		assert.Equal(t, 2, g.UsedReplicas)
+		assert.Equal(t, 0, g.FreeReplicas)
+	})
+}
+
+func TestGPU__Compact(t *testing.T) {
+	testCases := []struct {
+		name              string
+		gpu               timeslicing.GPU
+		expectedCompacted bool
+		expectedGeometry  gpu.Geometry
+	}{
+		{
+			name:              "Full GPU",
+			gpu:               timeslicing.NewFullGPU("Tesla-T4", 0),
+			expectedCompacted: false,
+			expectedGeometry:  gpu.Geometry{},
+		},
+		{
+			name:              "GPU without used replicas should become a full GPU",
+			gpu:               timeslicing.NewGpuOrPanic("Tesla-T4", 0, 0, 4),
+			expectedCompacted: true,
+			expectedGeometry:  gpu.Geometry{},
+		},
+		{
+			name:              "GPU with used replicas should not change",
+			gpu:               timeslicing.NewGpuOrPanic("Tesla-T4", 0, 1, 3),
+			expectedCompacted: false,
+			expectedGeometry:  gpu.Geometry{timeslicing.ProfileShared: 4},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			compacted := tt.gpu.Compact()
+			assert.Equal(t, tt.expectedCompacted, compacted)
+			assert.Equal(t, tt.expectedGeometry, tt.gpu.GetGeometry())
+		})
+	}
+}
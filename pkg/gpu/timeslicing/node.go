@@ -0,0 +1,236 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+type Node struct {
+	Name     string
+	GPUs     []GPU
+	nodeInfo framework.NodeInfo
+}
+
+func NewNode(n framework.NodeInfo) (Node, error) {
+	if n.Node() == nil {
+		return Node{}, fmt.Errorf("node is nil")
+	}
+	node := *n.Node()
+	gpus, err := extractGPUs(node, n.Pods)
+	if err != nil {
+		return Node{}, err
+	}
+	return Node{
+		Name:     node.Name,
+		GPUs:     gpus,
+		nodeInfo: n,
+	}, nil
+}
+
+func extractGPUs(n v1.Node, pods []*framework.PodInfo) ([]GPU, error) {
+	// Extract common GPU info from node labels
+	gpuModel, err := gpu.GetModel(n)
+	if err != nil {
+		return nil, err
+	}
+	gpuCount, err := gpu.GetCount(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Init GPUs from annotations. GPUs not included in the annotations
+	// do not have any replica, so they are full GPUs.
+	statusAnnotations, _ := gpu.ParseNodeAnnotations(n)
+	gpuAnnotations := statusAnnotations.Filter(IsTimeSlicingStatusAnnotation).GroupByGpuIndex()
+	fullGpuAnnotations := statusAnnotations.Filter(IsFullGpuStatusAnnotation).GroupByGpuIndex()
+	result := make([]GPU, 0, gpuCount)
+	reportedFree := make(map[int]bool)
+	var reportedUsed int
+	for i := 0; i < gpuCount; i++ {
+		var usedReplicas, freeReplicas int
+		for _, a := range gpuAnnotations[i] {
+			if a.IsUsed() {
+				usedReplicas += a.Quantity
+			}
+			if a.IsFree() {
+				freeReplicas += a.Quantity
+			}
+		}
+		g, err := NewGPU(gpuModel, i, usedReplicas, freeReplicas)
+		if err != nil {
+			return nil, err
+		}
+		if g.getReplicas() == 0 {
+			for _, a := range fullGpuAnnotations[i] {
+				if a.IsUsed() {
+					g.UsedAsFullGPU = true
+					reportedUsed++
+				}
+				if a.IsFree() {
+					reportedFree[i] = true
+				}
+			}
+		}
+		result = append(result, g)
+	}
+
+	// If the pods of the node use more full GPUs than the ones reported by the gpu-agent, for instance
+	// because the agent has not reported them yet, then it is not known which GPUs are in use, so all
+	// the full GPUs not reported as free are considered used.
+	var requestedFullGPUs int
+	for _, p := range pods {
+		requestedFullGPUs += GetRequestedFullGPUs(*p.Pod)
+	}
+	if requestedFullGPUs > reportedUsed {
+		for i := range result {
+			if result[i].getReplicas() == 0 && !reportedFree[i] {
+				result[i].UsedAsFullGPU = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (n *Node) Clone() interface{} {
+	gpus := make([]GPU, len(n.GPUs))
+	for i, g := range n.GPUs {
+		gpus[i] = g.Clone()
+	}
+	clonedNodeInfo := n.nodeInfo.Clone()
+	return &Node{
+		Name:     n.Name,
+		GPUs:     gpus,
+		nodeInfo: *clonedNodeInfo,
+	}
+}
+
+// UpdateGeometryFor updates the number of replicas of the GPUs of the node for providing the
+// replicas required by the slices provided as argument. GPUs are filled one after the other,
+// so that the remaining GPUs are left available as full GPUs.
+//
+// The method returns true if it changes the geometry of any GPU, false otherwise.
+func (n *Node) UpdateGeometryFor(slices map[gpu.Slice]int) (bool, error) {
+	// If there are no GPUs, then there's nothing to do
+	if len(n.GPUs) == 0 {
+		return false, nil
+	}
+	requiredReplicas := slices[ProfileShared]
+	if requiredReplicas <= 0 {
+		return false, nil
+	}
+
+	var anyGpuUpdated bool
+	for i := range n.GPUs {
+		if requiredReplicas <= 0 {
+			break
+		}
+		g := &n.GPUs[i]
+		updated := g.UpdateGeometryFor(map[gpu.Slice]int{ProfileShared: requiredReplicas})
+		anyGpuUpdated = anyGpuUpdated || updated
+		requiredReplicas -= g.FreeReplicas
+	}
+
+	// Update node info
+	if anyGpuUpdated {
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+	}
+
+	return anyGpuUpdated, nil
+}
+
+// Compact resets the GPUs of the node without used replicas to full GPUs,
+// updating the node resources accordingly.
+//
+// The method returns true if it changes the geometry of any GPU, false otherwise.
+func (n *Node) Compact() (bool, error) {
+	var anyGpuCompacted bool
+	for i := range n.GPUs {
+		compacted := n.GPUs[i].Compact()
+		anyGpuCompacted = anyGpuCompacted || compacted
+	}
+	if anyGpuCompacted {
+		n.nodeInfo.Allocatable.ScalarResources = n.computeScalarResources()
+	}
+	return anyGpuCompacted, nil
+}
+
+func (n *Node) computeScalarResources() map[v1.ResourceName]int64 {
+	res := make(map[v1.ResourceName]int64)
+
+	// Set all non-replica scalar resources
+	for r, v := range n.nodeInfo.Allocatable.ScalarResources {
+		if !IsGpuReplica(r) {
+			res[r] = v
+		}
+	}
+	// Set replica scalar resources
+	for r, v := range n.Geometry() {
+		res[r.(ProfileName).AsResourceName()] = int64(v)
+	}
+
+	return res
+}
+
+func (n *Node) GetName() string {
+	return n.Name
+}
+
+// Geometry returns the overall geometry of the node, which corresponds to the sum of the geometries of all
+// the GPUs present in the Node.
+func (n *Node) Geometry() map[gpu.Slice]int {
+	res := make(map[gpu.Slice]int)
+	for _, g := range n.GPUs {
+		for p, q := range g.GetGeometry() {
+			res[p] += q
+		}
+	}
+	return res
+}
+
+func (n *Node) NodeInfo() framework.NodeInfo {
+	return n.nodeInfo
+}
+
+// AddPod adds a Pod to the node by updating the free and used replicas of the Node GPUs according to the
+// replicas requested by the Pod.
+//
+// AddPod returns an error if the node does not have any GPU providing enough free replicas for the Pod.
+func (n *Node) AddPod(pod v1.Pod) error {
+	for i := range n.GPUs {
+		if err := n.GPUs[i].AddPod(pod); err == nil {
+			nodeInfo := n.NodeInfo()
+			nodeInfo.AddPod(&pod)
+			return nil
+		}
+	}
+	return fmt.Errorf("not enough free GPU replicas")
+}
+
+// HasFreeCapacity returns true if any of the GPUs of the node has enough free capacity for hosting more pods.
+func (n *Node) HasFreeCapacity() bool {
+	for _, g := range n.GPUs {
+		if g.HasFreeCapacity() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing_test
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/api/nos.nebuly.com/v1alpha1"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func newNodeOrPanic(node v1.Node, pods ...v1.Pod) timeslicing.Node {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&node)
+	for i := range pods {
+		nodeInfo.AddPod(&pods[i])
+	}
+	n, err := timeslicing.NewNode(*nodeInfo)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestNewNode(t *testing.T) {
+	testCases := []struct {
+		name         string
+		node         v1.Node
+		expectedGPUs []timeslicing.GPU
+		errExpected  bool
+	}{
+		{
+			name: "node without GPU count label",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+			}).Get(),
+			errExpected: true,
+		},
+		{
+			name: "node without GPU model label",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaCount: "1",
+			}).Get(),
+			errExpected: true,
+		},
+		{
+			name: "GPUs not included in annotations should be full GPUs, other annotations should be ignored",
+			node: factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+				constant.LabelNvidiaCount:   "3",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, timeslicing.ProfileShared, resource.StatusUsed): "2",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, timeslicing.ProfileShared, resource.StatusFree): "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, "10gb", resource.StatusFree):                    "1",
+			}).Get(),
+			expectedGPUs: []timeslicing.GPU{
+				timeslicing.NewFullGPU("Tesla-T4", 0),
+				timeslicing.NewGpuOrPanic("Tesla-T4", 1, 2, 1),
+				timeslicing.NewFullGPU("Tesla-T4", 2),
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&tt.node)
+			n, err := timeslicing.NewNode(*nodeInfo)
+			if tt.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.node.Name, n.Name)
+			assert.Equal(t, tt.expectedGPUs, n.GPUs)
+		})
+	}
+}
+
+func TestNode__UpdateGeometryFor(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		node                    timeslicing.Node
+		requiredSlices          map[gpu.Slice]int
+		expectedUpdated         bool
+		expectedGeometry        map[gpu.Slice]int
+		expectedGpuGeometries   []gpu.Geometry
+		expectedScalarResources map[v1.ResourceName]int64
+	}{
+		{
+			name: "No required replicas",
+			node: newNodeOrPanic(factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+				constant.LabelNvidiaCount:   "2",
+			}).Get()),
+			requiredSlices:        map[gpu.Slice]int{},
+			expectedUpdated:       false,
+			expectedGeometry:      map[gpu.Slice]int{},
+			expectedGpuGeometries: []gpu.Geometry{{}, {}},
+		},
+		{
+			name: "Replicas should be created on the first GPUs, leaving the others as full GPUs",
+			node: newNodeOrPanic(factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+				constant.LabelNvidiaCount:   "2",
+			}).Get()),
+			requiredSlices:        map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedUpdated:       true,
+			expectedGeometry:      map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedGpuGeometries: []gpu.Geometry{{timeslicing.ProfileShared: 3}, {}},
+			expectedScalarResources: map[v1.ResourceName]int64{
+				timeslicing.ProfileShared.AsResourceName(): 3,
+			},
+		},
+		{
+			name: "Replicas exceeding max replicas of a GPU should be created on the next GPU",
+			node: newNodeOrPanic(factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+				constant.LabelNvidiaCount:   "2",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, timeslicing.ProfileShared, resource.StatusUsed): "14",
+			}).Get()),
+			requiredSlices:  map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedUpdated: true,
+			expectedGeometry: map[gpu.Slice]int{
+				timeslicing.ProfileShared: timeslicing.MaxReplicas + timeslicing.MinReplicas,
+			},
+			expectedGpuGeometries: []gpu.Geometry{
+				{timeslicing.ProfileShared: timeslicing.MaxReplicas},
+				{timeslicing.ProfileShared: timeslicing.MinReplicas},
+			},
+			expectedScalarResources: map[v1.ResourceName]int64{
+				timeslicing.ProfileShared.AsResourceName(): timeslicing.MaxReplicas + timeslicing.MinReplicas,
+			},
+		},
+		{
+			name: "Full GPUs reported as used should not get replicas",
+			node: newNodeOrPanic(factory.BuildNode("node-1").WithLabels(map[string]string{
+				constant.LabelNvidiaProduct: "Tesla-T4",
+				constant.LabelNvidiaCount:   "2",
+			}).WithAnnotations(map[string]string{
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, timeslicing.ProfileFull, resource.StatusUsed): "1",
+				fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, timeslicing.ProfileFull, resource.StatusFree): "1",
+			}).Get()),
+			requiredSlices:        map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedUpdated:       true,
+			expectedGeometry:      map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedGpuGeometries: []gpu.Geometry{{}, {timeslicing.ProfileShared: 3}},
+			expectedScalarResources: map[v1.ResourceName]int64{
+				timeslicing.ProfileShared.AsResourceName(): 3,
+			},
+		},
+		{
+			name: "Pods using full GPUs not reported yet, full GPUs not reported as free should not get replicas",
+			node: newNodeOrPanic(
+				factory.BuildNode("node-1").WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: "Tesla-T4",
+					constant.LabelNvidiaCount:   "3",
+				}).WithAnnotations(map[string]string{
+					fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 2, timeslicing.ProfileFull, resource.StatusFree): "1",
+				}).Get(),
+				factory.BuildPod("ns-1", "pd-1").WithContainer(
+					factory.BuildContainer("c-1", "im-1").
+						WithScalarResourceRequest(constant.ResourceNvidiaGPU, 1).
+						Get(),
+				).Get(),
+			),
+			requiredSlices:        map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedUpdated:       true,
+			expectedGeometry:      map[gpu.Slice]int{timeslicing.ProfileShared: 3},
+			expectedGpuGeometries: []gpu.Geometry{{}, {}, {timeslicing.ProfileShared: 3}},
+			expectedScalarResources: map[v1.ResourceName]int64{
+				timeslicing.ProfileShared.AsResourceName(): 3,
+			},
+		},
+		{
+			name: "All full GPUs used by pods, should not create any replica",
+			node: newNodeOrPanic(
+				factory.BuildNode("node-1").WithLabels(map[string]string{
+					constant.LabelNvidiaProduct: "Tesla-T4",
+					constant.LabelNvidiaCount:   "1",
+				}).Get(),
+				factory.BuildPod("ns-1", "pd-1").WithContainer(
+					factory.BuildContainer("c-1", "im-1").
+						WithScalarResourceRequest(constant.ResourceNvidiaGPU, 1).
+						Get(),
+				).Get(),
+			),
+			requiredSlices:        map[gpu.Slice]int{timeslicing.ProfileShared: 1},
+			expectedUpdated:       false,
+			expectedGeometry:      map[gpu.Slice]int{},
+			expectedGpuGeometries: []gpu.Geometry{{}},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := tt.node.UpdateGeometryFor(tt.requiredSlices)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedUpdated, updated)
+			assert.Equal(t, tt.expectedGeometry, tt.node.Geometry())
+			gpuGeometries := make([]gpu.Geometry, 0, len(tt.node.GPUs))
+			for _, g := range tt.node.GPUs {
+				gpuGeometries = append(gpuGeometries, g.GetGeometry())
+			}
+			assert.Equal(t, tt.expectedGpuGeometries, gpuGeometries)
+			if tt.expectedUpdated {
+				assert.Equal(t, tt.expectedScalarResources, tt.node.NodeInfo().Allocatable.ScalarResources)
+			}
+		})
+	}
+}
+
+func TestNode__Compact(t *testing.T) {
+	node := newNodeOrPanic(factory.BuildNode("node-1").WithLabels(map[string]string{
+		constant.LabelNvidiaProduct: "Tesla-T4",
+		constant.LabelNvidiaCount:   "2",
+	}).WithAnnotations(map[string]string{
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, timeslicing.ProfileShared, resource.StatusUsed): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 0, timeslicing.ProfileShared, resource.StatusFree): "1",
+		fmt.Sprintf(v1alpha1.AnnotationGpuStatusFormat, 1, timeslicing.ProfileShared, resource.StatusFree): "4",
+	}).Get())
+
+	compacted, err := node.Compact()
+	assert.NoError(t, err)
+	assert.True(t, compacted)
+	assert.Equal(t, map[gpu.Slice]int{timeslicing.ProfileShared: 2}, node.Geometry())
+	assert.Equal(
+		t,
+		map[v1.ResourceName]int64{timeslicing.ProfileShared.AsResourceName(): 2},
+		node.NodeInfo().Allocatable.ScalarResources,
+	)
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	v1 "k8s.io/api/core/v1"
+)
+
+var profileNamePrefix = fmt.Sprintf("%s-", constant.ResourceNvidiaGPU.String())
+
+// ProfileName is the name of the profile of the replicas of a time-sliced GPU
+type ProfileName string
+
+func (p ProfileName) SmallerThan(other gpu.Slice) bool {
+	// All the replicas share the whole GPU, so no replica is smaller than another one
+	return false
+}
+
+func (p ProfileName) String() string {
+	return string(p)
+}
+
+func (p ProfileName) AsResourceName() v1.ResourceName {
+	return v1.ResourceName(fmt.Sprintf("%s%s", profileNamePrefix, p))
+}
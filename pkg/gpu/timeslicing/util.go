@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing
+
+import (
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/gpu"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ExtractProfileName extracts the name of the time-slicing profile from the provided resource name,
+// and returns an error if the resource name is not a valid NVIDIA time-slicing resource.
+//
+// Example:
+//
+//	nvidia.com/gpu-shared => shared
+//	nvidia.com/gpu => error
+func ExtractProfileName(resourceName v1.ResourceName) (ProfileName, error) {
+	if !IsGpuReplica(resourceName) {
+		return "", fmt.Errorf("invalid resource name, required value is %s", ProfileShared.AsResourceName())
+	}
+	return ProfileShared, nil
+}
+
+func ExtractProfileNameStr(r v1.ResourceName) (string, error) {
+	profileName, err := ExtractProfileName(r)
+	if err != nil {
+		return "", err
+	}
+	return profileName.String(), nil
+}
+
+// IsGpuReplica returns true if the resource provided as argument is a replica of a time-sliced GPU
+func IsGpuReplica(r v1.ResourceName) bool {
+	return r == ProfileShared.AsResourceName()
+}
+
+// GetRequestedReplicas returns the number of time-sliced GPU replicas requested by the Pod provided as argument
+func GetRequestedReplicas(pod v1.Pod) int {
+	var res int
+	for r, quantity := range resource.ComputePodRequest(pod) {
+		if IsGpuReplica(r) {
+			res += int(quantity.Value())
+		}
+	}
+	return res
+}
+
+func AsResources(g gpu.Geometry) map[v1.ResourceName]int {
+	res := make(map[v1.ResourceName]int)
+	for p, v := range g {
+		resourceName := v1.ResourceName(fmt.Sprintf("%s%s", profileNamePrefix, p))
+		res[resourceName] += v
+	}
+	return res
+}
+
+// IsTimeSlicingStatusAnnotation returns true if the status annotation provided as argument
+// refers to the replicas of a time-sliced GPU
+func IsTimeSlicingStatusAnnotation(a gpu.StatusAnnotation) bool {
+	return ProfileName(a.ProfileName) == ProfileShared
+}
+
+// IsFullGpuStatusAnnotation returns true if the status annotation provided as argument
+// refers to a GPU exposed as a single full GPU
+func IsFullGpuStatusAnnotation(a gpu.StatusAnnotation) bool {
+	return ProfileName(a.ProfileName) == ProfileFull
+}
+
+// GetRequestedFullGPUs returns the number of full GPUs requested by the Pod provided as argument
+func GetRequestedFullGPUs(pod v1.Pod) int {
+	quantity := resource.ComputePodRequest(pod)[constant.ResourceNvidiaGPU]
+	return int(quantity.Value())
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeslicing_test
+
+import (
+	"github.com/nebuly-ai/nos/pkg/gpu/timeslicing"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestExtractProfileName(t *testing.T) {
+	testCases := []struct {
+		name        string
+		resource    v1.ResourceName
+		expected    timeslicing.ProfileName
+		errExpected bool
+	}{
+		{
+			name:        "Full GPU",
+			resource:    "nvidia.com/gpu",
+			errExpected: true,
+		},
+		{
+			name:        "GPU slice",
+			resource:    "nvidia.com/gpu-10gb",
+			errExpected: true,
+		},
+		{
+			name:     "Time-sliced GPU replica",
+			resource: "nvidia.com/gpu-shared",
+			expected: timeslicing.ProfileShared,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := timeslicing.ExtractProfileName(tt.resource)
+			if tt.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, profile)
+		})
+	}
+}
@@ -20,12 +20,15 @@ import (
 	"context"
 	"fmt"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	pdrv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
 )
 
 type Client interface {
 	GetAllocatableDevices(ctx context.Context) ([]Device, error)
 	GetUsedDevices(ctx context.Context) ([]Device, error)
+	GetPodsUsingDevices(ctx context.Context, deviceIds []string) ([]types.NamespacedName, error)
+	GetDevicePods(ctx context.Context) (map[string]types.NamespacedName, error)
 }
 
 type clientImpl struct {
@@ -85,3 +88,59 @@ func (c clientImpl) GetUsedDevices(ctx context.Context) ([]Device, error) {
 
 	return result, nil
 }
+
+// GetPodsUsingDevices returns the Pods that have at least one container using any of the devices
+// with the IDs provided as argument.
+func (c clientImpl) GetPodsUsingDevices(ctx context.Context, deviceIds []string) ([]types.NamespacedName, error) {
+	listResp, err := c.lister.List(ctx, &pdrv1.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list resources used by running Pods from Kubelet gRPC socket: %s", err)
+	}
+
+	lookup := make(map[string]bool, len(deviceIds))
+	for _, id := range deviceIds {
+		lookup[id] = true
+	}
+	result := make([]types.NamespacedName, 0)
+	for _, r := range listResp.PodResources {
+		if podUsesAnyDevice(r, lookup) {
+			result = append(result, types.NamespacedName{Namespace: r.Namespace, Name: r.Name})
+		}
+	}
+
+	return result, nil
+}
+
+// GetDevicePods returns the Pods using the devices allocated to running Pods, indexed by device ID.
+func (c clientImpl) GetDevicePods(ctx context.Context) (map[string]types.NamespacedName, error) {
+	listResp, err := c.lister.List(ctx, &pdrv1.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list resources used by running Pods from Kubelet gRPC socket: %s", err)
+	}
+
+	result := make(map[string]types.NamespacedName)
+	for _, r := range listResp.PodResources {
+		for _, cr := range r.Containers {
+			for _, cd := range cr.GetDevices() {
+				for _, cdId := range cd.DeviceIds {
+					result[cdId] = types.NamespacedName{Namespace: r.Namespace, Name: r.Name}
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func podUsesAnyDevice(podResources *pdrv1.PodResources, deviceIds map[string]bool) bool {
+	for _, cr := range podResources.Containers {
+		for _, cd := range cr.GetDevices() {
+			for _, cdId := range cd.DeviceIds {
+				if deviceIds[cdId] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/types"
+	pdrv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
+	"testing"
+)
+
+type fakeLister struct {
+	listResp  pdrv1.ListPodResourcesResponse
+	listError error
+}
+
+func (l fakeLister) List(
+	_ context.Context,
+	_ *pdrv1.ListPodResourcesRequest,
+	_ ...grpc.CallOption) (*pdrv1.ListPodResourcesResponse, error) {
+	return &l.listResp, l.listError
+}
+
+func (l fakeLister) GetAllocatableResources(
+	_ context.Context,
+	_ *pdrv1.AllocatableResourcesRequest,
+	_ ...grpc.CallOption) (*pdrv1.AllocatableResourcesResponse, error) {
+	return &pdrv1.AllocatableResourcesResponse{}, nil
+}
+
+func TestClient_GetPodsUsingDevices(t *testing.T) {
+	podResources := []*pdrv1.PodResources{
+		{
+			Name:      "pod-1",
+			Namespace: "ns-1",
+			Containers: []*pdrv1.ContainerResources{
+				{
+					Devices: []*pdrv1.ContainerDevices{
+						{ResourceName: "nvidia.com/mig-1g.10gb", DeviceIds: []string{"dev-1"}},
+					},
+				},
+			},
+		},
+		{
+			Name:      "pod-2",
+			Namespace: "ns-2",
+			Containers: []*pdrv1.ContainerResources{
+				{},
+				{
+					Devices: []*pdrv1.ContainerDevices{
+						{ResourceName: "nvidia.com/mig-1g.10gb", DeviceIds: []string{"dev-2", "dev-3"}},
+					},
+				},
+			},
+		},
+		{
+			Name:      "pod-3",
+			Namespace: "ns-1",
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		listResp     pdrv1.ListPodResourcesResponse
+		listError    error
+		deviceIds    []string
+		expectedPods []types.NamespacedName
+		expectedErr  bool
+	}{
+		{
+			name:         "Lister returns error",
+			listError:    fmt.Errorf("error"),
+			deviceIds:    []string{"dev-1"},
+			expectedPods: nil,
+			expectedErr:  true,
+		},
+		{
+			name:         "No device IDs",
+			listResp:     pdrv1.ListPodResourcesResponse{PodResources: podResources},
+			deviceIds:    []string{},
+			expectedPods: []types.NamespacedName{},
+		},
+		{
+			name:         "No Pod uses the devices",
+			listResp:     pdrv1.ListPodResourcesResponse{PodResources: podResources},
+			deviceIds:    []string{"dev-4"},
+			expectedPods: []types.NamespacedName{},
+		},
+		{
+			name:      "Each Pod is returned once",
+			listResp:  pdrv1.ListPodResourcesResponse{PodResources: podResources},
+			deviceIds: []string{"dev-1", "dev-2", "dev-3"},
+			expectedPods: []types.NamespacedName{
+				{Namespace: "ns-1", Name: "pod-1"},
+				{Namespace: "ns-2", Name: "pod-2"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := resource.NewClient(fakeLister{listResp: tt.listResp, listError: tt.listError})
+			pods, err := client.GetPodsUsingDevices(context.Background(), tt.deviceIds)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.expectedPods, pods)
+		})
+	}
+}
+
+func TestClient_GetDevicePods(t *testing.T) {
+	podResources := []*pdrv1.PodResources{
+		{
+			Name:      "pod-1",
+			Namespace: "ns-1",
+			Containers: []*pdrv1.ContainerResources{
+				{
+					Devices: []*pdrv1.ContainerDevices{
+						{ResourceName: "nvidia.com/mig-1g.10gb", DeviceIds: []string{"dev-1"}},
+					},
+				},
+				{
+					Devices: []*pdrv1.ContainerDevices{
+						{ResourceName: "nvidia.com/mig-2g.20gb", DeviceIds: []string{"dev-2"}},
+					},
+				},
+			},
+		},
+		{
+			Name:      "pod-2",
+			Namespace: "ns-2",
+			Containers: []*pdrv1.ContainerResources{
+				{
+					Devices: []*pdrv1.ContainerDevices{
+						{ResourceName: "nvidia.com/gpu-10gb", DeviceIds: []string{"gpu-1::0", "gpu-1::1"}},
+					},
+				},
+			},
+		},
+		{
+			Name:      "pod-3",
+			Namespace: "ns-1",
+		},
+	}
+
+	t.Run("Lister returns error", func(t *testing.T) {
+		client := resource.NewClient(fakeLister{listError: fmt.Errorf("error")})
+		_, err := client.GetDevicePods(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("Devices are mapped to the Pods using them", func(t *testing.T) {
+		client := resource.NewClient(fakeLister{listResp: pdrv1.ListPodResourcesResponse{PodResources: podResources}})
+		pods, err := client.GetDevicePods(context.Background())
+		assert.NoError(t, err)
+		expected := map[string]types.NamespacedName{
+			"dev-1":    {Namespace: "ns-1", Name: "pod-1"},
+			"dev-2":    {Namespace: "ns-1", Name: "pod-1"},
+			"gpu-1::0": {Namespace: "ns-2", Name: "pod-2"},
+			"gpu-1::1": {Namespace: "ns-2", Name: "pod-2"},
+		}
+		assert.Equal(t, expected, pods)
+	})
+}
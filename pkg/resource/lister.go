@@ -29,7 +29,13 @@ const (
 )
 
 func NewPodResourcesListerClient(timeout time.Duration, maxMsgSize int) (pdrv1.PodResourcesListerClient, error) {
-	endpoint, err := util.LocalEndpoint(PodResourcesPath, podresources.Socket)
+	return NewPodResourcesListerClientAt(PodResourcesPath, timeout, maxMsgSize)
+}
+
+// NewPodResourcesListerClientAt returns a client of the PodResources GRPC service served on the
+// Kubelet socket located in the directory provided as argument.
+func NewPodResourcesListerClientAt(dir string, timeout time.Duration, maxMsgSize int) (pdrv1.PodResourcesListerClient, error) {
+	endpoint, err := util.LocalEndpoint(dir, podresources.Socket)
 	if err != nil {
 		return nil, err
 	}
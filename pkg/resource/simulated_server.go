@@ -0,0 +1,274 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	pdrv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/podresources"
+	"net"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SimulatedPodResourcesPath is the path of the directory containing the socket
+// served by SimulatedPodResourcesServer
+const SimulatedPodResourcesPath = "/var/run/nos/pod-resources"
+
+// AllocatableDeviceLister lists the devices that can be allocated to the Pods of a node
+type AllocatableDeviceLister interface {
+	GetAllocatableDevices(ctx context.Context) ([]Device, error)
+}
+
+// SimulatedPodResourcesServer is a PodResources gRPC server that simulates the one exposed by the Kubelet.
+// It exposes as allocatable the devices returned by an AllocatableDeviceLister, and it allocates them to the
+// Pods running on the node according to the resources requested by their containers, as the Kubelet does.
+//
+// Together with a simulated NVML client, it allows to run the agents on nodes without NVIDIA GPUs.
+type SimulatedPodResourcesServer struct {
+	pdrv1.UnimplementedPodResourcesListerServer
+
+	devices    AllocatableDeviceLister
+	kubeClient kubernetes.Interface
+	nodeName   string
+
+	mtx sync.Mutex
+	// allocations contains the IDs of the devices allocated to each Pod, indexed by container and resource name
+	allocations map[types.UID]podAllocation
+}
+
+type podAllocation struct {
+	namespacedName types.NamespacedName
+	containers     map[string]map[v1.ResourceName][]string
+}
+
+func NewSimulatedPodResourcesServer(devices AllocatableDeviceLister, kubeClient kubernetes.Interface, nodeName string) *SimulatedPodResourcesServer {
+	return &SimulatedPodResourcesServer{
+		devices:     devices,
+		kubeClient:  kubeClient,
+		nodeName:    nodeName,
+		allocations: make(map[types.UID]podAllocation),
+	}
+}
+
+// Start serves the PodResources gRPC service on a unix socket located in the directory provided as argument
+// until the context is cancelled. It returns as soon as the server is listening on the socket. The socket has the
+// same name as the one of the Kubelet, so that clients can connect to it through NewPodResourcesListerClientAt.
+func (s *SimulatedPodResourcesServer) Start(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create directory %s: %s", dir, err)
+	}
+	socket := filepath.Join(dir, podresources.Socket+".sock")
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove stale socket %s: %s", socket, err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("unable to listen on socket %s: %s", socket, err)
+	}
+
+	server := grpc.NewServer()
+	pdrv1.RegisterPodResourcesListerServer(server, s)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.FromContext(ctx).Error(err, "simulated PodResources server stopped")
+		}
+	}()
+	return nil
+}
+
+func (s *SimulatedPodResourcesServer) GetAllocatableResources(ctx context.Context, _ *pdrv1.AllocatableResourcesRequest) (*pdrv1.AllocatableResourcesResponse, error) {
+	devices, err := s.devices.GetAllocatableDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pdrv1.AllocatableResourcesResponse{
+		Devices: toContainerDevices(groupDeviceIdsByResource(devices)),
+	}, nil
+}
+
+func (s *SimulatedPodResourcesServer) List(ctx context.Context, _ *pdrv1.ListPodResourcesRequest) (*pdrv1.ListPodResourcesResponse, error) {
+	devices, err := s.devices.GetAllocatableDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	podList, err := s.kubeClient.CoreV1().Pods(v1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", s.nodeName).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Pods of node %s: %s", s.nodeName, err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.updateAllocations(podList.Items, groupDeviceIdsByResource(devices))
+
+	resp := &pdrv1.ListPodResourcesResponse{
+		PodResources: make([]*pdrv1.PodResources, 0, len(s.allocations)),
+	}
+	for _, a := range s.allocations {
+		podResources := &pdrv1.PodResources{
+			Name:       a.namespacedName.Name,
+			Namespace:  a.namespacedName.Namespace,
+			Containers: make([]*pdrv1.ContainerResources, 0, len(a.containers)),
+		}
+		for name, deviceIds := range a.containers {
+			podResources.Containers = append(podResources.Containers, &pdrv1.ContainerResources{
+				Name:    name,
+				Devices: toContainerDevices(deviceIds),
+			})
+		}
+		resp.PodResources = append(resp.PodResources, podResources)
+	}
+	sort.Slice(resp.PodResources, func(i, j int) bool {
+		return resp.PodResources[i].Namespace+"/"+resp.PodResources[i].Name <
+			resp.PodResources[j].Namespace+"/"+resp.PodResources[j].Name
+	})
+	return resp, nil
+}
+
+// updateAllocations releases the devices allocated to the Pods that are not running anymore
+// and allocates the free devices to the Pods that do not have any allocation yet.
+// The allocation of a Pod fails if there are not enough free devices for all its containers,
+// in which case the Pod does not get any device, as the Kubelet would reject it.
+func (s *SimulatedPodResourcesServer) updateAllocations(pods []v1.Pod, allocatable map[v1.ResourceName][]string) {
+	// Release the devices of the Pods that are not running anymore
+	active := make(map[types.UID]bool, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			active[pod.UID] = true
+		}
+	}
+	for uid := range s.allocations {
+		if !active[uid] {
+			delete(s.allocations, uid)
+		}
+	}
+
+	// Compute the free devices
+	used := make(map[string]bool)
+	for _, a := range s.allocations {
+		for _, deviceIds := range a.containers {
+			for _, ids := range deviceIds {
+				for _, id := range ids {
+					used[id] = true
+				}
+			}
+		}
+	}
+	free := make(map[v1.ResourceName][]string, len(allocatable))
+	for resourceName, ids := range allocatable {
+		for _, id := range ids {
+			if !used[id] {
+				free[resourceName] = append(free[resourceName], id)
+			}
+		}
+	}
+
+	// Allocate the free devices to the new Pods, starting from the oldest ones
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+	for _, pod := range pods {
+		if !active[pod.UID] {
+			continue
+		}
+		if _, ok := s.allocations[pod.UID]; ok {
+			continue
+		}
+		if allocation, ok := allocate(pod, free); ok {
+			s.allocations[pod.UID] = allocation
+		}
+	}
+}
+
+// allocate allocates to the containers of the Pod provided as argument the NVIDIA devices they request,
+// removing them from the free devices. Returns false, leaving the free devices unchanged, if there
+// are not enough free devices for all the containers or if the Pod does not request any device.
+func allocate(pod v1.Pod, free map[v1.ResourceName][]string) (podAllocation, bool) {
+	allocation := podAllocation{
+		namespacedName: types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name},
+		containers:     make(map[string]map[v1.ResourceName][]string),
+	}
+	taken := make(map[v1.ResourceName]int)
+	for _, c := range pod.Spec.Containers {
+		for resourceName, quantity := range c.Resources.Limits {
+			if !strings.HasPrefix(resourceName.String(), constant.NvidiaResourcePrefix) {
+				continue
+			}
+			n := int(quantity.Value())
+			if taken[resourceName]+n > len(free[resourceName]) {
+				return podAllocation{}, false
+			}
+			if allocation.containers[c.Name] == nil {
+				allocation.containers[c.Name] = make(map[v1.ResourceName][]string)
+			}
+			ids := free[resourceName][taken[resourceName] : taken[resourceName]+n]
+			allocation.containers[c.Name][resourceName] = append([]string{}, ids...)
+			taken[resourceName] += n
+		}
+	}
+	if len(allocation.containers) == 0 {
+		return podAllocation{}, false
+	}
+	for resourceName, n := range taken {
+		free[resourceName] = free[resourceName][n:]
+	}
+	return allocation, true
+}
+
+func groupDeviceIdsByResource(devices []Device) map[v1.ResourceName][]string {
+	res := make(map[v1.ResourceName][]string)
+	for _, d := range devices {
+		res[d.ResourceName] = append(res[d.ResourceName], d.DeviceId)
+	}
+	return res
+}
+
+func toContainerDevices(deviceIds map[v1.ResourceName][]string) []*pdrv1.ContainerDevices {
+	resourceNames := make([]v1.ResourceName, 0, len(deviceIds))
+	for r := range deviceIds {
+		resourceNames = append(resourceNames, r)
+	}
+	sort.Slice(resourceNames, func(i, j int) bool {
+		return resourceNames[i] < resourceNames[j]
+	})
+	res := make([]*pdrv1.ContainerDevices, 0, len(resourceNames))
+	for _, r := range resourceNames {
+		res = append(res, &pdrv1.ContainerDevices{
+			ResourceName: r.String(),
+			DeviceIds:    deviceIds[r],
+		})
+	}
+	return res
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource_test
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/constant"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+type fakeDeviceLister struct {
+	devices []resource.Device
+}
+
+func (l fakeDeviceLister) GetAllocatableDevices(_ context.Context) ([]resource.Device, error) {
+	return l.devices, nil
+}
+
+func buildGpuPod(name string, phase v1.PodPhase, creation time.Time, migDevices int) v1.Pod {
+	container := factory.BuildContainer("c", "test")
+	if migDevices > 0 {
+		container = container.WithScalarResourceLimit("nvidia.com/mig-1g.5gb", migDevices)
+	}
+	return factory.BuildPod("ns-1", name).
+		WithUID(name).
+		WithNodeName("node-1").
+		WithPhase(phase).
+		WithCreationTimestamp(metav1.NewTime(creation)).
+		WithContainer(container.Get()).
+		Get()
+}
+
+func TestSimulatedPodResourcesServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	pod1 := buildGpuPod("pd-1", v1.PodRunning, now, 1)
+	pod2 := buildGpuPod("pd-2", v1.PodPending, now.Add(time.Second), 2)
+	pod3 := buildGpuPod("pd-3", v1.PodSucceeded, now.Add(-time.Second), 1)
+	pod4 := buildGpuPod("pd-4", v1.PodRunning, now.Add(-time.Second), 0)
+	kubeClient := fake.NewSimpleClientset(&pod1, &pod2, &pod3, &pod4)
+	devices := fakeDeviceLister{
+		devices: []resource.Device{
+			{ResourceName: "nvidia.com/mig-1g.5gb", DeviceId: "dev-1", Status: resource.StatusUnknown},
+			{ResourceName: "nvidia.com/mig-1g.5gb", DeviceId: "dev-2", Status: resource.StatusUnknown},
+			{ResourceName: constant.ResourceNvidiaGPU, DeviceId: "gpu-1", Status: resource.StatusUnknown},
+		},
+	}
+
+	// Serve
+	dir := t.TempDir()
+	server := resource.NewSimulatedPodResourcesServer(devices, kubeClient, "node-1")
+	require.NoError(t, server.Start(ctx, dir))
+	lister, err := resource.NewPodResourcesListerClientAt(dir, 5*time.Second, constant.DefaultPodResourcesMaxMsgSize)
+	require.NoError(t, err)
+	client := resource.NewClient(lister)
+
+	// Allocatable devices
+	allocatable, err := client.GetAllocatableDevices(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, devices.devices, allocatable)
+
+	// Only the first Pod can get the devices it requests
+	used, err := client.GetUsedDevices(ctx)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]resource.Device{{ResourceName: "nvidia.com/mig-1g.5gb", DeviceId: "dev-1", Status: resource.StatusUsed}},
+		used,
+	)
+
+	// Allocations are stable
+	pods, err := client.GetPodsUsingDevices(ctx, []string{"dev-1"})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, pod1.Name, pods[0].Name)
+
+	// The devices of deleted Pods are released and allocated to the other Pods
+	err = kubeClient.CoreV1().Pods(pod1.Namespace).Delete(ctx, pod1.Name, metav1.DeleteOptions{})
+	require.NoError(t, err)
+	used, err = client.GetUsedDevices(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]resource.Device{
+			{ResourceName: "nvidia.com/mig-1g.5gb", DeviceId: "dev-1", Status: resource.StatusUsed},
+			{ResourceName: "nvidia.com/mig-1g.5gb", DeviceId: "dev-2", Status: resource.StatusUsed},
+		},
+		used,
+	)
+	pods, err = client.GetPodsUsingDevices(ctx, []string{"dev-1", "dev-2"})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, pod2.Name, pods[0].Name)
+}
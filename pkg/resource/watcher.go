@@ -0,0 +1,208 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"strings"
+	"time"
+)
+
+var _ manager.LeaderElectionRunnable = &Watcher{}
+
+// Watcher watches the events that can change the devices allocated to the Pods running on a node, namely
+// the lifecycle events of the Pods of the node and the restarts of the device plugins, and notifies them
+// through the channel returned by Events.
+//
+// Notifications are debounced: all the events received within the debounce period following the first one
+// are notified at the end of the period with a single notification, so that a burst of events, such as
+// the ones generated by a new partitioning of the GPUs, triggers a single report of the devices.
+type Watcher struct {
+	kubeClient      kubernetes.Interface
+	nodeName        string
+	devicePluginDir string
+	debounce        time.Duration
+	triggers        chan struct{}
+	events          chan event.GenericEvent
+}
+
+// NewWatcher returns a Watcher for the node provided as argument. The restarts of the device
+// plugins are detected by watching the creation of their sockets in devicePluginDir, which is
+// not watched if empty.
+func NewWatcher(kubeClient kubernetes.Interface, nodeName string, devicePluginDir string, debounce time.Duration) *Watcher {
+	return &Watcher{
+		kubeClient:      kubeClient,
+		nodeName:        nodeName,
+		devicePluginDir: devicePluginDir,
+		debounce:        debounce,
+		triggers:        make(chan struct{}, 1),
+		events:          make(chan event.GenericEvent, 1),
+	}
+}
+
+// Events returns the channel through which the Watcher notifies that the devices of the node may have changed.
+// Each notification carries the node, so that the channel can be used as source of the controllers
+// reconciling the node.
+func (w *Watcher) Events() <-chan event.GenericEvent {
+	return w.events
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: each agent must
+// watch the devices of its own node regardless of leader election
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Trigger schedules a notification at the end of the current debounce period
+func (w *Watcher) Trigger() {
+	select {
+	case w.triggers <- struct{}{}:
+	default:
+	}
+}
+
+// Start watches the Pods and the device plugins of the node until the context is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("DeviceWatcher")
+	logger.Info("starting device watcher", "node", w.nodeName, "debounce", w.debounce)
+
+	// Watch the lifecycle of the Pods running on the node
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		w.kubeClient,
+		0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", w.nodeName).String()
+		}),
+	)
+	factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			w.Trigger()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, oldOk := oldObj.(*v1.Pod)
+			newPod, newOk := newObj.(*v1.Pod)
+			if oldOk && newOk && podLifecycleChanged(*oldPod, *newPod) {
+				w.Trigger()
+			}
+		},
+		DeleteFunc: func(_ interface{}) {
+			w.Trigger()
+		},
+	})
+	factory.Start(ctx.Done())
+
+	// Watch the restarts of the device plugins
+	if w.devicePluginDir != "" {
+		if err := w.watchDevicePlugins(ctx); err != nil {
+			logger.Error(err, "unable to watch device plugins, their restarts will be detected only by periodic reports")
+		}
+	}
+
+	w.notifyDebounced(ctx)
+	return nil
+}
+
+// watchDevicePlugins triggers a notification whenever a socket is created in the device plugin directory,
+// which happens when a device plugin registers to the Kubelet after a restart, or when the Kubelet restarts
+func (w *Watcher) watchDevicePlugins(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("DeviceWatcher")
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err = fsWatcher.Add(w.devicePluginDir); err != nil {
+		_ = fsWatcher.Close()
+		return err
+	}
+
+	go func() {
+		defer fsWatcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				if e.Op&fsnotify.Create != 0 && strings.HasSuffix(e.Name, ".sock") {
+					logger.V(1).Info("device plugin socket created", "socket", e.Name)
+					w.Trigger()
+				}
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error(err, "error watching device plugins")
+			}
+		}
+	}()
+	return nil
+}
+
+// notifyDebounced sends a notification at the end of the debounce period following each
+// trigger, until the context is cancelled
+func (w *Watcher) notifyDebounced(ctx context.Context) {
+	timer := time.NewTimer(w.debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	var pending bool
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.triggers:
+			if !pending {
+				timer.Reset(w.debounce)
+				pending = true
+			}
+		case <-timer.C:
+			pending = false
+			w.notify()
+		}
+	}
+}
+
+// notify sends a notification, unless another one is already waiting to be consumed
+func (w *Watcher) notify() {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: w.nodeName}}
+	select {
+	case w.events <- event.GenericEvent{Object: node}:
+	default:
+	}
+}
+
+// podLifecycleChanged returns true if the Pod moved to a different phase of its lifecycle,
+// or if it started being deleted, which can change the devices allocated to it
+func podLifecycleChanged(oldPod v1.Pod, newPod v1.Pod) bool {
+	if oldPod.Status.Phase != newPod.Status.Phase {
+		return true
+	}
+	return oldPod.DeletionTimestamp.IsZero() != newPod.DeletionTimestamp.IsZero()
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource_test
+
+import (
+	"context"
+	"github.com/nebuly-ai/nos/pkg/resource"
+	"github.com/nebuly-ai/nos/pkg/test/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testNodeName = "node-1"
+	testDebounce = 100 * time.Millisecond
+	testTimeout  = 5 * time.Second
+)
+
+func startWatcher(t *testing.T, w *resource.Watcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = w.Start(ctx)
+	}()
+}
+
+func waitEvent(t *testing.T, w *resource.Watcher) {
+	select {
+	case e := <-w.Events():
+		assert.Equal(t, testNodeName, e.Object.GetName())
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func assertNoEvent(t *testing.T, w *resource.Watcher) {
+	select {
+	case <-w.Events():
+		t.Fatal("unexpected event")
+	case <-time.After(3 * testDebounce):
+	}
+}
+
+func TestWatcher__Trigger(t *testing.T) {
+	w := resource.NewWatcher(fake.NewSimpleClientset(), testNodeName, "", testDebounce)
+	startWatcher(t, w)
+
+	// A burst of triggers results in a single event
+	for i := 0; i < 10; i++ {
+		w.Trigger()
+	}
+	waitEvent(t, w)
+	assertNoEvent(t, w)
+
+	// Triggers after the debounce period result in a new event
+	w.Trigger()
+	waitEvent(t, w)
+}
+
+func TestWatcher__PodLifecycle(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	w := resource.NewWatcher(kubeClient, testNodeName, "", testDebounce)
+	startWatcher(t, w)
+
+	// Give the watcher the time to start watching the Pods
+	time.Sleep(testDebounce)
+
+	ctx := context.Background()
+	pod := factory.BuildPod("ns-1", "pd-1").WithNodeName(testNodeName).WithPhase(v1.PodPending).Get()
+
+	// Pod created
+	created, err := kubeClient.CoreV1().Pods(pod.Namespace).Create(ctx, &pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	waitEvent(t, w)
+
+	// Pod updated without changing its lifecycle
+	created.Labels = map[string]string{"foo": "bar"}
+	updated, err := kubeClient.CoreV1().Pods(pod.Namespace).Update(ctx, created, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assertNoEvent(t, w)
+
+	// Pod phase changed
+	updated.Status.Phase = v1.PodRunning
+	_, err = kubeClient.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	waitEvent(t, w)
+
+	// Pod deleted
+	err = kubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+	require.NoError(t, err)
+	waitEvent(t, w)
+}
+
+func TestWatcher__DevicePluginRestart(t *testing.T) {
+	dir := t.TempDir()
+	w := resource.NewWatcher(fake.NewSimpleClientset(), testNodeName, dir, testDebounce)
+	startWatcher(t, w)
+
+	// Give the watcher the time to start watching the directory
+	time.Sleep(testDebounce)
+
+	// Files other than sockets are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kubelet_internal_checkpoint"), []byte{}, 0600))
+	assertNoEvent(t, w)
+
+	// Device plugin socket created
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nvidia-gpu.sock"), []byte{}, 0600))
+	waitEvent(t, w)
+}
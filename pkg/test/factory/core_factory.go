@@ -47,6 +47,11 @@ func (b *nodeBuilder) WithLabels(labels map[string]string) *nodeBuilder {
 	return b
 }
 
+func (b *nodeBuilder) WithConditions(conditions ...v1.NodeCondition) *nodeBuilder {
+	b.Node.Status.Conditions = conditions
+	return b
+}
+
 func BuildNode(name string) *nodeBuilder {
 	node := v1.Node{
 		TypeMeta: metav1.TypeMeta{
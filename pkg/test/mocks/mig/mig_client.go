@@ -30,6 +30,8 @@ type Client struct {
 	NumCallsGetMigDeviceResources uint
 
 	ReturnedMigDeviceResources gpu.DeviceList
+	ReturnedProfilePlacements  map[int]mig.ProfilePlacements
+	ReturnedDevicePlacements   map[string]mig.Placement
 	ReturnedError              gpu.Error
 
 	lockReset                 sync.Mutex
@@ -78,3 +80,11 @@ func (m *Client) GetAllocatableMigDevices(ctx context.Context) (gpu.DeviceList,
 func (m *Client) DeleteAllExcept(_ context.Context, resources gpu.DeviceList) error {
 	return m.ReturnedError
 }
+
+func (m *Client) GetProfilePlacements(_ context.Context) (map[int]mig.ProfilePlacements, gpu.Error) {
+	return m.ReturnedProfilePlacements, m.ReturnedError
+}
+
+func (m *Client) GetMigDevicePlacements(_ context.Context) (map[string]mig.Placement, gpu.Error) {
+	return m.ReturnedDevicePlacements, m.ReturnedError
+}
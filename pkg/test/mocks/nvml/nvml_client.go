@@ -20,6 +20,7 @@ package mocks
 
 import (
 	gpu "github.com/nebuly-ai/nos/pkg/gpu"
+	nvml "github.com/nebuly-ai/nos/pkg/gpu/nvml"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -44,6 +45,22 @@ func (_m *Client) CreateMigDevices(migProfileNames []string, gpuIndex int) gpu.E
 	return r0
 }
 
+// CreateMigDeviceWithPlacement provides a mock function with given fields: migProfileName, placement, gpuIndex
+func (_m *Client) CreateMigDeviceWithPlacement(migProfileName string, placement nvml.GpuInstancePlacement, gpuIndex int) gpu.Error {
+	ret := _m.Called(migProfileName, placement, gpuIndex)
+
+	var r0 gpu.Error
+	if rf, ok := ret.Get(0).(func(string, nvml.GpuInstancePlacement, int) gpu.Error); ok {
+		r0 = rf(migProfileName, placement, gpuIndex)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(gpu.Error)
+		}
+	}
+
+	return r0
+}
+
 // DeleteAllMigDevicesExcept provides a mock function with given fields: migDeviceIds
 func (_m *Client) DeleteAllMigDevicesExcept(migDeviceIds []string) error {
 	ret := _m.Called(migDeviceIds)
@@ -74,6 +91,31 @@ func (_m *Client) DeleteMigDevice(id string) gpu.Error {
 	return r0
 }
 
+// GetDevicesUtilization provides a mock function with given fields:
+func (_m *Client) GetDevicesUtilization() ([]nvml.DeviceUtilization, gpu.Error) {
+	ret := _m.Called()
+
+	var r0 []nvml.DeviceUtilization
+	if rf, ok := ret.Get(0).(func() []nvml.DeviceUtilization); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]nvml.DeviceUtilization)
+		}
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func() gpu.Error); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
 // GetGpuIndex provides a mock function with given fields: gpuId
 func (_m *Client) GetGpuIndex(gpuId string) (int, gpu.Error) {
 	ret := _m.Called(gpuId)
@@ -97,6 +139,104 @@ func (_m *Client) GetGpuIndex(gpuId string) (int, gpu.Error) {
 	return r0, r1
 }
 
+// GetGpuInstanceProfiles provides a mock function with given fields: gpuIndex
+func (_m *Client) GetGpuInstanceProfiles(gpuIndex int) ([]nvml.GpuInstanceProfile, gpu.Error) {
+	ret := _m.Called(gpuIndex)
+
+	var r0 []nvml.GpuInstanceProfile
+	if rf, ok := ret.Get(0).(func(int) []nvml.GpuInstanceProfile); ok {
+		r0 = rf(gpuIndex)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]nvml.GpuInstanceProfile)
+		}
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func(int) gpu.Error); ok {
+		r1 = rf(gpuIndex)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
+// GetGpuIndexes provides a mock function with given fields:
+func (_m *Client) GetGpuIndexes() ([]int, gpu.Error) {
+	ret := _m.Called()
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func() []int); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func() gpu.Error); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
+// GetGpuModels provides a mock function with given fields:
+func (_m *Client) GetGpuModels() ([]gpu.Model, gpu.Error) {
+	ret := _m.Called()
+
+	var r0 []gpu.Model
+	if rf, ok := ret.Get(0).(func() []gpu.Model); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpu.Model)
+		}
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func() gpu.Error); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
+// GetMigMode provides a mock function with given fields: gpuIndex
+func (_m *Client) GetMigMode(gpuIndex int) (nvml.MigMode, gpu.Error) {
+	ret := _m.Called(gpuIndex)
+
+	var r0 nvml.MigMode
+	if rf, ok := ret.Get(0).(func(int) nvml.MigMode); ok {
+		r0 = rf(gpuIndex)
+	} else {
+		r0 = ret.Get(0).(nvml.MigMode)
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func(int) gpu.Error); ok {
+		r1 = rf(gpuIndex)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
 // GetMigDeviceGpuIndex provides a mock function with given fields: migDeviceId
 func (_m *Client) GetMigDeviceGpuIndex(migDeviceId string) (int, gpu.Error) {
 	ret := _m.Called(migDeviceId)
@@ -120,6 +260,31 @@ func (_m *Client) GetMigDeviceGpuIndex(migDeviceId string) (int, gpu.Error) {
 	return r0, r1
 }
 
+// GetMigDevicePlacements provides a mock function with given fields:
+func (_m *Client) GetMigDevicePlacements() (map[string]nvml.GpuInstancePlacement, gpu.Error) {
+	ret := _m.Called()
+
+	var r0 map[string]nvml.GpuInstancePlacement
+	if rf, ok := ret.Get(0).(func() map[string]nvml.GpuInstancePlacement); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]nvml.GpuInstancePlacement)
+		}
+	}
+
+	var r1 gpu.Error
+	if rf, ok := ret.Get(1).(func() gpu.Error); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(gpu.Error)
+		}
+	}
+
+	return r0, r1
+}
+
 // GetMigEnabledGPUs provides a mock function with given fields:
 func (_m *Client) GetMigEnabledGPUs() ([]int, gpu.Error) {
 	ret := _m.Called()
@@ -145,6 +310,22 @@ func (_m *Client) GetMigEnabledGPUs() ([]int, gpu.Error) {
 	return r0, r1
 }
 
+// SetMigMode provides a mock function with given fields: gpuIndex, enabled
+func (_m *Client) SetMigMode(gpuIndex int, enabled bool) gpu.Error {
+	ret := _m.Called(gpuIndex, enabled)
+
+	var r0 gpu.Error
+	if rf, ok := ret.Get(0).(func(int, bool) gpu.Error); ok {
+		r0 = rf(gpuIndex, enabled)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(gpu.Error)
+		}
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewClient interface {
 	mock.TestingT
 	Cleanup(func())
@@ -23,6 +23,8 @@ import (
 
 	resource "github.com/nebuly-ai/nos/pkg/resource"
 	mock "github.com/stretchr/testify/mock"
+
+	types "k8s.io/apimachinery/pkg/types"
 )
 
 // Client is an autogenerated mock type for the Client type
@@ -53,6 +55,52 @@ func (_m *Client) GetAllocatableDevices(ctx context.Context) ([]resource.Device,
 	return r0, r1
 }
 
+// GetDevicePods provides a mock function with given fields: ctx
+func (_m *Client) GetDevicePods(ctx context.Context) (map[string]types.NamespacedName, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]types.NamespacedName
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]types.NamespacedName); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]types.NamespacedName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPodsUsingDevices provides a mock function with given fields: ctx, deviceIds
+func (_m *Client) GetPodsUsingDevices(ctx context.Context, deviceIds []string) ([]types.NamespacedName, error) {
+	ret := _m.Called(ctx, deviceIds)
+
+	var r0 []types.NamespacedName
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []types.NamespacedName); ok {
+		r0 = rf(ctx, deviceIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]types.NamespacedName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, deviceIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUsedDevices provides a mock function with given fields: ctx
 func (_m *Client) GetUsedDevices(ctx context.Context) ([]resource.Device, error) {
 	ret := _m.Called(ctx)
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetCondition returns the condition of the node with the type provided as argument, if any
+func GetCondition(node v1.Node, conditionType v1.NodeConditionType) (v1.NodeCondition, bool) {
+	for _, c := range node.Status.Conditions {
+		if c.Type == conditionType {
+			return c, true
+		}
+	}
+	return v1.NodeCondition{}, false
+}
+
+// SetCondition adds the condition provided as argument to the node, or updates the existing condition
+// of the node with the same type. The transition time of the condition is updated only if its status changes.
+//
+// SetCondition returns true if the condition of the node changed, false otherwise.
+func SetCondition(node *v1.Node, condition v1.NodeCondition) bool {
+	now := metav1.Now()
+	for i, c := range node.Status.Conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status && c.Reason == condition.Reason && c.Message == condition.Message {
+			return false
+		}
+		condition.LastHeartbeatTime = now
+		condition.LastTransitionTime = c.LastTransitionTime
+		if c.Status != condition.Status {
+			condition.LastTransitionTime = now
+		}
+		node.Status.Conditions[i] = condition
+		return true
+	}
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+	return true
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2023 nebuly.com.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node_test
+
+import (
+	"github.com/nebuly-ai/nos/pkg/util/node"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestSetCondition(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+	testCases := []struct {
+		name               string
+		conditions         []v1.NodeCondition
+		condition          v1.NodeCondition
+		expectedChanged    bool
+		expectedConditions int
+		expectedTransition bool
+	}{
+		{
+			name:               "Condition is added",
+			conditions:         []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			condition:          v1.NodeCondition{Type: "Foo", Status: v1.ConditionTrue, Reason: "Bar"},
+			expectedChanged:    true,
+			expectedConditions: 2,
+			expectedTransition: true,
+		},
+		{
+			name: "Same condition, nothing changes",
+			conditions: []v1.NodeCondition{
+				{Type: "Foo", Status: v1.ConditionTrue, Reason: "Bar", LastTransitionTime: past},
+			},
+			condition:          v1.NodeCondition{Type: "Foo", Status: v1.ConditionTrue, Reason: "Bar"},
+			expectedChanged:    false,
+			expectedConditions: 1,
+			expectedTransition: false,
+		},
+		{
+			name: "Different reason, transition time is kept",
+			conditions: []v1.NodeCondition{
+				{Type: "Foo", Status: v1.ConditionTrue, Reason: "Bar", LastTransitionTime: past},
+			},
+			condition:          v1.NodeCondition{Type: "Foo", Status: v1.ConditionTrue, Reason: "Baz"},
+			expectedChanged:    true,
+			expectedConditions: 1,
+			expectedTransition: false,
+		},
+		{
+			name: "Different status, transition time is updated",
+			conditions: []v1.NodeCondition{
+				{Type: "Foo", Status: v1.ConditionTrue, Reason: "Bar", LastTransitionTime: past},
+			},
+			condition:          v1.NodeCondition{Type: "Foo", Status: v1.ConditionFalse, Reason: "Bar"},
+			expectedChanged:    true,
+			expectedConditions: 1,
+			expectedTransition: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			n := v1.Node{Status: v1.NodeStatus{Conditions: tt.conditions}}
+			changed := node.SetCondition(&n, tt.condition)
+			assert.Equal(t, tt.expectedChanged, changed)
+			assert.Len(t, n.Status.Conditions, tt.expectedConditions)
+
+			c, ok := node.GetCondition(n, tt.condition.Type)
+			assert.True(t, ok)
+			assert.Equal(t, tt.condition.Reason, c.Reason)
+			assert.Equal(t, tt.condition.Status, c.Status)
+			assert.Equal(t, tt.expectedTransition, !c.LastTransitionTime.Equal(&past))
+		})
+	}
+}